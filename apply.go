@@ -0,0 +1,107 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	applyCmd = &cobra.Command{
+		Use:   "apply <plan-file>",
+		Short: "Execute a plan created with `plan --out`.",
+		Long:  "Execute exactly the actions of a plan created with `plan --out`. If the job or the units on the stack have changed since the plan was created, nothing is changed.",
+		Run:   applyRun,
+	}
+	applyFlags struct {
+		fg.Flags
+	}
+)
+
+func init() {
+	initDeploymentFlags(applyCmd.Flags(), &applyFlags.Flags)
+	initOutputFlags(applyCmd.Flags(), &applyFlags.Flags)
+}
+
+func applyRun(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		Exitf("Expected exactly 1 plan file\n")
+	}
+	plan, err := deployment.LoadPlan(args[0])
+	if err != nil {
+		Exitf("Cannot load plan: %v\n", err)
+	}
+	deploymentDefaults(cmd.Flags(), &applyFlags.Flags, nil)
+	runValidators(&applyFlags.Flags)
+
+	// Deploy exactly what was planned
+	if applyFlags.JobPath == "" {
+		applyFlags.JobPath = plan.JobPath
+	}
+	if applyFlags.ClusterPath == "" {
+		applyFlags.ClusterPath = plan.ClusterPath
+	}
+	if !cmd.Flags().Changed("groups") {
+		applyFlags.Groups = nil
+		for _, g := range plan.Groups {
+			applyFlags.Groups = append(applyFlags.Groups, string(g))
+		}
+	}
+	if !cmd.Flags().Changed("scaling-group") {
+		applyFlags.ScalingGroup = plan.ScalingGroup
+	}
+	applyFlags.Force = plan.Force
+
+	cluster, err := loadCluster(&applyFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&applyFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	delays := deployment.DeploymentDelays{
+		StopDelay:    applyFlags.StopDelay,
+		DestroyDelay: applyFlags.DestroyDelay,
+		SliceDelay:   applyFlags.SliceDelay,
+	}
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&applyFlags.Flags),
+		deployment.ScalingGroupSelection(applyFlags.ScalingGroup),
+		applyFlags.Force,
+		true,
+		globalFlags.verbose,
+		delays,
+		healthCheckConfig(&applyFlags.Flags),
+		rolloutOptions(cmd.Flags(), &applyFlags.Flags, *cluster),
+		renderCtx)
+	assert(err)
+	d.Events = eventSink(&applyFlags.Flags)
+
+	if err := d.Apply(rootCtx, plan); err != nil {
+		if deployment.IsPlanDrift(err) {
+			Exitf("Cannot apply plan: %v\nCreate a new plan with `j2 plan --out`.\n", err)
+		}
+		Exitf("Cannot apply plan: %v\n", err)
+	}
+}
@@ -86,7 +86,7 @@ func configureClusterRun(cmd *cobra.Command, args []string) {
 		Exitf("Cannot load scheduler: %v\n", err)
 	}
 
-	if err := scheduler.ConfigureCluster(&clusterConfig{}); err != nil {
+	if err := scheduler.ConfigureCluster(rootCtx, &clusterConfig{}); err != nil {
 		Exitf("Failed to configure cliuster: %#v\n", err)
 	}
 }
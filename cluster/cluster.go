@@ -54,6 +54,22 @@ type Cluster struct {
 	Network string `mapstructure:"network,omitempty"`
 
 	DefaultOptions flags.Options `mapstructure:"default-options,omitempty"`
+
+	// Resources of tasks that do not specify their own resources
+	DefaultResources *Resources `mapstructure:"-"`
+
+	// If set, job & cluster files are parsed in strict mode, reporting all unknown keys
+	// (with position and suggestion) instead of failing on the first one.
+	Strict bool `mapstructure:"strict,omitempty"`
+
+	// If set, a failed deployment restores the previous version of all updated scaling groups.
+	RollbackOnFailure bool `mapstructure:"rollback-on-failure,omitempty"`
+
+	// Names of jobs that cannot be destroyed without an explicit override
+	ProtectedJobs []string `mapstructure:"protected-jobs,omitempty"`
+
+	// Webhooks that receive the lifecycle events of deployments on this cluster
+	Notify Notify `mapstructure:"-"`
 }
 
 // New returns a new cluster for testing purposes.
@@ -92,9 +108,22 @@ func (c Cluster) validate() error {
 	if err := c.KubernetesOptions.validate(); err != nil {
 		return maskAny(err)
 	}
+	if c.DefaultResources != nil {
+		if err := c.DefaultResources.Validate(); err != nil {
+			return maskAny(err)
+		}
+	}
+	if err := c.Notify.Validate(); err != nil {
+		return maskAny(err)
+	}
 	return nil
 }
 
+// IsProtectedJob returns true if the job with given name is listed in the protected jobs of the cluster.
+func (c Cluster) IsProtectedJob(name string) bool {
+	return containsString(c.ProtectedJobs, name)
+}
+
 func (c *Cluster) setDefaults() {
 	if c.Orchestrator == "" {
 		c.Orchestrator = os.Getenv("PULCY_ORCHESTRATOR")
@@ -0,0 +1,34 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+// FormatRules specifies the canonical layout of cluster files.
+var FormatRules = hclutil.FormatRules{
+	Root: "cluster",
+	KeyOrder: map[string][]string{
+		"cluster":           []string{"stack", "domain", "tunnel", "instance-count", "orchestrator", "docker", "fleet", "kubernetes", "network", "default-options", "default-resources", "strict", "rollback-on-failure", "protected-jobs", "notify"},
+		"docker":            []string{"log-args", "env-file"},
+		"fleet":             []string{"after", "wants", "requires", "global-instance-constraints"},
+		"kubernetes":        []string{"kubeconfig", "context", "registry-secrets", "global-instance-constraints", "domain"},
+		"default-resources": ResourcesKeyOrder,
+		"notify":            NotifyKeyOrder["notify"],
+		"webhook":           NotifyKeyOrder["webhook"],
+	},
+	SortedBlocks: []string{"default-options"},
+}
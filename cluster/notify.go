@@ -0,0 +1,222 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+const (
+	NotifyEventStart   = "start"   // A deployment starts
+	NotifyEventStep    = "step"    // A step (scaling group) of a deployment has completed
+	NotifyEventFailure = "failure" // A deployment has failed
+	NotifyEventFinish  = "finish"  // A deployment has completed successfully
+
+	defaultWebhookRetries    = 3
+	defaultWebhookRetryDelay = 2 * time.Second
+)
+
+var (
+	// WebhookTemplateFuncs are the functions that can be used in webhook templates.
+	WebhookTemplateFuncs = template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"join": strings.Join,
+	}
+
+	notifyBlockKeys = []string{"webhook"}
+	notifyEvents    = []string{NotifyEventStart, NotifyEventStep, NotifyEventFailure, NotifyEventFinish}
+)
+
+// Notify specifies where the lifecycle events of deployments are announced.
+type Notify struct {
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+}
+
+// Webhook is an HTTP endpoint that deployment events are posted to.
+type Webhook struct {
+	Name        string   `json:"name" mapstructure:"-"`
+	URL         string   `json:"url,omitempty" mapstructure:"url,omitempty"`
+	Events      []string `json:"events,omitempty" mapstructure:"events,omitempty"`             // Events that are posted (all if empty)
+	Template    string   `json:"template,omitempty" mapstructure:"template,omitempty"`         // Go template of the payload (JSON of the event if empty)
+	ContentType string   `json:"content-type,omitempty" mapstructure:"content-type,omitempty"` // Content type of the payload (defaults to application/json)
+	Secret      string   `json:"-" mapstructure:"secret,omitempty"`                            // If set, the payload is signed with a HMAC-SHA256 using this secret
+	Retries     *int     `json:"retries,omitempty" mapstructure:"retries,omitempty"`           // Number of retries after a failed delivery
+	RetryDelay  string   `json:"retry-delay,omitempty" mapstructure:"retry-delay,omitempty"`   // Time to wait before the first retry (doubled for every next retry)
+	Disabled    bool     `json:"disabled,omitempty" mapstructure:"disabled,omitempty"`         // Used in a job to turn off a webhook of the cluster
+}
+
+// Merge returns a copy of the given notify options, in which the webhooks of the given override
+// replace the webhooks with the same name. Other webhooks of the override are added.
+func (n Notify) Merge(override Notify) Notify {
+	result := Notify{}
+	replaced := make(map[string]bool)
+	for _, w := range n.Webhooks {
+		for _, o := range override.Webhooks {
+			if o.Name == w.Name {
+				w = o
+				replaced[o.Name] = true
+				break
+			}
+		}
+		result.Webhooks = append(result.Webhooks, w)
+	}
+	for _, o := range override.Webhooks {
+		if !replaced[o.Name] {
+			result.Webhooks = append(result.Webhooks, o)
+		}
+	}
+	return result
+}
+
+// Validate checks the values of the given notify options.
+func (n Notify) Validate() error {
+	names := make(map[string]bool)
+	for _, w := range n.Webhooks {
+		if names[w.Name] {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "duplicate webhook '%s'", w.Name))
+		}
+		names[w.Name] = true
+		if err := w.Validate(); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the values of the given webhook.
+func (w Webhook) Validate() error {
+	if w.Disabled {
+		return nil
+	}
+	if w.URL == "" {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "webhook '%s' has no url", w.Name))
+	}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "webhook '%s' has an invalid url '%s'", w.Name, w.URL))
+	}
+	for _, e := range w.Events {
+		if !containsString(notifyEvents, e) {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "webhook '%s' has an unknown event '%s'", w.Name, e))
+		}
+	}
+	if w.Template != "" {
+		if _, err := template.New(w.Name).Funcs(WebhookTemplateFuncs).Parse(w.Template); err != nil {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "webhook '%s' has an invalid template: %v", w.Name, err))
+		}
+	}
+	if w.Retries != nil && *w.Retries < 0 {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "webhook '%s' has a negative number of retries", w.Name))
+	}
+	if w.RetryDelay != "" {
+		if _, err := time.ParseDuration(w.RetryDelay); err != nil {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "webhook '%s' has an invalid retry-delay '%s'", w.Name, w.RetryDelay))
+		}
+	}
+	return nil
+}
+
+// Wants returns true if the given event must be posted to this webhook.
+func (w Webhook) Wants(event string) bool {
+	if w.Disabled {
+		return false
+	}
+	return len(w.Events) == 0 || containsString(w.Events, event)
+}
+
+// RetryCount returns the number of retries after a failed delivery.
+func (w Webhook) RetryCount() int {
+	if w.Retries == nil {
+		return defaultWebhookRetries
+	}
+	return *w.Retries
+}
+
+// RetryDelayDuration returns the time to wait before the first retry.
+func (w Webhook) RetryDelayDuration() time.Duration {
+	if d, err := time.ParseDuration(w.RetryDelay); err == nil {
+		return d
+	}
+	return defaultWebhookRetryDelay
+}
+
+// ParseNotify parses the notify objects in the given list.
+// Only 1 notify object is allowed.
+func ParseNotify(list *ast.ObjectList, owner string) (Notify, error) {
+	if len(list.Items) > 1 {
+		return Notify{}, maskAny(errgo.WithCausef(nil, ValidationError, "cannot have more than 1 notify object in %s", owner))
+	}
+	obj, ok := list.Items[0].Val.(*ast.ObjectType)
+	if !ok {
+		return Notify{}, maskAny(errgo.WithCausef(nil, ValidationError, "notify of %s is not an object", owner))
+	}
+	// Check that there is nothing but webhooks
+	if err := hclutil.Decode(obj, notifyBlockKeys, nil, &struct{}{}); err != nil {
+		return Notify{}, maskAny(err)
+	}
+	n := Notify{}
+	for _, item := range obj.List.Filter("webhook").Items {
+		if len(item.Keys) != 1 {
+			return Notify{}, maskAny(errgo.WithCausef(nil, ValidationError, "webhook of %s must have a name", owner))
+		}
+		wobj, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			return Notify{}, maskAny(errgo.WithCausef(nil, ValidationError, "webhook of %s is not an object", owner))
+		}
+		w := Webhook{Name: item.Keys[0].Token.Value().(string)}
+		if err := hclutil.Decode(wobj, nil, nil, &w); err != nil {
+			return Notify{}, maskAny(errgo.Notef(err, "webhook '%s' of %s", w.Name, owner))
+		}
+		n.Webhooks = append(n.Webhooks, w)
+	}
+	return n, nil
+}
+
+// UnknownNotifyKeys returns all unknown keys in the given notify object.
+func UnknownNotifyKeys(obj *ast.ObjectType) []hclutil.UnknownKey {
+	result := hclutil.UnknownKeys(obj, "notify", notifyBlockKeys, struct{}{})
+	for _, item := range obj.List.Filter("webhook").Items {
+		if wobj, ok := item.Val.(*ast.ObjectType); ok {
+			result = append(result, hclutil.UnknownKeys(wobj, "webhook", nil, Webhook{})...)
+		}
+	}
+	return result
+}
+
+// NotifyKeyOrder holds the canonical order of the keys of notify objects, used to format files.
+var NotifyKeyOrder = map[string][]string{
+	"notify":  notifyBlockKeys,
+	"webhook": []string{"url", "events", "template", "content-type", "secret", "retries", "retry-delay", "disabled"},
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
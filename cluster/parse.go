@@ -25,8 +25,26 @@ import (
 	"github.com/pulcy/j2/pkg/hclutil"
 )
 
-// ParseClusterFromFile reads a cluster from file
-func ParseClusterFromFile(path string) (*Cluster, error) {
+var (
+	// Keys that are parsed separately (not by hclutil.Decode)
+	clusterBlockKeys = []string{
+		"default-options",
+		"default-resources",
+		"docker",
+		"fleet",
+		"kubernetes",
+		"notify",
+		"quark",
+	}
+	dockerBlockKeys     = []string{"log-args"}
+	fleetBlockKeys      = []string{"after", "wants", "requires", "global-instance-constraints"}
+	kubernetesBlockKeys = []string{"global-instance-constraints"}
+)
+
+// ParseClusterFromFile reads a cluster from file.
+// If strict is set, or the cluster file itself contains `strict = true`,
+// all unknown keys are reported (with position) instead of only the first.
+func ParseClusterFromFile(path string, strict bool) (*Cluster, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, maskAny(err)
@@ -46,12 +64,25 @@ func ParseClusterFromFile(path string) (*Cluster, error) {
 		return nil, errgo.New("'cluster' stanza not found")
 	}
 
+	// Check for unknown keys
+	if strict || isStrict(matches) {
+		if keys := unknownKeys(matches); len(keys) > 0 {
+			for i := range keys {
+				keys[i].File = path
+			}
+			return nil, maskAny(&hclutil.UnknownKeysError{Keys: keys})
+		}
+	}
+
 	// Parse hcl into Cluster
 	cluster := &Cluster{}
 	if err := cluster.parse(matches); err != nil {
 		return nil, maskAny(err)
 	}
 	cluster.setDefaults()
+	if strict {
+		cluster.Strict = true
+	}
 
 	// Validate the cluster
 	if err := cluster.validate(); err != nil {
@@ -72,14 +103,7 @@ func (c *Cluster) parse(list *ast.ObjectList) error {
 	obj := list.Items[0]
 
 	// Decode the object
-	excludeList := []string{
-		"default-options",
-		"docker",
-		"fleet",
-		"kubernetes",
-		"quark",
-	}
-	if err := hclutil.Decode(obj.Val, excludeList, nil, c); err != nil {
+	if err := hclutil.Decode(obj.Val, clusterBlockKeys, nil, c); err != nil {
 		return maskAny(err)
 	}
 	c.Stack = obj.Keys[0].Token.Value().(string)
@@ -131,6 +155,33 @@ func (c *Cluster) parse(list *ast.ObjectList) error {
 		}
 	}
 
+	// Parse notify
+	if o := listVal.Filter("notify"); len(o.Items) > 0 {
+		n, err := ParseNotify(o, fmt.Sprintf("cluster '%s'", c.Stack))
+		if err != nil {
+			return maskAny(err)
+		}
+		c.Notify = n
+	}
+
+	// Parse default-resources
+	if o := listVal.Filter("default-resources"); len(o.Items) > 0 {
+		if len(o.Items) > 1 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "cannot have more than 1 default-resources object in cluster '%s'", c.Stack))
+		}
+		for _, o := range o.Elem().Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				r := Resources{}
+				if err := hclutil.Decode(obj, nil, nil, &r); err != nil {
+					return maskAny(err)
+				}
+				c.DefaultResources = &r
+			} else {
+				return maskAny(errgo.WithCausef(nil, ValidationError, "default-resources of cluster '%s' is not an object", c.Stack))
+			}
+		}
+	}
+
 	// Parse default-options
 	if o := listVal.Filter("default-options"); len(o.Items) > 0 {
 		for _, o := range o.Elem().Items {
@@ -151,10 +202,7 @@ func (c *Cluster) parse(list *ast.ObjectList) error {
 // parse a DockerOptions
 func (options *DockerOptions) parse(obj *ast.ObjectType, c Cluster) error {
 	// Parse the object
-	excludeList := []string{
-		"log-args",
-	}
-	if err := hclutil.Decode(obj, excludeList, nil, options); err != nil {
+	if err := hclutil.Decode(obj, dockerBlockKeys, nil, options); err != nil {
 		return maskAny(err)
 	}
 	// Parse log-args
@@ -172,13 +220,7 @@ func (options *DockerOptions) parse(obj *ast.ObjectType, c Cluster) error {
 // parse a FleetOptions
 func (options *FleetOptions) parse(obj *ast.ObjectType, c Cluster) error {
 	// Parse the object
-	excludeList := []string{
-		"after",
-		"wants",
-		"requires",
-		"global-instance-constraints",
-	}
-	if err := hclutil.Decode(obj, excludeList, nil, options); err != nil {
+	if err := hclutil.Decode(obj, fleetBlockKeys, nil, options); err != nil {
 		return maskAny(err)
 	}
 	// Parse after
@@ -221,10 +263,7 @@ func (options *FleetOptions) parse(obj *ast.ObjectType, c Cluster) error {
 // parse a KubernetesOptions
 func (options *KubernetesOptions) parse(obj *ast.ObjectType, c Cluster) error {
 	// Parse the object
-	excludeList := []string{
-		"global-instance-constraints",
-	}
-	if err := hclutil.Decode(obj, excludeList, nil, options); err != nil {
+	if err := hclutil.Decode(obj, kubernetesBlockKeys, nil, options); err != nil {
 		return maskAny(err)
 	}
 	// Parse global-instance-constraints
@@ -0,0 +1,170 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/juju/errgo"
+)
+
+const (
+	// minMemoryLimit is the smallest memory limit accepted by docker.
+	minMemoryLimit = 6 * 1024 * 1024
+)
+
+var (
+	cpuPattern    = regexp.MustCompile(`^([0-9]+)m$|^([0-9]+)(\.[0-9]{1,3})?$`)
+	memoryPattern = regexp.MustCompile(`^([0-9]+)(k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+	memoryFactors = map[string]int64{
+		"":   1,
+		"k":  1000,
+		"M":  1000 * 1000,
+		"G":  1000 * 1000 * 1000,
+		"T":  1000 * 1000 * 1000 * 1000,
+		"P":  1000 * 1000 * 1000 * 1000 * 1000,
+		"E":  1000 * 1000 * 1000 * 1000 * 1000 * 1000,
+		"Ki": 1 << 10,
+		"Mi": 1 << 20,
+		"Gi": 1 << 30,
+		"Ti": 1 << 40,
+		"Pi": 1 << 50,
+		"Ei": 1 << 60,
+	}
+)
+
+// Resources specifies the compute resources of a task.
+// Quantities use the kubernetes notation, e.g. cpu "500m" or "0.5" and memory "512M" or "512Mi".
+type Resources struct {
+	CPU         string `json:"cpu,omitempty" mapstructure:"cpu,omitempty"`                   // Requested CPU
+	CPULimit    string `json:"cpu-limit,omitempty" mapstructure:"cpu-limit,omitempty"`       // Maximum CPU
+	Memory      string `json:"memory,omitempty" mapstructure:"memory,omitempty"`             // Requested memory
+	MemoryLimit string `json:"memory-limit,omitempty" mapstructure:"memory-limit,omitempty"` // Maximum memory
+}
+
+// ResourcesKeyOrder specifies the canonical order of the keys of a resources block.
+var ResourcesKeyOrder = []string{"cpu", "cpu-limit", "memory", "memory-limit"}
+
+// Validate checks the values of the given resources.
+// If ok, return nil, otherwise returns an error.
+func (r Resources) Validate() error {
+	cpu, err := r.CPUMillis()
+	if err != nil {
+		return maskAny(err)
+	}
+	cpuLimit, err := r.CPULimitMillis()
+	if err != nil {
+		return maskAny(err)
+	}
+	memory, err := r.MemoryBytes()
+	if err != nil {
+		return maskAny(err)
+	}
+	memoryLimit, err := r.MemoryLimitBytes()
+	if err != nil {
+		return maskAny(err)
+	}
+	if cpu > 0 && cpuLimit > 0 && cpuLimit < cpu {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "cpu-limit (%s) is less than cpu (%s)", r.CPULimit, r.CPU))
+	}
+	if memory > 0 && memoryLimit > 0 && memoryLimit < memory {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "memory-limit (%s) is less than memory (%s)", r.MemoryLimit, r.Memory))
+	}
+	if memoryLimit > 0 && memoryLimit < minMemoryLimit {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "memory-limit (%s) must be at least 6Mi", r.MemoryLimit))
+	}
+	return nil
+}
+
+// CPUMillis returns the requested CPU in millicores (0 if not set).
+func (r Resources) CPUMillis() (int64, error) {
+	return parseCPU("cpu", r.CPU)
+}
+
+// CPULimitMillis returns the CPU limit in millicores (0 if not set).
+func (r Resources) CPULimitMillis() (int64, error) {
+	return parseCPU("cpu-limit", r.CPULimit)
+}
+
+// MemoryBytes returns the requested memory in bytes (0 if not set).
+func (r Resources) MemoryBytes() (int64, error) {
+	return parseMemory("memory", r.Memory)
+}
+
+// MemoryLimitBytes returns the memory limit in bytes (0 if not set).
+func (r Resources) MemoryLimitBytes() (int64, error) {
+	return parseMemory("memory-limit", r.MemoryLimit)
+}
+
+// parseCPU parses the given CPU quantity into millicores.
+func parseCPU(key, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	m := cpuPattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "invalid %s '%s' (use e.g. 500m or 0.5)", key, value))
+	}
+	var millis int64
+	if m[1] != "" {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s '%s' is too large", key, value))
+		}
+		millis = n
+	} else {
+		cores, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil || cores >= (1<<63-1)/1000 {
+			return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s '%s' is too large", key, value))
+		}
+		millis = cores * 1000
+		if fraction := m[3]; fraction != "" {
+			digits := fraction[1:]
+			for len(digits) < 3 {
+				digits += "0"
+			}
+			f, _ := strconv.ParseInt(digits, 10, 64)
+			millis += f
+		}
+	}
+	if millis == 0 {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s must be more than 0", key))
+	}
+	return millis, nil
+}
+
+// parseMemory parses the given memory quantity into bytes.
+func parseMemory(key, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	m := memoryPattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "invalid %s '%s' (use e.g. 512M or 512Mi)", key, value))
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "invalid %s '%s': %v", key, value, err))
+	}
+	factor := memoryFactors[m[2]]
+	if n > (1<<63-1)/factor {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s '%s' is too large", key, value))
+	}
+	if n == 0 {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s must be more than 0", key))
+	}
+	return n * factor, nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster_test
+
+import (
+	"testing"
+
+	"github.com/pulcy/j2/cluster"
+)
+
+func TestResourcesCPUMillis(t *testing.T) {
+	tests := []struct {
+		Input         string
+		ErrorExpected bool
+		Expected      int64
+	}{
+		{Input: "", Expected: 0},
+		{Input: "500m", Expected: 500},
+		{Input: "1m", Expected: 1},
+		{Input: "2", Expected: 2000},
+		{Input: "0.5", Expected: 500},
+		{Input: "1.25", Expected: 1250},
+		{Input: "0.001", Expected: 1},
+		{Input: "9223372036854775807m", Expected: 9223372036854775807},
+		{Input: "0", ErrorExpected: true},
+		{Input: "0m", ErrorExpected: true},
+		{Input: "0.0", ErrorExpected: true},
+		{Input: "0.0001", ErrorExpected: true},
+		{Input: "9223372036854775808m", ErrorExpected: true},
+		{Input: "9223372036854775", ErrorExpected: true},
+		{Input: "99999999999999999999", ErrorExpected: true},
+		{Input: "-1", ErrorExpected: true},
+		{Input: "1.5m", ErrorExpected: true},
+		{Input: "1k", ErrorExpected: true},
+		{Input: "abc", ErrorExpected: true},
+	}
+	for _, test := range tests {
+		millis, err := cluster.Resources{CPU: test.Input}.CPUMillis()
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in '%s', got none", test.Input)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Unexpected error in '%s': %#v", test.Input, err)
+			} else if millis != test.Expected {
+				t.Errorf("Unexpected result for '%s'. Expected %d, got %d", test.Input, test.Expected, millis)
+			}
+		}
+	}
+}
+
+func TestResourcesMemoryBytes(t *testing.T) {
+	tests := []struct {
+		Input         string
+		ErrorExpected bool
+		Expected      int64
+	}{
+		{Input: "", Expected: 0},
+		{Input: "1024", Expected: 1024},
+		{Input: "1k", Expected: 1000},
+		{Input: "512M", Expected: 512 * 1000 * 1000},
+		{Input: "512Mi", Expected: 512 * 1024 * 1024},
+		{Input: "2Gi", Expected: 2 * 1024 * 1024 * 1024},
+		{Input: "1Ti", Expected: 1 << 40},
+		{Input: "7Ei", Expected: 7 << 60},
+		{Input: "9E", Expected: 9 * 1000 * 1000 * 1000 * 1000 * 1000 * 1000},
+		{Input: "0", ErrorExpected: true},
+		{Input: "0Mi", ErrorExpected: true},
+		{Input: "8Ei", ErrorExpected: true},
+		{Input: "10E", ErrorExpected: true},
+		{Input: "9223372036854775807k", ErrorExpected: true},
+		{Input: "99999999999999999999", ErrorExpected: true},
+		{Input: "-1Mi", ErrorExpected: true},
+		{Input: "1.5Gi", ErrorExpected: true},
+		{Input: "1MB", ErrorExpected: true},
+		{Input: "1m", ErrorExpected: true},
+	}
+	for _, test := range tests {
+		bytes, err := cluster.Resources{Memory: test.Input}.MemoryBytes()
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in '%s', got none", test.Input)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Unexpected error in '%s': %#v", test.Input, err)
+			} else if bytes != test.Expected {
+				t.Errorf("Unexpected result for '%s'. Expected %d, got %d", test.Input, test.Expected, bytes)
+			}
+		}
+	}
+}
+
+func TestResourcesValidate(t *testing.T) {
+	tests := []struct {
+		Input         cluster.Resources
+		ErrorExpected bool
+	}{
+		{Input: cluster.Resources{}},
+		{Input: cluster.Resources{CPU: "250m", CPULimit: "0.5", Memory: "64Mi", MemoryLimit: "128Mi"}},
+		{Input: cluster.Resources{CPU: "1", CPULimit: "1000m"}},
+		{Input: cluster.Resources{MemoryLimit: "6Mi"}},
+		{Input: cluster.Resources{CPU: "1", CPULimit: "500m"}, ErrorExpected: true},
+		{Input: cluster.Resources{Memory: "128Mi", MemoryLimit: "64Mi"}, ErrorExpected: true},
+		{Input: cluster.Resources{MemoryLimit: "5Mi"}, ErrorExpected: true},
+		{Input: cluster.Resources{CPULimit: "0"}, ErrorExpected: true},
+		{Input: cluster.Resources{Memory: "lots"}, ErrorExpected: true},
+	}
+	for _, test := range tests {
+		err := test.Input.Validate()
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in %#v, got none", test.Input)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error in %#v: %#v", test.Input, err)
+		}
+	}
+}
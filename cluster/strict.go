@@ -0,0 +1,86 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+// isStrict returns true if the cluster in the given list contains `strict = true`.
+func isStrict(list *ast.ObjectList) bool {
+	for _, item := range list.Items {
+		obj, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			continue
+		}
+		for _, o := range obj.List.Filter("strict").Items {
+			if lit, ok := o.Val.(*ast.LiteralType); ok && lit.Token.Type == token.BOOL {
+				if v, _ := lit.Token.Value().(bool); v {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// unknownKeys returns all keys in the cluster in the given list that are not known.
+// The `quark` block is no longer used, so it is reported as well.
+func unknownKeys(list *ast.ObjectList) []hclutil.UnknownKey {
+	var result []hclutil.UnknownKey
+	known := make([]string, 0, len(clusterBlockKeys))
+	for _, k := range clusterBlockKeys {
+		if k != "quark" {
+			known = append(known, k)
+		}
+	}
+	for _, item := range list.Items {
+		obj, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			continue
+		}
+		result = append(result, hclutil.UnknownKeys(obj, "cluster", known, Cluster{})...)
+		for _, o := range obj.List.Filter("docker").Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				result = append(result, hclutil.UnknownKeys(obj, "docker", dockerBlockKeys, DockerOptions{})...)
+			}
+		}
+		for _, o := range obj.List.Filter("fleet").Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				result = append(result, hclutil.UnknownKeys(obj, "fleet", fleetBlockKeys, FleetOptions{})...)
+			}
+		}
+		for _, o := range obj.List.Filter("kubernetes").Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				result = append(result, hclutil.UnknownKeys(obj, "kubernetes", kubernetesBlockKeys, KubernetesOptions{})...)
+			}
+		}
+		for _, o := range obj.List.Filter("default-resources").Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				result = append(result, hclutil.UnknownKeys(obj, "default-resources", nil, Resources{})...)
+			}
+		}
+		for _, o := range obj.List.Filter("notify").Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				result = append(result, UnknownNotifyKeys(obj)...)
+			}
+		}
+	}
+	hclutil.SortUnknownKeys(result)
+	return result
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/deployment"
+)
+
+var (
+	// rootCtx is the context in which all commands run.
+	rootCtx = context.Background()
+	// cancelRoot cancels rootCtx.
+	cancelRoot = func() {}
+)
+
+// setupContext creates the context in which all commands run.
+// If a global timeout is configured, the context is canceled once it has passed.
+// The first interrupt signal wakes up all interruptible sleeps of a deployment.
+// If there are none, the user is asked to press Ctrl-C again. A second interrupt
+// signal (within 5 seconds) cancels the context, any further signal terminates the process.
+func setupContext() {
+	ctx := context.Background()
+	if globalFlags.timeout > 0 {
+		ctx, cancelRoot = context.WithTimeout(ctx, globalFlags.timeout)
+	} else {
+		ctx, cancelRoot = context.WithCancel(ctx)
+	}
+	interrupts := deployment.NewInterrupts()
+	rootCtx = deployment.WithInterrupts(ctx, interrupts)
+
+	go func() {
+		c := make(chan os.Signal, 10)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+
+		stopCounter := 0
+		for {
+			select {
+			case <-c:
+				if rootCtx.Err() != nil {
+					os.Exit(1)
+				}
+				if interrupts.Interrupt() {
+					// Woke up a sleeper
+					stopCounter = 0
+				} else if stopCounter >= 1 {
+					fmt.Print("Stopping...\n")
+					cancelRoot()
+				} else {
+					fmt.Print("Press Ctrl-C again to stop\n")
+					stopCounter++
+				}
+			case <-time.After(time.Second * 5):
+				stopCounter = 0
+			}
+		}
+	}()
+}
@@ -28,8 +28,19 @@ const (
 	defaultDryRun               = false
 	defaultScalingGroup         = uint(0) // all
 	defaultLocal                = false
+	defaultStrict               = false
+	defaultHealthCheck          = "none"
+	defaultHealthTimeout        = 5 * time.Minute
+	defaultStepTimeout          = time.Duration(0) // no timeout
+	defaultTimeout              = time.Duration(0) // no timeout
+	defaultRollbackOnFailure    = false
+	defaultCanary               = uint(0) // job settings
+	defaultParallelGroups       = uint(1)
+	defaultStealLock            = false
+	defaultResume               = false
 	defaultGithubTokenPath      = "~/.pulcy/github-token"
 	defaultLogLevel             = "info"
+	defaultOutputFormat         = "text"
 )
 
 var (
@@ -25,6 +25,7 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/deployment"
 	"github.com/pulcy/j2/extpoints"
 	fg "github.com/pulcy/j2/flags"
 	"github.com/pulcy/j2/jobs"
@@ -43,8 +44,16 @@ func initDeploymentFlags(fs *pflag.FlagSet, f *fg.Flags) {
 	fs.BoolVarP(&f.Local, "local", "l", defaultLocal, "User local vagrant test cluster")
 	fs.DurationVar(&f.StopDelay, "stop-delay", defaultStopDelay, "Time between stop and destroy")
 	fs.DurationVar(&f.DestroyDelay, "destroy-delay", defaultDestroyDelay, "Time between destroy and re-create")
-	fs.DurationVar(&f.SliceDelay, "slice-delay", defaultSliceDelay, "Time between update of scaling slices")
+	fs.DurationVar(&f.SliceDelay, "slice-delay", defaultSliceDelay, "Time between update of scaling slices (when health checks are disabled)")
+	fs.StringVar(&f.HealthCheck, "health-check", defaultHealthCheck, "How to wait for healthy units before updating the next scaling slice (state|http|none)")
+	fs.DurationVar(&f.HealthTimeout, "health-timeout", defaultHealthTimeout, "Maximum time to wait for the units of a scaling slice to become healthy")
+	fs.DurationVar(&f.StepTimeout, "step-timeout", defaultStepTimeout, "Maximum duration of a single deployment step, after which the step is considered failed (0 means no timeout)")
+	fs.UintVar(&f.Canary, "canary", defaultCanary, "Number of scaling slices to update before waiting for promotion (job override)")
+	fs.UintVar(&f.ParallelGroups, "parallel-groups", defaultParallelGroups, "Maximum number of independent task groups to update at the same time (progress is not recorded for --resume)")
+	fs.BoolVar(&f.StealLock, "steal-lock", defaultStealLock, "Take over the deployment lock of the job when it is held by someone else")
+	fs.BoolVar(&f.RollbackOnFailure, "rollback-on-failure", defaultRollbackOnFailure, "Restore the previous version of all updated scaling slices when a deployment step fails (cluster override)")
 	fs.VarP(&f.Options, "option", "o", "Set an option (key=value)")
+	fs.BoolVar(&f.Strict, "strict", defaultStrict, "Report all unknown keys in job & cluster files (cluster override)")
 
 	f.VaultCACert = os.Getenv("VAULT_CACERT")
 	f.VaultCAPath = os.Getenv("VAULT_CAPATH")
@@ -84,6 +93,53 @@ func deploymentDefaults(fs *pflag.FlagSet, f *fg.Flags, args []string) {
 	}
 }
 
+// initOutputFlags adds the flag that selects how the progress of a command is reported.
+func initOutputFlags(fs *pflag.FlagSet, f *fg.Flags) {
+	fs.StringVar(&f.Output, "output", defaultOutputFormat, "Output format of the progress (text|json)")
+}
+
+// eventSink returns the sink that receives the progress of a command, as selected by the output flag.
+// It returns nil for text output, which shows the progress in the terminal.
+// With json output, every event is written to stdout as a single line of JSON
+// and errors are written to stderr.
+func eventSink(f *fg.Flags) deployment.EventSink {
+	switch f.Output {
+	case "text":
+		return nil
+	case "json":
+		exitOutput = os.Stderr
+		return deployment.NewJSONEventSink(os.Stdout)
+	default:
+		Exitf("--output invalid: must be text or json\n")
+		return nil
+	}
+}
+
+// healthCheckConfig returns the health check configuration from the given flags.
+func healthCheckConfig(f *fg.Flags) deployment.HealthCheckConfig {
+	return deployment.HealthCheckConfig{
+		HealthCheck:   deployment.HealthCheckMode(f.HealthCheck),
+		HealthTimeout: f.HealthTimeout,
+	}
+}
+
+// rolloutOptions returns the rollout options from the given flags, using the cluster
+// settings for flags that are not set.
+func rolloutOptions(fs *pflag.FlagSet, f *fg.Flags, cluster cluster.Cluster) deployment.RolloutOptions {
+	options := deployment.RolloutOptions{
+		RollbackOnFailure: cluster.RollbackOnFailure,
+		Canary:            f.Canary,
+		ParallelGroups:    f.ParallelGroups,
+		StealLock:         f.StealLock,
+		Resume:            f.Resume,
+		StepTimeout:       f.StepTimeout,
+	}
+	if fs.Changed("rollback-on-failure") {
+		options.RollbackOnFailure = f.RollbackOnFailure
+	}
+	return options
+}
+
 // Gets the list of group names to operate on based on the deployment flags.
 func groups(f *fg.Flags) []jobs.TaskGroupName {
 	names := []jobs.TaskGroupName{}
@@ -114,6 +170,27 @@ func loadJob(f *fg.Flags, cluster cluster.Cluster, orchestrator extpoints.Orches
 	return job, nil
 }
 
+// loadJobOffline loads the a job from the given flags, without accessing any network resources.
+func loadJobOffline(f *fg.Flags, cluster cluster.Cluster, orchestrator extpoints.Orchestrator, stubSecrets bool) (*jobs.Job, error) {
+	if f.JobPath == "" {
+		return nil, maskAny(errgo.New("--job missing"))
+	}
+	path, err := resolvePath(f.JobPath, "config", ".hcl")
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	provider, err := orchestrator.RenderProvider()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	renderer := provider.CreateRenderer(cluster)
+	job, err := jobs.ParseJobFromFileOffline(path, cluster, renderer, f.Options, log, stubSecrets)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return job, nil
+}
+
 // loadCluster loads a cluster description from the given flags.
 func loadCluster(f *fg.Flags) (*cluster.Cluster, error) {
 	if f.ClusterPath == "" {
@@ -127,7 +204,7 @@ func loadCluster(f *fg.Flags) (*cluster.Cluster, error) {
 	if err != nil {
 		return nil, maskAny(err)
 	}
-	cluster, err := cluster.ParseClusterFromFile(path)
+	cluster, err := cluster.ParseClusterFromFile(path, f.Strict)
 	if err != nil {
 		return nil, maskAny(err)
 	}
@@ -0,0 +1,416 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+const (
+	// defaultSwitchTimeout is the maximum time to wait for the units of the deployed color
+	// to become healthy before switching frontends, when no health timeout is configured.
+	defaultSwitchTimeout = 5 * time.Minute
+)
+
+// blueGreenUnits holds the units of all selected task groups that use blue/green deployments.
+type blueGreenUnits struct {
+	colors    map[jobs.TaskGroupName]jobs.Color // Color in which every group is deployed
+	frontends scalingGroupUnits                 // Frontend units in the deployed color
+	deployed  []scheduler.Unit                  // Other units in the deployed color
+	retained  []scheduler.Unit                  // Units in the other color, kept until retired
+	replaced  []scheduler.Unit                  // Frontend units in the other color
+}
+
+// excluded returns all units that are not part of the deployment of any scaling group.
+func (bg blueGreenUnits) excluded() []scheduler.Unit {
+	return append(append(bg.frontends.Units(), bg.retained...), bg.replaced...)
+}
+
+// blueGreenGroups returns all selected task groups that use blue/green deployments.
+func (d *Deployment) blueGreenGroups() []*jobs.TaskGroup {
+	var result []*jobs.TaskGroup
+	for _, tg := range d.job.Groups {
+		if tg.BlueGreen && d.groupSelection.Includes(tg.Name) {
+			result = append(result, tg)
+		}
+	}
+	return result
+}
+
+// renderGroup generates the units of the given group in the given color, for all selected scaling groups.
+// If frontendsOnly is set, only the frontend units of the group are generated.
+func (d *Deployment) renderGroup(tg *jobs.TaskGroup, color jobs.Color, frontendsOnly bool) (scalingGroupUnits, error) {
+	current := tg.Color()
+	tg.SetColor(color)
+	defer tg.SetColor(current)
+
+	var result scalingGroupUnits
+	maxCount := d.job.MaxCount()
+	for scalingGroup := uint(1); scalingGroup <= maxCount; scalingGroup++ {
+		if !d.scalingGroupSelection.Includes(scalingGroup) {
+			continue
+		}
+		units, err := d.renderUnits(scalingGroup, []jobs.TaskGroupName{tg.Name}, frontendsOnly)
+		if err != nil {
+			return scalingGroupUnits{}, maskAny(err)
+		}
+		result.units = append(result.units, units...)
+	}
+	return result, nil
+}
+
+// activeColor returns the color of the given group that its frontends point to.
+// An empty color is returned when the group has not been deployed yet.
+func (d *Deployment) activeColor(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, tg *jobs.TaskGroup) (jobs.Color, error) {
+	isLoaded := containsPredicate(loadedJobUnits)
+	var current, registered, deployed []jobs.Color
+	for _, color := range []jobs.Color{jobs.ColorBlue, jobs.ColorGreen} {
+		frontends, err := d.renderGroup(tg, color, true)
+		if err != nil {
+			return "", maskAny(err)
+		}
+		if frontends.Len() > 0 && len(selectUnitNames(frontends.Units(), isLoaded)) == frontends.Len() {
+			registered = append(registered, color)
+			if !hasChangedUnits(ctx, s, frontends) {
+				current = append(current, color)
+			}
+		}
+		units, err := d.renderGroup(tg, color, false)
+		if err != nil {
+			return "", maskAny(err)
+		}
+		if len(selectUnitNames(units.Units(), isLoaded)) > 0 {
+			deployed = append(deployed, color)
+		}
+	}
+	switch {
+	case len(current) == 1:
+		return current[0], nil
+	case len(registered) == 1:
+		return registered[0], nil
+	case len(deployed) == 1:
+		return deployed[0], nil
+	case len(registered) == 0 && len(deployed) == 0:
+		return "", nil
+	default:
+		return "", maskAny(errgo.WithCausef(nil, BlueGreenError, "cannot determine which color of group %s serves its frontends", tg.Name))
+	}
+}
+
+// hasChangedUnits returns true if at least one of the given units is different on the cluster.
+func hasChangedUnits(ctx context.Context, s scheduler.Scheduler, units scalingGroupUnits) bool {
+	for _, u := range units.units {
+		if _, changed, err := s.HasChanged(ctx, u); err != nil || changed {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveColors decides in which color every selected blue/green group is deployed.
+// A group stays in its active color when none of its units (other than its frontends) have changed.
+// Otherwise it is deployed in the other color, next to the active color.
+func (d *Deployment) resolveColors(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, ui *stateUI) error {
+	d.blueGreen = blueGreenUnits{colors: make(map[jobs.TaskGroupName]jobs.Color)}
+	isLoaded := containsPredicate(loadedJobUnits)
+	for _, tg := range d.blueGreenGroups() {
+		ui.MessageSink <- fmt.Sprintf("Checking active color of %s", tg.Name)
+		active, err := d.activeColor(ctx, s, loadedJobUnits, tg)
+		if err != nil {
+			return maskAny(err)
+		}
+		color := jobs.ColorBlue
+		if active != "" {
+			color = active
+			units, err := d.renderGroup(tg, active, false)
+			if err != nil {
+				return maskAny(err)
+			}
+			for _, u := range units.units {
+				if !isLoaded(u) {
+					color = active.Other()
+					break
+				}
+				if _, modified := d.checkModified(ctx, u, s, ui); modified {
+					color = active.Other()
+					break
+				}
+			}
+		}
+		ui.Verbosef("Group '%s' is active in %s, deploying %s\n", tg.Name, active, color)
+		if err := d.setColor(tg, color); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// setColor sets the color in which the given blue/green group is deployed
+// and collects the units of both colors of the group.
+func (d *Deployment) setColor(tg *jobs.TaskGroup, color jobs.Color) error {
+	tg.SetColor(color)
+	d.blueGreen.colors[tg.Name] = color
+	deployed, err := d.renderGroup(tg, color, false)
+	if err != nil {
+		return maskAny(err)
+	}
+	retained, err := d.renderGroup(tg, color.Other(), false)
+	if err != nil {
+		return maskAny(err)
+	}
+	replaced, err := d.renderGroup(tg, color.Other(), true)
+	if err != nil {
+		return maskAny(err)
+	}
+	d.blueGreen.deployed = append(d.blueGreen.deployed, deployed.Units()...)
+	d.blueGreen.retained = append(d.blueGreen.retained, retained.Units()...)
+	d.blueGreen.replaced = append(d.blueGreen.replaced, replaced.Units()...)
+	return nil
+}
+
+// planFrontends decides which action is needed for every frontend unit of the blue/green groups.
+// Loaded frontend units of the other color are removed.
+func (d *Deployment) planFrontends(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, clusterHash func(scheduler.Unit) (string, error), ui *stateUI) ([]UnitPlan, error) {
+	var result []UnitPlan
+	isLoaded := containsPredicate(loadedJobUnits)
+	for _, u := range d.blueGreen.frontends.units {
+		up, err := d.planUnit(ctx, s, u, isLoaded(u), false, clusterHash, ui)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, up)
+	}
+	replaced := selectUnitNames(loadedJobUnits, containsPredicate(d.blueGreen.replaced))
+	for _, u := range selectUnitNames(replaced, notPredicate(containsPredicate(d.blueGreen.frontends.Units()))) {
+		up, err := removalPlan(u, clusterHash)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, up)
+	}
+	return result, nil
+}
+
+// switchTimeout returns the maximum time to wait for the units of the deployed color to become healthy
+// before switching frontends. Frontends are only switched to healthy units, even when health checks
+// are disabled, so the default is used when no (positive) health timeout is configured.
+func (d *Deployment) switchTimeout() time.Duration {
+	if d.HealthTimeout > 0 {
+		return d.HealthTimeout
+	}
+	return defaultSwitchTimeout
+}
+
+// switchFrontends points the frontends of all blue/green groups to the color in which they are deployed.
+// It waits until all units of that color are healthy, then starts the frontend units of that color
+// and finally removes the frontend units of the other color.
+// The units of the other color are left running until they are retired.
+func (d *Deployment) switchFrontends(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, frontends []UnitPlan, step int, ui *stateUI, confirm bool) error {
+	if err := d.waitUntilHealthy(ctx, s, d.blueGreen.deployed, d.switchTimeout(), ui); err != nil {
+		return maskAny(err)
+	}
+	ui.Clear()
+
+	if !d.force {
+		formattedChanges := formatChanges(frontends)
+		ui.HeaderSink <- fmt.Sprintf("Step %d: Switch frontends of %s on '%s'.\n%s\n", step, formatColors(d.blueGreen.colors), d.cluster.Stack, formattedChanges)
+		if confirm && !d.autoContinue {
+			if err := ui.Confirm("Are you sure you want to continue?"); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+
+	// Frontend units that cannot be updated in place are destroyed first
+	if ipu, ok := s.(scheduler.InPlaceUpdater); !ok || !ipu.UpdatesInPlace() {
+		modifiedUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(unitPlanNames(frontends, PlanActionUpdate)))
+		failedUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(unitPlanNames(frontends, PlanActionRestart)))
+		if len(modifiedUnitNames) > 0 || len(failedUnitNames) > 0 {
+			if err := d.destroyUnits(ctx, s, modifiedUnitNames, failedUnitNames, nil, ui); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+
+	// Register the frontends of the deployed color
+	unitsToLaunch := d.blueGreen.frontends.selectByNames(unitPlanNames(frontends, PlanActionUpdate, PlanActionRestart, PlanActionCreate))
+	if unitsToLaunch.Len() > 0 {
+		if err := launchUnits(ctx, s, unitsToLaunch, ui); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	// Remove the frontend units of the other color
+	obsoleteUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(unitPlanNames(frontends, PlanActionRemove)))
+	if len(obsoleteUnitNames) > 0 {
+		if err := d.destroyUnits(ctx, s, nil, nil, obsoleteUnitNames, ui); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// Switch points the frontends of all selected blue/green groups back to their other color.
+// The units of that color must not have been retired.
+func (d *Deployment) Switch(ctx context.Context) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	unlock, err := d.lockJob(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer unlock()
+
+	s, loadedJobUnits, err := d.loadJobUnits(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	isLoaded := containsPredicate(loadedJobUnits)
+	d.blueGreen = blueGreenUnits{colors: make(map[jobs.TaskGroupName]jobs.Color)}
+	for _, tg := range d.blueGreenGroups() {
+		active, err := d.activeColor(ctx, s, loadedJobUnits, tg)
+		if err != nil {
+			return maskAny(err)
+		}
+		if active == "" {
+			continue
+		}
+		color := active.Other()
+		units, err := d.renderGroup(tg, color, false)
+		if err != nil {
+			return maskAny(err)
+		}
+		if len(selectUnitNames(units.Units(), isLoaded)) == 0 {
+			return maskAny(errgo.WithCausef(nil, BlueGreenError, "group %s has no %s units to switch to", tg.Name, color))
+		}
+		if err := d.setColor(tg, color); err != nil {
+			return maskAny(err)
+		}
+	}
+	if err := d.generateScalingGroups(); err != nil {
+		return maskAny(err)
+	}
+
+	noClusterHash := func(scheduler.Unit) (string, error) { return "", nil }
+	frontends, err := d.planFrontends(ctx, s, loadedJobUnits, noClusterHash, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	ui.Clear()
+	if !hasUnitChanges(frontends) {
+		ui.MessageSink <- "No modifications needed."
+		return nil
+	}
+	err = d.switchFrontends(ctx, s, loadedJobUnits, frontends, 1, ui, true)
+	ui.Summary(planSummary(Plan{Frontends: frontends}), err)
+	if err != nil {
+		return maskAny(err)
+	}
+	ui.MessageSink <- "Done."
+	return nil
+}
+
+// Retire removes the units of all selected blue/green groups in the color that does not serve their frontends.
+// If a color is given, the units of that color are removed instead, as long as it is not known to
+// serve the frontends.
+func (d *Deployment) Retire(ctx context.Context, color jobs.Color) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	unlock, err := d.lockJob(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer unlock()
+
+	s, loadedJobUnits, err := d.loadJobUnits(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	var unitNames []scheduler.Unit
+	for _, tg := range d.blueGreenGroups() {
+		active, err := d.activeColor(ctx, s, loadedJobUnits, tg)
+		if err != nil && (color == "" || errgo.Cause(err) != BlueGreenError) {
+			return maskAny(err)
+		}
+		retiredColor := color
+		if retiredColor == "" {
+			if active == "" {
+				continue
+			}
+			retiredColor = active.Other()
+		} else if retiredColor == active {
+			return maskAny(errgo.WithCausef(nil, BlueGreenError, "color %s of group %s serves its frontends", color, tg.Name))
+		}
+		units, err := d.renderGroup(tg, retiredColor, false)
+		if err != nil {
+			return maskAny(err)
+		}
+		frontends, err := d.renderGroup(tg, retiredColor, true)
+		if err != nil {
+			return maskAny(err)
+		}
+		// Frontend units that are shared by both colors are kept
+		shared, err := d.renderGroup(tg, retiredColor.Other(), true)
+		if err != nil {
+			return maskAny(err)
+		}
+		retired := append(units.Units(), selectUnitNames(frontends.Units(), notPredicate(containsPredicate(shared.Units())))...)
+		unitNames = append(unitNames, selectUnitNames(loadedJobUnits, containsPredicate(retired))...)
+	}
+	if len(unitNames) == 0 {
+		if d.Events == nil {
+			fmt.Printf("No units on the cluster need to be retired\n")
+		}
+		ui.Summary(nil, nil)
+		return nil
+	}
+
+	if err := d.confirmDestroy(unitNames, false, ui); err != nil {
+		return maskAny(err)
+	}
+	err = d.destroyUnits(ctx, s, nil, nil, unitNames, ui)
+	ui.Summary(map[PlanAction]int{PlanActionRemove: len(unitNames)}, err)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// hasUnitChanges returns true if the given list contains at least one action other than none.
+func hasUnitChanges(units []UnitPlan) bool {
+	return ScalingGroupPlan{Units: units}.HasChanges()
+}
+
+// formatColors creates a description of the given colors of all blue/green groups.
+func formatColors(colors map[jobs.TaskGroupName]jobs.Color) string {
+	var list []string
+	for name, color := range colors {
+		list = append(list, fmt.Sprintf("%s (%s)", name, color))
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+const blueGreenTestJob = `job "shop" {
+	group "web" {
+		count = 2
+		blue-green = true
+		task "web" {
+			image = "nginx:1.11"
+			ports = ["80"]
+			frontend {
+				domain = "web.example.com"
+			}
+		}
+	}
+	group "db" {
+		task "db" {
+			image = "redis:3.2"
+		}
+	}
+}
+`
+
+// blueGreenUnit describes units of the web group of blueGreenTestJob in a single color.
+type blueGreenUnit struct {
+	Color     jobs.Color
+	Frontends bool // Frontend units instead of the other units
+	Modified  bool // Content on the cluster differs from the rendered content
+	Partial   bool // Only the units of the first scaling group are on the cluster
+}
+
+// webGroup returns the web group of the job of the given deployment.
+func webGroup(t *testing.T, d *Deployment) *jobs.TaskGroup {
+	tg, err := d.job.TaskGroup("web")
+	if err != nil {
+		t.Fatalf("Cannot find group web: %v", err)
+	}
+	return tg
+}
+
+// loadBlueGreenUnits creates a scheduler with the given units of the web group of blueGreenTestJob.
+func loadBlueGreenUnits(t *testing.T, units []blueGreenUnit) *testScheduler {
+	s := newTestScheduler("shop")
+	d, _ := newTestDeployment(t, blueGreenTestJob, s)
+	tg := webGroup(t, d)
+	for _, u := range units {
+		rendered, err := d.renderGroup(tg, u.Color, u.Frontends)
+		if err != nil {
+			t.Fatalf("Cannot render %s units: %v", u.Color, err)
+		}
+		for i, x := range rendered.units {
+			if u.Partial && i > 0 {
+				break
+			}
+			content := x.Content()
+			if u.Modified {
+				content += "\n# modified"
+			}
+			s.units[x.Name()] = content
+		}
+	}
+	return s
+}
+
+func TestActiveColor(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Loaded   []blueGreenUnit
+		Expected jobs.Color
+		Error    bool
+	}{
+		{Name: "not deployed"},
+		{
+			Name:     "blue with frontends",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorBlue, Frontends: true}},
+			Expected: jobs.ColorBlue,
+		},
+		{
+			Name:     "both colors, green frontends",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorGreen}, {Color: jobs.ColorGreen, Frontends: true}},
+			Expected: jobs.ColorGreen,
+		},
+		{
+			Name: "both frontends, blue modified",
+			Loaded: []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorGreen},
+				{Color: jobs.ColorBlue, Frontends: true, Modified: true}, {Color: jobs.ColorGreen, Frontends: true}},
+			Expected: jobs.ColorGreen,
+		},
+		{
+			Name:     "green frontends partially registered",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorGreen, Frontends: true, Partial: true}},
+			Expected: jobs.ColorBlue,
+		},
+		{
+			Name:     "blue without frontends",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue}},
+			Expected: jobs.ColorBlue,
+		},
+		{
+			Name:   "both colors without frontends",
+			Loaded: []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorGreen}},
+			Error:  true,
+		},
+		{
+			Name: "both frontends unchanged",
+			Loaded: []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorGreen},
+				{Color: jobs.ColorBlue, Frontends: true}, {Color: jobs.ColorGreen, Frontends: true}},
+			Error: true,
+		},
+	}
+	for _, test := range tests {
+		s := loadBlueGreenUnits(t, test.Loaded)
+		d, _ := newTestDeployment(t, blueGreenTestJob, s)
+		ctx := context.Background()
+		_, loaded, err := d.loadJobUnits(ctx)
+		if err != nil {
+			t.Fatalf("%s: cannot load units: %v", test.Name, err)
+		}
+		color, err := d.activeColor(ctx, s, loaded, webGroup(t, d))
+		if test.Error {
+			if errgo.Cause(err) != BlueGreenError {
+				t.Errorf("%s: expected BlueGreenError, got %v (color %s)", test.Name, err, color)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.Name, err)
+		} else if color != test.Expected {
+			t.Errorf("%s: expected active color '%s', got '%s'", test.Name, test.Expected, color)
+		}
+	}
+}
+
+func TestResolveColors(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Loaded   []blueGreenUnit
+		Expected jobs.Color
+	}{
+		{
+			Name:     "not deployed",
+			Expected: jobs.ColorBlue,
+		},
+		{
+			Name:     "blue unchanged",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorBlue, Frontends: true}},
+			Expected: jobs.ColorBlue,
+		},
+		{
+			Name:     "blue modified",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue, Modified: true}, {Color: jobs.ColorBlue, Frontends: true}},
+			Expected: jobs.ColorGreen,
+		},
+		{
+			Name:     "blue incomplete",
+			Loaded:   []blueGreenUnit{{Color: jobs.ColorBlue, Partial: true}, {Color: jobs.ColorBlue, Frontends: true}},
+			Expected: jobs.ColorGreen,
+		},
+		{
+			Name: "green modified",
+			Loaded: []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorGreen, Modified: true},
+				{Color: jobs.ColorGreen, Frontends: true}},
+			Expected: jobs.ColorBlue,
+		},
+	}
+	for _, test := range tests {
+		s := loadBlueGreenUnits(t, test.Loaded)
+		d, _ := newTestDeployment(t, blueGreenTestJob, s)
+		ctx := context.Background()
+		_, loaded, err := d.loadJobUnits(ctx)
+		if err != nil {
+			t.Fatalf("%s: cannot load units: %v", test.Name, err)
+		}
+		ui := d.newUI()
+		err = d.resolveColors(ctx, s, loaded, ui)
+		ui.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.Name, err)
+			continue
+		}
+		if len(d.blueGreen.colors) != 1 {
+			t.Errorf("%s: expected a color for 1 group, got %v", test.Name, d.blueGreen.colors)
+		}
+		if color := d.blueGreen.colors["web"]; color != test.Expected {
+			t.Errorf("%s: expected color '%s', got '%s'", test.Name, test.Expected, color)
+		}
+		if color := webGroup(t, d).Color(); color != test.Expected {
+			t.Errorf("%s: expected group color '%s', got '%s'", test.Name, test.Expected, color)
+		}
+		for _, u := range d.blueGreen.deployed {
+			if !namesPredicate([]string{
+				"shop-web-" + string(test.Expected) + "-web-mn@1.service",
+				"shop-web-" + string(test.Expected) + "-web-mn@2.service",
+			})(u) {
+				t.Errorf("%s: unexpected deployed unit %s", test.Name, u.Name())
+			}
+		}
+		if len(d.blueGreen.deployed) != 2 || len(d.blueGreen.retained) != 2 || len(d.blueGreen.replaced) != 2 {
+			t.Errorf("%s: expected 2 deployed, retained & replaced units, got %d, %d & %d", test.Name,
+				len(d.blueGreen.deployed), len(d.blueGreen.retained), len(d.blueGreen.replaced))
+		}
+	}
+}
+
+func TestSwitchTimeout(t *testing.T) {
+	tests := []struct {
+		HealthTimeout time.Duration
+		Expected      time.Duration
+	}{
+		{0, defaultSwitchTimeout},
+		{-time.Second, defaultSwitchTimeout},
+		{time.Minute, time.Minute},
+	}
+	for _, test := range tests {
+		d := &Deployment{HealthCheckConfig: HealthCheckConfig{HealthCheck: HealthCheckNone, HealthTimeout: test.HealthTimeout}}
+		if timeout := d.switchTimeout(); timeout != test.Expected {
+			t.Errorf("Expected switch timeout %s for health timeout %s, got %s", test.Expected, test.HealthTimeout, timeout)
+		}
+	}
+}
+
+func TestSwitchWithoutHealthTimeout(t *testing.T) {
+	s := loadBlueGreenUnits(t, []blueGreenUnit{{Color: jobs.ColorBlue}, {Color: jobs.ColorBlue, Frontends: true}, {Color: jobs.ColorGreen}})
+	// The green units only become healthy after the first check
+	s.unhealthy["shop-web-green-web-mn@1.service"] = 1
+	d, _ := newTestDeployment(t, blueGreenTestJob, s)
+	d.HealthCheckConfig = HealthCheckConfig{HealthCheck: HealthCheckNone}
+	if err := d.Switch(context.Background()); err != nil {
+		t.Fatalf("Switch failed: %v", err)
+	}
+	for _, name := range []string{"shop-web-green-web-fe@1.service", "shop-web-green-web-fe@2.service"} {
+		if _, ok := s.units[name]; !ok {
+			t.Errorf("Expected %s to be started", name)
+		}
+	}
+	for _, name := range []string{"shop-web-blue-web-fe@1.service", "shop-web-blue-web-fe@2.service"} {
+		if _, ok := s.units[name]; ok {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/prometheus"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// canarySettings returns the canary that applies to this deployment, or nil if there is none.
+// The canary of the job takes precedence over canaries of the selected task groups.
+// The canary count can be overridden using RolloutOptions.
+func (d *Deployment) canarySettings() *jobs.Canary {
+	var canary *jobs.Canary
+	if d.job.Canary != nil {
+		c := *d.job.Canary
+		canary = &c
+	} else {
+		for _, tg := range d.job.Groups {
+			if !d.groupSelection.Includes(tg.Name) || tg.Canary == nil {
+				continue
+			}
+			if canary == nil || tg.Canary.Count > canary.Count {
+				c := *tg.Canary
+				canary = &c
+			}
+		}
+	}
+	if d.Canary > 0 {
+		if canary == nil {
+			canary = &jobs.Canary{}
+		}
+		canary.Count = d.Canary
+	}
+	return canary
+}
+
+// canaryRecord holds a canary that waits for manual promotion.
+// It contains the previous versions of the units of the canary, so it can still be aborted
+// by a later invocation.
+type canaryRecord struct {
+	Steps []canaryStepRecord `json:"steps"`
+}
+
+// canaryStepRecord holds the rollback step of a single scaling group of a canary.
+type canaryStepRecord struct {
+	ScalingGroup uint                    `json:"scaling-group"`
+	Launched     []string                `json:"launched,omitempty"`
+	Previous     []scheduler.HistoryUnit `json:"previous,omitempty"`
+}
+
+// newCanaryRecord creates a record of a canary deployed in the given steps.
+func newCanaryRecord(steps []rollbackStep) *canaryRecord {
+	r := &canaryRecord{}
+	for _, step := range steps {
+		sr := canaryStepRecord{
+			ScalingGroup: step.scalingGroup,
+			Launched:     step.launched,
+		}
+		for _, u := range step.previous {
+			sr.Previous = append(sr.Previous, scheduler.HistoryUnit{
+				Name:         u.Name(),
+				ScalingGroup: step.scalingGroup,
+				Hash:         contentHash(u.Content()),
+				Content:      u.Content(),
+			})
+		}
+		r.Steps = append(r.Steps, sr)
+	}
+	return r
+}
+
+// includes returns true if the given scaling group is part of the canary.
+func (r canaryRecord) includes(scalingGroup uint) bool {
+	for _, step := range r.Steps {
+		if step.ScalingGroup == scalingGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// rollbackSteps reconstructs the steps needed to roll back the canary.
+func (r canaryRecord) rollbackSteps(s scheduler.Scheduler) ([]rollbackStep, error) {
+	var steps []rollbackStep
+	for _, sr := range r.Steps {
+		step := rollbackStep{
+			scalingGroup: sr.ScalingGroup,
+			launched:     sr.Launched,
+		}
+		for _, hu := range sr.Previous {
+			u, err := s.ParseUnit(hu.Name, hu.Content)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			step.previous = append(step.previous, u)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// pendingCanary returns the progress record of the deployment that stopped after a canary
+// that must be promoted manually.
+func (d *Deployment) pendingCanary() (*progressRecord, error) {
+	record, err := d.loadProgress()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if record == nil || record.Canary == nil {
+		return nil, maskAny(errgo.WithCausef(nil, CanaryNotDeployedError, "no canary of job '%s' is waiting for promotion, use `run` to deploy the canary first", d.job.Name))
+	}
+	return record, nil
+}
+
+// refusePendingCanary returns an error when a canary of the job waits for manual promotion.
+// Deploying the job would overwrite the record that is needed to abort that canary.
+func (d *Deployment) refusePendingCanary() error {
+	record, err := d.loadProgress()
+	if err != nil {
+		return maskAny(err)
+	}
+	if record != nil && record.Canary != nil {
+		return maskAny(errgo.WithCausef(nil, CanaryPendingError, "a canary of job '%s' waits for promotion since %s, use `j2 promote` or `j2 promote --abort` first", d.job.Name, record.Updated.Format(time.RFC1123)))
+	}
+	return nil
+}
+
+// saveCanary records the canary deployed in the given steps, so it can be promoted
+// or aborted by a later invocation.
+func (d *Deployment) saveCanary(steps []rollbackStep) error {
+	if d.progress == nil {
+		d.progress = &progressRecord{
+			Job:         d.job.Name,
+			Stack:       d.cluster.Stack,
+			ContentHash: d.deploymentHash(),
+		}
+	}
+	d.progress.Canary = newCanaryRecord(steps)
+	d.progress.Updated = time.Now()
+	if err := d.saveProgress(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Promote continues a deployment that stopped after a canary that must be promoted manually,
+// updating all remaining scaling groups.
+// It fails when the job has changed since the canary was deployed, or when the scaling groups
+// of the canary are not up to date.
+func (d *Deployment) Promote(ctx context.Context) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	unlock, err := d.lockJob(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer unlock()
+
+	record, err := d.pendingCanary()
+	if err != nil {
+		return maskAny(err)
+	}
+	s, loadedJobUnits, err := d.prepare(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	if record.ContentHash != d.deploymentHash() {
+		return maskAny(errgo.WithCausef(nil, CanaryNotDeployedError, "job '%s' has changed since its canary was deployed, use `run` to deploy a new canary", d.job.Name))
+	}
+	plan, err := d.createPlan(ctx, s, loadedJobUnits, ui, false)
+	if err != nil {
+		return maskAny(err)
+	}
+	ui.Clear()
+
+	for _, sgp := range plan.ScalingGroups {
+		if record.Canary.includes(sgp.ScalingGroup) && sgp.HasChanges() {
+			return maskAny(errgo.WithCausef(nil, CanaryNotDeployedError, "scaling group %d of the canary is not up to date, use `run` to deploy the canary first", sgp.ScalingGroup))
+		}
+	}
+
+	// Continue recording the progress of the deployment, the canary is no longer pending
+	d.progress = record
+	d.progress.Canary = nil
+	if err := d.executePlan(ctx, s, loadedJobUnits, plan, ui, true, nil); err != nil {
+		return maskAny(err)
+	}
+	d.discardProgress(ui)
+	return nil
+}
+
+// AbortCanary restores the scaling groups of a canary that waits for manual promotion
+// to their previous version.
+func (d *Deployment) AbortCanary(ctx context.Context) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	unlock, err := d.lockJob(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer unlock()
+
+	record, err := d.pendingCanary()
+	if err != nil {
+		return maskAny(err)
+	}
+	s, _, err := d.prepare(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	steps, err := record.Canary.rollbackSteps(s)
+	if err != nil {
+		return maskAny(err)
+	}
+	ui.HeaderSink <- fmt.Sprintf("Aborting canary on scaling group(s) %s of '%s'.\n", formatScalingGroups(steps), d.cluster.Stack)
+	if err := ui.Confirm("Are you sure you want to continue?"); err != nil {
+		return maskAny(err)
+	}
+	report, err := d.rollback(ctx, s, steps, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	ui.HeaderSink <- fmt.Sprintf("Rolled back canary on '%s'.\n%s\n", d.cluster.Stack, report)
+	d.progress = record
+	d.discardProgress(ui)
+	return nil
+}
+
+// promoteCanary decides whether the deployed canary is promoted to all scaling groups.
+// It returns true when the deployment must continue with the remaining scaling groups.
+// If the canary is aborted, all given steps are rolled back.
+func (d *Deployment) promoteCanary(ctx context.Context, s scheduler.Scheduler, canary jobs.Canary, steps []rollbackStep, ui *stateUI) (bool, error) {
+	abort := func(reason string) (bool, error) {
+		ui.Warningf("Aborting canary: %s\n", reason)
+		ctx, cancel := rollbackContext()
+		defer cancel()
+		report, err := d.rollback(ctx, s, steps, ui)
+		if err != nil {
+			return false, maskAny(errgo.WithCausef(err, CanaryAbortedError, "canary aborted (%s) and rollback failed", reason))
+		}
+		ui.HeaderSink <- fmt.Sprintf("Rolled back canary on '%s'.\n%s\n", d.cluster.Stack, report)
+		return false, maskAny(errgo.WithCausef(nil, CanaryAbortedError, "canary aborted: %s", reason))
+	}
+
+	switch canary.PromoteMode() {
+	case jobs.CanaryPromoteManual:
+		// Without a record, the canary could not be aborted later
+		if err := d.saveCanary(steps); err != nil {
+			return abort(fmt.Sprintf("cannot record canary: %v", err))
+		}
+		ui.HeaderSink <- fmt.Sprintf("Canary deployed on scaling group(s) %s of '%s'.\nUse `j2 promote` to continue with the remaining scaling groups, or `j2 promote --abort` to restore their previous version.\n", formatScalingGroups(steps), d.cluster.Stack)
+		return false, nil
+	case jobs.CanaryPromoteMetrics:
+		if err := ui.Wait(ctx, canary.AnalysisDuration(), "Analyzing canary, querying error rate in %s..."); err != nil {
+			return false, maskAny(err)
+		}
+		values, err := prometheus.Query(ctx, canary.MetricsURL, canary.ErrorRate)
+		if err != nil {
+			return abort(fmt.Sprintf("cannot query error rate: %v", err))
+		}
+		if len(values) == 0 {
+			return abort("error rate query returned no samples")
+		}
+		for _, v := range values {
+			if math.IsNaN(v) {
+				return abort("error rate is not a number (is there any traffic?)")
+			}
+			if v > canary.MaxErrorRate {
+				return abort(fmt.Sprintf("error rate %g exceeds maximum of %g", v, canary.MaxErrorRate))
+			}
+		}
+		ui.Verbosef("Canary error rate within limits: %v\n", values)
+		return true, nil
+	default:
+		answer, err := ui.Ask(fmt.Sprintf("Canary deployed on scaling group(s) %s. Promote to all scaling groups?", formatScalingGroups(steps)), "promote", "abort")
+		if err != nil {
+			return false, maskAny(err)
+		}
+		if answer != "promote" {
+			return abort("by user")
+		}
+		return true, nil
+	}
+}
+
+// hasChangesAfter returns true if the given plan has any changes after the scaling group with given index.
+func hasChangesAfter(plan Plan, sgIndex int) bool {
+	if len(plan.Cleanup) > 0 {
+		return true
+	}
+	for _, sgp := range plan.ScalingGroups[sgIndex+1:] {
+		if sgp.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// formatScalingGroups returns a comma separated list of the given scaling groups.
+func formatScalingGroups(steps []rollbackStep) string {
+	var groups []string
+	for _, s := range steps {
+		groups = append(groups, fmt.Sprintf("%d", s.scalingGroup))
+	}
+	return strings.Join(groups, ",")
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/pulcy/j2/cluster"
 	"github.com/pulcy/j2/extpoints"
 	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
 )
 
 type DeploymentDelays struct {
@@ -45,10 +46,22 @@ type Deployment struct {
 	force                 bool
 	autoContinue          bool
 	DeploymentDelays
+	HealthCheckConfig
+	RolloutOptions
 	renderContext RenderContext
 	orchestrator  extpoints.Orchestrator
 
+	scheduler     scheduler.Scheduler
 	scalingGroups []scalingGroupUnits
+	blueGreen     blueGreenUnits
+	rollbackOf    *scheduler.HistoryEntry // History entry that is redeployed by Rollback
+	progress      *progressRecord         // Scaling groups completed by Run
+	started       time.Time               // Start of the plan being executed (used in notifications)
+	notifications *notifyQueue            // Delivers notifications of the plan being executed
+
+	// Events receives the progress of all commands, if set.
+	// Without it, progress is shown in the terminal.
+	Events EventSink
 }
 
 type RenderContext interface {
@@ -65,7 +78,7 @@ type RenderContext interface {
 // NewDeployment creates a new Deployment instances and generates all unit files for the given job.
 func NewDeployment(orchestrator extpoints.Orchestrator, job jobs.Job, cluster cluster.Cluster, groupSelection TaskGroupSelection,
 	scalingGroupSelection ScalingGroupSelection, force, autoContinue, verbose bool, delays DeploymentDelays,
-	renderContext RenderContext) (*Deployment, error) {
+	healthCheck HealthCheckConfig, rollout RolloutOptions, renderContext RenderContext) (*Deployment, error) {
 	return &Deployment{
 		job:                   job,
 		cluster:               cluster,
@@ -75,14 +88,39 @@ func NewDeployment(orchestrator extpoints.Orchestrator, job jobs.Job, cluster cl
 		autoContinue:     autoContinue,
 		verbose:          verbose,
 		DeploymentDelays: delays,
+		HealthCheckConfig: healthCheck,
+		RolloutOptions:    rollout,
 		renderContext:    renderContext,
 		orchestrator:     orchestrator,
 	}, nil
 }
 
+// newUI creates the UI that reports the progress of a command.
+func (d *Deployment) newUI() *stateUI {
+	ui := newStateUI(d.verbose, d.Events)
+	if d.Events != nil {
+		// Questions can only be answered by auto continue
+		ui.autoConfirm = d.autoContinue
+	}
+	return ui
+}
+
+// getScheduler returns the scheduler of the configured job & cluster.
+// It is created on first use.
+func (d *Deployment) getScheduler() (scheduler.Scheduler, error) {
+	if d.scheduler == nil {
+		s, err := d.orchestrator.Scheduler(d.job, d.cluster)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		d.scheduler = s
+	}
+	return d.scheduler, nil
+}
+
 // DryRun creates all unit files it will deploy during a normal `Run` and present them to the user.
 func (d *Deployment) DryRun() error {
-	ui := newStateUI(d.verbose)
+	ui := d.newUI()
 	defer ui.Close()
 
 	if err := d.generateScalingGroups(); err != nil {
@@ -93,7 +131,7 @@ func (d *Deployment) DryRun() error {
 		return maskAny(err)
 	}
 	unitNames := []string{}
-	for _, sgu := range d.scalingGroups {
+	for _, sgu := range append(d.scalingGroups, d.blueGreen.frontends) {
 		for _, u := range sgu.units {
 			unitPath := filepath.Join(dir, u.Name())
 			if err := ioutil.WriteFile(unitPath, []byte(u.Content()), 0644); err != nil {
@@ -119,6 +157,16 @@ func (d *Deployment) DryRun() error {
 	return nil
 }
 
+// scalingGroup returns the generated units for the given scaling group.
+func (d *Deployment) scalingGroup(scalingGroup uint) (scalingGroupUnits, error) {
+	for _, sgu := range d.scalingGroups {
+		if sgu.scalingGroup == scalingGroup {
+			return sgu, nil
+		}
+	}
+	return scalingGroupUnits{}, maskAny(errgo.WithCausef(nil, InvalidPlanError, "scaling group %d is not part of the deployment", scalingGroup))
+}
+
 // generateScalingGroups generates the unit files for all scaling groups included in the selection.
 // After this, a call to cleanup is needed.
 func (d *Deployment) generateScalingGroups() error {
@@ -132,6 +180,13 @@ func (d *Deployment) generateScalingGroups() error {
 			return maskAny(err)
 		}
 		d.scalingGroups = append(d.scalingGroups, sgu)
+
+		// Frontends of blue/green groups are switched after all scaling groups
+		frontends, err := d.renderUnits(scalingGroup, d.groupSelection, true)
+		if err != nil {
+			return maskAny(err)
+		}
+		d.blueGreen.frontends.units = append(d.blueGreen.frontends.units, frontends...)
 	}
 	return nil
 }
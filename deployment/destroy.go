@@ -16,20 +16,47 @@ package deployment
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/juju/errgo"
+
 	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
 	"golang.org/x/sync/errgroup"
 )
 
+// DestroyOptions control how Destroy removes the units of a job.
+type DestroyOptions struct {
+	DryRun             bool // Only show the units that would be destroyed
+	OverrideProtection bool // Allow destroying a protected job, after typing its name
+}
+
 // Destroy removes all unit files that belong to the configured job from the configured cluster.
-func (d *Deployment) Destroy() error {
-	s, err := d.orchestrator.Scheduler(d.job, d.cluster)
+// A job that is protected against destroy (by the job, one of the selected groups or the cluster)
+// is only destroyed when protection is overridden and the user has typed the name of the job.
+func (d *Deployment) Destroy(ctx context.Context, options DestroyOptions) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	s, err := d.getScheduler()
+	if err != nil {
+		return maskAny(err)
+	}
+	if options.DryRun {
+		return maskAny(d.previewDestroy(ctx, s, ui))
+	}
+	protection := d.destroyProtection()
+	if len(protection) > 0 && !options.OverrideProtection {
+		return maskAny(errgo.WithCausef(nil, DestroyProtectedError, "%s (use --override-protection to destroy it anyway)", strings.Join(protection, ", ")))
+	}
+	unlock, err := d.lockJob(ctx, ui)
 	if err != nil {
 		return maskAny(err)
 	}
+	defer unlock()
 
-	list, err := s.List()
+	list, err := s.List(ctx)
 	if err != nil {
 		return maskAny(err)
 	}
@@ -37,23 +64,79 @@ func (d *Deployment) Destroy() error {
 	predicate := d.createUnitNamePredicate(s)
 	unitNames := selectUnitNames(list, predicate)
 	if len(unitNames) == 0 {
-		fmt.Printf("No units on the cluster match the given arguments\n")
+		if d.Events == nil {
+			fmt.Printf("No units on the cluster match the given arguments\n")
+		}
+		ui.Summary(nil, nil)
 		return nil
 	}
 
-	ui := newStateUI(d.verbose)
-	defer ui.Close()
-
+	if len(protection) > 0 {
+		// Typing the name is required, even with --force or --yes
+		question := fmt.Sprintf("%s.\nType the name of the job to destroy it anyway", strings.Join(protection, ", "))
+		if _, err := ui.Ask(question, d.job.Name.String()); err != nil {
+			return maskAny(err)
+		}
+	}
 	if err := d.confirmDestroy(unitNames, false, ui); err != nil {
 		return maskAny(err)
 	}
-	if err := d.destroyUnits(s, nil, nil, unitNames, ui); err != nil {
+	err = d.destroyUnits(ctx, s, nil, nil, unitNames, ui)
+	ui.Summary(map[PlanAction]int{PlanActionRemove: len(unitNames)}, err)
+	if err != nil {
 		return maskAny(err)
 	}
 
 	return nil
 }
 
+// previewDestroy shows all units (and their resources) that Destroy would remove, without removing them.
+func (d *Deployment) previewDestroy(ctx context.Context, s scheduler.Scheduler, ui *stateUI) error {
+	list, err := s.List(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	units := selectUnitNames(list, d.createUnitNamePredicate(s))
+	descriptions := make([]string, 0, len(units))
+	for _, u := range units {
+		if describer, ok := s.(scheduler.UnitDescriber); ok {
+			descriptions = append(descriptions, describer.DescribeUnit(u))
+		} else {
+			descriptions = append(descriptions, u.Name())
+		}
+	}
+	sort.Strings(descriptions)
+
+	msg := fmt.Sprintf("No units on stack '%s' match the given arguments.\n", d.cluster.Stack)
+	if len(units) > 0 {
+		msg = fmt.Sprintf("The following %d unit(s) would be destroyed on stack '%s':\n- %s\n", len(units), d.cluster.Stack, strings.Join(descriptions, "\n- "))
+	}
+	if protection := d.destroyProtection(); len(protection) > 0 {
+		msg = msg + fmt.Sprintf("\nDestroy is prevented: %s.\n", strings.Join(protection, ", "))
+	}
+	ui.HeaderSink <- msg
+	ui.Summary(nil, nil)
+	return nil
+}
+
+// destroyProtection returns the reasons why destroying the configured job (and group selection) is prevented.
+// It returns an empty list when the job is not protected.
+func (d *Deployment) destroyProtection() []string {
+	var reasons []string
+	if d.cluster.IsProtectedJob(d.job.Name.String()) {
+		reasons = append(reasons, fmt.Sprintf("job '%s' is protected by cluster '%s'", d.job.Name, d.cluster.Stack))
+	}
+	if d.job.PreventDestroy {
+		reasons = append(reasons, fmt.Sprintf("job '%s' has prevent-destroy set", d.job.Name))
+	}
+	for _, tg := range d.job.Groups {
+		if tg.PreventDestroy && d.groupSelection.Includes(tg.Name) {
+			reasons = append(reasons, fmt.Sprintf("group '%s' has prevent-destroy set", tg.Name))
+		}
+	}
+	return reasons
+}
+
 func (d *Deployment) confirmDestroy(units []scheduler.Unit, obsolete bool, ui *stateUI) error {
 	if !d.force {
 		obsoleteMsg := ""
@@ -69,23 +152,25 @@ func (d *Deployment) confirmDestroy(units []scheduler.Unit, obsolete bool, ui *s
 	return nil
 }
 
-func (d *Deployment) destroyUnits(f scheduler.Scheduler, modifiedUnits, failedUnits, obsoleteUnits []scheduler.Unit, ui *stateUI) error {
+func (d *Deployment) destroyUnits(ctx context.Context, f scheduler.Scheduler, modifiedUnits, failedUnits, obsoleteUnits []scheduler.Unit, ui *stateUI) error {
 	destroy := func(f scheduler.Scheduler, reason scheduler.Reason, units []scheduler.Unit, ui *stateUI) error {
 		if len(units) == 0 {
 			return nil
 		}
 		ui.MessageSink <- fmt.Sprintf("Stopping %d unit(s)", len(units))
-		stats, err := f.Stop(ui.EventSink, reason, units...)
+		stats, err := f.Stop(ctx, ui.EventSink, reason, units...)
 		if err != nil {
 			ui.Warningf("Warning: stop failed.\n%s\n", err.Error())
 		}
 
 		if stats.StoppedGlobalUnits > 0 {
-			InterruptibleSleep(ui.MessageSink, f.UpdateStopDelay(d.StopDelay), "Waiting for %s...")
+			if err := ui.Wait(ctx, f.UpdateStopDelay(d.StopDelay), "Waiting for %s..."); err != nil {
+				return maskAny(err)
+			}
 		}
 
 		ui.MessageSink <- fmt.Sprintf("Destroying %d unit(s)", len(units))
-		if err := f.Destroy(ui.EventSink, reason, units...); err != nil {
+		if err := f.Destroy(ctx, ui.EventSink, reason, units...); err != nil {
 			return maskAny(err)
 		}
 		return nil
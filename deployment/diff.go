@@ -0,0 +1,210 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+type DiffAction string
+
+const (
+	DiffActionCreate    = DiffAction("create")
+	DiffActionUpdate    = DiffAction("update")
+	DiffActionRemove    = DiffAction("remove")
+	DiffActionUnchanged = DiffAction("unchanged")
+)
+
+// UnitDiff holds the differences between a unit as it exists on the cluster
+// and its rendered version.
+type UnitDiff struct {
+	Name         string     `json:"name"`
+	ScalingGroup uint       `json:"scaling-group,omitempty"`
+	Action       DiffAction `json:"action"`
+	Diff         string     `json:"diff,omitempty"` // Unified diff
+}
+
+// Diff renders all units exactly like `Run` does and compares them with the
+// units on the cluster. The result contains a unified diff for every unit that
+// will be created, updated or removed as obsolete.
+func (d *Deployment) Diff(ctx context.Context) ([]UnitDiff, error) {
+	s, err := d.orchestrator.Scheduler(d.job, d.cluster)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	allUnits, err := s.List(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	// Find out which current units belong to the configured job
+	loadedJobUnits := selectUnitNames(allUnits, d.createUnitNamePredicate(s))
+
+	// Decide in which color blue/green groups are deployed
+	if len(d.blueGreenGroups()) > 0 {
+		ui := d.newUI()
+		err := d.resolveColors(ctx, s, loadedJobUnits, ui)
+		ui.Close()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+	}
+
+	// Create scaling group units
+	if err := d.generateScalingGroups(); err != nil {
+		return nil, maskAny(err)
+	}
+
+	// Units of blue/green groups outside the scaling groups are compared separately
+	remainingLoadedJobUnitNames := selectUnitNames(loadedJobUnits, notPredicate(containsPredicate(d.blueGreen.excluded())))
+
+	var result []UnitDiff
+	for _, sg := range d.scalingGroups {
+		// Select the loaded units that belong to this scaling group
+		correctScalingGroupPredicate := func(unit scheduler.Unit) bool {
+			return s.IsUnitForScalingGroup(unit, sg.scalingGroup)
+		}
+		loadedScalingGroupUnitNames := selectUnitNames(remainingLoadedJobUnitNames, correctScalingGroupPredicate)
+		remainingLoadedJobUnitNames = selectUnitNames(remainingLoadedJobUnitNames, notPredicate(containsPredicate(loadedScalingGroupUnitNames)))
+		obsoleteUnitNames := selectUnitNames(loadedScalingGroupUnitNames, notPredicate(containsPredicate(sg.Units())))
+
+		for _, u := range sg.units {
+			ud, err := d.unitDiff(ctx, s, u, containsPredicate(loadedScalingGroupUnitNames)(u), sg.scalingGroup)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			result = append(result, ud)
+		}
+
+		for _, u := range obsoleteUnitNames {
+			ud, err := d.removalDiff(ctx, s, u, sg.scalingGroup)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			result = append(result, ud)
+		}
+	}
+
+	// Frontends of blue/green groups
+	isLoaded := containsPredicate(loadedJobUnits)
+	for _, u := range d.blueGreen.frontends.units {
+		ud, err := d.unitDiff(ctx, s, u, isLoaded(u), 0)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, ud)
+	}
+	replaced := selectUnitNames(loadedJobUnits, containsPredicate(d.blueGreen.replaced))
+	for _, u := range selectUnitNames(replaced, notPredicate(containsPredicate(d.blueGreen.frontends.Units()))) {
+		ud, err := d.removalDiff(ctx, s, u, 0)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, ud)
+	}
+
+	// Remaining units will be removed
+	for _, u := range remainingLoadedJobUnitNames {
+		ud, err := d.removalDiff(ctx, s, u, 0)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, ud)
+	}
+
+	return result, nil
+}
+
+// unitDiff creates a diff between the given rendered unit and the unit as it exists on the cluster.
+func (d *Deployment) unitDiff(ctx context.Context, s scheduler.Scheduler, u scheduler.UnitData, loaded bool, scalingGroup uint) (UnitDiff, error) {
+	newContent, err := s.NormalizeContent(u)
+	if err != nil {
+		return UnitDiff{}, maskAny(err)
+	}
+	ud := UnitDiff{
+		Name:         u.Name(),
+		ScalingGroup: scalingGroup,
+	}
+	curContent := ""
+	if loaded {
+		curContent, err = s.Cat(ctx, u)
+		if err != nil && !scheduler.IsNotFound(err) {
+			return UnitDiff{}, maskAny(err)
+		}
+	}
+	if curContent == "" {
+		ud.Action = DiffActionCreate
+	} else if curContent == newContent {
+		ud.Action = DiffActionUnchanged
+	} else {
+		ud.Action = DiffActionUpdate
+	}
+	if ud.Action != DiffActionUnchanged {
+		if ud.Diff, err = unifiedDiff(u.Name(), curContent, newContent); err != nil {
+			return UnitDiff{}, maskAny(err)
+		}
+	}
+	return ud, nil
+}
+
+// removalDiff creates a diff for a unit that will be removed.
+func (d *Deployment) removalDiff(ctx context.Context, s scheduler.Scheduler, unit scheduler.Unit, scalingGroup uint) (UnitDiff, error) {
+	curContent, err := s.Cat(ctx, unit)
+	if err != nil && !scheduler.IsNotFound(err) {
+		return UnitDiff{}, maskAny(err)
+	}
+	diff, err := unifiedDiff(unit.Name(), curContent, "")
+	if err != nil {
+		return UnitDiff{}, maskAny(err)
+	}
+	return UnitDiff{
+		Name:         unit.Name(),
+		ScalingGroup: scalingGroup,
+		Action:       DiffActionRemove,
+		Diff:         diff,
+	}, nil
+}
+
+// unifiedDiff creates a unified diff between the given contents of a unit.
+func unifiedDiff(name, current, rendered string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(current),
+		B:        splitLines(rendered),
+		FromFile: "cluster/" + name,
+		ToFile:   "rendered/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return diff, nil
+}
+
+// splitLines splits the given content into lines (including their line endings).
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return strings.SplitAfter(content, "\n")[:strings.Count(content, "\n")]
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"sort"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+type DriftKind string
+
+const (
+	DriftModified = DriftKind("modified") // Unit on the cluster differs from its rendered version
+	DriftMissing  = DriftKind("missing")  // Rendered unit does not exist on the cluster
+	DriftObsolete = DriftKind("obsolete") // Unit of a job that is no longer rendered by that job
+	DriftOrphaned = DriftKind("orphaned") // Unit that belongs to none of the jobs
+)
+
+// UnitDrift describes a unit on the cluster that does not match the jobs it was deployed from.
+type UnitDrift struct {
+	Job   jobs.JobName `json:"job,omitempty"` // Empty for orphaned units
+	Name  string       `json:"name"`
+	Kind  DriftKind    `json:"kind"`
+	Diffs []string     `json:"diffs,omitempty"` // Summary of changes (modified only)
+}
+
+// Drift compares the rendered units of the jobs of all given deployments with the units on the cluster.
+// It reports units that are modified, missing or obsolete and units that belong to none of the jobs.
+// The deployments must all target the same cluster.
+// Orphaned units are only searched among the units listed by the schedulers of the given jobs.
+// On Kubernetes these are the units in the namespaces of those jobs, other namespaces are not searched.
+// The result is sorted by job & unit name, with orphaned units first.
+func Drift(ctx context.Context, deployments []*Deployment) ([]UnitDrift, error) {
+	var result []UnitDrift
+	var schedulers []scheduler.Scheduler
+	for _, d := range deployments {
+		units, err := d.Status(ctx)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		for _, u := range units {
+			ud := UnitDrift{Job: d.job.Name, Name: u.Name}
+			switch {
+			case u.Inactive || !u.Changed:
+				continue
+			case u.Obsolete:
+				ud.Kind = DriftObsolete
+			case u.State == UnitStateNotCreated:
+				ud.Kind = DriftMissing
+			default:
+				ud.Kind = DriftModified
+				ud.Diffs = u.Diffs
+			}
+			result = append(result, ud)
+		}
+
+		s, err := d.getScheduler()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		schedulers = append(schedulers, s)
+	}
+
+	// Find units that belong to none of the jobs.
+	// Every scheduler lists the units it can see, which (on kubernetes) is the namespace of its job.
+	seen := make(map[string]struct{})
+	for _, s := range schedulers {
+		units, err := s.List(ctx)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		for _, u := range units {
+			if _, ok := seen[u.Name()]; ok {
+				continue
+			}
+			seen[u.Name()] = struct{}{}
+			if !isUnitForAnyJob(schedulers, u) {
+				result = append(result, UnitDrift{Name: u.Name(), Kind: DriftOrphaned})
+			}
+		}
+	}
+
+	sort.Sort(unitDriftByJob(result))
+	return result, nil
+}
+
+// isUnitForAnyJob returns true if the given unit is part of the job of at least one of the given schedulers.
+func isUnitForAnyJob(schedulers []scheduler.Scheduler, unit scheduler.Unit) bool {
+	for _, s := range schedulers {
+		if s.IsUnitForJob(unit) {
+			return true
+		}
+	}
+	return false
+}
+
+type unitDriftByJob []UnitDrift
+
+func (l unitDriftByJob) Len() int      { return len(l) }
+func (l unitDriftByJob) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l unitDriftByJob) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.Job != b.Job {
+		return a.Job < b.Job
+	}
+	return a.Name < b.Name
+}
@@ -19,5 +19,23 @@ import (
 )
 
 var (
-	maskAny = errgo.MaskFunc(errgo.Any)
+	InvalidPlanError       = errgo.New("invalid plan")
+	PlanDriftError         = errgo.New("plan drift")
+	UnhealthyError         = errgo.New("unhealthy")
+	CanaryAbortedError     = errgo.New("canary aborted")
+	CanaryNotDeployedError = errgo.New("canary not deployed")
+	CanaryPendingError     = errgo.New("canary pending")
+	BlueGreenError         = errgo.New("blue/green error")
+	RevisionNotFoundError  = errgo.New("revision not found")
+	HookFailedError        = errgo.New("hook failed")
+	ResumeError            = errgo.New("cannot resume")
+	NotInteractiveError    = errgo.New("not interactive")
+	DestroyProtectedError  = errgo.New("destroy protected")
+	maskAny                = errgo.MaskFunc(errgo.Any)
 )
+
+// IsPlanDrift returns true if the given error is caused by a plan that no longer
+// matches the job or the state of the cluster.
+func IsPlanDrift(err error) bool {
+	return errgo.Cause(err) == PlanDriftError
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	EventHeader  = EventType("header")  // Start of a step, Message holds its description
+	EventUnit    = EventType("unit")    // Progress of a single unit, reported by the scheduler
+	EventState   = EventType("state")   // Additional state of a unit (such as a summary of its differences) in Extra
+	EventMessage = EventType("message") // Progress message
+	EventWarning = EventType("warning")
+	EventVerbose = EventType("verbose") // Only reported in verbose mode
+	EventWait    = EventType("wait")    // Waiting period of Duration
+	EventConfirm = EventType("confirm") // Question that has been answered automatically
+	EventSummary = EventType("summary") // End of a deployment, Actions holds the number of units per action
+)
+
+// Event describes a single step in the progress of a command.
+type Event struct {
+	Type     EventType          `json:"type"`
+	Time     time.Time          `json:"time"`
+	Section  string             `json:"section,omitempty"` // Title of the parallel section the event belongs to (if any)
+	Unit     string             `json:"unit,omitempty"`
+	Message  string             `json:"message,omitempty"`
+	Extra    string             `json:"extra,omitempty"`
+	Duration time.Duration      `json:"-"`
+	Actions  map[PlanAction]int `json:"actions,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// EventSink receives the events of a command.
+// When a deployment is configured with an EventSink, it reports its progress there
+// instead of in the terminal, and it does not ask any questions.
+// Events can be reported from multiple goroutines at the same time.
+type EventSink interface {
+	Event(evt Event)
+}
+
+// NewJSONEventSink creates an EventSink that writes every event as a single line of JSON to the given writer.
+func NewJSONEventSink(w io.Writer) EventSink {
+	return &jsonEventSink{encoder: json.NewEncoder(w)}
+}
+
+type jsonEventSink struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// Event writes the given event as a single line of JSON.
+func (s *jsonEventSink) Event(evt Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := struct {
+		Event
+		Duration string `json:"duration,omitempty"`
+	}{Event: evt}
+	if evt.Duration > 0 {
+		data.Duration = evt.Duration.String()
+	}
+	s.encoder.Encode(data)
+}
+
+// planSummary returns the number of units of the given plan per action.
+func planSummary(plan Plan) map[PlanAction]int {
+	actions := make(map[PlanAction]int)
+	for _, sgp := range plan.ScalingGroups {
+		for _, up := range sgp.Units {
+			actions[up.Action]++
+		}
+	}
+	for _, up := range plan.Frontends {
+		actions[up.Action]++
+	}
+	for _, up := range plan.Cleanup {
+		actions[up.Action]++
+	}
+	return actions
+}
@@ -71,6 +71,19 @@ func containsPredicate(list []scheduler.Unit) func(scheduler.Unit) bool {
 	}
 }
 
+// namesPredicate creates a predicate that returns true if the name of the given unit
+// is contained in the given list.
+func namesPredicate(names []string) func(scheduler.Unit) bool {
+	return func(unit scheduler.Unit) bool {
+		for _, x := range names {
+			if x == unit.Name() {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func notPredicate(predicate func(scheduler.Unit) bool) func(scheduler.Unit) bool {
 	return func(unit scheduler.Unit) bool {
 		return !predicate(unit)
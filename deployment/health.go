@@ -0,0 +1,113 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/ryanuber/columnize"
+
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+type HealthCheckMode string
+
+const (
+	HealthCheckNone  = HealthCheckMode("none")  // Wait for the slice delay between scaling groups
+	HealthCheckState = HealthCheckMode("state") // Unit active state (fleet) or available replicas (kubernetes), both gated by the check of the task
+	HealthCheckHTTP  = HealthCheckMode("http")  // Request on the http-check-path or http check of the task (through the tunnel)
+
+	healthCheckInterval = 2 * time.Second
+)
+
+// Validate returns an error if the given mode is not a known health check mode.
+func (m HealthCheckMode) Validate() error {
+	switch m {
+	case "", HealthCheckNone, HealthCheckState, HealthCheckHTTP:
+		return nil
+	default:
+		return maskAny(fmt.Errorf("unknown health check '%s', expected none, state or http", m))
+	}
+}
+
+// IsEnabled returns true if units must become healthy before continuing with the next scaling group.
+func (m HealthCheckMode) IsEnabled() bool {
+	return m != "" && m != HealthCheckNone
+}
+
+// HealthCheckConfig specifies how to wait for the units of a scaling group to become healthy,
+// before continuing with the next scaling group.
+type HealthCheckConfig struct {
+	HealthCheck   HealthCheckMode
+	HealthTimeout time.Duration
+}
+
+// healthChecker returns the state (including health) of a unit.
+type healthChecker func(context.Context, scheduler.Unit) (scheduler.UnitState, error)
+
+// createHealthChecker creates a health checker for the configured health check mode.
+func (d *Deployment) createHealthChecker(s scheduler.Scheduler, ui *stateUI) healthChecker {
+	if d.HealthCheck == HealthCheckHTTP {
+		if hc, ok := s.(scheduler.HTTPHealthChecker); ok {
+			return hc.CheckHTTPHealth
+		}
+		ui.Warningf("Orchestrator does not support http health checks, using unit state instead.\n")
+	}
+	return s.GetState
+}
+
+// waitUntilHealthy waits until all given units are healthy.
+// If that does not happen within the given timeout, an error is returned
+// that lists all units that are not healthy.
+func (d *Deployment) waitUntilHealthy(ctx context.Context, s scheduler.Scheduler, units []scheduler.Unit, timeout time.Duration, ui *stateUI) error {
+	check := d.createHealthChecker(s, ui)
+	deadline := time.Now().Add(timeout)
+	for {
+		unhealthy := make(map[string]string)
+		for _, u := range units {
+			state, err := check(ctx, u)
+			if scheduler.IsNotFound(err) {
+				unhealthy[u.Name()] = "not found"
+			} else if err != nil {
+				unhealthy[u.Name()] = err.Error()
+			} else if !state.Healthy {
+				unhealthy[u.Name()] = state.Message
+			}
+		}
+		if len(unhealthy) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			report := []string{"# Unit | State"}
+			for name, msg := range unhealthy {
+				report = append(report, fmt.Sprintf("# %s | %s", name, msg))
+			}
+			sort.Strings(report[1:])
+			formattedReport := strings.Replace(columnize.SimpleFormat(report), "#", " ", -1)
+			return maskAny(errgo.WithCausef(nil, UnhealthyError, "%d unit(s) did not become healthy within %s:\n%s", len(unhealthy), timeout, formattedReport))
+		}
+		ui.MessageSink <- fmt.Sprintf("Waiting for %d unit(s) to become healthy...", len(unhealthy))
+		select {
+		case <-ctx.Done():
+			return maskAny(ctx.Err())
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
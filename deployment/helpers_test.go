@@ -0,0 +1,345 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/cluster"
+	_ "github.com/pulcy/j2/engine/docker"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/render"
+	fleetrender "github.com/pulcy/j2/render/fleet"
+	"github.com/pulcy/j2/scheduler"
+	fleetscheduler "github.com/pulcy/j2/scheduler/fleet"
+)
+
+// testContext is a render context with fixed project & image names.
+type testContext struct{}
+
+func (testContext) ProjectName() string      { return "j2" }
+func (testContext) ProjectVersion() string   { return "test" }
+func (testContext) ProjectBuild() string     { return "test" }
+func (testContext) ImageVaultMonkey() string { return "pulcy/vault-monkey:latest" }
+func (testContext) ImageWormhole() string    { return "pulcy/wormhole:latest" }
+func (testContext) ImageAlpine() string      { return "alpine:3.4" }
+func (testContext) ImageCephVolume() string  { return "pulcy/ceph-volume:latest" }
+
+// testOrchestrator renders units (fleet units by default) and deploys them on a testScheduler.
+type testOrchestrator struct {
+	s        *testScheduler
+	provider render.RenderProvider
+}
+
+func (o testOrchestrator) RenderProvider() (render.RenderProvider, error) {
+	if o.provider != nil {
+		return o.provider, nil
+	}
+	return fleetrender.NewRenderProvider(), nil
+}
+
+func (o testOrchestrator) Scheduler(jobs.Job, cluster.Cluster) (scheduler.Scheduler, error) {
+	return o.s, nil
+}
+
+// testUnit is a unit on a testScheduler.
+type testUnit struct {
+	name    string
+	content string
+}
+
+func (u testUnit) Name() string    { return u.name }
+func (u testUnit) Content() string { return u.content }
+
+// testScheduler is an in-memory scheduler that names units like fleet does.
+// All units on it are healthy, except for the units listed in unhealthy.
+type testScheduler struct {
+	mutex       sync.Mutex
+	job         jobs.JobName
+	units       map[string]string // Content of the units on the cluster by name
+	unhealthy   map[string]int    // Number of state checks for which units are not healthy (-1 for all)
+	history     []scheduler.HistoryEntry
+	started     []string        // Names of all started units, in order
+	destroyed   []string        // Names of all destroyed units, in order
+	startErr    error           // Returned by Start, if set
+	failDestroy map[string]bool // Names of units that cannot be destroyed
+}
+
+// newTestScheduler creates a scheduler for the job with given name with the given units on the cluster.
+func newTestScheduler(job jobs.JobName, units ...scheduler.UnitData) *testScheduler {
+	s := &testScheduler{
+		job:         job,
+		units:       make(map[string]string),
+		unhealthy:   make(map[string]int),
+		failDestroy: make(map[string]bool),
+	}
+	for _, u := range units {
+		s.units[u.Name()] = u.Content()
+	}
+	return s
+}
+
+func (s *testScheduler) ValidateCluster(ctx context.Context) error { return nil }
+
+func (s *testScheduler) ConfigureCluster(ctx context.Context, config scheduler.ClusterConfig) error {
+	return nil
+}
+
+func (s *testScheduler) List(ctx context.Context) ([]scheduler.Unit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var names []string
+	for name := range s.units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []scheduler.Unit
+	for _, name := range names {
+		result = append(result, testUnit{name: name})
+	}
+	return result, nil
+}
+
+func (s *testScheduler) GetState(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.units[unit.Name()]; !ok {
+		return scheduler.UnitState{}, maskAny(scheduler.NotFoundError)
+	}
+	if n := s.unhealthy[unit.Name()]; n != 0 {
+		if n > 0 {
+			s.unhealthy[unit.Name()] = n - 1
+		}
+		return scheduler.UnitState{Message: "unhealthy"}, nil
+	}
+	return scheduler.UnitState{Healthy: true, Message: "running"}, nil
+}
+
+func (s *testScheduler) Cat(ctx context.Context, unit scheduler.Unit) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	content, ok := s.units[unit.Name()]
+	if !ok {
+		return "", maskAny(scheduler.NotFoundError)
+	}
+	return content, nil
+}
+
+func (s *testScheduler) GetCurrent(ctx context.Context, unit scheduler.Unit) (scheduler.UnitData, error) {
+	content, err := s.Cat(ctx, unit)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return testUnit{name: unit.Name(), content: content}, nil
+}
+
+func (s *testScheduler) NormalizeContent(unit scheduler.UnitData) (string, error) {
+	return unit.Content(), nil
+}
+
+func (s *testScheduler) HasChanged(ctx context.Context, unit scheduler.UnitData) ([]string, bool, error) {
+	content, err := s.Cat(ctx, unit)
+	if scheduler.IsNotFound(err) {
+		return nil, true, nil
+	} else if err != nil {
+		return nil, false, maskAny(err)
+	}
+	if content != unit.Content() {
+		return []string{"content"}, true, nil
+	}
+	return nil, false, nil
+}
+
+func (s *testScheduler) Stop(ctx context.Context, events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) (scheduler.StopStats, error) {
+	return scheduler.StopStats{StoppedUnits: len(units)}, nil
+}
+
+func (s *testScheduler) Destroy(ctx context.Context, events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, u := range units {
+		if s.failDestroy[u.Name()] {
+			return maskAny(errgo.Newf("cannot destroy %s", u.Name()))
+		}
+		delete(s.units, u.Name())
+		s.destroyed = append(s.destroyed, u.Name())
+	}
+	return nil
+}
+
+func (s *testScheduler) Start(ctx context.Context, events chan scheduler.Event, units scheduler.UnitDataList) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.startErr != nil {
+		return maskAny(s.startErr)
+	}
+	for i := 0; i < units.Len(); i++ {
+		u := units.Get(i)
+		s.units[u.Name()] = u.Content()
+		s.started = append(s.started, u.Name())
+	}
+	return nil
+}
+
+func (s *testScheduler) IsUnitForScalingGroup(unit scheduler.Unit, scalingGroup uint) bool {
+	return fleetscheduler.IsUnitForScalingGroup(unit.Name(), s.job, scalingGroup)
+}
+
+func (s *testScheduler) IsUnitForJob(unit scheduler.Unit) bool {
+	return fleetscheduler.IsUnitForJob(unit.Name(), s.job)
+}
+
+func (s *testScheduler) IsUnitForTaskGroup(unit scheduler.Unit, g jobs.TaskGroupName) bool {
+	return fleetscheduler.IsUnitForTaskGroup(unit.Name(), s.job, g)
+}
+
+func (s *testScheduler) UpdateStopDelay(d time.Duration) time.Duration    { return 0 }
+func (s *testScheduler) UpdateDestroyDelay(d time.Duration) time.Duration { return 0 }
+
+func (s *testScheduler) Lock(ctx context.Context, holder string, ttl time.Duration, steal bool) (scheduler.Lease, error) {
+	return testLease{info: scheduler.NewLockInfo(holder, ttl)}, nil
+}
+
+func (s *testScheduler) AddHistory(ctx context.Context, entry scheduler.HistoryEntry) (scheduler.HistoryEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry.Revision = len(s.history) + 1
+	s.history = append(s.history, entry)
+	return entry, nil
+}
+
+func (s *testScheduler) History(ctx context.Context) ([]scheduler.HistoryEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]scheduler.HistoryEntry(nil), s.history...), nil
+}
+
+func (s *testScheduler) ParseUnit(name, content string) (scheduler.UnitData, error) {
+	return testUnit{name: name, content: content}, nil
+}
+
+func (s *testScheduler) GetCompletion(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
+	return s.GetState(ctx, unit)
+}
+
+func (s *testScheduler) Output(ctx context.Context, unit scheduler.Unit, lines int) (string, error) {
+	return "", nil
+}
+
+// unitNames returns the names of all units on the cluster, sorted.
+func (s *testScheduler) unitNames() []string {
+	units, _ := s.List(context.Background())
+	var result []string
+	for _, u := range units {
+		result = append(result, u.Name())
+	}
+	return result
+}
+
+// testLease is a lease of a testScheduler.
+type testLease struct {
+	info scheduler.LockInfo
+}
+
+func (l testLease) Info() scheduler.LockInfo          { return l.info }
+func (l testLease) Stolen() *scheduler.LockInfo       { return nil }
+func (l testLease) Renew(ctx context.Context) error   { return nil }
+func (l testLease) Release(ctx context.Context) error { return nil }
+
+// testEvents collects all events of a command.
+type testEvents struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (e *testEvents) Event(evt Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.events = append(e.events, evt)
+}
+
+// ofType returns the messages of all collected events of the given type.
+func (e *testEvents) ofType(t EventType) []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var result []string
+	for _, evt := range e.events {
+		if evt.Type == t {
+			result = append(result, evt.Message)
+		}
+	}
+	return result
+}
+
+// parseTestJob parses the given job source, using a cluster with a single instance.
+func parseTestJob(t *testing.T, src string) (jobs.Job, cluster.Cluster) {
+	f, err := ioutil.TempFile("", "j2-test")
+	if err != nil {
+		t.Fatalf("Cannot create job file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(src); err != nil {
+		t.Fatalf("Cannot write job file: %v", err)
+	}
+	f.Close()
+	cl := cluster.New("example.com", "test", 1)
+	r := fleetrender.NewRenderProvider().CreateRenderer(cl)
+	job, err := jobs.ParseJobFromFileOffline(f.Name(), cl, r, fg.Options{}, nil, false)
+	if err != nil {
+		t.Fatalf("Cannot parse job: %v", err)
+	}
+	return *job, cl
+}
+
+// newTestDeployment creates a deployment of the given job source onto the given scheduler.
+// It does not wait between steps, does not ask questions and collects all events.
+func newTestDeployment(t *testing.T, src string, s *testScheduler) (*Deployment, *testEvents) {
+	job, cl := parseTestJob(t, src)
+	d, err := NewDeployment(testOrchestrator{s: s}, job, cl, nil, 0, false, true, false, DeploymentDelays{},
+		HealthCheckConfig{HealthTimeout: time.Second}, RolloutOptions{}, testContext{})
+	if err != nil {
+		t.Fatalf("Cannot create deployment: %v", err)
+	}
+	events := &testEvents{}
+	d.Events = events
+	return d, events
+}
+
+// useTempHome points the home directory (where progress is recorded) to a new temporary directory.
+// The returned function removes that directory and restores the home directory.
+func useTempHome(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "j2-home")
+	if err != nil {
+		t.Fatalf("Cannot create home directory: %v", err)
+	}
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	homedir.DisableCache = true
+	return func() {
+		os.Setenv("HOME", home)
+		homedir.DisableCache = false
+		os.RemoveAll(dir)
+	}
+}
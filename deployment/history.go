@@ -0,0 +1,184 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/render"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// History returns the deployment history of the configured job, oldest entry first.
+func (d *Deployment) History(ctx context.Context) ([]scheduler.HistoryEntry, error) {
+	s, err := d.getScheduler()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	entries, err := s.History(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return entries, nil
+}
+
+// Rollback redeploys the units recorded in the given revision of the deployment history
+// of the configured job, using the same rolling update as `Run`.
+// The job, task groups & scaling group that were recorded in that revision are used,
+// instead of the configured job & selection.
+func (d *Deployment) Rollback(ctx context.Context, revision int) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	unlock, err := d.lockJob(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer unlock()
+
+	entries, err := d.History(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	var entry *scheduler.HistoryEntry
+	for i := range entries {
+		if entries[i].Revision == revision {
+			entry = &entries[i]
+		}
+	}
+	if entry == nil {
+		return maskAny(errgo.WithCausef(nil, RevisionNotFoundError, "job '%s' has no revision %d", d.job.Name, revision))
+	}
+	// Hooks, notifications & blue/green groups are taken from the job of that revision
+	job, err := jobs.ParseJobFromJSON(entry.Job)
+	if err != nil {
+		return maskAny(errgo.Notef(err, "cannot read job of revision %d", revision))
+	}
+	d.job = *job
+	d.groupSelection = nil
+	for _, g := range entry.Groups {
+		d.groupSelection = append(d.groupSelection, jobs.TaskGroupName(g))
+	}
+	d.scalingGroupSelection = ScalingGroupSelection(entry.ScalingGroup)
+	d.rollbackOf = entry
+
+	s, loadedJobUnits, err := d.loadJobUnits(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Blue/green groups are deployed in their recorded color
+	d.blueGreen = blueGreenUnits{colors: make(map[jobs.TaskGroupName]jobs.Color)}
+	for _, tg := range d.blueGreenGroups() {
+		if color, found := entry.Colors[tg.Name.String()]; found {
+			if err := d.setColor(tg, jobs.Color(color)); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+
+	// Use the recorded units instead of rendering them
+	d.scalingGroups = nil
+	for _, hu := range entry.Units {
+		u, err := s.ParseUnit(hu.Name, hu.Content)
+		if err != nil {
+			return maskAny(err)
+		}
+		if hu.ScalingGroup == 0 {
+			d.blueGreen.frontends.units = append(d.blueGreen.frontends.units, u)
+			continue
+		}
+		if n := len(d.scalingGroups); n == 0 || d.scalingGroups[n-1].scalingGroup != hu.ScalingGroup {
+			d.scalingGroups = append(d.scalingGroups, scalingGroupUnits{scalingGroup: hu.ScalingGroup})
+		}
+		sgu := &d.scalingGroups[len(d.scalingGroups)-1]
+		sgu.units = append(sgu.units, u)
+	}
+
+	plan, err := d.createPlan(ctx, s, loadedJobUnits, ui, false)
+	if err != nil {
+		return maskAny(err)
+	}
+	ui.Clear()
+
+	if err := d.executePlan(ctx, s, loadedJobUnits, plan, ui, true, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// recordHistory stores the deployed units in the deployment history of the job.
+// Failures are reported as a warning only, since the deployment itself succeeded.
+func (d *Deployment) recordHistory(ctx context.Context, s scheduler.Scheduler, ui *stateUI) {
+	entry, err := d.historyEntry()
+	if err == nil {
+		entry, err = s.AddHistory(ctx, entry)
+	}
+	if err != nil {
+		ui.Warningf("Warning: failed to record deployment history: %v", err)
+		return
+	}
+	ui.Verbosef("Recorded revision %d of job '%s'", entry.Revision, d.job.Name)
+}
+
+// historyEntry creates a history entry describing the deployment of the generated units.
+func (d *Deployment) historyEntry() (scheduler.HistoryEntry, error) {
+	entry := scheduler.HistoryEntry{
+		Created:      time.Now(),
+		Operator:     currentOperator(),
+		ScalingGroup: uint(d.scalingGroupSelection),
+	}
+	if d.rollbackOf != nil {
+		// The units of a rollback are rendered from the job of the rolled back revision
+		entry.Version = d.rollbackOf.Version
+		entry.RollbackOf = d.rollbackOf.Revision
+		entry.Job = d.rollbackOf.Job
+	} else {
+		raw, err := d.job.Json()
+		if err != nil {
+			return scheduler.HistoryEntry{}, maskAny(err)
+		}
+		entry.Version = d.renderContext.ProjectVersion()
+		entry.Job = raw
+	}
+	for _, g := range d.groupSelection {
+		entry.Groups = append(entry.Groups, g.String())
+	}
+	if len(d.blueGreen.colors) > 0 {
+		entry.Colors = make(map[string]string)
+		for name, color := range d.blueGreen.colors {
+			entry.Colors[name.String()] = string(color)
+		}
+	}
+	addUnits := func(scalingGroup uint, units []render.UnitData) {
+		for _, u := range units {
+			entry.Units = append(entry.Units, scheduler.HistoryUnit{
+				Name:         u.Name(),
+				ScalingGroup: scalingGroup,
+				Hash:         contentHash(u.Content()),
+				Content:      u.Content(),
+			})
+		}
+	}
+	for _, sgu := range d.scalingGroups {
+		addUnits(sgu.scalingGroup, sgu.units)
+	}
+	addUnits(0, d.blueGreen.frontends.units)
+	return entry, nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+)
+
+// hookedTestJob is blueGreenTestJob with a newer web image and a pre-deploy hook.
+var hookedTestJob = strings.Replace(strings.Replace(blueGreenTestJob, "nginx:1.11", "nginx:1.12", 1),
+	`job "shop" {`, `job "shop" {
+	hook "pre-deploy" {
+		image = "shop-migrations:1.0"
+	}`, 1)
+
+// runTestJob deploys the given job source onto the given scheduler.
+func runTestJob(t *testing.T, src string, s *testScheduler) {
+	d, _ := newTestDeployment(t, src, s)
+	if err := d.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestHistoryEntry(t *testing.T) {
+	defer useTempHome(t)()
+	s := newTestScheduler("shop")
+	runTestJob(t, blueGreenTestJob, s)
+	if len(s.history) != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", len(s.history))
+	}
+	entry := s.history[0]
+
+	if entry.Revision != 1 || entry.Version != "test" || entry.RollbackOf != 0 || entry.Operator == "" {
+		t.Errorf("Unexpected revision, version, rollback or operator in %#v", entry)
+	}
+	if len(entry.Groups) != 0 || entry.ScalingGroup != 0 {
+		t.Errorf("Expected all groups & scaling groups, got %v & %d", entry.Groups, entry.ScalingGroup)
+	}
+	if expected := map[string]string{"web": "blue"}; !reflect.DeepEqual(entry.Colors, expected) {
+		t.Errorf("Expected colors %v, got %v", expected, entry.Colors)
+	}
+	job, err := jobs.ParseJobFromJSON(entry.Job)
+	if err != nil {
+		t.Fatalf("Cannot parse recorded job: %v", err)
+	}
+	if job.Name != "shop" || len(job.Groups) != 2 {
+		t.Errorf("Unexpected recorded job %s", entry.Job)
+	}
+
+	expectedUnits := []struct {
+		Name         string
+		ScalingGroup uint
+	}{
+		{"shop-db-db-mn@1.service", 1},
+		{"shop-web-blue-web-mn@1.service", 1},
+		{"shop-web-blue-web-mn@2.service", 2},
+		{"shop-web-blue-web-fe@1.service", 0},
+		{"shop-web-blue-web-fe@2.service", 0},
+	}
+	if len(entry.Units) != len(expectedUnits) {
+		t.Fatalf("Expected %d units, got %d", len(expectedUnits), len(entry.Units))
+	}
+	for i, expected := range expectedUnits {
+		hu := entry.Units[i]
+		if hu.Name != expected.Name || hu.ScalingGroup != expected.ScalingGroup {
+			t.Errorf("Expected unit %s in scaling group %d, got %s in %d", expected.Name, expected.ScalingGroup, hu.Name, hu.ScalingGroup)
+		}
+		if hu.Content != s.units[hu.Name] {
+			t.Errorf("Recorded content of %s differs from deployed content", hu.Name)
+		}
+		if hu.Hash != contentHash(hu.Content) {
+			t.Errorf("Unexpected hash of %s: %s", hu.Name, hu.Hash)
+		}
+	}
+}
+
+func TestHistoryEntryOfRollback(t *testing.T) {
+	d, _ := newTestDeployment(t, hookedTestJob, newTestScheduler("shop"))
+	d.groupSelection = TaskGroupSelection{"web"}
+	d.scalingGroupSelection = 2
+	d.rollbackOf = &scheduler.HistoryEntry{Revision: 3, Version: "old", Job: []byte(`{"name":"shop"}`)}
+	entry, err := d.historyEntry()
+	if err != nil {
+		t.Fatalf("Cannot create history entry: %v", err)
+	}
+	if entry.Version != "old" || entry.RollbackOf != 3 || string(entry.Job) != `{"name":"shop"}` {
+		t.Errorf("Expected version, job & rollback of revision 3, got %#v", entry)
+	}
+	if !reflect.DeepEqual(entry.Groups, []string{"web"}) || entry.ScalingGroup != 2 {
+		t.Errorf("Expected group web & scaling group 2, got %v & %d", entry.Groups, entry.ScalingGroup)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	defer useTempHome(t)()
+	s := newTestScheduler("shop")
+	runTestJob(t, blueGreenTestJob, s)
+	runTestJob(t, hookedTestJob, s)
+	if len(s.history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(s.history))
+	}
+	first := s.history[0]
+
+	// Unknown revisions are refused
+	d, _ := newTestDeployment(t, hookedTestJob, s)
+	if err := d.Rollback(context.Background(), 7); errgo.Cause(err) != RevisionNotFoundError {
+		t.Errorf("Expected RevisionNotFoundError, got %v", err)
+	}
+
+	// The rollback is made by someone with the current job file, which has a hook that revision 1 lacks
+	s.started = nil
+	d, _ = newTestDeployment(t, hookedTestJob, s)
+	if err := d.Rollback(context.Background(), 1); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if len(d.job.Hooks) != 0 {
+		t.Errorf("Expected job of revision 1 without hooks, got %d hook(s)", len(d.job.Hooks))
+	}
+	for _, name := range s.started {
+		if !strings.HasPrefix(name, "shop-db-") && !strings.HasPrefix(name, "shop-web-") {
+			t.Errorf("Unexpected unit %s started by rollback", name)
+		}
+	}
+	for _, hu := range first.Units {
+		if content, ok := s.units[hu.Name]; !ok {
+			t.Errorf("Expected %s on the cluster after rollback", hu.Name)
+		} else if content != hu.Content {
+			t.Errorf("Expected content of revision 1 for %s after rollback", hu.Name)
+		}
+	}
+	for _, name := range []string{"shop-web-green-web-fe@1.service", "shop-web-green-web-fe@2.service"} {
+		if _, ok := s.units[name]; ok {
+			t.Errorf("Expected %s to be removed by rollback", name)
+		}
+	}
+
+	if len(s.history) != 3 {
+		t.Fatalf("Expected 3 history entries, got %d", len(s.history))
+	}
+	last := s.history[2]
+	if last.RollbackOf != 1 || string(last.Job) != string(first.Job) || !reflect.DeepEqual(last.Colors, first.Colors) {
+		t.Errorf("Expected rollback of revision 1 with its job & colors, got %#v", last)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/ryanuber/columnize"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+const (
+	hookOutputLines = 20 // Number of lines of output of a hook shown to the user
+)
+
+// runHooks runs the hooks of the given type of the job and of all selected groups, one after another.
+// An error is returned as soon as one of them fails.
+func (d *Deployment) runHooks(ctx context.Context, s scheduler.Scheduler, ht jobs.HookType, ui *stateUI) error {
+	for _, tg := range d.job.HooksOf(ht, d.groupSelection.Includes) {
+		if err := d.runHook(ctx, s, tg, ui); err != nil {
+			return maskAny(err)
+		}
+		ui.Clear()
+	}
+	return nil
+}
+
+// runHook starts the units of the given hook and waits until they have completed.
+// The tail of the output of the hook is shown to the user.
+// Afterwards the units are removed, so the hook runs anew on the next deployment.
+func (d *Deployment) runHook(ctx context.Context, s scheduler.Scheduler, tg *jobs.TaskGroup, ui *stateUI) error {
+	header := fmt.Sprintf("Running %s hook of %s on '%s'.\n", tg.Hook, tg.HookOwner(), d.cluster.Stack)
+	ui.HeaderSink <- header
+
+	job := d.job
+	job.Groups = jobs.TaskGroupList{tg}
+	rendered, err := d.renderJobUnits(job, 1, nil, false)
+	if err != nil {
+		return maskAny(err)
+	}
+	units := scalingGroupUnits{scalingGroup: 1, units: rendered}
+
+	// Remove what is left of an earlier run
+	var existing []scheduler.Unit
+	for _, u := range units.Units() {
+		if _, err := s.GetState(ctx, u); err == nil {
+			existing = append(existing, u)
+		}
+	}
+	if err := d.destroyUnits(ctx, s, nil, nil, existing, ui); err != nil {
+		return maskAny(err)
+	}
+
+	if err := launchUnits(ctx, s, units, ui); err != nil {
+		return maskAny(err)
+	}
+	waitErr := d.waitUntilCompleted(ctx, s, units.Units(), tg.HookTimeoutDuration(), ui)
+
+	// Show the output of the hook
+	var output []string
+	for _, u := range units.Units() {
+		text, err := s.Output(ctx, u, hookOutputLines)
+		if err != nil {
+			ui.Warningf("Cannot fetch output of %s: %v\n", u.Name(), err)
+		} else if text = strings.TrimSpace(text); text != "" {
+			output = append(output, text)
+		}
+	}
+	if len(output) > 0 {
+		ui.HeaderSink <- fmt.Sprintf("%s\n%s\n", header, strings.Join(output, "\n"))
+	}
+
+	if err := d.destroyUnits(ctx, s, nil, nil, units.Units(), ui); err != nil {
+		ui.Warningf("Failed to remove units of %s hook: %v\n", tg.Hook, err)
+	}
+	if waitErr != nil {
+		return maskAny(errgo.WithCausef(nil, HookFailedError, "%s hook of %s failed: %s", tg.Hook, tg.HookOwner(), waitErr.Error()))
+	}
+	return nil
+}
+
+// waitUntilCompleted waits until all given units have completed successfully.
+// An error is returned when one of them fails, or when they have not completed
+// within the given timeout.
+func (d *Deployment) waitUntilCompleted(ctx context.Context, s scheduler.Scheduler, units []scheduler.Unit, timeout time.Duration, ui *stateUI) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := make(map[string]string)
+		for _, u := range units {
+			state, err := s.GetCompletion(ctx, u)
+			if scheduler.IsNotFound(err) {
+				pending[u.Name()] = "not found"
+			} else if err != nil {
+				pending[u.Name()] = err.Error()
+			} else if state.Failed {
+				return maskAny(fmt.Errorf("%s failed: %s", u.Name(), state.Message))
+			} else if !state.Healthy {
+				pending[u.Name()] = state.Message
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			report := []string{"# Unit | State"}
+			for name, msg := range pending {
+				report = append(report, fmt.Sprintf("# %s | %s", name, msg))
+			}
+			sort.Strings(report[1:])
+			formattedReport := strings.Replace(columnize.SimpleFormat(report), "#", " ", -1)
+			return maskAny(fmt.Errorf("%d unit(s) did not complete within %s:\n%s", len(pending), timeout, formattedReport))
+		}
+		ui.MessageSink <- fmt.Sprintf("Waiting for %d unit(s) to complete...", len(pending))
+		select {
+		case <-ctx.Done():
+			return maskAny(ctx.Err())
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
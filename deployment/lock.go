@@ -0,0 +1,94 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+const (
+	// lockTTL is the time after which the deployment lock of a job expires when its holder
+	// stops renewing it (e.g. because it crashed).
+	lockTTL = time.Minute
+)
+
+// lockJob acquires the deployment lock of the configured job, to prevent concurrent
+// modifications of the job by others.
+// The lock is renewed in the background until the returned function is called.
+func (d *Deployment) lockJob(ctx context.Context, ui *stateUI) (func(), error) {
+	s, err := d.getScheduler()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	lease, err := s.Lock(ctx, currentOperator(), lockTTL, d.StealLock)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if stolen := lease.Stolen(); stolen != nil {
+		ui.Warningf("Took over the lock of job '%s' from %s", d.job.Name, stolen)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lease.Renew(ctx); scheduler.IsLockLost(err) {
+					ui.Warningf("Warning: lost the lock of job '%s': %v", d.job.Name, err)
+					return
+				} else if err != nil {
+					ui.Verbosef("Failed to renew the lock of job '%s': %v", d.job.Name, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		// Release the lock, even when the deployment has been canceled.
+		if err := lease.Release(context.Background()); err != nil {
+			ui.Warningf("Warning: failed to release the lock of job '%s': %v", d.job.Name, err)
+		}
+	}, nil
+}
+
+// currentOperator returns a description of the current user & host, used to identify the holder
+// of a lock and the operator of a deployment.
+func currentOperator() string {
+	userName := "unknown"
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	hostName, err := os.Hostname()
+	if err != nil {
+		hostName = "unknown"
+	}
+	return fmt.Sprintf("%s@%s", userName, hostName)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/jobs"
+)
+
+const (
+	// notifyTimeout is the maximum time spent delivering a single event to a webhook (including retries).
+	notifyTimeout = time.Minute
+	// webhookRequestTimeout is the maximum duration of a single request to a webhook.
+	webhookRequestTimeout = 10 * time.Second
+	// notifyQueueSize is the maximum number of deliveries waiting in a notify queue.
+	notifyQueueSize = 64
+	// notifyFlushTimeout is the maximum time to wait for queued deliveries at the end of a deployment.
+	notifyFlushTimeout = 2 * time.Minute
+)
+
+// NotifyEvent is the payload of a lifecycle event of a deployment, posted to webhooks.
+// Webhook templates are executed with a NotifyEvent as data.
+type NotifyEvent struct {
+	Event        string       `json:"event"` // start, step, failure or finish
+	Job          jobs.JobName `json:"job"`
+	Stack        string       `json:"stack"`
+	Operator     string       `json:"operator"` // user@host
+	Time         time.Time    `json:"time"`
+	Step         int          `json:"step,omitempty"`          // Number of the completed step (step only)
+	ScalingGroup uint         `json:"scaling-group,omitempty"` // Scaling group of the completed step (step only)
+	Units        []string     `json:"units"`                   // Names of the units changed by the deployment (or step)
+	Duration     string       `json:"duration"`                // Time since the start of the deployment
+	Error        string       `json:"error,omitempty"`         // Cause of the failure (failure only)
+}
+
+// webhooks returns the webhooks of the configured cluster, overridden by those of the configured job.
+func (d *Deployment) webhooks() []cluster.Webhook {
+	n := d.cluster.Notify
+	if d.job.Notify != nil {
+		n = n.Merge(*d.job.Notify)
+	}
+	return n.Webhooks
+}
+
+// notify posts the given event to all webhooks that want to receive it.
+// While a plan is executed, the event is queued and delivered in the background,
+// so webhooks never stall the deployment.
+// A failure to deliver the event never fails the deployment, it results in a warning.
+func (d *Deployment) notify(evt NotifyEvent, ui *stateUI) {
+	var webhooks []cluster.Webhook
+	for _, w := range d.webhooks() {
+		if w.Wants(evt.Event) {
+			webhooks = append(webhooks, w)
+		}
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	evt.Job = d.job.Name
+	evt.Stack = d.cluster.Stack
+	evt.Operator = currentOperator()
+	evt.Time = time.Now()
+	evt.Duration = evt.Time.Sub(d.started).Round(time.Second).String()
+	if evt.Units == nil {
+		evt.Units = []string{}
+	}
+	for _, w := range webhooks {
+		if d.notifications != nil {
+			d.notifications.add(w, evt)
+		} else {
+			deliverWebhook(w, evt, ui)
+		}
+	}
+}
+
+// deliverWebhook posts the given event to the given webhook, reporting a failure as warning.
+func deliverWebhook(w cluster.Webhook, evt NotifyEvent, ui *stateUI) {
+	// Deliver even when the deployment has been canceled
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := postWebhook(ctx, w, evt); err != nil {
+		ui.Warningf("Failed to notify webhook '%s' of %s event: %v", w.Name, evt.Event, err)
+	} else {
+		ui.Verbosef("Notified webhook '%s' of %s event", w.Name, evt.Event)
+	}
+}
+
+// notifyQueue delivers events to webhooks in the background, in the order in which they are added.
+type notifyQueue struct {
+	ui         *stateUI
+	mutex      sync.Mutex
+	closed     bool
+	deliveries chan webhookDelivery
+	done       chan struct{}
+}
+
+// webhookDelivery is an event waiting to be posted to a webhook.
+type webhookDelivery struct {
+	webhook cluster.Webhook
+	evt     NotifyEvent
+}
+
+// newNotifyQueue creates a notify queue and starts delivering its events.
+// Failed deliveries are reported as warnings on the given UI.
+func newNotifyQueue(ui *stateUI) *notifyQueue {
+	q := &notifyQueue{
+		ui:         ui,
+		deliveries: make(chan webhookDelivery, notifyQueueSize),
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+		for dl := range q.deliveries {
+			deliverWebhook(dl.webhook, dl.evt, q.ui)
+		}
+	}()
+	return q
+}
+
+// add queues the given event for delivery to the given webhook.
+// The event is dropped (with a warning) when the queue is full.
+func (q *notifyQueue) add(w cluster.Webhook, evt NotifyEvent) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.deliveries <- webhookDelivery{webhook: w, evt: evt}:
+	default:
+		q.ui.Warningf("Too many pending notifications, dropped %s event for webhook '%s'", evt.Event, w.Name)
+	}
+}
+
+// flush waits until all queued events have been delivered (at most notifyFlushTimeout).
+// No events can be added afterwards.
+func (q *notifyQueue) flush() {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.deliveries)
+	}
+	q.mutex.Unlock()
+	select {
+	case <-q.done:
+	case <-time.After(notifyFlushTimeout):
+		q.ui.Warningf("Not all webhooks have been notified within %s", notifyFlushTimeout)
+	}
+}
+
+// postWebhook posts the given event to the given webhook, retrying failed deliveries
+// as configured in the webhook.
+func postWebhook(ctx context.Context, w cluster.Webhook, evt NotifyEvent) error {
+	var body []byte
+	if w.Template == "" {
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return maskAny(err)
+		}
+		body = data
+	} else {
+		t, err := template.New(w.Name).Funcs(cluster.WebhookTemplateFuncs).Parse(w.Template)
+		if err != nil {
+			return maskAny(err)
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, evt); err != nil {
+			return maskAny(err)
+		}
+		body = buf.Bytes()
+	}
+	contentType := w.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	client := &http.Client{Timeout: webhookRequestTimeout}
+	delay := w.RetryDelayDuration()
+	retries := w.RetryCount()
+	for attempt := 0; ; attempt++ {
+		err := postWebhookOnce(ctx, client, w, evt.Event, contentType, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return maskAny(err)
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// postWebhookOnce performs a single request to the given webhook.
+func postWebhookOnce(ctx context.Context, client *http.Client, w cluster.Webhook, event, contentType string, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return maskAny(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-J2-Event", event)
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-J2-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return maskAny(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return maskAny(fmt.Errorf("unexpected status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// changedUnitNames returns the names of all units that are changed by the given plan.
+func changedUnitNames(plan Plan) []string {
+	changed := []PlanAction{PlanActionCreate, PlanActionUpdate, PlanActionRestart, PlanActionRemove}
+	var result []string
+	for _, sgp := range plan.ScalingGroups {
+		result = append(result, sgp.unitNames(changed...)...)
+	}
+	result = append(result, unitPlanNames(plan.Frontends, changed...)...)
+	result = append(result, unitPlanNames(plan.Cleanup, changed...)...)
+	return result
+}
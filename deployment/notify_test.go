@@ -0,0 +1,277 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/cluster"
+)
+
+// webhookRequest is a request received by a webhookServer.
+type webhookRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+}
+
+// webhookServer records the requests posted to it.
+// The first `failures` requests are answered with an internal server error.
+type webhookServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	failures int
+	requests []webhookRequest
+}
+
+func newWebhookServer(failures int) *webhookServer {
+	ws := &webhookServer{failures: failures}
+	ws.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ws.mutex.Lock()
+		defer ws.mutex.Unlock()
+		ws.requests = append(ws.requests, webhookRequest{Path: r.URL.Path, Header: r.Header, Body: body, Time: time.Now()})
+		if len(ws.requests) <= ws.failures {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	return ws
+}
+
+// received returns a copy of the requests received so far.
+func (ws *webhookServer) received() []webhookRequest {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	return append([]webhookRequest(nil), ws.requests...)
+}
+
+// events returns the events of all received requests with the given path.
+func (ws *webhookServer) events(path string) []string {
+	var result []string
+	for _, r := range ws.received() {
+		if r.Path == path {
+			result = append(result, r.Header.Get("X-J2-Event"))
+		}
+	}
+	return result
+}
+
+func retries(n int) *int { return &n }
+
+func TestPostWebhookSignature(t *testing.T) {
+	ws := newWebhookServer(0)
+	defer ws.Close()
+
+	evt := NotifyEvent{Event: cluster.NotifyEventStart, Job: "shop", Stack: "test", Units: []string{"shop-web-web-mn@1.service"}}
+	if err := postWebhook(context.Background(), cluster.Webhook{Name: "signed", URL: ws.URL, Secret: "s3cret"}, evt); err != nil {
+		t.Fatalf("postWebhook failed: %v", err)
+	}
+	template := `{"text": "{{.Job}} {{.Event}}"}`
+	if err := postWebhook(context.Background(), cluster.Webhook{Name: "unsigned", URL: ws.URL, Template: template, ContentType: "text/plain"}, evt); err != nil {
+		t.Fatalf("postWebhook failed: %v", err)
+	}
+	requests := ws.received()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+
+	signed := requests[0]
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(signed.Body)
+	if sig, expected := signed.Header.Get("X-J2-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); sig != expected {
+		t.Errorf("Expected signature '%s', got '%s'", expected, sig)
+	}
+	if ct := signed.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected content type application/json, got '%s'", ct)
+	}
+	if event := signed.Header.Get("X-J2-Event"); event != cluster.NotifyEventStart {
+		t.Errorf("Expected event header '%s', got '%s'", cluster.NotifyEventStart, event)
+	}
+	var posted NotifyEvent
+	if err := json.Unmarshal(signed.Body, &posted); err != nil {
+		t.Errorf("Cannot decode posted event: %v", err)
+	} else if !reflect.DeepEqual(posted, evt) {
+		t.Errorf("Expected posted event %#v, got %#v", evt, posted)
+	}
+
+	unsigned := requests[1]
+	if sig := unsigned.Header.Get("X-J2-Signature"); sig != "" {
+		t.Errorf("Expected no signature without secret, got '%s'", sig)
+	}
+	if ct := unsigned.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("Expected content type text/plain, got '%s'", ct)
+	}
+	if body := string(unsigned.Body); body != `{"text": "shop start"}` {
+		t.Errorf("Unexpected templated body '%s'", body)
+	}
+}
+
+func TestPostWebhookRetry(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Failures int
+		Retries  int
+		Requests int
+		Error    bool
+	}{
+		{Name: "no failures", Failures: 0, Retries: 3, Requests: 1},
+		{Name: "recovered", Failures: 2, Retries: 3, Requests: 3},
+		{Name: "exhausted", Failures: 5, Retries: 2, Requests: 3, Error: true},
+		{Name: "no retries", Failures: 1, Retries: 0, Requests: 1, Error: true},
+	}
+	delay := 20 * time.Millisecond
+	for _, test := range tests {
+		ws := newWebhookServer(test.Failures)
+		w := cluster.Webhook{Name: "retry", URL: ws.URL, Retries: retries(test.Retries), RetryDelay: delay.String()}
+		err := postWebhook(context.Background(), w, NotifyEvent{Event: cluster.NotifyEventFinish})
+		ws.Close()
+		if test.Error && err == nil {
+			t.Errorf("%s: expected an error", test.Name)
+		} else if !test.Error && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.Name, err)
+		}
+		requests := ws.received()
+		if len(requests) != test.Requests {
+			t.Errorf("%s: expected %d requests, got %d", test.Name, test.Requests, len(requests))
+			continue
+		}
+		// The delay doubles after every retry
+		expected := delay
+		for i := 1; i < len(requests); i++ {
+			if waited := requests[i].Time.Sub(requests[i-1].Time); waited < expected {
+				t.Errorf("%s: expected retry %d after at least %s, got %s", test.Name, i, expected, waited)
+			}
+			expected *= 2
+		}
+	}
+}
+
+func TestPostWebhookCanceled(t *testing.T) {
+	ws := newWebhookServer(10)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	w := cluster.Webhook{Name: "canceled", URL: ws.URL, Retries: retries(10), RetryDelay: "1s"}
+	start := time.Now()
+	if err := postWebhook(ctx, w, NotifyEvent{Event: cluster.NotifyEventFinish}); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected retries to stop when the context is done, took %s", elapsed)
+	}
+}
+
+func TestNotifyEventFilter(t *testing.T) {
+	ws := newWebhookServer(0)
+	defer ws.Close()
+
+	d, _ := newTestDeployment(t, progressTestJob, newTestScheduler("shop"))
+	d.cluster.Notify = cluster.Notify{Webhooks: []cluster.Webhook{
+		{Name: "all", URL: ws.URL + "/all"},
+		{Name: "failures", URL: ws.URL + "/failures", Events: []string{cluster.NotifyEventFailure}},
+		{Name: "disabled-by-job", URL: ws.URL + "/disabled"},
+	}}
+	d.job.Notify = &cluster.Notify{Webhooks: []cluster.Webhook{
+		{Name: "disabled-by-job", Disabled: true},
+		{Name: "job", URL: ws.URL + "/job", Events: []string{cluster.NotifyEventStart, cluster.NotifyEventFinish}},
+	}}
+	ui := d.newUI()
+	for _, event := range []string{cluster.NotifyEventStart, cluster.NotifyEventStep, cluster.NotifyEventFailure, cluster.NotifyEventFinish} {
+		d.notify(NotifyEvent{Event: event}, ui)
+	}
+	ui.Close()
+
+	expected := map[string][]string{
+		"/all":      {cluster.NotifyEventStart, cluster.NotifyEventStep, cluster.NotifyEventFailure, cluster.NotifyEventFinish},
+		"/failures": {cluster.NotifyEventFailure},
+		"/disabled": nil,
+		"/job":      {cluster.NotifyEventStart, cluster.NotifyEventFinish},
+	}
+	for path, events := range expected {
+		if received := ws.events(path); !reflect.DeepEqual(received, events) {
+			t.Errorf("Expected events %v on %s, got %v", events, path, received)
+		}
+	}
+}
+
+func TestNotifyQueue(t *testing.T) {
+	ws := newWebhookServer(0)
+	defer ws.Close()
+
+	d, events := newTestDeployment(t, progressTestJob, newTestScheduler("shop"))
+	ui := d.newUI()
+	q := newNotifyQueue(ui)
+	w := cluster.Webhook{Name: "queued", URL: ws.URL}
+	for _, event := range []string{cluster.NotifyEventStart, cluster.NotifyEventStep, cluster.NotifyEventFinish} {
+		q.add(w, NotifyEvent{Event: event})
+	}
+	q.flush()
+	// Events added after a flush are dropped
+	q.add(w, NotifyEvent{Event: cluster.NotifyEventFailure})
+	ui.Close()
+
+	if received, expected := ws.events("/"), []string{cluster.NotifyEventStart, cluster.NotifyEventStep, cluster.NotifyEventFinish}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected events %v in order, got %v", expected, received)
+	}
+	if warnings := events.ofType(EventWarning); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %q", warnings)
+	}
+}
+
+func TestNotifyFailureIsWarning(t *testing.T) {
+	defer useTempHome(t)()
+	ws := newWebhookServer(100)
+	defer ws.Close()
+
+	s := newTestScheduler("shop")
+	d, events := newTestDeployment(t, progressTestJob, s)
+	d.cluster.Notify = cluster.Notify{Webhooks: []cluster.Webhook{
+		{Name: "broken", URL: ws.URL, Events: []string{cluster.NotifyEventStart, cluster.NotifyEventFinish}, Retries: retries(0)},
+	}}
+	if err := d.Run(context.Background()); err != nil {
+		t.Fatalf("Expected deployment to succeed when notifications fail, got %v", err)
+	}
+	if len(s.started) != 2 {
+		t.Errorf("Expected 2 started units, got %v", s.started)
+	}
+	warnings := events.ofType(EventWarning)
+	for _, event := range []string{cluster.NotifyEventStart, cluster.NotifyEventFinish} {
+		found := false
+		for _, w := range warnings {
+			found = found || strings.Contains(w, "Failed to notify webhook 'broken' of "+event+" event")
+		}
+		if !found {
+			t.Errorf("Expected a warning for the %s event, got %q", event, warnings)
+		}
+	}
+	if received := ws.events("/"); len(received) != 2 {
+		t.Errorf("Expected 2 delivery attempts, got %v", received)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// lane is a set of task groups that is updated independently of all other task groups.
+type lane struct {
+	groups []jobs.TaskGroupName
+	plan   Plan // Holds the scaling groups of the plan, limited to the units of the groups in this lane
+}
+
+// title returns the title of the progress section of this lane.
+func (l lane) title() string {
+	var names []string
+	for _, g := range l.groups {
+		names = append(names, g.String())
+	}
+	return fmt.Sprintf("Group(s) %s", strings.Join(names, ", "))
+}
+
+// createLanes splits the scaling groups of the given plan into lanes of task groups that
+// do not depend on each other.
+// It returns nil when all task groups must be updated together, which is also the case
+// when the plan contains units that do not belong to any of the selected task groups.
+func (d *Deployment) createLanes(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, plan Plan, canary *jobs.Canary, ui *stateUI) ([]lane, error) {
+	if d.ParallelGroups < 2 {
+		return nil, nil
+	}
+	if canary != nil {
+		ui.Warningf("Task groups are not updated in parallel when deploying a canary.\n")
+		return nil, nil
+	}
+
+	var groups []jobs.TaskGroupName
+	for _, tg := range d.job.Groups {
+		if d.groupSelection.Includes(tg.Name) {
+			groups = append(groups, tg.Name)
+		}
+	}
+	sets, err := d.job.IndependentGroups(groups)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if len(sets) < 2 {
+		return nil, nil
+	}
+
+	// Find the lane of every unit
+	laneOf := make(map[string]int)
+	for i, set := range sets {
+		for _, sg := range d.scalingGroups {
+			units, err := d.renderUnits(sg.scalingGroup, set, false)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			for _, u := range units {
+				laneOf[u.Name()] = i
+			}
+		}
+	}
+	for i, set := range sets {
+		for _, u := range loadedJobUnits {
+			if _, found := laneOf[u.Name()]; found {
+				continue
+			}
+			for _, g := range set {
+				if s.IsUnitForTaskGroup(u, g) {
+					laneOf[u.Name()] = i
+				}
+			}
+		}
+	}
+
+	// Split the scaling groups over the lanes
+	lanes := make([]lane, len(sets))
+	for i, set := range sets {
+		lanes[i].groups = set
+		lanes[i].plan = Plan{
+			Version: plan.Version,
+			Created: plan.Created,
+			Job:     plan.Job,
+			Stack:   plan.Stack,
+			Groups:  set,
+			Force:   plan.Force,
+		}
+	}
+	for _, sgp := range plan.ScalingGroups {
+		laneUnits := make([][]UnitPlan, len(lanes))
+		for _, up := range sgp.Units {
+			i, found := laneOf[up.Name]
+			if !found {
+				// E.g. a unit of a group that has been removed from the job
+				ui.Warningf("Task groups are not updated in parallel, since unit %s does not belong to any of them.\n", up.Name)
+				return nil, nil
+			}
+			laneUnits[i] = append(laneUnits[i], up)
+		}
+		for i, units := range laneUnits {
+			if len(units) > 0 {
+				lanes[i].plan.ScalingGroups = append(lanes[i].plan.ScalingGroups, ScalingGroupPlan{ScalingGroup: sgp.ScalingGroup, Units: units})
+			}
+		}
+	}
+
+	// Lanes without changes need no rollout
+	var result []lane
+	for _, l := range lanes {
+		if l.plan.HasChanges() {
+			result = append(result, l)
+		}
+	}
+	if len(result) < 2 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// executeLanes performs the actions of the scaling groups of all given lanes.
+// Every lane goes through its scaling groups on its own, at most ParallelGroups lanes at a time.
+// All changes are confirmed at once.
+// A failing lane does not stop the other lanes, but the deployment fails once all lanes are done,
+// rolling back the steps of all lanes.
+func (d *Deployment) executeLanes(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, plan Plan, lanes []lane, ui *stateUI, confirm bool, r *rollout) error {
+	// Confirm modifications
+	if !d.force {
+		var units []UnitPlan
+		for _, l := range lanes {
+			for _, sgp := range l.plan.ScalingGroups {
+				units = append(units, sgp.Units...)
+			}
+		}
+		ui.HeaderSink <- fmt.Sprintf("Step %d: Update %d independent sets of task groups on '%s', %d at a time.\n%s\n", r.step, len(lanes), d.cluster.Stack, d.ParallelGroups, formatChanges(units))
+		if confirm && !d.autoContinue {
+			if err := ui.Confirm("Are you sure you want to continue?"); err != nil {
+				return maskAny(err)
+			}
+		}
+		ui.Clear()
+	}
+	ui.HeaderSink <- fmt.Sprintf("Step %d: Updating %d independent sets of task groups on '%s', %d at a time.\n", r.step, len(lanes), d.cluster.Stack, d.ParallelGroups)
+
+	// Run all lanes
+	sections := make([]*stateUI, len(lanes))
+	for i, l := range lanes {
+		sections[i] = ui.Section(l.title())
+		sections[i].MessageSink <- "Waiting for other task groups..."
+	}
+	rollouts := make([]*rollout, len(lanes))
+	laneErrors := make([]error, len(lanes))
+	limit := make(chan struct{}, d.ParallelGroups)
+	var wg sync.WaitGroup
+	for i, l := range lanes {
+		wg.Add(1)
+		go func(i int, l lane) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			rollouts[i] = &rollout{step: 1, lane: true}
+			if _, err := d.executeScalingGroups(ctx, s, loadedJobUnits, l.plan, sections[i], false, nil, rollouts[i]); err != nil {
+				laneErrors[i] = err
+				sections[i].MessageSink <- fmt.Sprintf("Failed: %v", err)
+			} else {
+				sections[i].MessageSink <- "Done."
+			}
+		}(i, l)
+	}
+	wg.Wait()
+	for _, section := range sections {
+		section.Close()
+	}
+
+	// Collect results
+	var failures []string
+	var cause error
+	for i, l := range lanes {
+		r.modifications += rollouts[i].modifications
+		r.rollbackSteps = append(r.rollbackSteps, rollouts[i].rollbackSteps...)
+		if err := laneErrors[i]; err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", l.title(), err))
+			if cause == nil {
+				cause = err
+			}
+		}
+	}
+	r.step++
+	if cause != nil {
+		err := errgo.WithCausef(nil, errgo.Cause(cause), "%d of %d sets of task groups failed:\n- %s", len(failures), len(lanes), strings.Join(failures, "\n- "))
+		return d.failDeployment(s, r.rollbackSteps, err, ui)
+	}
+	ui.Clear()
+	return nil
+}
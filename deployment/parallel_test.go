@@ -0,0 +1,164 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+// laneTestJob returns a job in which web depends on api, while cache & worker are independent.
+// The given image is used for web & worker.
+func laneTestJob(image string, legacy bool) string {
+	src := `job "shop" {
+	task "web" {
+		image = "` + image + `"
+		links = ["shop.api.api"]
+	}
+	task "api" {
+		image = "api:1.0"
+	}
+	task "cache" {
+		count = 2
+		image = "memcached:1.4"
+	}
+	task "worker" {
+		image = "` + image + `"
+	}
+`
+	if legacy {
+		src += `	task "legacy" {
+		image = "legacy:1.0"
+	}
+`
+	}
+	return src + "}\n"
+}
+
+// formatLanes describes the groups & units of all given lanes.
+func formatLanes(lanes []lane) []string {
+	var result []string
+	for _, l := range lanes {
+		var groups []string
+		for _, g := range l.groups {
+			groups = append(groups, g.String())
+		}
+		desc := strings.Join(groups, ",") + ":"
+		for _, sgp := range l.plan.ScalingGroups {
+			desc += fmt.Sprintf(" %d%v", sgp.ScalingGroup, sgp.unitNames(PlanActionCreate, PlanActionUpdate, PlanActionRemove, PlanActionNone))
+		}
+		result = append(result, desc)
+	}
+	return result
+}
+
+func TestCreateLanes(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Deployed string // Job source deployed before creating the lanes (if any)
+		Job      string
+		Parallel uint
+		Canary   bool
+		Expected []string
+		Warning  bool
+	}{
+		{
+			Name:     "all new",
+			Job:      laneTestJob("nginx:1.11", false),
+			Parallel: 2,
+			Expected: []string{
+				"api,web: 1[shop-api-api-mn@1.service shop-web-web-mn@1.service]",
+				"cache: 1[shop-cache-cache-mn@1.service] 2[shop-cache-cache-mn@2.service]",
+				"worker: 1[shop-worker-worker-mn@1.service]",
+			},
+		},
+		{
+			Name:     "not parallel",
+			Job:      laneTestJob("nginx:1.11", false),
+			Parallel: 1,
+		},
+		{
+			Name:     "canary",
+			Job:      laneTestJob("nginx:1.11", false),
+			Parallel: 2,
+			Canary:   true,
+			Warning:  true,
+		},
+		{
+			Name:     "web & worker changed",
+			Deployed: laneTestJob("nginx:1.11", false),
+			Job:      laneTestJob("nginx:1.12", false),
+			Parallel: 3,
+			Expected: []string{
+				"api,web: 1[shop-api-api-mn@1.service shop-web-web-mn@1.service]",
+				"worker: 1[shop-worker-worker-mn@1.service]",
+			},
+		},
+		{
+			Name:     "single lane changed",
+			Deployed: laneTestJob("nginx:1.11", false),
+			Job:      strings.Replace(laneTestJob("nginx:1.11", false), "api:1.0", "api:1.1", 1),
+			Parallel: 2,
+		},
+		{
+			Name:     "group removed",
+			Deployed: laneTestJob("nginx:1.11", true),
+			Job:      laneTestJob("nginx:1.12", false),
+			Parallel: 2,
+			Warning:  true,
+		},
+	}
+	defer useTempHome(t)()
+	for _, test := range tests {
+		s := newTestScheduler("shop")
+		if test.Deployed != "" {
+			runTestJob(t, test.Deployed, s)
+		}
+		d, events := newTestDeployment(t, test.Job, s)
+		d.ParallelGroups = test.Parallel
+		ctx := context.Background()
+		ui := d.newUI()
+		_, loaded, err := d.prepare(ctx, ui)
+		if err != nil {
+			t.Fatalf("%s: cannot prepare deployment: %v", test.Name, err)
+		}
+		plan, err := d.createPlan(ctx, s, loaded, ui, false)
+		if err != nil {
+			t.Fatalf("%s: cannot create plan: %v", test.Name, err)
+		}
+		var canary *jobs.Canary
+		if test.Canary {
+			canary = &jobs.Canary{Count: 1}
+		}
+		lanes, err := d.createLanes(ctx, s, loaded, plan, canary, ui)
+		ui.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.Name, err)
+			continue
+		}
+		if actual := formatLanes(lanes); !reflect.DeepEqual(actual, test.Expected) {
+			t.Errorf("%s: expected lanes %q, got %q", test.Name, test.Expected, actual)
+		}
+		if warned := len(events.ofType(EventWarning)) > 0; warned != test.Warning {
+			t.Errorf("%s: expected warning %v, got %q", test.Name, test.Warning, events.ofType(EventWarning))
+		}
+	}
+}
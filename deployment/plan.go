@@ -0,0 +1,440 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+type PlanAction string
+
+const (
+	PlanActionCreate  = PlanAction("create")
+	PlanActionUpdate  = PlanAction("update")
+	PlanActionRestart = PlanAction("restart") // Unit is in failed state
+	PlanActionRemove  = PlanAction("remove")
+	PlanActionNone    = PlanAction("none")
+
+	// planVersion is the version of the plan file format.
+	planVersion = 1
+)
+
+// Plan holds all actions that a deployment will perform for every unit of a job.
+type Plan struct {
+	Version       int                  `json:"version"`
+	Created       time.Time            `json:"created"`
+	Job           jobs.JobName         `json:"job"`
+	Stack         string               `json:"stack"`
+	JobPath       string               `json:"job-path,omitempty"`
+	ClusterPath   string               `json:"cluster-path,omitempty"`
+	Groups        []jobs.TaskGroupName `json:"groups,omitempty"`
+	ScalingGroup  uint                 `json:"scaling-group,omitempty"`
+	Force         bool                 `json:"force,omitempty"`
+	ScalingGroups []ScalingGroupPlan   `json:"scaling-groups"`
+	Frontends     []UnitPlan           `json:"frontends,omitempty"` // Frontend units of blue/green groups, switched after all scaling groups
+	Cleanup       []UnitPlan           `json:"cleanup,omitempty"`   // Obsolete units that do not belong to any scaling group
+
+	Colors map[jobs.TaskGroupName]jobs.Color `json:"colors,omitempty"` // Color in which every blue/green group is deployed
+}
+
+// ScalingGroupPlan holds the actions for all units of a single scaling group.
+type ScalingGroupPlan struct {
+	ScalingGroup uint       `json:"scaling-group"`
+	Units        []UnitPlan `json:"units"`
+}
+
+// UnitPlan holds the action for a single unit.
+type UnitPlan struct {
+	Name        string     `json:"name"`
+	Action      PlanAction `json:"action"`
+	Diffs       []string   `json:"diffs,omitempty"`        // Summary of changes (update only)
+	Message     string     `json:"message,omitempty"`      // Failure message (restart only)
+	ContentHash string     `json:"content-hash,omitempty"` // Hash of the rendered unit
+	ClusterHash string     `json:"cluster-hash,omitempty"` // Hash of the unit as it exists on the cluster
+}
+
+// LoadPlan reads a plan from the file with given path.
+func LoadPlan(path string) (Plan, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Plan{}, maskAny(err)
+	}
+	var p Plan
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return Plan{}, maskAny(err)
+	}
+	if p.Version != planVersion {
+		return Plan{}, maskAny(errgo.WithCausef(nil, InvalidPlanError, "unsupported plan version %d", p.Version))
+	}
+	return p, nil
+}
+
+// Save writes the plan to the file with given path.
+func (p Plan) Save(path string) error {
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := ioutil.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// HasChanges returns true if the plan contains at least one action other than none.
+func (p Plan) HasChanges() bool {
+	if len(p.Cleanup) > 0 || p.HasFrontendChanges() {
+		return true
+	}
+	for _, sgp := range p.ScalingGroups {
+		if sgp.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFrontendChanges returns true if the plan switches the frontends of at least one blue/green group.
+func (p Plan) HasFrontendChanges() bool {
+	return hasUnitChanges(p.Frontends)
+}
+
+// Drift returns a description of all differences between the given plan and
+// a plan that was created from the current state of job & cluster.
+// If the plan is still valid, an empty list is returned.
+func (p Plan) Drift(current Plan) []string {
+	var result []string
+	if p.Job != current.Job {
+		result = append(result, fmt.Sprintf("plan is for job '%s', got '%s'", p.Job, current.Job))
+	}
+	if p.Stack != current.Stack {
+		result = append(result, fmt.Sprintf("plan is for stack '%s', got '%s'", p.Stack, current.Stack))
+	}
+	for name, color := range p.Colors {
+		if cur, ok := current.Colors[name]; !ok {
+			result = append(result, fmt.Sprintf("group '%s' is no longer deployed in %s", name, color))
+		} else if cur != color {
+			result = append(result, fmt.Sprintf("group '%s' is deployed in %s instead of %s", name, cur, color))
+		}
+	}
+	saved := p.unitsByName()
+	now := current.unitsByName()
+	for name, up := range saved {
+		cur, ok := now[name]
+		if !ok {
+			result = append(result, fmt.Sprintf("unit '%s' is no longer part of the deployment", name))
+			continue
+		}
+		if up.Action != cur.Action {
+			result = append(result, fmt.Sprintf("unit '%s' needs action '%s' instead of '%s'", name, cur.Action, up.Action))
+		}
+		if up.ContentHash != cur.ContentHash {
+			result = append(result, fmt.Sprintf("rendered unit '%s' has changed", name))
+		}
+		if up.ClusterHash != cur.ClusterHash {
+			result = append(result, fmt.Sprintf("unit '%s' has changed on the cluster", name))
+		}
+	}
+	for name, cur := range now {
+		if _, ok := saved[name]; !ok {
+			result = append(result, fmt.Sprintf("unit '%s' (%s) is not part of the plan", name, cur.Action))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// unitsByName returns all units of the plan, keyed by unit name.
+func (p Plan) unitsByName() map[string]UnitPlan {
+	result := make(map[string]UnitPlan)
+	for _, sgp := range p.ScalingGroups {
+		for _, up := range sgp.Units {
+			result[up.Name] = up
+		}
+	}
+	for _, up := range p.Frontends {
+		result[up.Name] = up
+	}
+	for _, up := range p.Cleanup {
+		result[up.Name] = up
+	}
+	return result
+}
+
+// HasChanges returns true if the scaling group contains at least one action other than none.
+func (sgp ScalingGroupPlan) HasChanges() bool {
+	for _, up := range sgp.Units {
+		if up.Action != PlanActionNone {
+			return true
+		}
+	}
+	return false
+}
+
+// unitNames returns the names of all units that have one of the given actions.
+func (sgp ScalingGroupPlan) unitNames(actions ...PlanAction) []string {
+	return unitPlanNames(sgp.Units, actions...)
+}
+
+// unitPlanNames returns the names of all units in the given list that have one of the given actions.
+func unitPlanNames(units []UnitPlan, actions ...PlanAction) []string {
+	var result []string
+	for _, up := range units {
+		for _, a := range actions {
+			if up.Action == a {
+				result = append(result, up.Name)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Plan renders all units and compares them with the units on the cluster.
+// The result describes the actions that `Apply` will perform.
+func (d *Deployment) Plan(ctx context.Context) (Plan, error) {
+	ui := d.newUI()
+	defer ui.Close()
+
+	s, loadedJobUnits, err := d.prepare(ctx, ui)
+	if err != nil {
+		return Plan{}, maskAny(err)
+	}
+	plan, err := d.createPlan(ctx, s, loadedJobUnits, ui, true)
+	if err != nil {
+		return Plan{}, maskAny(err)
+	}
+	ui.Clear()
+	return plan, nil
+}
+
+// Apply performs all actions of the given plan, without asking for confirmation.
+// If the job or the units on the cluster have changed since the plan was created,
+// an error is returned and nothing is changed.
+func (d *Deployment) Apply(ctx context.Context, plan Plan) error {
+	ui := d.newUI()
+	defer ui.Close()
+
+	unlock, err := d.lockJob(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer unlock()
+
+	if err := d.refusePendingCanary(); err != nil {
+		return maskAny(err)
+	}
+	s, loadedJobUnits, err := d.prepare(ctx, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	current, err := d.createPlan(ctx, s, loadedJobUnits, ui, true)
+	if err != nil {
+		return maskAny(err)
+	}
+	if drift := plan.Drift(current); len(drift) > 0 {
+		return maskAny(errgo.WithCausef(nil, PlanDriftError, "plan no longer matches job & cluster:\n- %s", strings.Join(drift, "\n- ")))
+	}
+	ui.Clear()
+
+	if err := d.executePlan(ctx, s, loadedJobUnits, plan, ui, false, d.canarySettings()); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// prepare fetches the units of the configured job from the cluster and generates
+// the units of all selected scaling groups.
+func (d *Deployment) prepare(ctx context.Context, ui *stateUI) (scheduler.Scheduler, []scheduler.Unit, error) {
+	s, loadedJobUnits, err := d.loadJobUnits(ctx)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	// Decide in which color blue/green groups are deployed
+	if err := d.resolveColors(ctx, s, loadedJobUnits, ui); err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	// Create scaling group units
+	if err := d.generateScalingGroups(); err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	return s, loadedJobUnits, nil
+}
+
+// loadJobUnits fetches the units of the configured job from the cluster.
+func (d *Deployment) loadJobUnits(ctx context.Context) (scheduler.Scheduler, []scheduler.Unit, error) {
+	s, err := d.getScheduler()
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	allUnits, err := s.List(ctx)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	// Check that cluster is valid
+	if err := s.ValidateCluster(ctx); err != nil {
+		return nil, nil, maskAny(err)
+	}
+
+	// Find out which current units belong to the configured job
+	return s, selectUnitNames(allUnits, d.createUnitNamePredicate(s)), nil
+}
+
+// createPlan decides which action is needed for every generated unit and every unit
+// of the job that is loaded on the cluster.
+// If withClusterHashes is set, the content of all loaded units is fetched to detect later changes.
+func (d *Deployment) createPlan(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, ui *stateUI, withClusterHashes bool) (Plan, error) {
+	plan := Plan{
+		Version:      planVersion,
+		Created:      time.Now(),
+		Job:          d.job.Name,
+		Stack:        d.cluster.Stack,
+		Groups:       d.groupSelection,
+		ScalingGroup: uint(d.scalingGroupSelection),
+		Force:        d.force,
+	}
+
+	clusterHash := func(unit scheduler.Unit) (string, error) {
+		if !withClusterHashes {
+			return "", nil
+		}
+		content, err := s.Cat(ctx, unit)
+		if scheduler.IsNotFound(err) {
+			return "", nil
+		} else if err != nil {
+			return "", maskAny(err)
+		}
+		return contentHash(content), nil
+	}
+
+	// Units of blue/green groups outside the scaling groups are planned separately
+	remainingLoadedJobUnitNames := selectUnitNames(loadedJobUnits, notPredicate(containsPredicate(d.blueGreen.excluded())))
+	for _, sg := range d.scalingGroups {
+		// Select the loaded units that belong to this scaling group
+		correctScalingGroupPredicate := func(unit scheduler.Unit) bool {
+			return s.IsUnitForScalingGroup(unit, sg.scalingGroup)
+		}
+		loadedScalingGroupUnitNames := selectUnitNames(remainingLoadedJobUnitNames, correctScalingGroupPredicate)
+		// Update remainingLoadedJobUnitNames
+		remainingLoadedJobUnitNames = selectUnitNames(remainingLoadedJobUnitNames, notPredicate(containsPredicate(loadedScalingGroupUnitNames)))
+		isLoaded := containsPredicate(loadedScalingGroupUnitNames)
+
+		sgp := ScalingGroupPlan{ScalingGroup: sg.scalingGroup}
+		completed := d.progress.isCompleted(sg.scalingGroup)
+		for _, u := range sg.units {
+			up, err := d.planUnit(ctx, s, u, isLoaded(u), completed, clusterHash, ui)
+			if err != nil {
+				return Plan{}, maskAny(err)
+			}
+			sgp.Units = append(sgp.Units, up)
+		}
+
+		// Loaded units that have become obsolete
+		for _, u := range selectUnitNames(loadedScalingGroupUnitNames, notPredicate(containsPredicate(sg.Units()))) {
+			up, err := removalPlan(u, clusterHash)
+			if err != nil {
+				return Plan{}, maskAny(err)
+			}
+			sgp.Units = append(sgp.Units, up)
+		}
+		plan.ScalingGroups = append(plan.ScalingGroups, sgp)
+	}
+
+	// Frontends of blue/green groups
+	if len(d.blueGreen.colors) > 0 {
+		frontends, err := d.planFrontends(ctx, s, loadedJobUnits, clusterHash, ui)
+		if err != nil {
+			return Plan{}, maskAny(err)
+		}
+		plan.Frontends = frontends
+		plan.Colors = d.blueGreen.colors
+	}
+
+	// Remaining units will be removed
+	for _, u := range remainingLoadedJobUnitNames {
+		up, err := removalPlan(u, clusterHash)
+		if err != nil {
+			return Plan{}, maskAny(err)
+		}
+		plan.Cleanup = append(plan.Cleanup, up)
+	}
+
+	return plan, nil
+}
+
+// planUnit decides which action is needed for the given generated unit.
+// Loaded units of a scaling group completed by an interrupted deployment (of the same content)
+// are not checked again.
+func (d *Deployment) planUnit(ctx context.Context, s scheduler.Scheduler, u scheduler.UnitData, loaded, completed bool, clusterHash func(scheduler.Unit) (string, error), ui *stateUI) (UnitPlan, error) {
+	up := UnitPlan{
+		Name:        u.Name(),
+		Action:      PlanActionCreate,
+		ContentHash: contentHash(u.Content()),
+	}
+	if loaded {
+		var err error
+		if up.ClusterHash, err = clusterHash(u); err != nil {
+			return UnitPlan{}, maskAny(err)
+		}
+		if completed {
+			ui.Verbosef("Unit '%s' was completed by the interrupted deployment\n", u.Name())
+			up.Action = PlanActionNone
+		} else if diffs, modified := d.checkModified(ctx, u, s, ui); modified {
+			up.Action = PlanActionUpdate
+			up.Diffs = diffs
+		} else if msg, failed := d.checkFailed(ctx, u, s, ui); failed {
+			up.Action = PlanActionRestart
+			up.Message = msg
+		} else {
+			up.Action = PlanActionNone
+		}
+	}
+	return up, nil
+}
+
+// removalPlan creates the plan for a unit that is obsolete.
+func removalPlan(unit scheduler.Unit, clusterHash func(scheduler.Unit) (string, error)) (UnitPlan, error) {
+	hash, err := clusterHash(unit)
+	if err != nil {
+		return UnitPlan{}, maskAny(err)
+	}
+	return UnitPlan{
+		Name:        unit.Name(),
+		Action:      PlanActionRemove,
+		ClusterHash: hash,
+	}, nil
+}
+
+// contentHash returns a hex encoded SHA256 hash of the given content.
+func contentHash(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
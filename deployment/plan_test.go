@@ -0,0 +1,212 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+// driftTestPlan returns a plan with a unit in a scaling group, a frontend unit & a cleanup unit.
+func driftTestPlan() Plan {
+	return Plan{
+		Version: planVersion,
+		Job:     "shop",
+		Stack:   "test",
+		ScalingGroups: []ScalingGroupPlan{{
+			ScalingGroup: 1,
+			Units: []UnitPlan{
+				{Name: "shop-web-blue-web-mn@1.service", Action: PlanActionUpdate, ContentHash: "c1", ClusterHash: "h1"},
+				{Name: "shop-db-db-mn@1.service", Action: PlanActionNone, ContentHash: "c2", ClusterHash: "h2"},
+			},
+		}},
+		Frontends: []UnitPlan{
+			{Name: "shop-web-blue-web-fe@1.service", Action: PlanActionCreate, ContentHash: "c3"},
+		},
+		Cleanup: []UnitPlan{
+			{Name: "shop-web-blue-web-mn@2.service", Action: PlanActionRemove, ClusterHash: "h4"},
+		},
+		Colors: map[jobs.TaskGroupName]jobs.Color{"web": jobs.ColorBlue},
+	}
+}
+
+func TestPlanDrift(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Modify   func(p *Plan)
+		Expected []string
+	}{
+		{
+			Name:   "unchanged",
+			Modify: func(p *Plan) {},
+		},
+		{
+			Name:     "content hash",
+			Modify:   func(p *Plan) { p.ScalingGroups[0].Units[1].ContentHash = "c2'" },
+			Expected: []string{"rendered unit 'shop-db-db-mn@1.service' has changed"},
+		},
+		{
+			Name:     "cluster hash",
+			Modify:   func(p *Plan) { p.Cleanup[0].ClusterHash = "h4'" },
+			Expected: []string{"unit 'shop-web-blue-web-mn@2.service' has changed on the cluster"},
+		},
+		{
+			Name: "cluster hash & action",
+			Modify: func(p *Plan) {
+				p.ScalingGroups[0].Units[1].ClusterHash = "h2'"
+				p.ScalingGroups[0].Units[1].Action = PlanActionUpdate
+			},
+			Expected: []string{
+				"unit 'shop-db-db-mn@1.service' has changed on the cluster",
+				"unit 'shop-db-db-mn@1.service' needs action 'update' instead of 'none'",
+			},
+		},
+		{
+			Name: "unit added",
+			Modify: func(p *Plan) {
+				p.Cleanup = append(p.Cleanup, UnitPlan{Name: "shop-web-blue-web-mn@3.service", Action: PlanActionRemove})
+			},
+			Expected: []string{"unit 'shop-web-blue-web-mn@3.service' (remove) is not part of the plan"},
+		},
+		{
+			Name:     "unit removed",
+			Modify:   func(p *Plan) { p.Frontends = nil },
+			Expected: []string{"unit 'shop-web-blue-web-fe@1.service' is no longer part of the deployment"},
+		},
+		{
+			Name: "unit moved to other scaling group",
+			Modify: func(p *Plan) {
+				p.ScalingGroups = append(p.ScalingGroups, ScalingGroupPlan{ScalingGroup: 2, Units: p.ScalingGroups[0].Units[1:]})
+				p.ScalingGroups[0].Units = p.ScalingGroups[0].Units[:1]
+			},
+		},
+		{
+			Name:     "color",
+			Modify:   func(p *Plan) { p.Colors["web"] = jobs.ColorGreen },
+			Expected: []string{"group 'web' is deployed in green instead of blue"},
+		},
+		{
+			Name:     "color removed",
+			Modify:   func(p *Plan) { p.Colors = nil },
+			Expected: []string{"group 'web' is no longer deployed in blue"},
+		},
+		{
+			Name: "job & stack",
+			Modify: func(p *Plan) {
+				p.Job = "other"
+				p.Stack = "production"
+			},
+			Expected: []string{"plan is for job 'shop', got 'other'", "plan is for stack 'test', got 'production'"},
+		},
+	}
+	for _, test := range tests {
+		current := driftTestPlan()
+		test.Modify(&current)
+		drift := driftTestPlan().Drift(current)
+		if len(drift) == 0 && len(test.Expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(drift, test.Expected) {
+			t.Errorf("%s: expected drift %q, got %q", test.Name, test.Expected, drift)
+		}
+	}
+}
+
+func TestPlanSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "j2-plan")
+	if err != nil {
+		t.Fatalf("Cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := driftTestPlan()
+	plan.Created = time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	plan.Groups = []jobs.TaskGroupName{"web", "db"}
+	path := filepath.Join(dir, "plan.json")
+	if err := plan.Save(path); err != nil {
+		t.Fatalf("Cannot save plan: %v", err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("Cannot load plan: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, plan) {
+		t.Errorf("Expected loaded plan %#v, got %#v", plan, loaded)
+	}
+	if drift := plan.Drift(loaded); len(drift) > 0 {
+		t.Errorf("Expected no drift of loaded plan, got %q", drift)
+	}
+
+	tests := []struct {
+		Name    string
+		Content string
+		Invalid bool // InvalidPlanError expected
+	}{
+		{"unsupported version", `{"version": 99, "job": "shop"}`, true},
+		{"no version", `{"job": "shop"}`, true},
+		{"invalid json", `{"version": `, false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "invalid.json")
+		if err := ioutil.WriteFile(path, []byte(test.Content), 0644); err != nil {
+			t.Fatalf("Cannot write plan: %v", err)
+		}
+		_, err := LoadPlan(path)
+		if err == nil {
+			t.Errorf("%s: expected error, got none", test.Name)
+		} else if invalid := errgo.Cause(err) == InvalidPlanError; invalid != test.Invalid {
+			t.Errorf("%s: expected InvalidPlanError %v, got %v", test.Name, test.Invalid, err)
+		}
+	}
+	if _, err := LoadPlan(filepath.Join(dir, "missing.json")); !os.IsNotExist(errgo.Cause(err)) {
+		t.Errorf("Expected not-exist error for missing plan, got %v", err)
+	}
+}
+
+func TestApplyDriftedPlan(t *testing.T) {
+	defer useTempHome(t)()
+	s := newTestScheduler("shop")
+	runTestJob(t, blueGreenTestJob, s)
+
+	d, _ := newTestDeployment(t, hookedTestJob, s)
+	plan, err := d.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Cannot create plan: %v", err)
+	}
+	if !plan.HasChanges() {
+		t.Fatalf("Expected plan with changes")
+	}
+
+	// Someone changes a unit on the cluster after the plan has been created
+	s.units["shop-db-db-mn@1.service"] += "\n# changed"
+	started := len(s.started)
+	d, _ = newTestDeployment(t, hookedTestJob, s)
+	err = d.Apply(context.Background(), plan)
+	if !IsPlanDrift(err) {
+		t.Fatalf("Expected plan drift error, got %v", err)
+	}
+	if len(s.started) != started {
+		t.Errorf("Expected no units to be started, got %v", s.started[started:])
+	}
+}
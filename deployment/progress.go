@@ -0,0 +1,189 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+const (
+	progressDir = "~/.pulcy/j2/progress"
+)
+
+// progressRecord holds the scaling groups completed by a deployment, so that
+// an interrupted deployment can be resumed where it stopped.
+// When a deployment stops after a canary that must be promoted manually, it also
+// holds what is needed to abort that canary.
+type progressRecord struct {
+	Job         jobs.JobName  `json:"job"`
+	Stack       string        `json:"stack"`
+	ContentHash string        `json:"content-hash"` // Hash of all units rendered for the deployment
+	Completed   []uint        `json:"completed,omitempty"`
+	Canary      *canaryRecord `json:"canary,omitempty"` // Canary waiting for `promote` (or `promote --abort`)
+	Updated     time.Time     `json:"updated"`
+}
+
+// isCompleted returns true if the given scaling group has been completed with the recorded content.
+// A nil record has no completed scaling groups.
+func (p *progressRecord) isCompleted(scalingGroup uint) bool {
+	if p == nil {
+		return false
+	}
+	for _, x := range p.Completed {
+		if x == scalingGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// progressPath returns the path of the file that holds the progress of deployments of the job on the stack.
+func (d *Deployment) progressPath() (string, error) {
+	dir, err := homedir.Expand(progressDir)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return filepath.Join(dir, d.cluster.Stack, string(d.job.Name)+".json"), nil
+}
+
+// deploymentHash returns a hash of the names & contents of all units rendered for the deployment.
+func (d *Deployment) deploymentHash() string {
+	var lines []string
+	for _, sgu := range append(d.scalingGroups, d.blueGreen.frontends) {
+		for _, u := range sgu.units {
+			lines = append(lines, fmt.Sprintf("%s %s", u.Name(), contentHash(u.Content())))
+		}
+	}
+	sort.Strings(lines)
+	raw, _ := json.Marshal(lines)
+	return contentHash(string(raw))
+}
+
+// startProgress starts recording the progress of the deployment.
+// If resume is set, the scaling groups completed by an interrupted deployment with
+// the same content are taken over. If the content has changed since, an error is returned.
+func (d *Deployment) startProgress(resume bool, ui *stateUI) error {
+	d.progress = &progressRecord{
+		Job:         d.job.Name,
+		Stack:       d.cluster.Stack,
+		ContentHash: d.deploymentHash(),
+	}
+	if !resume {
+		return nil
+	}
+	previous, err := d.loadProgress()
+	if err != nil {
+		return maskAny(err)
+	}
+	if previous == nil {
+		ui.Warningf("No interrupted deployment of job '%s' found, starting with the first scaling group.\n", d.job.Name)
+		return nil
+	}
+	if previous.ContentHash != d.progress.ContentHash {
+		return maskAny(errgo.WithCausef(nil, ResumeError, "job '%s' has changed since the interrupted deployment of %s, run without --resume", d.job.Name, previous.Updated.Format(time.RFC1123)))
+	}
+	d.progress.Completed = previous.Completed
+	ui.Verbosef("Resuming deployment, completed scaling groups: %v\n", previous.Completed)
+	return nil
+}
+
+// loadProgress reads the progress record of the last deployment of the job on the stack.
+// It returns nil when there is no such record.
+func (d *Deployment) loadProgress() (*progressRecord, error) {
+	path, err := d.progressPath()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	var record progressRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, maskAny(err)
+	}
+	return &record, nil
+}
+
+// completeScalingGroup records that the given scaling group has been completed.
+// Failures are reported as a warning only, since they do not affect the deployment itself.
+func (d *Deployment) completeScalingGroup(scalingGroup uint, ui *stateUI) {
+	if d.progress == nil || d.progress.isCompleted(scalingGroup) {
+		return
+	}
+	d.progress.Completed = append(d.progress.Completed, scalingGroup)
+	d.progress.Updated = time.Now()
+	if err := d.saveProgress(); err != nil {
+		ui.Warningf("Failed to record progress of deployment: %v\n", err)
+	}
+}
+
+// saveProgress writes the progress record to disk.
+// The record of a canary holds the previous content of its units, which can include secrets,
+// so the record is only accessible by the current user.
+func (d *Deployment) saveProgress() error {
+	path, err := d.progressPath()
+	if err != nil {
+		return maskAny(err)
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return maskAny(err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return maskAny(err)
+	}
+	raw, err := json.MarshalIndent(d.progress, "", "\t")
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := ioutil.WriteFile(path, append(raw, '\n'), 0600); err != nil {
+		return maskAny(err)
+	}
+	// WriteFile does not change the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// discardProgress removes the progress record, once the deployment no longer
+// needs to be resumed.
+func (d *Deployment) discardProgress(ui *stateUI) {
+	if d.progress == nil {
+		return
+	}
+	d.progress = nil
+	path, err := d.progressPath()
+	if err == nil {
+		err = os.Remove(path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		ui.Warningf("Failed to remove progress of deployment: %v\n", err)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+	"golang.org/x/net/context"
+)
+
+// progressTestJob is a job with 2 scaling groups.
+const progressTestJob = `job "shop" {
+	task "web" {
+		count = 2
+		image = "nginx:1.11"
+	}
+}
+`
+
+// newProgressTestDeployment creates a deployment of the given job source with all its units generated.
+func newProgressTestDeployment(t *testing.T, src string) (*Deployment, *testEvents) {
+	d, events := newTestDeployment(t, src, newTestScheduler("shop"))
+	if err := d.generateScalingGroups(); err != nil {
+		t.Fatalf("Cannot generate scaling groups: %v", err)
+	}
+	return d, events
+}
+
+func TestIsCompleted(t *testing.T) {
+	var none *progressRecord
+	if none.isCompleted(1) {
+		t.Errorf("Expected nil record to have no completed scaling groups")
+	}
+	p := &progressRecord{Completed: []uint{1, 3}}
+	for sg, expected := range map[uint]bool{1: true, 2: false, 3: true, 4: false} {
+		if completed := p.isCompleted(sg); completed != expected {
+			t.Errorf("Expected completed %v for scaling group %d, got %v", expected, sg, completed)
+		}
+	}
+}
+
+func TestDeploymentHash(t *testing.T) {
+	d, _ := newProgressTestDeployment(t, progressTestJob)
+	hash := d.deploymentHash()
+	if same, _ := newProgressTestDeployment(t, progressTestJob); same.deploymentHash() != hash {
+		t.Errorf("Expected the same hash for the same job")
+	}
+
+	// The order of the units does not matter
+	sgs := d.scalingGroups
+	d.scalingGroups = []scalingGroupUnits{sgs[1], sgs[0]}
+	if h := d.deploymentHash(); h != hash {
+		t.Errorf("Expected the same hash for reordered scaling groups")
+	}
+
+	others := []string{
+		strings.Replace(progressTestJob, "nginx:1.11", "nginx:1.12", 1), // Changed content
+		strings.Replace(progressTestJob, "count = 2", "count = 3", 1),   // Added units
+		strings.Replace(progressTestJob, `task "web"`, `task "www"`, 1), // Renamed units
+	}
+	for _, src := range others {
+		if other, _ := newProgressTestDeployment(t, src); other.deploymentHash() == hash {
+			t.Errorf("Expected a different hash for\n%s", src)
+		}
+	}
+
+	// Frontends of blue/green groups are included
+	d.blueGreen.frontends.units = sgs[0].units
+	if h := d.deploymentHash(); h == hash {
+		t.Errorf("Expected a different hash with frontends")
+	}
+}
+
+func TestStartProgress(t *testing.T) {
+	defer useTempHome(t)()
+
+	// Record scaling group 1 of an interrupted deployment
+	d, _ := newProgressTestDeployment(t, progressTestJob)
+	ui := d.newUI()
+	if err := d.startProgress(false, ui); err != nil {
+		t.Fatalf("Cannot start progress: %v", err)
+	}
+	d.completeScalingGroup(1, ui)
+	ui.Close()
+	path, err := d.progressPath()
+	if err != nil {
+		t.Fatalf("Cannot get progress path: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected progress record: %v", err)
+	} else if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("Expected progress record with mode 0600, got %o", mode)
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("Expected progress directory with mode 0700, got %v (%v)", info.Mode(), err)
+	}
+
+	tests := []struct {
+		Name      string
+		Job       string
+		Resume    bool
+		Completed []uint
+		Error     bool
+	}{
+		{Name: "no resume", Job: progressTestJob},
+		{Name: "resume", Job: progressTestJob, Resume: true, Completed: []uint{1}},
+		{Name: "resume changed job", Job: strings.Replace(progressTestJob, "nginx:1.11", "nginx:1.12", 1), Resume: true, Error: true},
+		{Name: "no resume changed job", Job: strings.Replace(progressTestJob, "nginx:1.11", "nginx:1.12", 1)},
+	}
+	for _, test := range tests {
+		d, _ := newProgressTestDeployment(t, test.Job)
+		ui := d.newUI()
+		err := d.startProgress(test.Resume, ui)
+		ui.Close()
+		if test.Error {
+			if errgo.Cause(err) != ResumeError {
+				t.Errorf("%s: expected ResumeError, got %v", test.Name, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(d.progress.Completed, test.Completed) {
+			t.Errorf("%s: expected completed scaling groups %v, got %v", test.Name, test.Completed, d.progress.Completed)
+		}
+		if d.progress.ContentHash != d.deploymentHash() || d.progress.Job != "shop" || d.progress.Stack != "test" {
+			t.Errorf("%s: unexpected progress record %#v", test.Name, d.progress)
+		}
+	}
+
+	// Without a record, resume starts at the first scaling group
+	d.discardProgress(ui)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected progress record to be removed, got %v", err)
+	}
+	d, events := newProgressTestDeployment(t, progressTestJob)
+	ui = d.newUI()
+	err = d.startProgress(true, ui)
+	ui.Close()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(d.progress.Completed) != 0 || len(events.ofType(EventWarning)) != 1 {
+		t.Errorf("Expected no completed scaling groups and a warning, got %v & %q", d.progress.Completed, events.ofType(EventWarning))
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	defer useTempHome(t)()
+
+	// Record scaling group 1 of an interrupted deployment
+	d, _ := newProgressTestDeployment(t, progressTestJob)
+	ui := d.newUI()
+	if err := d.startProgress(false, ui); err != nil {
+		t.Fatalf("Cannot start progress: %v", err)
+	}
+	d.completeScalingGroup(1, ui)
+	ui.Close()
+
+	// Units of the completed scaling group are skipped, even if their content differs in the cluster
+	s := newTestScheduler("shop", testUnit{"shop-web-web-mn@1.service", "old"})
+	d, _ = newTestDeployment(t, progressTestJob, s)
+	d.Resume = true
+	if err := d.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if expected := []string{"shop-web-web-mn@2.service"}; !reflect.DeepEqual(s.started, expected) {
+		t.Errorf("Expected only %v to be started, got %v", expected, s.started)
+	}
+	if path, _ := d.progressPath(); path != "" {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected progress record to be removed after completion, got %v", err)
+		}
+	}
+}
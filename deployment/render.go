@@ -0,0 +1,78 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Render generates all unit files for the configured job and writes them into the given directory.
+// It does not access the cluster and does not ask for any confirmation.
+// Units with JSON content (kubernetes resources) are written as indented JSON with a `.json` extension.
+// The names of the written files are returned in sorted order, followed by the names of the (stale)
+// files in the directory that have not been written. If prune is set, these stale files are removed.
+func (d *Deployment) Render(dir string, prune bool) ([]string, []string, error) {
+	if err := d.generateScalingGroups(); err != nil {
+		return nil, nil, maskAny(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, maskAny(err)
+	}
+	var fileNames []string
+	written := make(map[string]struct{})
+	for _, sgu := range append(d.scalingGroups, d.blueGreen.frontends) {
+		for _, u := range sgu.units {
+			fileName := u.Name()
+			content := []byte(u.Content())
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, content, "", "  "); err == nil {
+				buf.WriteString("\n")
+				content = buf.Bytes()
+				fileName = fileName + ".json"
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, fileName), content, 0644); err != nil {
+				return nil, nil, maskAny(err)
+			}
+			fileNames = append(fileNames, fileName)
+			written[fileName] = struct{}{}
+		}
+	}
+	sort.Strings(fileNames)
+
+	// Find files of units that are no longer rendered
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	var staleFileNames []string
+	for _, info := range infos {
+		name := info.Name()
+		if _, ok := written[name]; ok || !info.Mode().IsRegular() {
+			continue
+		}
+		if prune {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return nil, nil, maskAny(err)
+			}
+		}
+		staleFileNames = append(staleFileNames, name)
+	}
+	return fileNames, staleFileNames, nil
+}
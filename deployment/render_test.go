@@ -0,0 +1,192 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	k8srender "github.com/pulcy/j2/render/kubernetes"
+)
+
+// renderTestJob is a job with a blue/green group (that has frontends) and a regular group.
+const renderTestJob = `job "shop" {
+	group "web" {
+		count = 2
+		blue-green = true
+		task "web" {
+			image = "nginx:1.11"
+			frontend {
+				domain = "shop.example.com"
+			}
+		}
+	}
+	task "db" {
+		image = "redis:3.2"
+	}
+}
+`
+
+// tempRenderDir creates a temporary directory to render into.
+func tempRenderDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "j2-render")
+	if err != nil {
+		t.Fatalf("Cannot create directory: %v", err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// dirFileNames returns the names of the files in the given directory.
+func dirFileNames(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Cannot read directory: %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func TestRender(t *testing.T) {
+	dir, cleanup := tempRenderDir(t)
+	defer cleanup()
+
+	d, _ := newTestDeployment(t, renderTestJob, newTestScheduler("shop"))
+	fileNames, stale, err := d.Render(filepath.Join(dir, "units"), false)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	expected := []string{
+		"shop-db-db-mn@1.service",
+		"shop-web-blue-web-fe@1.service",
+		"shop-web-blue-web-fe@2.service",
+		"shop-web-blue-web-mn@1.service",
+		"shop-web-blue-web-mn@2.service",
+	}
+	if !reflect.DeepEqual(fileNames, expected) {
+		t.Errorf("Expected files %v, got %v", expected, fileNames)
+	}
+	if len(stale) != 0 {
+		t.Errorf("Expected no stale files, got %v", stale)
+	}
+	if names := dirFileNames(t, filepath.Join(dir, "units")); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected directory with %v, got %v", expected, names)
+	}
+	for _, sgu := range append(d.scalingGroups, d.blueGreen.frontends) {
+		for _, u := range sgu.units {
+			content, err := ioutil.ReadFile(filepath.Join(dir, "units", u.Name()))
+			if err != nil {
+				t.Errorf("Cannot read %s: %v", u.Name(), err)
+			} else if string(content) != u.Content() {
+				t.Errorf("Expected content of %s to be the rendered unit", u.Name())
+			}
+		}
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	dir, cleanup := tempRenderDir(t)
+	defer cleanup()
+
+	job, cl := parseTestJob(t, `job "shop" {
+	task "db" {
+		image = "redis:3.2"
+	}
+}
+`)
+	d, err := NewDeployment(testOrchestrator{s: newTestScheduler("shop"), provider: k8srender.NewRenderProvider()}, job, cl, nil, 0, false, true, false,
+		DeploymentDelays{}, HealthCheckConfig{}, RolloutOptions{}, testContext{})
+	if err != nil {
+		t.Fatalf("Cannot create deployment: %v", err)
+	}
+	fileNames, _, err := d.Render(dir, false)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(fileNames) == 0 {
+		t.Fatalf("Expected rendered files")
+	}
+	for _, name := range fileNames {
+		if !strings.HasSuffix(name, ".json") {
+			t.Errorf("Expected %s to have a .json extension", name)
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Cannot read %s: %v", name, err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(content, &value); err != nil {
+			t.Errorf("Expected %s to contain JSON: %v", name, err)
+		}
+		if lines := strings.Split(string(content), "\n"); len(lines) < 3 || !strings.HasPrefix(lines[1], "  ") || lines[len(lines)-1] != "" {
+			t.Errorf("Expected %s to contain indented JSON ending with a newline, got\n%s", name, content)
+		}
+	}
+}
+
+func TestRenderStaleFiles(t *testing.T) {
+	for _, prune := range []bool{false, true} {
+		dir, cleanup := tempRenderDir(t)
+		defer cleanup()
+
+		// Files of a removed task, an unrelated file & a directory
+		for _, name := range []string{"shop-old-old-mn.service", "notes.txt"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("old"), 0644); err != nil {
+				t.Fatalf("Cannot write %s: %v", name, err)
+			}
+		}
+		if err := os.Mkdir(filepath.Join(dir, "subdir"), 0755); err != nil {
+			t.Fatalf("Cannot create directory: %v", err)
+		}
+		// File of a unit that is rendered again
+		if err := ioutil.WriteFile(filepath.Join(dir, "shop-db-db-mn@1.service"), []byte("old"), 0644); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+
+		d, _ := newTestDeployment(t, renderTestJob, newTestScheduler("shop"))
+		fileNames, stale, err := d.Render(dir, prune)
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		if expected := []string{"notes.txt", "shop-old-old-mn.service"}; !reflect.DeepEqual(stale, expected) {
+			t.Errorf("prune=%v: expected stale files %v, got %v", prune, expected, stale)
+		}
+		expected := append([]string{}, fileNames...)
+		if !prune {
+			expected = append(expected, stale...)
+		}
+		expected = append(expected, "subdir")
+		names := dirFileNames(t, dir)
+		if len(names) != len(expected) {
+			t.Errorf("prune=%v: expected directory with %v, got %v", prune, expected, names)
+		}
+		for _, name := range expected {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("prune=%v: expected %s to exist: %v", prune, name, err)
+			}
+		}
+		if content, _ := ioutil.ReadFile(filepath.Join(dir, "shop-db-db-mn@1.service")); string(content) == "old" {
+			t.Errorf("prune=%v: expected rendered unit to be overwritten", prune)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/ryanuber/columnize"
+
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// RolloutOptions specifies how a deployment is rolled out and how it behaves when one of its steps fails.
+type RolloutOptions struct {
+	// If set, all scaling groups updated so far are restored to their previous version
+	// when a step of the deployment fails.
+	RollbackOnFailure bool
+	// If set, overrides the number of scaling groups in the canary of the job.
+	Canary uint
+	// Maximum number of independent task groups that are updated at the same time.
+	// Values below 2 update all task groups together, one scaling group at a time.
+	ParallelGroups uint
+	// If set, the deployment lock of the job is taken over when it is held by someone else.
+	StealLock bool
+	// If set, Run skips the scaling groups completed by an interrupted deployment of the same content.
+	Resume bool
+	// If set, a step of the deployment that takes longer than this duration is canceled
+	// (and considered failed).
+	StepTimeout time.Duration
+}
+
+const (
+	// rollbackTimeout is the maximum duration of rolling back a failed deployment.
+	rollbackTimeout = 15 * time.Minute
+)
+
+// rollbackContext returns a context for rolling back a failed deployment.
+// It is not derived from the context of the deployment, since that is likely to be canceled
+// (e.g. by --timeout or Ctrl-C) when the deployment fails.
+func rollbackContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), rollbackTimeout)
+}
+
+// rollbackStep holds the state needed to undo the changes made in a single scaling group.
+type rollbackStep struct {
+	scalingGroup uint
+	launched     []string             // Names of units (re)launched in this step
+	previous     []scheduler.UnitData // Units as they existed on the cluster before this step
+}
+
+// unitDataList implements scheduler.UnitDataList for a slice of units.
+type unitDataList []scheduler.UnitData
+
+func (l unitDataList) Len() int {
+	return len(l)
+}
+
+func (l unitDataList) Get(index int) scheduler.UnitData {
+	return l[index]
+}
+
+// createRollbackStep captures the current content of all units that will be replaced or removed
+// in the given scaling group.
+func (d *Deployment) createRollbackStep(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, sgp ScalingGroupPlan, ui *stateUI) (rollbackStep, error) {
+	step := rollbackStep{
+		scalingGroup: sgp.ScalingGroup,
+		launched:     sgp.unitNames(PlanActionUpdate, PlanActionRestart, PlanActionCreate),
+	}
+	units := selectUnitNames(loadedJobUnits, namesPredicate(sgp.unitNames(PlanActionUpdate, PlanActionRestart, PlanActionRemove)))
+	for _, u := range units {
+		ui.MessageSink <- fmt.Sprintf("Saving current version of %s", u.Name())
+		current, err := s.GetCurrent(ctx, u)
+		if scheduler.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return rollbackStep{}, maskAny(err)
+		}
+		step.previous = append(step.previous, current)
+	}
+	return step, nil
+}
+
+// failDeployment is called when a step of the deployment failed with given error.
+// If configured, all given steps are rolled back.
+func (d *Deployment) failDeployment(s scheduler.Scheduler, steps []rollbackStep, cause error, ui *stateUI) error {
+	if !d.RollbackOnFailure || len(steps) == 0 {
+		return maskAny(cause)
+	}
+	ui.Warningf("Deployment failed: %v\nRolling back %d scaling group(s).\n", cause, len(steps))
+	ctx, cancel := rollbackContext()
+	defer cancel()
+	report, err := d.rollback(ctx, s, steps, ui)
+	if err != nil {
+		return maskAny(errgo.WithCausef(err, errgo.Cause(cause), "deployment failed (%v) and rollback failed", cause))
+	}
+	ui.HeaderSink <- fmt.Sprintf("Rolled back %d scaling group(s) on '%s'.\n%s\n", len(steps), d.cluster.Stack, report)
+	// The rolled back scaling groups have to be deployed again
+	d.discardProgress(ui)
+	return maskAny(errgo.WithCausef(cause, errgo.Cause(cause), "deployment failed, rolled back %d scaling group(s)", len(steps)))
+}
+
+// rollback restores the previous version of all units changed in the given steps.
+// The steps are undone in reverse order.
+// It returns a report of all units that have been rolled back.
+func (d *Deployment) rollback(ctx context.Context, s scheduler.Scheduler, steps []rollbackStep, ui *stateUI) (string, error) {
+	allUnits, err := s.List(ctx)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	exists := containsPredicate(allUnits)
+	changes := []string{"# Unit | Rollback action"}
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		sg, err := d.scalingGroup(step.scalingGroup)
+		if err != nil {
+			return "", maskAny(err)
+		}
+
+		// Destroy the new versions of all launched units.
+		// Units that have a previous version are destroyed as updated, others as obsolete.
+		var previous []scheduler.Unit
+		for _, u := range step.previous {
+			previous = append(previous, u)
+		}
+		launched := selectUnitNames(sg.Units(), namesPredicate(step.launched))
+		launched = selectUnitNames(launched, exists)
+		replaced := selectUnitNames(launched, containsPredicate(previous))
+		created := selectUnitNames(launched, notPredicate(containsPredicate(previous)))
+		if err := d.destroyUnits(ctx, s, replaced, nil, created, ui); err != nil {
+			return "", maskAny(err)
+		}
+		for _, u := range created {
+			changes = append(changes, fmt.Sprintf("# %s | Removed", u.Name()))
+		}
+
+		// Restore previous versions
+		if len(step.previous) > 0 {
+			if err := ui.Wait(ctx, s.UpdateDestroyDelay(d.DestroyDelay), "Waiting for %s..."); err != nil {
+				return "", maskAny(err)
+			}
+			if err := launchUnits(ctx, s, unitDataList(step.previous), ui); err != nil {
+				return "", maskAny(err)
+			}
+			for _, u := range step.previous {
+				changes = append(changes, fmt.Sprintf("# %s | Restored previous version", u.Name()))
+			}
+		}
+		ui.Clear()
+	}
+	sort.Strings(changes[1:])
+	return strings.Replace(columnize.SimpleFormat(changes), "#", " ", -1), nil
+}
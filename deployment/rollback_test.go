@@ -0,0 +1,129 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/scheduler"
+)
+
+func TestCreateRollbackStep(t *testing.T) {
+	s := newTestScheduler("shop")
+	for _, name := range []string{"updated", "restarted", "removed", "unchanged"} {
+		s.units[name] = name + " content"
+	}
+	var loaded []scheduler.Unit
+	for _, name := range []string{"updated", "restarted", "removed", "unchanged", "gone"} {
+		loaded = append(loaded, testUnit{name: name})
+	}
+	sgp := ScalingGroupPlan{
+		ScalingGroup: 2,
+		Units: []UnitPlan{
+			{Name: "updated", Action: PlanActionUpdate},
+			{Name: "restarted", Action: PlanActionRestart},
+			{Name: "removed", Action: PlanActionRemove},
+			{Name: "unchanged", Action: PlanActionNone},
+			{Name: "created", Action: PlanActionCreate},
+			{Name: "gone", Action: PlanActionRemove}, // Removed from the cluster in the meantime
+		},
+	}
+	d := &Deployment{Events: &testEvents{}}
+	ui := d.newUI()
+	defer ui.Close()
+	step, err := d.createRollbackStep(context.Background(), s, loaded, sgp, ui)
+	if err != nil {
+		t.Fatalf("Cannot create rollback step: %v", err)
+	}
+	if step.scalingGroup != 2 {
+		t.Errorf("Expected scaling group 2, got %d", step.scalingGroup)
+	}
+	if expected := []string{"updated", "restarted", "created"}; !reflect.DeepEqual(step.launched, expected) {
+		t.Errorf("Expected launched units %v, got %v", expected, step.launched)
+	}
+	var previous []string
+	for _, u := range step.previous {
+		previous = append(previous, u.Name()+": "+u.Content())
+	}
+	if expected := []string{"updated: updated content", "restarted: restarted content", "removed: removed content"}; !reflect.DeepEqual(previous, expected) {
+		t.Errorf("Expected previous units %v, got %v", expected, previous)
+	}
+}
+
+// cleanupTestJobs are two versions of a job. The second version updates the web group
+// and reduces its count, so the units of its second scaling group are cleaned up.
+var cleanupTestJobs = []string{`job "shop" {
+	task "web" {
+		count = 2
+		image = "nginx:1.11"
+	}
+}
+`, `job "shop" {
+	task "web" {
+		count = 1
+		image = "nginx:1.12"
+	}
+}
+`}
+
+func TestRollbackOnCleanupFailure(t *testing.T) {
+	defer useTempHome(t)()
+	s := newTestScheduler("shop")
+	runTestJob(t, cleanupTestJobs[0], s)
+	before := make(map[string]string)
+	for name, content := range s.units {
+		before[name] = content
+	}
+
+	s.failDestroy["shop-web-web-mn@2.service"] = true
+	d, events := newTestDeployment(t, cleanupTestJobs[1], s)
+	d.RollbackOnFailure = true
+	err := d.Run(context.Background())
+	if err == nil {
+		t.Fatalf("Expected cleanup to fail, got no error")
+	}
+	if !strings.Contains(err.Error(), "rolled back 1 scaling group(s)") {
+		t.Errorf("Expected rollback of 1 scaling group, got %v", err)
+	}
+	if !reflect.DeepEqual(keys(s.units), keys(before)) {
+		t.Errorf("Expected units %v after rollback, got %v", keys(before), keys(s.units))
+	}
+	for name, content := range before {
+		if s.units[name] != content {
+			t.Errorf("Expected previous version of %s after rollback", name)
+		}
+	}
+	if len(events.ofType(EventWarning)) == 0 {
+		t.Errorf("Expected a warning about the rollback")
+	}
+	if len(s.history) != 1 {
+		t.Errorf("Expected failed deployment not to be recorded, got %d history entries", len(s.history))
+	}
+}
+
+// keys returns the sorted keys of the given map.
+func keys(m map[string]string) []string {
+	var result []string
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
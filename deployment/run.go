@@ -18,223 +18,376 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ryanuber/columnize"
 
+	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/jobs"
 	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
 )
 
 // Run creates all applicable unit files and deploys them onto the configured cluster.
-func (d *Deployment) Run() error {
-	// Fetch all current units
-	s, err := d.orchestrator.Scheduler(d.job, d.cluster)
+func (d *Deployment) Run(ctx context.Context) error {
+	// Prepare UI
+	ui := d.newUI()
+	defer ui.Close()
+
+	// Prevent concurrent deployments of the job
+	unlock, err := d.lockJob(ctx, ui)
 	if err != nil {
 		return maskAny(err)
 	}
+	defer unlock()
 
-	allUnits, err := s.List()
+	if err := d.refusePendingCanary(); err != nil {
+		return maskAny(err)
+	}
+
+	// Fetch all current units
+	s, loadedJobUnits, err := d.prepare(ctx, ui)
 	if err != nil {
 		return maskAny(err)
 	}
 
-	// Prepare UI
-	ui := newStateUI(d.verbose)
-	defer ui.Close()
+	// Record completed scaling groups, so an interrupted deployment can be resumed
+	if err := d.startProgress(d.Resume, ui); err != nil {
+		return maskAny(err)
+	}
 
-	// Check that cluster is valid
-	if err := s.ValidateCluster(); err != nil {
+	// Decide what to do with every unit
+	plan, err := d.createPlan(ctx, s, loadedJobUnits, ui, false)
+	if err != nil {
 		return maskAny(err)
 	}
+	ui.Clear()
 
-	// Find out which current units belong to the configured job
-	remainingLoadedJobUnitNames := selectUnitNames(allUnits, d.createUnitNamePredicate(s))
+	if err := d.executePlan(ctx, s, loadedJobUnits, plan, ui, true, d.canarySettings()); err != nil {
+		return maskAny(err)
+	}
+	// Keep the record of a canary that waits for `promote`
+	if d.progress == nil || d.progress.Canary == nil {
+		d.discardProgress(ui)
+	}
+	return nil
+}
+
+// rollout tracks the progress of a deployment through its steps.
+type rollout struct {
+	step           int            // Number of the next step
+	modifications  int            // Number of steps that modified the cluster
+	rollbackSteps  []rollbackStep // Steps that are rolled back when the deployment fails
+	recordProgress bool           // If set, completed scaling groups are recorded (not for parallel lanes)
+	lane           bool           // If set, failures are returned without rolling back (the caller fails the deployment)
+}
 
-	// Create scaling group units
-	if err := d.generateScalingGroups(); err != nil {
+// failRollout fails the deployment with given error, rolling back the steps of the given rollout.
+// The rollout of a lane is not rolled back here, since other lanes may still be running.
+func (d *Deployment) failRollout(s scheduler.Scheduler, r *rollout, err error, ui *stateUI) error {
+	if r.lane {
 		return maskAny(err)
 	}
+	return d.failDeployment(s, r.rollbackSteps, err, ui)
+}
 
-	// Ask for confirmation
-	maxScale := d.scalingGroups[len(d.scalingGroups)-1].scalingGroup
+// executePlan performs all actions of the given plan, one scaling group at a time.
+// If confirm is set, the user is asked for confirmation before every step.
+// If a canary is given, the deployment pauses after the scaling groups of the canary
+// until the canary is promoted.
+// If configured, independent task groups are updated in parallel.
+// Pre-deploy hooks run before the first step, post-deploy hooks after the last step.
+// Once done, a summary of the plan is reported.
+func (d *Deployment) executePlan(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, plan Plan, ui *stateUI, confirm bool, canary *jobs.Canary) (err error) {
+	d.started = time.Now()
+	d.notifications = newNotifyQueue(ui)
+	notify := plan.HasChanges()
+	paused := false
+	defer func() {
+		ui.Summary(planSummary(plan), err)
+		if notify && err != nil {
+			d.notify(NotifyEvent{Event: cluster.NotifyEventFailure, Units: changedUnitNames(plan), Error: err.Error()}, ui)
+		} else if notify && !paused {
+			d.notify(NotifyEvent{Event: cluster.NotifyEventFinish, Units: changedUnitNames(plan)}, ui)
+		}
+		d.notifications.flush()
+		d.notifications = nil
+	}()
+	r := &rollout{step: 1, recordProgress: true}
+	if notify {
+		d.notify(NotifyEvent{Event: cluster.NotifyEventStart, Units: changedUnitNames(plan)}, ui)
+		if err := d.runHooks(ctx, s, jobs.HookPreDeploy, ui); err != nil {
+			return maskAny(err)
+		}
+	}
+	lanes, err := d.createLanes(ctx, s, loadedJobUnits, plan, canary, ui)
+	if err != nil {
+		return maskAny(err)
+	}
+	if len(lanes) > 1 {
+		if err := d.executeLanes(ctx, s, loadedJobUnits, plan, lanes, ui, confirm, r); err != nil {
+			return maskAny(err)
+		}
+	} else {
+		completed, err := d.executeScalingGroups(ctx, s, loadedJobUnits, plan, ui, confirm, canary, r)
+		if err != nil {
+			return maskAny(err)
+		}
+		if !completed {
+			paused = true
+			return nil
+		}
+	}
+
+	// Switch the frontends of blue/green groups
+	if plan.HasFrontendChanges() {
+		if err := d.switchFrontends(ctx, s, loadedJobUnits, plan.Frontends, r.step, ui, confirm); err != nil {
+			return d.failDeployment(s, r.rollbackSteps, err, ui)
+		}
+		r.step++
+		r.modifications++
+		ui.Clear()
+	}
+
+	// Destroy remaining units
+	if len(plan.Cleanup) > 0 {
+		remainingLoadedJobUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(unitPlanNames(plan.Cleanup, PlanActionRemove)))
+		formattedChanges := formatChanges(plan.Cleanup)
+		ui.HeaderSink <- fmt.Sprintf("Step %d: Cleanup of obsolete units on '%s'.\n%s\n", r.step, d.cluster.Stack, formattedChanges)
+		if confirm {
+			if err := ui.Confirm("Are you sure you want to continue?"); err != nil {
+				return maskAny(err)
+			}
+		}
+
+		if err := d.destroyUnits(ctx, s, nil, nil, remainingLoadedJobUnitNames, ui); err != nil {
+			return d.failDeployment(s, r.rollbackSteps, err, ui)
+		}
+
+		r.modifications++
+	}
+
+	// Notify in case we did nothing
+	if r.modifications == 0 {
+		ui.MessageSink <- "No modifications needed."
+	} else {
+		d.recordHistory(ctx, s, ui)
+		if err := d.runHooks(ctx, s, jobs.HookPostDeploy, ui); err != nil {
+			return maskAny(err)
+		}
+		ui.MessageSink <- "Done."
+	}
+
+	return nil
+}
+
+// executeScalingGroups performs the actions of all scaling groups of the given plan, one scaling group at a time.
+// It returns false when the deployment stopped after a canary that is not (yet) promoted.
+func (d *Deployment) executeScalingGroups(ctx context.Context, s scheduler.Scheduler, loadedJobUnits []scheduler.Unit, plan Plan, ui *stateUI, confirm bool, canary *jobs.Canary, r *rollout) (bool, error) {
+	maxScale := uint(0)
+	if len(plan.ScalingGroups) > 0 {
+		maxScale = plan.ScalingGroups[len(plan.ScalingGroups)-1].ScalingGroup
+	}
 
 	// Go over every scale
-	step := 1
-	totalModifications := 0
 	waitBeforeNextStep := false
-	for sgIndex, sg := range d.scalingGroups {
-		// Select the loaded units that belong to this scaling group
-		correctScalingGroupPredicate := func(unit scheduler.Unit) bool {
-			return s.IsUnitForScalingGroup(unit, sg.scalingGroup)
-		}
-		loadedScalingGroupUnitNames := selectUnitNames(remainingLoadedJobUnitNames, correctScalingGroupPredicate)
-		// Update remainingLoadedJobUnitNames
-		remainingLoadedJobUnitNames = selectUnitNames(remainingLoadedJobUnitNames, notPredicate(containsPredicate(loadedScalingGroupUnitNames)))
-
-		// Select the loaded unit name that have become obsolete
-		sgUnitNames := sg.Units()
-		obsoleteUnitNames := selectUnitNames(loadedScalingGroupUnitNames, notPredicate(containsPredicate(sgUnitNames)))
-		notObsoleteUnitNames := selectUnitNames(loadedScalingGroupUnitNames, containsPredicate(sgUnitNames))
-
-		// Select the unit names that are modified and need an update
-		isModifiedPredicate := d.isModifiedPredicate(sg, s, ui)
-		modifiedUnitNames := selectUnitNames(notObsoleteUnitNames, isModifiedPredicate)
-		isFailedPredicate := d.isFailedPredicate(sg, s, ui)
-		failedUnitNames := selectUnitNames(notObsoleteUnitNames, isFailedPredicate)
+	for sgIndex, sgp := range plan.ScalingGroups {
+		sg, err := d.scalingGroup(sgp.ScalingGroup)
+		if err != nil {
+			return false, maskAny(err)
+		}
+		// Only the units of the plan are part of this rollout
+		sg = sg.selectByNames(sgp.unitNames(PlanActionNone, PlanActionUpdate, PlanActionRestart, PlanActionCreate))
+
+		// Select the loaded units that need to be destroyed
+		modifiedUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(sgp.unitNames(PlanActionUpdate)))
+		failedUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(sgp.unitNames(PlanActionRestart)))
+		obsoleteUnitNames := selectUnitNames(loadedJobUnits, namesPredicate(sgp.unitNames(PlanActionRemove)))
 		unitNamesToDestroy := append(append(obsoleteUnitNames, modifiedUnitNames...), failedUnitNames...)
-		newUnitNames := selectUnitNames(sgUnitNames, notPredicate(containsPredicate(loadedScalingGroupUnitNames)))
 
 		// Are there any changes?
-		anyModifications := (len(loadedScalingGroupUnitNames) != len(sg.units)) || (len(unitNamesToDestroy) > 0)
+		anyModifications := sgp.HasChanges()
 
-		// Wait a bit before proceeding
-		if waitBeforeNextStep && anyModifications {
-			InterruptibleSleep(ui.MessageSink, d.SliceDelay, fmt.Sprintf("Waiting %s before continuing with scaling group %d of %d...", "%s", (sgIndex+1), maxScale))
+		// Wait a bit before proceeding (health checks replace this delay)
+		if waitBeforeNextStep && anyModifications && !d.HealthCheck.IsEnabled() {
+			if err := ui.Wait(ctx, d.SliceDelay, fmt.Sprintf("Waiting %s before continuing with scaling group %d of %d...", "%s", (sgIndex+1), maxScale)); err != nil {
+				return false, maskAny(err)
+			}
 			ui.Clear()
 		}
 
 		// Confirm modifications
 		if anyModifications && !d.force {
 			curScale := sg.scalingGroup
-			changes := []string{"# Unit | Action"}
-			changes = append(changes, formatChanges("# ", unitsToNames(obsoleteUnitNames), "Remove (is obsolete) !!!", ui)...)
-			changes = append(changes, formatChanges("# ", unitsToNames(modifiedUnitNames), "Update", ui)...)
-			changes = append(changes, formatChanges("# ", unitsToNames(failedUnitNames), "Failed state", ui)...)
-			changes = append(changes, formatChanges("# ", unitsToNames(newUnitNames), "Create", ui)...)
-			sort.Strings(changes[1:])
-			formattedChanges := strings.Replace(columnize.SimpleFormat(changes), "#", " ", -1)
-			ui.HeaderSink <- fmt.Sprintf("Step %d: Update scaling group %d of %d on '%s'.\n%s\n", step, curScale, maxScale, d.cluster.Stack, formattedChanges)
-			if !d.autoContinue {
+			formattedChanges := formatChanges(sgp.Units)
+			ui.HeaderSink <- fmt.Sprintf("Step %d: Update scaling group %d of %d on '%s'.\n%s\n", r.step, curScale, maxScale, d.cluster.Stack, formattedChanges)
+			if confirm && !d.autoContinue {
 				if err := ui.Confirm("Are you sure you want to continue?"); err != nil {
-					return maskAny(err)
+					return false, maskAny(err)
 				}
 			}
 		}
 
-		// Destroy the obsolete & modified units
-		if len(unitNamesToDestroy) > 0 {
-			if err := d.destroyUnits(s, modifiedUnitNames, failedUnitNames, obsoleteUnitNames, ui); err != nil {
-				return maskAny(err)
+		// Perform the step, limiting its duration (if configured)
+		err = func() error {
+			stepCtx, cancel := d.stepContext(ctx)
+			defer cancel()
+
+			// Save the current version of all units we are about to replace
+			inCanary := canary != nil && uint(r.modifications) < canary.Count
+			if anyModifications && (d.RollbackOnFailure || inCanary) {
+				rs, err := d.createRollbackStep(stepCtx, s, loadedJobUnits, sgp, ui)
+				if err != nil {
+					return d.failRollout(s, r, err, ui)
+				}
+				r.rollbackSteps = append(r.rollbackSteps, rs)
 			}
 
-			InterruptibleSleep(ui.MessageSink, s.UpdateDestroyDelay(d.DestroyDelay), "Waiting for %s...")
-		}
+			// Destroy the obsolete & modified units
+			if len(unitNamesToDestroy) > 0 {
+				if err := d.destroyUnits(stepCtx, s, modifiedUnitNames, failedUnitNames, obsoleteUnitNames, ui); err != nil {
+					return d.failRollout(s, r, err, ui)
+				}
 
-		// Now launch everything
-		unitsToLaunch := sg.selectByNames(modifiedUnitNames, failedUnitNames, newUnitNames)
-		if unitsToLaunch.Len() > 0 {
-			if err := launchUnits(s, unitsToLaunch, ui); err != nil {
-				return maskAny(err)
+				if err := ui.Wait(stepCtx, s.UpdateDestroyDelay(d.DestroyDelay), "Waiting for %s..."); err != nil {
+					return d.failRollout(s, r, err, ui)
+				}
+			}
+
+			// Now launch everything
+			unitsToLaunch := sg.selectByNames(sgp.unitNames(PlanActionUpdate, PlanActionRestart, PlanActionCreate))
+			if unitsToLaunch.Len() > 0 {
+				if err := launchUnits(stepCtx, s, unitsToLaunch, ui); err != nil {
+					return d.failRollout(s, r, err, ui)
+				}
+			}
+
+			// Wait for all units of the scaling group to become healthy
+			if anyModifications && d.HealthCheck.IsEnabled() {
+				if err := d.waitUntilHealthy(stepCtx, s, sg.Units(), d.HealthTimeout, ui); err != nil {
+					return d.failRollout(s, r, err, ui)
+				}
 			}
+			return nil
+		}()
+		if err != nil {
+			return false, maskAny(err)
 		}
 
 		// Update counters
 		if anyModifications {
 			waitBeforeNextStep = true
-			totalModifications++
+			r.modifications++
+			d.notify(NotifyEvent{Event: cluster.NotifyEventStep, Step: r.step, ScalingGroup: sgp.ScalingGroup, Units: sgp.unitNames(PlanActionCreate, PlanActionUpdate, PlanActionRestart, PlanActionRemove)}, ui)
 		}
-		step++
-		ui.Clear()
-	}
-
-	// Destroy remaining units
-	if len(remainingLoadedJobUnitNames) > 0 {
-		changes := []string{"# Unit | Action"}
-		changes = append(changes, formatChanges("# ", unitsToNames(remainingLoadedJobUnitNames), "Remove (is obsolete) !!!", ui)...)
-		sort.Strings(changes[1:])
-		formattedChanges := strings.Replace(columnize.SimpleFormat(changes), "#", " ", -1)
-		ui.HeaderSink <- fmt.Sprintf("Step %d: Cleanup of obsolete units on '%s'.\n%s\n", step, d.cluster.Stack, formattedChanges)
-		if err := ui.Confirm("Are you sure you want to continue?"); err != nil {
-			return maskAny(err)
+		r.step++
+		if r.recordProgress {
+			d.completeScalingGroup(sgp.ScalingGroup, ui)
 		}
+		ui.Clear()
 
-		if err := d.destroyUnits(s, nil, nil, remainingLoadedJobUnitNames, ui); err != nil {
-			return maskAny(err)
+		// Wait for promotion of the canary
+		if anyModifications && canary != nil && uint(r.modifications) == canary.Count && hasChangesAfter(plan, sgIndex) {
+			promoted, err := d.promoteCanary(ctx, s, *canary, r.rollbackSteps, ui)
+			if err != nil {
+				return false, maskAny(err)
+			}
+			if !promoted {
+				return false, nil
+			}
+			ui.Clear()
 		}
-
-		totalModifications++
 	}
+	return true, nil
+}
 
-	// Notify in case we did nothing
-	if totalModifications == 0 {
-		ui.MessageSink <- "No modifications needed."
-	} else {
-		ui.MessageSink <- "Done."
+// stepContext returns a context for a single step of a deployment.
+// The context is canceled when the configured step timeout has passed.
+func (d *Deployment) stepContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.StepTimeout > 0 {
+		return context.WithTimeout(ctx, d.StepTimeout)
 	}
-
-	return nil
+	return context.WithCancel(ctx)
 }
 
-// isFailedPredicate creates a predicate that returns true when the given unit file is in the failed status.
-func (d *Deployment) isFailedPredicate(sg scalingGroupUnits, f scheduler.Scheduler, ui *stateUI) func(scheduler.Unit) bool {
-	return func(unit scheduler.Unit) bool {
-		ui.MessageSink <- fmt.Sprintf("Checking state of %s", unit.Name())
-		unitState, err := f.GetState(unit)
-		if scheduler.IsNotFound(err) {
-			ui.Verbosef("Unit '%s' is not found\n", unit.Name())
-			return true
-		} else if err != nil {
-			ui.Warningf("GetState(%s) failed: %#v", unit.Name(), err)
-		}
-		if unitState.Failed {
-			ui.SetStateExtra(unit.Name(), unitState.Message)
-			ui.Verbosef("Unit '%s' is in failed state\n", unit.Name())
-			return true
-		}
-		return false
+// checkFailed returns true (and the failure message) when the given unit file is in the failed status.
+func (d *Deployment) checkFailed(ctx context.Context, unit scheduler.Unit, f scheduler.Scheduler, ui *stateUI) (string, bool) {
+	ui.MessageSink <- fmt.Sprintf("Checking state of %s", unit.Name())
+	unitState, err := f.GetState(ctx, unit)
+	if scheduler.IsNotFound(err) {
+		ui.Verbosef("Unit '%s' is not found\n", unit.Name())
+		return "", true
+	} else if err != nil {
+		ui.Warningf("GetState(%s) failed: %#v", unit.Name(), err)
 	}
+	if unitState.Failed {
+		ui.Verbosef("Unit '%s' is in failed state\n", unit.Name())
+		return unitState.Message, true
+	}
+	return "", false
 }
 
-// isModifiedPredicate creates a predicate that returns true when the given unit file is modified
-func (d *Deployment) isModifiedPredicate(sg scalingGroupUnits, f scheduler.Scheduler, ui *stateUI) func(scheduler.Unit) bool {
-	return func(unit scheduler.Unit) bool {
-		if d.force {
-			return true
-		}
-		ui.MessageSink <- fmt.Sprintf("Checking %s for modifications", unit.Name())
-		newUnit, err := sg.get(unit)
-		if err != nil {
-			ui.Verbosef("Failed to read new '%s' unit: %#v\n", unit.Name(), err)
-			return true // Assume it is modified
-		}
-		diffs, changed, err := f.HasChanged(newUnit)
-		if err != nil {
-			ui.Verbosef("Failed to check '%s' for changes: %#v\n", unit.Name(), err)
-			return true // Assume it is modified
-		}
-		if changed {
-			postfix := ""
-			if len(diffs) > 3 {
-				diffs = diffs[:3]
-				postfix = "..."
-			}
-			ui.SetStateExtra(unit.Name(), strings.Join(diffs, ",")+postfix)
-			return true
-		}
-		ui.Verbosef("Unit '%s' has not changed\n", unit.Name())
-		return false
+// checkModified returns true (and a summary of the differences) when the given unit file is modified.
+func (d *Deployment) checkModified(ctx context.Context, newUnit scheduler.UnitData, f scheduler.Scheduler, ui *stateUI) ([]string, bool) {
+	if d.force {
+		return nil, true
+	}
+	ui.MessageSink <- fmt.Sprintf("Checking %s for modifications", newUnit.Name())
+	diffs, changed, err := f.HasChanged(ctx, newUnit)
+	if err != nil {
+		ui.Verbosef("Failed to check '%s' for changes: %#v\n", newUnit.Name(), err)
+		return nil, true // Assume it is modified
+	}
+	if changed {
+		return diffs, true
 	}
+	ui.Verbosef("Unit '%s' has not changed\n", newUnit.Name())
+	return nil, false
 }
 
-func launchUnits(f scheduler.Scheduler, units scheduler.UnitDataList, ui *stateUI) error {
+func launchUnits(ctx context.Context, f scheduler.Scheduler, units scheduler.UnitDataList, ui *stateUI) error {
 	ui.Verbosef("Starting %#v\n", units)
 
 	ui.MessageSink <- fmt.Sprintf("Starting %d unit(s)", units.Len())
-	if err := f.Start(ui.EventSink, units); err != nil {
+	if err := f.Start(ctx, ui.EventSink, units); err != nil {
 		return maskAny(err)
 	}
 
 	return nil
 }
 
-func formatChanges(prefix string, unitNames []string, action string, ui *stateUI) []string {
-	result := []string{}
-	for _, x := range unitNames {
-		extra := ui.GetStateExtra(x)
+// formatChanges creates a table of all units in the given list that have an action other than none.
+func formatChanges(units []UnitPlan) string {
+	changes := []string{"# Unit | Action"}
+	for _, up := range units {
+		var action, extra string
+		switch up.Action {
+		case PlanActionRemove:
+			action = "Remove (is obsolete) !!!"
+		case PlanActionUpdate:
+			action = "Update"
+			diffs := up.Diffs
+			postfix := ""
+			if len(diffs) > 3 {
+				diffs = diffs[:3]
+				postfix = "..."
+			}
+			if len(diffs) > 0 {
+				extra = strings.Join(diffs, ",") + postfix
+			}
+		case PlanActionRestart:
+			action = "Failed state"
+			extra = up.Message
+		case PlanActionCreate:
+			action = "Create"
+		default:
+			continue
+		}
 		if extra != "" {
 			extra = "(" + extra + ")"
 		}
-		result = append(result, fmt.Sprintf("%s%s | %s %s", prefix, x, action, extra))
+		changes = append(changes, fmt.Sprintf("# %s | %s %s", up.Name, action, extra))
 	}
-	sort.Strings(result)
-	return result
+	sort.Strings(changes[1:])
+	return strings.Replace(columnize.SimpleFormat(changes), "#", " ", -1)
 }
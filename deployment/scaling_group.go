@@ -15,8 +15,7 @@
 package deployment
 
 import (
-	"fmt"
-
+	"github.com/pulcy/j2/jobs"
 	"github.com/pulcy/j2/render"
 	"github.com/pulcy/j2/scheduler"
 )
@@ -34,21 +33,10 @@ func (sgu scalingGroupUnits) Units() []scheduler.Unit {
 	return units
 }
 
-func (sgu scalingGroupUnits) get(unit scheduler.Unit) (render.UnitData, error) {
-	for _, u := range sgu.units {
-		if u.Name() == unit.Name() {
-			return u, nil
-		}
-	}
-	return nil, maskAny(fmt.Errorf("unit '%s' not found", unit.Name()))
-}
-
-func (sgu scalingGroupUnits) selectByNames(units ...[]scheduler.Unit) scheduler.UnitDataList {
+func (sgu scalingGroupUnits) selectByNames(unitNames []string) scalingGroupUnits {
 	names := make(map[string]struct{})
-	for _, list := range units {
-		for _, u := range list {
-			names[u.Name()] = struct{}{}
-		}
+	for _, name := range unitNames {
+		names[name] = struct{}{}
 	}
 	var result []render.UnitData
 	for _, u := range sgu.units {
@@ -70,23 +58,39 @@ func (sgu scalingGroupUnits) Get(index int) scheduler.UnitData {
 // generateScalingGroupUnits generates the unit files for the given scaling group and returns
 // their names and file names.
 func (d *Deployment) generateScalingGroupUnits(scalingGroup uint) (scalingGroupUnits, error) {
-	renderProvider, err := d.orchestrator.RenderProvider()
+	units, err := d.renderUnits(scalingGroup, d.groupSelection, false)
 	if err != nil {
 		return scalingGroupUnits{}, maskAny(err)
 	}
+
+	return scalingGroupUnits{
+		scalingGroup: scalingGroup,
+		units:        units,
+	}, nil
+}
+
+// renderUnits generates the unit files of the given groups for the given scaling group.
+// If frontendsOnly is set, only the frontend units of blue/green groups are generated.
+func (d *Deployment) renderUnits(scalingGroup uint, groups []jobs.TaskGroupName, frontendsOnly bool) ([]render.UnitData, error) {
+	return d.renderJobUnits(d.job, scalingGroup, groups, frontendsOnly)
+}
+
+// renderJobUnits generates the unit files of the given groups of the given job for the given scaling group.
+func (d *Deployment) renderJobUnits(job jobs.Job, scalingGroup uint, groups []jobs.TaskGroupName, frontendsOnly bool) ([]render.UnitData, error) {
+	renderProvider, err := d.orchestrator.RenderProvider()
+	if err != nil {
+		return nil, maskAny(err)
+	}
 	config := render.RenderConfig{
-		Groups:              d.groupSelection,
+		Groups:              groups,
 		CurrentScalingGroup: scalingGroup,
 		Cluster:             d.cluster,
+		FrontendsOnly:       frontendsOnly,
 	}
 	renderer := renderProvider.CreateRenderer(d.cluster)
-	units, err := renderer.GenerateUnits(d.job, d.renderContext, config, d.cluster.InstanceCount)
+	units, err := renderer.GenerateUnits(job, d.renderContext, config, d.cluster.InstanceCount)
 	if err != nil {
-		return scalingGroupUnits{}, maskAny(err)
+		return nil, maskAny(err)
 	}
-
-	return scalingGroupUnits{
-		scalingGroup: scalingGroup,
-		units:        units,
-	}, nil
+	return units, nil
 }
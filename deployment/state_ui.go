@@ -24,10 +24,13 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gosuri/uilive"
+	"github.com/juju/errgo"
 	"github.com/pulcy/j2/scheduler"
 	"github.com/ryanuber/columnize"
+	"golang.org/x/net/context"
 )
 
 // ESC is the ASCII code for escape character
@@ -51,19 +54,30 @@ type stateUI struct {
 	verbose      bool
 	bypassWriter io.Writer
 	autoConfirm  bool
+	events       EventSink // If set, progress is reported as events instead of in the terminal
+
+	parent       *stateUI       // Set for sections
+	title        string         // Title of a section
+	index        int            // Index of a section in its parent
+	sectionTexts map[int]string // Last rendered text of every section, by index
+	sectionCount int
 }
 
-func newStateUI(verbose bool) *stateUI {
+// newStateUI creates the UI of a command.
+// If an event sink is given, all progress is reported to that sink.
+func newStateUI(verbose bool, events EventSink) *stateUI {
 	s := &stateUI{
-		HeaderSink:  make(chan string),
-		EventSink:   make(chan scheduler.Event),
-		MessageSink: make(chan string),
-		states:      make(map[string]string),
-		stateExtras: make(map[string]string),
-		writer:      uilive.New(),
-		stopChan:    make(chan bool),
-		verbose:     verbose,
-		autoConfirm: false,
+		HeaderSink:   make(chan string),
+		EventSink:    make(chan scheduler.Event),
+		MessageSink:  make(chan string),
+		states:       make(map[string]string),
+		stateExtras:  make(map[string]string),
+		writer:       uilive.New(),
+		stopChan:     make(chan bool),
+		verbose:      verbose,
+		autoConfirm:  false,
+		events:       events,
+		sectionTexts: make(map[int]string),
 	}
 	s.bypassWriter = s.writer.Bypass()
 	//s.writer.Start()
@@ -79,7 +93,37 @@ func (s *stateUI) Close() {
 	close(s.HeaderSink)
 	close(s.EventSink)
 	close(s.MessageSink)
-	s.writer.Stop()
+	if s.parent == nil {
+		s.writer.Stop()
+	}
+}
+
+// Section creates a UI that shows its progress in a separate section, below the
+// progress of this UI. Sections can be used concurrently.
+// The section must be closed when it is no longer used.
+func (s *stateUI) Section(title string) *stateUI {
+	s.mutex.Lock()
+	index := s.sectionCount
+	s.sectionCount++
+	s.mutex.Unlock()
+
+	section := &stateUI{
+		HeaderSink:   make(chan string),
+		EventSink:    make(chan scheduler.Event),
+		MessageSink:  make(chan string),
+		states:       make(map[string]string),
+		stateExtras:  make(map[string]string),
+		stopChan:     make(chan bool),
+		verbose:      s.verbose,
+		bypassWriter: s.bypassWriter,
+		autoConfirm:  s.autoConfirm,
+		events:       s.events,
+		parent:       s,
+		title:        title,
+		index:        index,
+	}
+	go section.processSinks()
+	return section
 }
 
 func (s *stateUI) Clear() {
@@ -90,6 +134,7 @@ func (s *stateUI) Clear() {
 	s.states = make(map[string]string)
 	s.stateExtras = make(map[string]string)
 	s.lastMessage = ""
+	s.sectionTexts = make(map[int]string)
 }
 
 func (s *stateUI) SetStateExtra(unitName, extra string) {
@@ -97,6 +142,10 @@ func (s *stateUI) SetStateExtra(unitName, extra string) {
 	defer s.mutex.Unlock()
 
 	s.stateExtras[unitName] = extra
+	if s.events != nil {
+		s.emit(Event{Type: EventState, Unit: unitName, Extra: extra})
+		return
+	}
 	s.redraw()
 }
 
@@ -108,6 +157,13 @@ func (s *stateUI) GetStateExtra(unitName string) string {
 }
 
 func (s *stateUI) Confirm(question string) error {
+	if s.events != nil {
+		if !s.autoConfirm {
+			return maskAny(errgo.WithCausef(nil, NotInteractiveError, "cannot ask for confirmation when reporting events (use --yes): %s", question))
+		}
+		s.emit(Event{Type: EventConfirm, Message: question})
+		return nil
+	}
 	prefix := ""
 	for {
 		var line string
@@ -137,18 +193,85 @@ func (s *stateUI) Confirm(question string) error {
 	}
 }
 
+// Ask asks the given question until the user enters one of the given answers.
+// The answer is returned.
+func (s *stateUI) Ask(question string, answers ...string) (string, error) {
+	if s.events != nil {
+		return "", maskAny(errgo.WithCausef(nil, NotInteractiveError, "cannot ask a question when reporting events: %s", question))
+	}
+	prefix := ""
+	for {
+		s.MessageSink <- fmt.Sprintf("%s%s [%s]", prefix, question, strings.Join(answers, "|"))
+		bufStdin := bufio.NewReader(os.Stdin)
+		lineRaw, _, err := bufStdin.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line := string(lineRaw)
+		clearLine()
+
+		for _, a := range answers {
+			if line == a {
+				s.MessageSink <- ""
+				return a, nil
+			}
+		}
+		prefix = fmt.Sprintf("Please enter one of %s. ", strings.Join(answers, ", "))
+	}
+}
+
 func (s *stateUI) Warningf(format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
+	if s.events != nil {
+		s.emit(Event{Type: EventWarning, Message: strings.TrimSpace(msg)})
+		return
+	}
 	fmt.Fprintln(s.bypassWriter, strings.TrimSuffix(msg, "\n"))
 }
 
 func (s *stateUI) Verbosef(format string, args ...interface{}) {
 	if s.verbose {
 		msg := fmt.Sprintf(format, args...)
+		if s.events != nil {
+			s.emit(Event{Type: EventVerbose, Message: strings.TrimSpace(msg)})
+			return
+		}
 		fmt.Fprintln(s.bypassWriter, strings.TrimSuffix(msg, "\n"))
 	}
 }
 
+// Wait holds execution for the given duration, showing the given message with the remaining time.
+// See InterruptibleSleep.
+func (s *stateUI) Wait(ctx context.Context, duration time.Duration, message string) error {
+	if s.events != nil {
+		s.emit(Event{Type: EventWait, Message: fmt.Sprintf(message, duration), Duration: duration})
+		return maskAny(InterruptibleSleep(ctx, nil, duration, message))
+	}
+	return maskAny(InterruptibleSleep(ctx, s.MessageSink, duration, message))
+}
+
+// Summary reports the number of units per action of a completed (or failed) deployment.
+// The summary is only reported as an event, the terminal shows the progress instead.
+func (s *stateUI) Summary(actions map[PlanAction]int, err error) {
+	if s.events == nil {
+		return
+	}
+	evt := Event{Type: EventSummary, Actions: actions}
+	if err != nil {
+		evt.Error = err.Error()
+	}
+	s.emit(evt)
+}
+
+// emit reports the given event to the event sink.
+func (s *stateUI) emit(evt Event) {
+	evt.Time = time.Now()
+	if s.parent != nil {
+		evt.Section = strings.TrimSpace(s.title)
+	}
+	s.events.Event(evt)
+}
+
 func (s *stateUI) processSinks() {
 	defer s.stopWait.Done()
 	for {
@@ -170,6 +293,12 @@ func (s *stateUI) processHeader(header string) {
 	defer s.mutex.Unlock()
 
 	s.lastHeader = header
+	if s.events != nil {
+		if header = strings.TrimSpace(header); header != "" {
+			s.emit(Event{Type: EventHeader, Message: header})
+		}
+		return
+	}
 	s.redraw()
 }
 
@@ -178,6 +307,10 @@ func (s *stateUI) processEvent(evt scheduler.Event) {
 	defer s.mutex.Unlock()
 
 	s.states[evt.UnitName] = evt.Message
+	if s.events != nil {
+		s.emit(Event{Type: EventUnit, Unit: evt.UnitName, Message: evt.Message})
+		return
+	}
 	s.redraw()
 }
 
@@ -185,11 +318,44 @@ func (s *stateUI) processMessage(message string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if s.events != nil {
+		// Repeated messages (e.g. while waiting for units to become healthy) are reported once
+		if message != "" && message != s.lastMessage {
+			s.emit(Event{Type: EventMessage, Message: strings.TrimSpace(message)})
+		}
+		s.lastMessage = message
+		return
+	}
 	s.lastMessage = message
 	s.redraw()
 }
 
 func (s *stateUI) redraw() {
+	msg := s.format()
+	if s.parent != nil {
+		s.parent.updateSection(s.index, strings.TrimSuffix(s.title, "\n")+"\n\n"+msg)
+		return
+	}
+	for i := 0; i < s.sectionCount; i++ {
+		if text := s.sectionTexts[i]; text != "" {
+			msg = msg + text
+		}
+	}
+	fmt.Fprintln(s.writer, strings.TrimSuffix(msg, "\n"))
+	s.writer.Flush()
+}
+
+// updateSection stores the rendered text of the section with given index and redraws.
+func (s *stateUI) updateSection(index int, text string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sectionTexts[index] = text
+	s.redraw()
+}
+
+// format renders the header, unit states and message of this UI.
+func (s *stateUI) format() string {
 	msg := ""
 	if s.lastHeader != "" {
 		msg = msg + strings.TrimSuffix(s.lastHeader, "\n") + "\n\n"
@@ -211,6 +377,5 @@ func (s *stateUI) redraw() {
 	if s.lastMessage != "" {
 		msg = msg + strings.TrimSuffix(s.lastMessage, "\n") + "\n\n"
 	}
-	fmt.Fprintln(s.writer, strings.TrimSuffix(msg, "\n"))
-	s.writer.Flush()
+	return msg
 }
@@ -0,0 +1,190 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"sort"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/render"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+const (
+	UnitStateOK         = "ok"
+	UnitStateFailed     = "failed"
+	UnitStateNotFound   = "not-found"
+	UnitStateNotCreated = "not-created"
+	UnitStateUnknown    = "unknown"
+)
+
+// UnitStatus describes the live state of a single unit of a job.
+type UnitStatus struct {
+	Name         string             `json:"name"`
+	TaskGroup    jobs.TaskGroupName `json:"task-group,omitempty"`
+	ScalingGroup uint               `json:"scaling-group,omitempty"`
+	State        string             `json:"state"`
+	Message      string             `json:"message,omitempty"`
+	Machine      string             `json:"machine,omitempty"`
+	Changed      bool               `json:"changed"`
+	Obsolete     bool               `json:"obsolete,omitempty"`
+	Inactive     bool               `json:"inactive,omitempty"` // Unit of a blue/green group in the color that does not serve its frontends
+	Diffs        []string           `json:"diffs,omitempty"`
+}
+
+// Status fetches the state of all units of the configured job (and task-group selection)
+// from the cluster and compares them with their rendered versions.
+// Units that are rendered but do not exist on the cluster are included as well.
+// The result is sorted by task group, scaling group & unit name.
+func (d *Deployment) Status(ctx context.Context) ([]UnitStatus, error) {
+	s, err := d.orchestrator.Scheduler(d.job, d.cluster)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	allUnits, err := s.List(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	jobUnits := selectUnitNames(allUnits, d.createUnitNamePredicate(s))
+
+	// Blue/green groups are rendered in the color that serves their frontends
+	d.blueGreen = blueGreenUnits{colors: make(map[jobs.TaskGroupName]jobs.Color)}
+	for _, tg := range d.blueGreenGroups() {
+		color, err := d.activeColor(ctx, s, jobUnits, tg)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		if color == "" {
+			color = jobs.ColorBlue
+		}
+		if err := d.setColor(tg, color); err != nil {
+			return nil, maskAny(err)
+		}
+	}
+	isInactive := containsPredicate(append(d.blueGreen.retained, d.blueGreen.replaced...))
+
+	// Render the units as they should be
+	if err := d.generateScalingGroups(); err != nil {
+		return nil, maskAny(err)
+	}
+	renderedGroups := append(d.scalingGroups, d.blueGreen.frontends)
+	rendered := make(map[string]render.UnitData)
+	for _, sg := range renderedGroups {
+		for _, u := range sg.units {
+			rendered[u.Name()] = u
+		}
+	}
+
+	var result []UnitStatus
+	found := make(map[string]struct{})
+	for _, u := range jobUnits {
+		found[u.Name()] = struct{}{}
+		status := UnitStatus{
+			Name:         u.Name(),
+			TaskGroup:    d.taskGroupOf(s, u),
+			ScalingGroup: d.scalingGroupOf(s, u),
+		}
+		unitState, err := s.GetState(ctx, u)
+		if scheduler.IsNotFound(err) {
+			status.State = UnitStateNotFound
+		} else if err != nil {
+			status.State = UnitStateUnknown
+			status.Message = err.Error()
+		} else {
+			if unitState.Failed {
+				status.State = UnitStateFailed
+			} else {
+				status.State = UnitStateOK
+			}
+			status.Message = unitState.Message
+			status.Machine = unitState.Machine
+		}
+		if newUnit, ok := rendered[u.Name()]; !ok {
+			if isInactive(u) {
+				status.Inactive = true
+			} else {
+				status.Changed = true
+				status.Obsolete = true
+			}
+		} else {
+			diffs, changed, err := s.HasChanged(ctx, newUnit)
+			if err != nil {
+				changed = true // Assume it is modified
+			}
+			status.Changed = changed
+			status.Diffs = diffs
+		}
+		result = append(result, status)
+	}
+
+	// Add units that have not been created yet
+	for _, sg := range renderedGroups {
+		for _, u := range sg.units {
+			if _, ok := found[u.Name()]; ok {
+				continue
+			}
+			result = append(result, UnitStatus{
+				Name:         u.Name(),
+				TaskGroup:    d.taskGroupOf(s, u),
+				ScalingGroup: d.scalingGroupOf(s, u),
+				State:        UnitStateNotCreated,
+				Changed:      true,
+			})
+		}
+	}
+
+	sort.Sort(unitStatusByGroup(result))
+	return result, nil
+}
+
+// taskGroupOf returns the name of the task group the given unit belongs to.
+// If no such task group is found, an empty name is returned.
+func (d *Deployment) taskGroupOf(s scheduler.Scheduler, unit scheduler.Unit) jobs.TaskGroupName {
+	for _, tg := range d.job.Groups {
+		if s.IsUnitForTaskGroup(unit, tg.Name) {
+			return tg.Name
+		}
+	}
+	return ""
+}
+
+// scalingGroupOf returns the scaling group the given unit belongs to.
+// If no such scaling group is found, 0 is returned.
+func (d *Deployment) scalingGroupOf(s scheduler.Scheduler, unit scheduler.Unit) uint {
+	maxCount := d.job.MaxCount()
+	for scalingGroup := uint(1); scalingGroup <= maxCount; scalingGroup++ {
+		if s.IsUnitForScalingGroup(unit, scalingGroup) {
+			return scalingGroup
+		}
+	}
+	return 0
+}
+
+type unitStatusByGroup []UnitStatus
+
+func (l unitStatusByGroup) Len() int      { return len(l) }
+func (l unitStatusByGroup) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l unitStatusByGroup) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.TaskGroup != b.TaskGroup {
+		return a.TaskGroup < b.TaskGroup
+	}
+	if a.ScalingGroup != b.ScalingGroup {
+		return a.ScalingGroup < b.ScalingGroup
+	}
+	return a.Name < b.Name
+}
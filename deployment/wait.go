@@ -16,81 +16,84 @@ package deployment
 
 import (
 	"fmt"
-	"os"
-	"os/signal"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
-)
 
-var (
-	sleepMutex  sync.Mutex
-	sleepCond   *sync.Cond
-	stopCounter int32
-	waiting     bool
-	waitID      int32
+	"golang.org/x/net/context"
 )
 
-func init() {
-	sleepCond = sync.NewCond(&sleepMutex)
+// Interrupts is used to wake up goroutines that are holding execution in InterruptibleSleep.
+type Interrupts struct {
+	mutex       sync.Mutex
+	interrupted chan struct{}
+	waiting     int32
+}
 
-	go func() {
-		c := make(chan os.Signal, 10)
-		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
+type interruptsKey struct{}
 
-		for {
-			select {
-			case <-c:
-				if !waiting {
-					if stopCounter >= 1 {
-						os.Exit(1)
-					} else {
-						fmt.Print("Press Ctrl-C again to stop\n")
-						atomic.AddInt32(&stopCounter, 1)
-					}
-				}
-				sleepCond.Broadcast()
-			case <-time.After(time.Second * 5):
-				atomic.StoreInt32(&stopCounter, 0)
-			}
-		}
-	}()
+// NewInterrupts creates a new Interrupts instance.
+func NewInterrupts() *Interrupts {
+	return &Interrupts{
+		interrupted: make(chan struct{}),
+	}
 }
 
-// InterruptibleSleep holds execution for a given duration, or until an interrupt signal is received.
-func InterruptibleSleep(messages chan string, duration time.Duration, message string) {
-	waiting = true
-	waitID++
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		delay := time.Millisecond * 500
-		for {
-			messages <- fmt.Sprintf(message, time.Duration(time.Second*time.Duration(duration.Seconds())))
-			if !waiting {
-				break
-			}
-			time.Sleep(delay)
-			duration = duration - delay
-		}
-	}()
+// WithInterrupts returns a copy of the given context that carries the given interrupts.
+// InterruptibleSleep calls using the resulting context can be woken up by calling Interrupt.
+func WithInterrupts(ctx context.Context, i *Interrupts) context.Context {
+	return context.WithValue(ctx, interruptsKey{}, i)
+}
 
-	go func() {
-		origWaitID := waitID
-		time.Sleep(duration)
-		if waiting && origWaitID == waitID {
-			sleepCond.Broadcast()
-		}
-	}()
+// Interrupt wakes up all goroutines that are holding execution in InterruptibleSleep.
+// It returns true if there were any such goroutines.
+func (i *Interrupts) Interrupt() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	close(i.interrupted)
+	i.interrupted = make(chan struct{})
+	return atomic.LoadInt32(&i.waiting) > 0
+}
 
-	sleepMutex.Lock()
-	sleepCond.Wait()
-	sleepMutex.Unlock()
+// wait returns a channel that is closed on the next call to Interrupt.
+func (i *Interrupts) wait() chan struct{} {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.interrupted
+}
 
-	atomic.StoreInt32(&stopCounter, 0)
-	waiting = false
+// InterruptibleSleep holds execution for a given duration, or until interrupted.
+// It is safe to sleep in multiple goroutines at the same time.
+// If the given context is done before the duration has passed, its error is returned.
+// If messages is nil, no progress messages are sent.
+func InterruptibleSleep(ctx context.Context, messages chan string, duration time.Duration, message string) error {
+	var stop chan struct{}
+	if i, ok := ctx.Value(interruptsKey{}).(*Interrupts); ok {
+		atomic.AddInt32(&i.waiting, 1)
+		defer atomic.AddInt32(&i.waiting, -1)
+		stop = i.wait()
+	}
 
-	wg.Wait()
+	delay := time.Millisecond * 500
+	deadline := time.Now().Add(duration)
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	for {
+		remaining := deadline.Sub(time.Now())
+		if remaining < 0 {
+			remaining = 0
+		}
+		if messages != nil {
+			messages <- fmt.Sprintf(message, time.Duration(time.Second*time.Duration(remaining.Seconds())))
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(ctx.Err())
+		case <-stop:
+		case <-timer.C:
+		case <-time.After(delay):
+			continue
+		}
+		return nil
+	}
 }
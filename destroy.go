@@ -28,16 +28,21 @@ var (
 	destroyCmd = &cobra.Command{
 		Use:   "destroy",
 		Short: "Destroy a job on a stack.",
-		Long:  "Destroy a job on a stack.",
-		Run:   destroyRun,
+		Long: "Destroy a job on a stack. " +
+			"Jobs that are protected (by prevent-destroy or the protected-jobs of the cluster) " +
+			"are only destroyed with --override-protection, after typing the name of the job.",
+		Run: destroyRun,
 	}
 	destroyFlags struct {
 		fg.Flags
+		overrideProtection bool
 	}
 )
 
 func init() {
 	initDeploymentFlags(destroyCmd.Flags(), &destroyFlags.Flags)
+	initOutputFlags(destroyCmd.Flags(), &destroyFlags.Flags)
+	destroyCmd.Flags().BoolVar(&destroyFlags.overrideProtection, "override-protection", false, "Allow destroying a job that is protected against destroy")
 }
 
 func destroyRun(cmd *cobra.Command, args []string) {
@@ -50,36 +55,44 @@ func destroyRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		Exitf("Cannot initialize orchestrator: %v\n", err)
 	}
-	destroyValidators(&destroyFlags.Flags, *cluster, orchestrator)
+	job := destroyValidators(&destroyFlags.Flags, *cluster, orchestrator)
 
-	job := jobs.Job{
-		Name: jobs.JobName(destroyFlags.JobPath),
-	}
 	delays := deployment.DeploymentDelays{
 		StopDelay:    destroyFlags.StopDelay,
 		DestroyDelay: destroyFlags.DestroyDelay,
 		SliceDelay:   destroyFlags.SliceDelay,
 	}
-	d, err := deployment.NewDeployment(orchestrator, job, *cluster,
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
 		groups(&destroyFlags.Flags),
 		deployment.ScalingGroupSelection(destroyFlags.ScalingGroup),
 		destroyFlags.Force,
 		destroyFlags.AutoContinue,
 		globalFlags.verbose,
 		delays,
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{StealLock: destroyFlags.StealLock},
 		renderCtx)
 	assert(err)
+	d.Events = eventSink(&destroyFlags.Flags)
 
-	assert(d.Destroy())
+	assert(d.Destroy(rootCtx, deployment.DestroyOptions{
+		DryRun:             destroyFlags.DryRun,
+		OverrideProtection: destroyFlags.overrideProtection,
+	}))
 }
 
-func destroyValidators(f *fg.Flags, cluster cluster.Cluster, orchestrator extpoints.Orchestrator) {
+// destroyValidators returns the job to destroy.
+// If the job path refers to a job file, the parsed job is returned (including its destroy protection),
+// otherwise the job path is used as the name of the job.
+func destroyValidators(f *fg.Flags, cluster cluster.Cluster, orchestrator extpoints.Orchestrator) *jobs.Job {
 	j, err := loadJob(f, cluster, orchestrator)
 	if err == nil {
 		f.JobPath = j.Name.String()
+		return j
 	}
 	jn := jobs.JobName(f.JobPath)
 	if err := jn.Validate(); err != nil {
 		Exitf("--job invalid: %v\n", err)
 	}
+	return &jobs.Job{Name: jn}
 }
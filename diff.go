@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	diffCmd = &cobra.Command{
+		Use:   "diff",
+		Short: "Show the differences between a job on a stack and its rendered units.",
+		Long:  "Show a unified diff for every unit of a job that will be created, updated or removed by `run`.",
+		Run:   diffRun,
+	}
+	diffFlags struct {
+		fg.Flags
+	}
+)
+
+func init() {
+	initDeploymentFlags(diffCmd.Flags(), &diffFlags.Flags)
+}
+
+func diffRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &diffFlags.Flags, args)
+
+	cluster, err := loadCluster(&diffFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&diffFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&diffFlags.Flags),
+		deployment.ScalingGroupSelection(diffFlags.ScalingGroup),
+		diffFlags.Force,
+		diffFlags.AutoContinue,
+		globalFlags.verbose,
+		deployment.DeploymentDelays{},
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{},
+		renderCtx)
+	assert(err)
+
+	diffs, err := d.Diff(rootCtx)
+	if err != nil {
+		Exitf("Cannot compare units: %v\n", err)
+	}
+	changes := 0
+	for _, ud := range diffs {
+		if ud.Action == deployment.DiffActionUnchanged {
+			continue
+		}
+		changes++
+		fmt.Printf("# %s (%s)\n%s\n", ud.Name, ud.Action, ud.Diff)
+	}
+	if changes == 0 {
+		fmt.Println("No differences found.")
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	driftCmd = &cobra.Command{
+		Use:   "drift [job-file-or-directory...]",
+		Short: "Detect units on a stack that differ from the jobs on disk.",
+		Long: "Compare all jobs found in the given files & directories with the units on a stack. " +
+			"Reports units that are modified, missing or obsolete and units that belong to none of the jobs. " +
+			"On Kubernetes only the namespaces of the given jobs are searched for units that belong to none of the jobs. " +
+			"Exits with code 2 when drift is found.",
+		Run: driftRun,
+	}
+	driftFlags struct {
+		fg.Flags
+		output string
+		ignore []string
+	}
+)
+
+func init() {
+	initDeploymentFlags(driftCmd.Flags(), &driftFlags.Flags)
+	driftCmd.Flags().StringVar(&driftFlags.output, "output", defaultOutputFormat, "Output format (text|json)")
+	driftCmd.Flags().StringSliceVar(&driftFlags.ignore, "ignore", nil, "Glob patterns of orphaned unit names to ignore (e.g. 'gluon*')")
+}
+
+func driftRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &driftFlags.Flags, nil)
+	if driftFlags.output != "text" && driftFlags.output != "json" {
+		Exitf("--output invalid: must be text or json\n")
+	}
+	for _, pattern := range driftFlags.ignore {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			Exitf("--ignore invalid: %v\n", err)
+		}
+	}
+	paths := args
+	if len(paths) == 0 {
+		if driftFlags.JobPath == "" {
+			Exitf("No jobs specified\n")
+		}
+		paths = []string{driftFlags.JobPath}
+	}
+	jobPaths, err := findJobFiles(paths)
+	if err != nil {
+		Exitf("Cannot find jobs: %v\n", err)
+	}
+	if len(jobPaths) == 0 {
+		Exitf("No jobs found in %s\n", strings.Join(paths, ", "))
+	}
+
+	cluster, err := loadCluster(&driftFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+
+	var deployments []*deployment.Deployment
+	for _, path := range jobPaths {
+		f := driftFlags.Flags
+		f.JobPath = path
+		job, err := loadJob(&f, *cluster, orchestrator)
+		if err != nil {
+			Exitf("Cannot load job '%s': %v\n", path, err)
+		}
+		d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+			nil,
+			deployment.ScalingGroupSelection(0),
+			false,
+			false,
+			globalFlags.verbose,
+			deployment.DeploymentDelays{},
+			deployment.HealthCheckConfig{},
+			deployment.RolloutOptions{},
+			renderCtx)
+		assert(err)
+		deployments = append(deployments, d)
+	}
+
+	drift, err := deployment.Drift(rootCtx, deployments)
+	if err != nil {
+		Exitf("Cannot detect drift: %v\n", err)
+	}
+	drift = ignoreOrphans(drift, driftFlags.ignore)
+
+	if driftFlags.output == "json" {
+		if drift == nil {
+			drift = []deployment.UnitDrift{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		assert(encoder.Encode(drift))
+	} else if len(drift) == 0 {
+		fmt.Printf("No drift found in %d job(s) on '%s'\n", len(deployments), cluster.Stack)
+	} else {
+		lines := []string{"Job | Unit | Drift | Changes"}
+		for _, ud := range drift {
+			job := string(ud.Job)
+			if job == "" {
+				job = "-"
+			}
+			lines = append(lines, fmt.Sprintf("%s | %s | %s | %s", job, ud.Name, ud.Kind, strings.Join(ud.Diffs, ",")))
+		}
+		fmt.Println(columnize.SimpleFormat(lines))
+	}
+	if len(drift) > 0 {
+		os.Exit(2)
+	}
+}
+
+// findJobFiles returns the paths of all job files in the given list of files & directories.
+// Directories are searched (non-recursively) for files with an .hcl extension.
+func findJobFiles(paths []string) ([]string, error) {
+	var result []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		if !info.IsDir() {
+			result = append(result, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == ".hcl" {
+				result = append(result, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return result, nil
+}
+
+// ignoreOrphans removes all orphaned units whose name matches one of the given patterns.
+func ignoreOrphans(drift []deployment.UnitDrift, patterns []string) []deployment.UnitDrift {
+	var result []deployment.UnitDrift
+	for _, ud := range drift {
+		ignored := false
+		if ud.Kind == deployment.DriftOrphaned {
+			for _, pattern := range patterns {
+				if ok, _ := filepath.Match(pattern, ud.Name); ok {
+					ignored = true
+					break
+				}
+			}
+		}
+		if !ignored {
+			result = append(result, ud)
+		}
+	}
+	return result
+}
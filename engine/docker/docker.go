@@ -16,6 +16,7 @@ package docker
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pulcy/j2/cluster"
 	"github.com/pulcy/j2/jobs"
@@ -54,11 +55,24 @@ func (e *dockerEngine) pullCmd(image string) cmdline.Cmdline {
 }
 
 func (e *dockerEngine) stopCmd(containerName string) cmdline.Cmdline {
+	return e.stopCmdWithTimeout(containerName, e.containerTimeoutStopSec)
+}
+
+func (e *dockerEngine) stopCmdWithTimeout(containerName string, timeoutSec int) cmdline.Cmdline {
 	cmd := cmdline.Cmdline{AllowFailure: true}
-	cmd.Add(nil, e.dockerPath, "stop", fmt.Sprintf("-t %v", e.containerTimeoutStopSec), containerName)
+	cmd.Add(nil, e.dockerPath, "stop", fmt.Sprintf("-t %v", timeoutSec), containerName)
 	return cmd
 }
 
+// mainStopTimeoutSec returns the number of seconds docker waits after sending the stop signal
+// to the main container of the given task, before killing it.
+func (e *dockerEngine) mainStopTimeoutSec(t *jobs.Task) int {
+	if t.Drain == nil {
+		return e.containerTimeoutStopSec
+	}
+	return int(t.Drain.StopTimeoutDuration() / time.Second)
+}
+
 func (e *dockerEngine) removeCmd(containerName string) cmdline.Cmdline {
 	cmd := cmdline.Cmdline{AllowFailure: true}
 	cmd.Add(nil, e.dockerPath, "rm", "-f", containerName)
@@ -0,0 +1,112 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/cmdline"
+)
+
+// createDockerHealthArgs creates the `docker run` arguments that configure a docker health check
+// for the check of the given task.
+func createDockerHealthArgs(t *jobs.Task) ([]string, error) {
+	c := t.Check
+	healthCmd, err := createHealthCmd(t)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	args := []string{
+		"--health-cmd=" + strconv.Quote(healthCmd),
+		"--health-interval=" + c.IntervalDuration().String(),
+		"--health-timeout=" + c.TimeoutDuration().String(),
+		fmt.Sprintf("--health-retries=%d", c.UnhealthyThresholdCount()),
+	}
+	if delay := c.InitialDelayDuration(); delay > 0 {
+		args = append(args, "--health-start-period="+delay.String())
+	}
+	return args, nil
+}
+
+// createHealthCmd creates the shell command that is executed inside the container
+// to perform the check of the given task.
+func createHealthCmd(t *jobs.Task) (string, error) {
+	c := t.Check
+	switch c.Type {
+	case jobs.CheckTypeExec:
+		quoted := make([]string, 0, len(c.Command))
+		for _, arg := range c.Command {
+			quoted = append(quoted, cmdline.ShellQuote(arg))
+		}
+		return strings.Join(quoted, " "), nil
+	case jobs.CheckTypeHTTP, jobs.CheckTypeTCP:
+		port, err := t.CheckPort()
+		if err != nil {
+			return "", maskAny(err)
+		}
+		if c.Type == jobs.CheckTypeTCP {
+			return fmt.Sprintf("nc -z localhost %d || exit 1", port), nil
+		}
+		if !c.IsPlainHTTPGet() {
+			// wget cannot use other methods or check the status, use curl instead
+			return createCurlCmd(c.CurlArgs(port), c.StatusLinePattern()), nil
+		}
+		args := []string{"wget", "-q", "-O", "/dev/null"}
+		names := make([]string, 0, len(c.Headers))
+		for name := range c.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			args = append(args, "--header", cmdline.ShellQuote(fmt.Sprintf("%s: %s", name, c.Headers[name])))
+		}
+		path := c.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		args = append(args, cmdline.ShellQuote(fmt.Sprintf("http://localhost:%d%s", port, path)))
+		return strings.Join(args, " ") + " || exit 1", nil
+	default:
+		return "", maskAny(fmt.Errorf("Unknown check type '%s'", c.Type))
+	}
+}
+
+// createCurlCmd creates a shell command that runs curl with given arguments and fails
+// unless the status line of the response matches the given pattern.
+func createCurlCmd(curlArgs []string, statusPattern string) string {
+	quoted := make([]string, 0, len(curlArgs))
+	for _, arg := range curlArgs {
+		quoted = append(quoted, cmdline.ShellQuote(arg))
+	}
+	return fmt.Sprintf("%s | head -n 1 | grep -q %s || exit 1", strings.Join(quoted, " "), cmdline.ShellQuote(statusPattern))
+}
+
+// createReadinessWaitCmd creates a command that waits until the docker health check of the given
+// container reports healthy. It fails when the container becomes unhealthy or does not
+// become healthy in time.
+func (e *dockerEngine) createReadinessWaitCmd(t *jobs.Task, containerName string) cmdline.Cmdline {
+	c := t.Check
+	maxWait := c.InitialDelayDuration() + (c.IntervalDuration()+c.TimeoutDuration())*time.Duration(c.UnhealthyThresholdCount()+1)
+	seconds := int(math.Ceil(maxWait.Seconds()))
+	script := fmt.Sprintf("for i in $$(seq 1 %d); do s=$$(%s inspect -f {{.State.Health.Status}} %s); [ \"$$s\" = healthy ] && exit 0; [ \"$$s\" = unhealthy ] && exit 1; sleep 1; done; exit 1",
+		seconds, e.dockerPath, containerName)
+	return *cmdline.New(nil, e.shPath, "-c", "'"+script+"'")
+}
@@ -19,6 +19,7 @@ import (
 	"sort"
 	"strconv"
 
+	"github.com/pulcy/j2/cluster"
 	"github.com/pulcy/j2/engine"
 	"github.com/pulcy/j2/jobs"
 	"github.com/pulcy/j2/pkg/cmdline"
@@ -50,7 +51,7 @@ func (e *dockerEngine) CreateMainCmds(t *jobs.Task, env map[string]string, scali
 	}
 	cmds.Start = append(cmds.Start, secretsCmds...)
 	cmds.Start = append(cmds.Start,
-		e.stopCmd(containerName),
+		e.stopCmdWithTimeout(containerName, e.mainStopTimeoutSec(t)),
 		e.removeCmd(containerName),
 		e.cleanupCmd(),
 	)
@@ -63,8 +64,12 @@ func (e *dockerEngine) CreateMainCmds(t *jobs.Task, env map[string]string, scali
 
 	cmds.Start = append(cmds.Start, execStart)
 
+	if t.Check != nil {
+		cmds.StartPost = append(cmds.StartPost, e.createReadinessWaitCmd(t, containerName))
+	}
+
 	cmds.Stop = append(cmds.Stop,
-		e.stopCmd(containerName),
+		e.stopCmdWithTimeout(containerName, e.mainStopTimeoutSec(t)),
 		e.removeCmd(containerName),
 	)
 
@@ -148,6 +153,27 @@ func (e *dockerEngine) createMainDockerCmdLine(t *jobs.Task, image string, env m
 			tcpLinkIndex++
 		}
 	}
+	if t.Resources != nil {
+		args, err := createDockerResourceArgs(*t.Resources)
+		if err != nil {
+			return cmdline.Cmdline{}, maskAny(err)
+		}
+		for _, arg := range args {
+			cmd.Add(env, arg)
+		}
+	}
+	if t.Check != nil {
+		args, err := createDockerHealthArgs(t)
+		if err != nil {
+			return cmdline.Cmdline{}, maskAny(err)
+		}
+		for _, arg := range args {
+			cmd.Add(env, arg)
+		}
+	}
+	if t.Drain != nil && !t.Drain.HasDefaultStopSignal() {
+		cmd.Add(env, "--stop-signal="+t.Drain.StopSignalName())
+	}
 	for _, arg := range t.LogDriver.CreateDockerLogArgs(e.options) {
 		cmd.Add(env, arg)
 	}
@@ -168,3 +194,34 @@ func (e *dockerEngine) createMainDockerCmdLine(t *jobs.Task, image string, env m
 
 	return cmd, nil
 }
+
+// createDockerResourceArgs creates the `docker run` arguments that apply the given resources.
+// Requested CPU is passed as relative CPU shares (1024 per core) and requested memory as memory reservation.
+func createDockerResourceArgs(r cluster.Resources) ([]string, error) {
+	var args []string
+	if cpu, err := r.CPUMillis(); err != nil {
+		return nil, maskAny(err)
+	} else if cpu > 0 {
+		shares := cpu * 1024 / 1000
+		if shares < 2 {
+			shares = 2
+		}
+		args = append(args, fmt.Sprintf("--cpu-shares=%d", shares))
+	}
+	if cpuLimit, err := r.CPULimitMillis(); err != nil {
+		return nil, maskAny(err)
+	} else if cpuLimit > 0 {
+		args = append(args, "--cpus="+strconv.FormatFloat(float64(cpuLimit)/1000, 'f', -1, 64))
+	}
+	if memory, err := r.MemoryBytes(); err != nil {
+		return nil, maskAny(err)
+	} else if memory > 0 {
+		args = append(args, fmt.Sprintf("--memory-reservation=%d", memory))
+	}
+	if memoryLimit, err := r.MemoryLimitBytes(); err != nil {
+		return nil, maskAny(err)
+	} else if memoryLimit > 0 {
+		args = append(args, fmt.Sprintf("--memory=%d", memoryLimit))
+	}
+	return args, nil
+}
@@ -20,8 +20,9 @@ import (
 )
 
 type Cmds struct {
-	Start []cmdline.Cmdline
-	Stop  []cmdline.Cmdline
+	Start     []cmdline.Cmdline
+	StartPost []cmdline.Cmdline
+	Stop      []cmdline.Cmdline
 }
 
 type Engine interface {
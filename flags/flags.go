@@ -35,7 +35,17 @@ type Flags struct {
 	StopDelay            time.Duration
 	DestroyDelay         time.Duration
 	SliceDelay           time.Duration
+	HealthCheck          string
+	HealthTimeout        time.Duration
+	RollbackOnFailure    bool
+	Canary               uint
+	ParallelGroups       uint
+	StealLock            bool
+	Resume               bool
+	StepTimeout          time.Duration
+	Output               string
 	Options              Options
+	Strict               bool
 
 	vault.VaultConfig
 	vault.GithubLoginData
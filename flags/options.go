@@ -74,6 +74,19 @@ func (o *Options) SetKeyValue(key string, value interface{}) {
 	o.options[key] = value
 }
 
+// With returns a copy of the options, extended with the given key/value pairs.
+// Values in the given map take precedence.
+func (o *Options) With(values map[string]interface{}) Options {
+	result := Options{}
+	for k, v := range o.options {
+		result.SetKeyValue(k, v)
+	}
+	for k, v := range values {
+		result.SetKeyValue(k, v)
+	}
+	return result
+}
+
 func (o *Options) Type() string {
 	return "options"
 }
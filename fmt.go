@@ -0,0 +1,94 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+var (
+	fmtCmd = &cobra.Command{
+		Use:   "fmt <file>...",
+		Short: "Rewrite job and cluster files in canonical layout.",
+		Long:  "Rewrite job and cluster files in canonical layout. Go template sections are left untouched.",
+		Run:   fmtRun,
+	}
+	fmtFlags struct {
+		check bool
+	}
+)
+
+func init() {
+	fmtCmd.Flags().BoolVar(&fmtFlags.check, "check", false, "Do not rewrite files, but fail if a file is not formatted")
+}
+
+func fmtRun(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		Exitf("No files specified\n")
+	}
+	failed := false
+	for _, path := range args {
+		changed, err := formatFile(path, fmtFlags.check)
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			failed = true
+		} else if changed {
+			// List files that are (or need to be) changed
+			fmt.Println(path)
+			if fmtFlags.check {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// formatFile formats the job or cluster file with given path.
+// Returns true if the file was not formatted.
+// If checkOnly is set, the file itself is not changed.
+func formatFile(path string, checkOnly bool) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, maskAny(err)
+	}
+	formatted, err := hclutil.Format(data, jobs.FormatRules, cluster.FormatRules)
+	if err != nil {
+		return false, maskAny(err)
+	}
+	if bytes.Equal(data, formatted) {
+		return false, nil
+	}
+	if !checkOnly {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, maskAny(err)
+		}
+		if err := ioutil.WriteFile(path, formatted, info.Mode()); err != nil {
+			return false, maskAny(err)
+		}
+	}
+	return true, nil
+}
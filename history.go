@@ -0,0 +1,104 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "Show the deployment history of a job on a stack.",
+		Long:  "Show when, by whom and with which version of j2 a job has been deployed on a stack.",
+		Run:   historyRun,
+	}
+	historyFlags struct {
+		fg.Flags
+		output string
+	}
+)
+
+func init() {
+	initDeploymentFlags(historyCmd.Flags(), &historyFlags.Flags)
+	historyCmd.Flags().StringVar(&historyFlags.output, "output", defaultOutputFormat, "Output format (text|json)")
+}
+
+func historyRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &historyFlags.Flags, args)
+	if historyFlags.output != "text" && historyFlags.output != "json" {
+		Exitf("--output invalid: must be text or json\n")
+	}
+
+	cluster, err := loadCluster(&historyFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&historyFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&historyFlags.Flags),
+		deployment.ScalingGroupSelection(historyFlags.ScalingGroup),
+		historyFlags.Force,
+		historyFlags.AutoContinue,
+		globalFlags.verbose,
+		deployment.DeploymentDelays{},
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{},
+		renderCtx)
+	assert(err)
+
+	entries, err := d.History(rootCtx)
+	if err != nil {
+		Exitf("Cannot get history: %v\n", err)
+	}
+
+	if historyFlags.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		assert(encoder.Encode(entries))
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No deployments of job '%s' recorded on '%s'\n", job.Name, cluster.Stack)
+		return
+	}
+	lines := []string{"Revision | Deployed | Operator | Version | Units | Note"}
+	for _, e := range entries {
+		note := ""
+		if e.RollbackOf > 0 {
+			note = fmt.Sprintf("rollback to %d", e.RollbackOf)
+		}
+		lines = append(lines, fmt.Sprintf("%d | %s | %s | %s | %d | %s", e.Revision, e.Created.Local().Format(time.RFC1123), e.Operator, e.Version, len(e.Units), note))
+	}
+	fmt.Println(columnize.SimpleFormat(lines))
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"github.com/juju/errgo"
+)
+
+const (
+	ColorBlue  = Color("blue")
+	ColorGreen = Color("green")
+)
+
+// Color identifies one of the two versions of a task group that is deployed
+// using blue/green deployments.
+type Color string
+
+// String returns the color as a string.
+func (c Color) String() string {
+	return string(c)
+}
+
+// Validate checks if a color is a known value.
+func (c Color) Validate() error {
+	switch c {
+	case ColorBlue, ColorGreen:
+		return nil
+	default:
+		return maskAny(errgo.WithCausef(nil, ValidationError, "invalid color '%s', expected blue or green", string(c)))
+	}
+}
+
+// Other returns the color that is deployed next to the given color.
+func (c Color) Other() Color {
+	if c == ColorGreen {
+		return ColorBlue
+	}
+	return ColorGreen
+}
+
+// addBlueGreenProblems checks that the given group can be deployed using blue/green deployments
+// and adds all problems found to the given list.
+// Both colors of the group run side by side, so they cannot claim the same host ports.
+func (tg *TaskGroup) addBlueGreenProblems(p *problems) {
+	if tg.Global {
+		p.add(errgo.WithCausef(nil, ValidationError, "group %s cannot be global and blue-green", tg.Name), "global")
+	}
+	hasFrontends := false
+	for _, t := range tg.Tasks {
+		if t.Name == TaskName(ColorBlue) || t.Name == TaskName(ColorGreen) {
+			p.add(errgo.WithCausef(nil, ValidationError, "task %s of blue-green group %s cannot be named after a color", t.Name, tg.Name), "task")
+		}
+		for _, port := range t.Ports {
+			pm, err := port.Parse()
+			if err != nil {
+				// Reported by the task itself
+				continue
+			}
+			if pm.HasHostPort() {
+				p.add(errgo.WithCausef(nil, ValidationError, "task %s of blue-green group %s cannot use host port %d", t.Name, tg.Name, pm.HostPort), "task")
+			}
+		}
+		if len(t.PublicFrontEnds) > 0 || len(t.PrivateFrontEnds) > 0 {
+			hasFrontends = true
+		}
+	}
+	if !hasFrontends {
+		p.add(errgo.WithCausef(nil, ValidationError, "blue-green group %s has no frontends", tg.Name), "blue-green")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+const (
+	CanaryPromotePrompt  = CanaryPromotion("prompt")  // Ask for promotion interactively
+	CanaryPromoteManual  = CanaryPromotion("manual")  // Stop after the canary, continue with `j2 promote`
+	CanaryPromoteMetrics = CanaryPromotion("metrics") // Promote when the error rate is below a threshold
+
+	defaultCanaryAnalysis = 5 * time.Minute
+)
+
+// CanaryPromotion specifies how a canary is promoted to all scaling groups.
+type CanaryPromotion string
+
+// Validate checks if a canary promotion is a known value.
+func (cp CanaryPromotion) Validate() error {
+	switch cp {
+	case "", CanaryPromotePrompt, CanaryPromoteManual, CanaryPromoteMetrics:
+		return nil
+	default:
+		return maskAny(errgo.WithCausef(nil, ValidationError, "invalid canary promote '%s'", string(cp)))
+	}
+}
+
+// Canary specifies that a deployment first updates a limited number of scaling groups
+// and only continues with the other scaling groups once the canary has been promoted.
+type Canary struct {
+	Count        uint            `json:"count,omitempty" mapstructure:"count,omitempty"`                   // Number of scaling groups in the canary
+	Promote      CanaryPromotion `json:"promote,omitempty" mapstructure:"promote,omitempty"`               // How to promote the canary
+	MetricsURL   string          `json:"metrics-url,omitempty" mapstructure:"metrics-url,omitempty"`       // Base URL of a Prometheus compatible HTTP API
+	ErrorRate    string          `json:"error-rate,omitempty" mapstructure:"error-rate,omitempty"`         // Query expression resulting in the error rate of the canary
+	MaxErrorRate float64         `json:"max-error-rate,omitempty" mapstructure:"max-error-rate,omitempty"` // Highest error rate that allows promotion
+	Analysis     string          `json:"analysis,omitempty" mapstructure:"analysis,omitempty"`             // Time to wait before querying the error rate
+}
+
+// PromoteMode returns how the canary is promoted, defaulting to prompt.
+func (c Canary) PromoteMode() CanaryPromotion {
+	if c.Promote == "" {
+		return CanaryPromotePrompt
+	}
+	return c.Promote
+}
+
+// AnalysisDuration returns the time to wait before querying the error rate of the canary.
+func (c Canary) AnalysisDuration() time.Duration {
+	if d, err := time.ParseDuration(c.Analysis); err == nil {
+		return d
+	}
+	return defaultCanaryAnalysis
+}
+
+// Validate checks the values of the given canary.
+// If ok, return nil, otherwise returns an error.
+func (c Canary) Validate() error {
+	if c.Count == 0 {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "canary count must be > 0"))
+	}
+	if err := c.Promote.Validate(); err != nil {
+		return maskAny(err)
+	}
+	if c.Analysis != "" {
+		if _, err := time.ParseDuration(c.Analysis); err != nil {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "invalid canary analysis '%s': %v", c.Analysis, err))
+		}
+	}
+	if c.Promote == CanaryPromoteMetrics {
+		if c.MetricsURL == "" {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "canary with metrics promotion needs a metrics-url"))
+		}
+		if _, err := url.Parse(c.MetricsURL); err != nil {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "invalid canary metrics-url '%s': %v", c.MetricsURL, err))
+		}
+		if c.ErrorRate == "" {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "canary with metrics promotion needs an error-rate expression"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+type CheckType string
+
+const (
+	CheckTypeHTTP = CheckType("http")
+	CheckTypeTCP  = CheckType("tcp")
+	CheckTypeExec = CheckType("exec")
+
+	defaultCheckInterval           = 10 * time.Second
+	defaultCheckTimeout            = 5 * time.Second
+	defaultCheckHealthyThreshold   = 1
+	defaultCheckUnhealthyThreshold = 3
+)
+
+// Check specifies how to determine that a task is alive & ready to receive requests.
+// It is used as liveness & readiness probe (kubernetes), as docker health check (fleet),
+// as http check of the load-balancer and by deployment health gating.
+type Check struct {
+	Type               CheckType         `json:"type,omitempty" mapstructure:"type,omitempty"`                               // http, tcp or exec (derived from the other fields if not set)
+	Path               string            `json:"path,omitempty" mapstructure:"path,omitempty"`                               // Path of the http request
+	Method             string            `json:"method,omitempty" mapstructure:"method,omitempty"`                           // Method of the http request (default GET)
+	Port               int               `json:"port,omitempty" mapstructure:"port,omitempty"`                               // Container port (default first port of the task)
+	Headers            map[string]string `json:"headers,omitempty" mapstructure:"-"`                                         // Headers of the http request
+	Status             int               `json:"status,omitempty" mapstructure:"status,omitempty"`                           // Expected status of the http response (default 2xx or 3xx)
+	Command            []string          `json:"command,omitempty" mapstructure:"command,omitempty"`                         // Command to execute in the container (exec)
+	Interval           string            `json:"interval,omitempty" mapstructure:"interval,omitempty"`                       // Time between checks
+	Timeout            string            `json:"timeout,omitempty" mapstructure:"timeout,omitempty"`                         // Maximum duration of a single check
+	InitialDelay       string            `json:"initial-delay,omitempty" mapstructure:"initial-delay,omitempty"`             // Time after start before the first check
+	HealthyThreshold   int               `json:"healthy-threshold,omitempty" mapstructure:"healthy-threshold,omitempty"`     // Successful checks needed to become healthy
+	UnhealthyThreshold int               `json:"unhealthy-threshold,omitempty" mapstructure:"unhealthy-threshold,omitempty"` // Failed checks needed to become unhealthy
+}
+
+// setDefaults derives the type of the check from its other fields, if not set.
+func (c *Check) setDefaults() {
+	if c.Type == "" {
+		if len(c.Command) > 0 {
+			c.Type = CheckTypeExec
+		} else if c.Path != "" {
+			c.Type = CheckTypeHTTP
+		} else {
+			c.Type = CheckTypeTCP
+		}
+	}
+}
+
+func (c Check) replaceVariables(ctx *variableContext) Check {
+	c.Type = CheckType(ctx.replaceString(string(c.Type)))
+	c.Path = ctx.replaceString(c.Path)
+	c.Method = ctx.replaceString(c.Method)
+	c.Headers = ctx.replaceStringMap(c.Headers)
+	c.Command = ctx.replaceStringSlice(c.Command)
+	c.Interval = ctx.replaceString(c.Interval)
+	c.Timeout = ctx.replaceString(c.Timeout)
+	c.InitialDelay = ctx.replaceString(c.InitialDelay)
+	return c
+}
+
+// Validate checks the values of the given check.
+// If ok, return nil, otherwise returns an error.
+func (c Check) Validate() error {
+	switch c.Type {
+	case CheckTypeHTTP:
+		if c.Path == "" {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "http check requires a path"))
+		}
+		if c.Status != 0 && (c.Status < 100 || c.Status > 599) {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "status must be a valid http status, got %d", c.Status))
+		}
+	case CheckTypeTCP:
+		if c.Path != "" || len(c.Headers) > 0 || c.Status != 0 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "tcp check cannot have a path, headers or status"))
+		}
+	case CheckTypeExec:
+		if len(c.Command) == 0 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "exec check requires a command"))
+		}
+	default:
+		return maskAny(errgo.WithCausef(nil, ValidationError, "check type has invalid value '%s', expected http, tcp or exec", c.Type))
+	}
+	if c.Type != CheckTypeExec && len(c.Command) > 0 {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "%s check cannot have a command", c.Type))
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "port must be a number between 1 and 65535, got %d", c.Port))
+	}
+	if c.HealthyThreshold < 0 || c.UnhealthyThreshold < 0 {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "thresholds cannot be negative"))
+	}
+	for _, d := range []struct {
+		key, value string
+	}{{"interval", c.Interval}, {"timeout", c.Timeout}, {"initial-delay", c.InitialDelay}} {
+		if _, err := parseCheckDuration(d.key, d.value, 0); err != nil {
+			return maskAny(err)
+		}
+	}
+	if c.TimeoutDuration() > c.IntervalDuration() {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "timeout (%s) cannot be longer than interval (%s)", c.TimeoutDuration(), c.IntervalDuration()))
+	}
+	return nil
+}
+
+// HTTPMethod returns the method of the http request of the check.
+func (c Check) HTTPMethod() string {
+	if c.Method == "" {
+		return "GET"
+	}
+	return c.Method
+}
+
+// IsPlainHTTPGet returns true if the check performs a GET request that accepts any 2xx or 3xx status.
+// Other checks cannot be expressed as a simple http probe.
+func (c Check) IsPlainHTTPGet() bool {
+	return strings.ToUpper(c.HTTPMethod()) == "GET" && c.Status == 0
+}
+
+// CurlArgs returns the arguments of a curl command that performs the http request of the check
+// on the given port of localhost. Only the response headers are written to stdout.
+func (c Check) CurlArgs(port int) []string {
+	args := []string{"curl", "-s", "-o", "/dev/null", "-D", "-"}
+	if method := strings.ToUpper(c.HTTPMethod()); method == "HEAD" {
+		// With -X HEAD, curl waits for a response body
+		args = append(args, "-I")
+	} else {
+		args = append(args, "-X", c.HTTPMethod())
+	}
+	names := make([]string, 0, len(c.Headers))
+	for name := range c.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-H", fmt.Sprintf("%s: %s", name, c.Headers[name]))
+	}
+	path := c.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return append(args, fmt.Sprintf("http://localhost:%d%s", port, path))
+}
+
+// StatusLinePattern returns a regular expression (for grep) that matches the status line
+// of a response with an expected status.
+func (c Check) StatusLinePattern() string {
+	if c.Status != 0 {
+		return fmt.Sprintf("^HTTP/[0-9.]* %d", c.Status)
+	}
+	return "^HTTP/[0-9.]* [23]"
+}
+
+// IsExpectedStatus returns true if the given http status indicates a healthy task.
+func (c Check) IsExpectedStatus(status int) bool {
+	if c.Status != 0 {
+		return status == c.Status
+	}
+	return status >= 200 && status < 400
+}
+
+// IntervalDuration returns the time between checks.
+func (c Check) IntervalDuration() time.Duration {
+	d, _ := parseCheckDuration("interval", c.Interval, defaultCheckInterval)
+	return d
+}
+
+// TimeoutDuration returns the maximum duration of a single check.
+func (c Check) TimeoutDuration() time.Duration {
+	d, _ := parseCheckDuration("timeout", c.Timeout, defaultCheckTimeout)
+	return d
+}
+
+// InitialDelayDuration returns the time after the start of the task before the first check.
+func (c Check) InitialDelayDuration() time.Duration {
+	d, _ := parseCheckDuration("initial-delay", c.InitialDelay, 0)
+	return d
+}
+
+// HealthyThresholdCount returns the number of successful checks needed to become healthy.
+func (c Check) HealthyThresholdCount() int {
+	if c.HealthyThreshold == 0 {
+		return defaultCheckHealthyThreshold
+	}
+	return c.HealthyThreshold
+}
+
+// UnhealthyThresholdCount returns the number of failed checks needed to become unhealthy.
+func (c Check) UnhealthyThresholdCount() int {
+	if c.UnhealthyThreshold == 0 {
+		return defaultCheckUnhealthyThreshold
+	}
+	return c.UnhealthyThreshold
+}
+
+// parseCheckDuration parses the given duration of a check, returning the given default when not set.
+func parseCheckDuration(key, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "invalid %s '%s': %v", key, value, err))
+	}
+	if d < 0 {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s cannot be negative", key))
+	}
+	return d, nil
+}
+
+// CheckPort returns the container port used by the check of the given task.
+// That is the port of the check, or the first port of the frontends or ports of the task.
+func (t *Task) CheckPort() (int, error) {
+	if t.Check == nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "task %s has no check", t.Name))
+	}
+	if t.Check.Port != 0 {
+		return t.Check.Port, nil
+	}
+	for _, f := range t.PublicFrontEnds {
+		if f.Port != 0 {
+			return f.Port, nil
+		}
+	}
+	for _, f := range t.PrivateFrontEnds {
+		if f.Port != 0 {
+			return f.Port, nil
+		}
+	}
+	for _, p := range t.Ports {
+		if pm, err := p.Parse(); err == nil && pm.IsTCP() {
+			return pm.ContainerPort, nil
+		}
+	}
+	return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s check of task %s needs a port", t.Check.Type, t.Name))
+}
+
+// LoadBalancerCheck returns the http path & method used by the load-balancer to check the given task.
+// An explicit http-check-path takes precedence over an http check.
+func (t *Task) LoadBalancerCheck() (path, method string) {
+	if t.HttpCheckPath != "" {
+		return t.HttpCheckPath, t.HttpCheckMethod
+	}
+	if t.Check != nil && t.Check.Type == CheckTypeHTTP {
+		return t.Check.Path, t.Check.Method
+	}
+	return "", ""
+}
+
+// validateCheck checks the check of the given task (if any).
+func (t *Task) validateCheck() error {
+	if t.Check == nil {
+		return nil
+	}
+	if !t.Type.IsService() {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "check of task %s is only allowed on service tasks", t.Name))
+	}
+	if err := t.Check.Validate(); err != nil {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "check of task %s: %s", t.Name, err.Error()))
+	}
+	if t.Check.Type != CheckTypeExec {
+		if _, err := t.CheckPort(); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"testing"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+func TestCheckValidate(t *testing.T) {
+	tests := []struct {
+		Input         jobs.Check
+		ErrorExpected bool
+	}{
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP, Path: "/health"}},
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP, Path: "/health", Method: "HEAD", Status: 204, Port: 8080, Headers: map[string]string{"Host": "example.com"}}},
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP, Path: "/health", Interval: "30s", Timeout: "30s", InitialDelay: "1m"}},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Port: 6379, HealthyThreshold: 2, UnhealthyThreshold: 5}},
+		{Input: jobs.Check{Type: jobs.CheckTypeExec, Command: []string{"pg_isready"}}},
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP, Path: "/health", Status: 99}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP, Path: "/health", Status: 600}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeHTTP, Path: "/health", Command: []string{"true"}}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Path: "/health"}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Status: 200}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Headers: map[string]string{"Host": "example.com"}}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeExec}, ErrorExpected: true},
+		{Input: jobs.Check{Type: "grpc", Port: 80}, ErrorExpected: true},
+		{Input: jobs.Check{}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Port: -1}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Port: 65536}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, HealthyThreshold: -1}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, UnhealthyThreshold: -1}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Interval: "often"}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, InitialDelay: "-5s"}, ErrorExpected: true},
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Interval: "5s", Timeout: "10s"}, ErrorExpected: true},
+		// The default interval (10s) is shorter than the timeout
+		{Input: jobs.Check{Type: jobs.CheckTypeTCP, Timeout: "20s"}, ErrorExpected: true},
+	}
+	for _, test := range tests {
+		err := test.Input.Validate()
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in %#v, got none", test.Input)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error in %#v: %#v", test.Input, err)
+		}
+	}
+}
+
+func TestTaskCheck(t *testing.T) {
+	tests := []struct {
+		Task          string
+		ErrorExpected bool
+	}{
+		{Task: `check { path = "/health" port = 80 }`},
+		// The type is derived from the other fields
+		{Task: `check { command = ["true"] }`},
+		// The port defaults to the first frontend or port of the task
+		{Task: `ports = ["6379"] check { }`},
+		{Task: `frontend { domain = "example.com" port = 8080 } check { path = "/health" }`},
+		{Task: `check { path = "/health" }`, ErrorExpected: true},
+		{Task: `check { type = "tcp" }`, ErrorExpected: true},
+		{Task: `type = "oneshot" check { command = ["true"] }`, ErrorExpected: true},
+		{Task: `check { path = "/health" port = 80 status = 1000 }`, ErrorExpected: true},
+	}
+	for _, test := range tests {
+		_, err := parseJobSource(`job "checks" { task "web" { image = "alpine:3.4" ` + test.Task + ` } }`)
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in '%s', got none", test.Task)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error in '%s': %v", test.Task, err)
+		}
+	}
+}
@@ -91,17 +91,26 @@ type Constraints []Constraint
 // Validate checks the values of all constraints in the given list.
 // If ok, return nil, otherwise returns an error.
 func (list Constraints) Validate() error {
+	if errors := list.errors(); len(errors) > 0 {
+		return maskAny(errors[0])
+	}
+	return nil
+}
+
+// errors checks the values of all constraints in the given list and returns all errors found.
+func (list Constraints) errors() []error {
+	var result []error
 	attributes := make(map[string]struct{})
 	for _, c := range list {
 		if err := c.Validate(); err != nil {
-			return maskAny(err)
+			result = append(result, maskAny(err))
 		}
 		if _, ok := attributes[c.Attribute]; ok {
-			return errgo.WithCausef(nil, ValidationError, "duplicate constraint for attribute '%s'", c.Attribute)
+			result = append(result, errgo.WithCausef(nil, ValidationError, "duplicate constraint for attribute '%s'", c.Attribute))
 		}
 		attributes[c.Attribute] = struct{}{}
 	}
-	return nil
+	return result
 }
 
 // Contains returns true if the given list contains a constrains with the given attribute.
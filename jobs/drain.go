@@ -0,0 +1,124 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/juju/errgo"
+)
+
+const (
+	defaultStopSignal  = "SIGTERM"
+	defaultStopTimeout = 10 * time.Second
+	maxDrainDuration   = time.Hour
+)
+
+var (
+	stopSignalPattern = regexp.MustCompile(`^(SIG[A-Z0-9+-]+|[0-9]+)$`)
+)
+
+// Drain specifies how a task is stopped gracefully.
+// The task is first removed from the load-balancer, then given the drain period
+// to finish its open connections, before it is sent the stop signal.
+// If it has not stopped after the stop timeout, it is killed.
+type Drain struct {
+	Period      string `json:"period,omitempty" mapstructure:"period,omitempty"`             // Time between deregistration and the stop signal
+	StopSignal  string `json:"stop-signal,omitempty" mapstructure:"stop-signal,omitempty"`   // Signal used to stop the task (default SIGTERM)
+	StopTimeout string `json:"stop-timeout,omitempty" mapstructure:"stop-timeout,omitempty"` // Time between the stop signal and killing the task
+}
+
+func (d Drain) replaceVariables(ctx *variableContext) Drain {
+	d.Period = ctx.replaceString(d.Period)
+	d.StopSignal = ctx.replaceString(d.StopSignal)
+	d.StopTimeout = ctx.replaceString(d.StopTimeout)
+	return d
+}
+
+// Validate checks the values of the given drain.
+// If ok, return nil, otherwise returns an error.
+func (d Drain) Validate() error {
+	if _, err := parseDrainDuration("period", d.Period, 0); err != nil {
+		return maskAny(err)
+	}
+	if _, err := parseDrainDuration("stop-timeout", d.StopTimeout, 0); err != nil {
+		return maskAny(err)
+	}
+	if d.StopSignal != "" && !stopSignalPattern.MatchString(d.StopSignal) {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "stop-signal must be a signal name (e.g. SIGQUIT) or number, got '%s'", d.StopSignal))
+	}
+	return nil
+}
+
+// PeriodDuration returns the time between the deregistration of the task and sending the stop signal.
+func (d Drain) PeriodDuration() time.Duration {
+	p, _ := parseDrainDuration("period", d.Period, 0)
+	return p
+}
+
+// StopSignalName returns the signal used to stop the task.
+func (d Drain) StopSignalName() string {
+	if d.StopSignal == "" {
+		return defaultStopSignal
+	}
+	return d.StopSignal
+}
+
+// HasDefaultStopSignal returns true if the task is stopped using the default signal (SIGTERM).
+func (d Drain) HasDefaultStopSignal() bool {
+	return d.StopSignalName() == defaultStopSignal
+}
+
+// StopTimeoutDuration returns the time between sending the stop signal and killing the task.
+func (d Drain) StopTimeoutDuration() time.Duration {
+	t, _ := parseDrainDuration("stop-timeout", d.StopTimeout, defaultStopTimeout)
+	return t
+}
+
+// parseDrainDuration parses the given duration of a drain, returning the given default when not set.
+func parseDrainDuration(key, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "invalid %s '%s': %v", key, value, err))
+	}
+	if d < 0 {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s cannot be negative", key))
+	}
+	if d > maxDrainDuration {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s cannot be longer than %s", key, maxDrainDuration))
+	}
+	if d%time.Second != 0 {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "%s must be a whole number of seconds, got '%s'", key, value))
+	}
+	return d, nil
+}
+
+// validateDrain checks the drain of the given task (if any).
+func (t *Task) validateDrain() error {
+	if t.Drain == nil {
+		return nil
+	}
+	if t.Type.IsProxy() {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "drain of task %s is not allowed on proxy tasks", t.Name))
+	}
+	if err := t.Drain.Validate(); err != nil {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "drain of task %s: %s", t.Name, err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+func TestDrainValidate(t *testing.T) {
+	tests := []struct {
+		Input         jobs.Drain
+		ErrorExpected bool
+	}{
+		{Input: jobs.Drain{}},
+		{Input: jobs.Drain{Period: "15s", StopSignal: "SIGQUIT", StopTimeout: "30s"}},
+		{Input: jobs.Drain{Period: "1h"}},
+		{Input: jobs.Drain{Period: "0s", StopTimeout: "0s"}},
+		{Input: jobs.Drain{StopSignal: "3"}},
+		{Input: jobs.Drain{StopSignal: "SIGRTMIN+1"}},
+		{Input: jobs.Drain{Period: "soon"}, ErrorExpected: true},
+		{Input: jobs.Drain{Period: "-5s"}, ErrorExpected: true},
+		{Input: jobs.Drain{Period: "1h1s"}, ErrorExpected: true},
+		{Input: jobs.Drain{Period: "1500ms"}, ErrorExpected: true},
+		{Input: jobs.Drain{StopTimeout: "2h"}, ErrorExpected: true},
+		{Input: jobs.Drain{StopTimeout: "10"}, ErrorExpected: true},
+		{Input: jobs.Drain{StopSignal: "QUIT"}, ErrorExpected: true},
+		{Input: jobs.Drain{StopSignal: "sigquit"}, ErrorExpected: true},
+		{Input: jobs.Drain{StopSignal: "-3"}, ErrorExpected: true},
+		{Input: jobs.Drain{StopSignal: "SIGQUIT; rm -rf /"}, ErrorExpected: true},
+	}
+	for _, test := range tests {
+		err := test.Input.Validate()
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in %#v, got none", test.Input)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error in %#v: %#v", test.Input, err)
+		}
+	}
+}
+
+func TestDrainDefaults(t *testing.T) {
+	tests := []struct {
+		Input               jobs.Drain
+		Period, StopTimeout time.Duration
+		StopSignal          string
+		DefaultStopSignal   bool
+	}{
+		{Input: jobs.Drain{}, Period: 0, StopTimeout: 10 * time.Second, StopSignal: "SIGTERM", DefaultStopSignal: true},
+		{Input: jobs.Drain{Period: "15s", StopSignal: "SIGQUIT", StopTimeout: "1m"}, Period: 15 * time.Second, StopTimeout: time.Minute, StopSignal: "SIGQUIT"},
+		{Input: jobs.Drain{StopSignal: "SIGTERM", StopTimeout: "0s"}, StopTimeout: 0, StopSignal: "SIGTERM", DefaultStopSignal: true},
+	}
+	for _, test := range tests {
+		if d := test.Input.PeriodDuration(); d != test.Period {
+			t.Errorf("Unexpected period of %#v. Expected %s, got %s", test.Input, test.Period, d)
+		}
+		if d := test.Input.StopTimeoutDuration(); d != test.StopTimeout {
+			t.Errorf("Unexpected stop timeout of %#v. Expected %s, got %s", test.Input, test.StopTimeout, d)
+		}
+		if s := test.Input.StopSignalName(); s != test.StopSignal {
+			t.Errorf("Unexpected stop signal of %#v. Expected %s, got %s", test.Input, test.StopSignal, s)
+		}
+		if b := test.Input.HasDefaultStopSignal(); b != test.DefaultStopSignal {
+			t.Errorf("Unexpected default stop signal of %#v. Expected %v, got %v", test.Input, test.DefaultStopSignal, b)
+		}
+	}
+}
+
+func TestTaskDrain(t *testing.T) {
+	tests := []struct {
+		Task          string
+		ErrorExpected bool
+	}{
+		{Task: `image = "alpine:3.4" drain { period = "15s" }`},
+		{Task: `image = "alpine:3.4" drain { stop-signal = "SIGQUIT" stop-timeout = "1m" }`},
+		{Task: `image = "alpine:3.4" drain { period = "15" }`, ErrorExpected: true},
+		{Task: `type = "proxy" target = "other.web.web" drain { period = "15s" }`, ErrorExpected: true},
+	}
+	for _, test := range tests {
+		_, err := parseJobSource(`job "drains" { task "web" { ` + test.Task + ` } }`)
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error in '%s', got none", test.Task)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error in '%s': %v", test.Task, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+// FormatRules specifies the canonical layout of job files.
+var FormatRules = hclutil.FormatRules{
+	Root: "job",
+	KeyOrder: map[string][]string{
+		"job":   append([]string{"id", "prevent-destroy"}, jobBlockKeys...),
+		"group": append([]string{"count", "global", "blue-green", "prevent-destroy", "restart"}, groupBlockKeys...),
+		// In the order of the fields of taskData, followed by the parse-only fields of parseTask
+		"task": []string{
+			"type",
+			"engine",
+			"timer",
+			"image",
+			"after",
+			"volumes-from",
+			"volumes",
+			"args",
+			"env",
+			"ports",
+			"frontend",
+			"private-frontend",
+			"http-check-path",
+			"http-check-method",
+			"sticky",
+			"backup",
+			"capabilities",
+			"network",
+			"links",
+			"link",
+			"secret",
+			"docker-args",
+			"log-driver",
+			"target",
+			"rewrite",
+			"user",
+			"metrics",
+			"resources",
+			"check",
+			"drain",
+			"count",
+			"global",
+			"constraint",
+		},
+		"frontend":         []string{"domain", "path-prefix", "ssl-cert", "port", "host-port", "user", "mode", "weight"},
+		"private-frontend": []string{"port", "host-port", "user", "weight", "mode", "register-instance"},
+		"user":             []string{"password"},
+		"secret":           []string{"field", "environment", "file"},
+		"constraint":       []string{"attribute", "value", "operator"},
+		"dependency":       []string{"network", "private-frontend"},
+		"link":             []string{"type", "ports"},
+		"rewrite":          []string{"path-prefix", "remove-path-prefix", "domain"},
+		"metrics":          []string{"port", "path", "rules-path"},
+		"resources":        cluster.ResourcesKeyOrder,
+		"check":            []string{"type", "path", "method", "port", "headers", "status", "command", "interval", "timeout", "initial-delay", "healthy-threshold", "unhealthy-threshold"},
+		"drain":            []string{"period", "stop-signal", "stop-timeout"},
+		"canary":           []string{"count", "promote", "metrics-url", "error-rate", "max-error-rate", "analysis"},
+	},
+	SortedBlocks: []string{"env"},
+	Values: map[string]func(string) (string, error){
+		"task.volumes": formatVolume,
+		"task.ports":   formatPortMapping,
+	},
+}
+
+func init() {
+	// Hooks contain a task definition
+	FormatRules.KeyOrder["hook"] = append(append([]string{}, hookBlockKeys...), FormatRules.KeyOrder["task"]...)
+	// Notify blocks are the same as in cluster files
+	for k, v := range cluster.NotifyKeyOrder {
+		FormatRules.KeyOrder[k] = v
+	}
+}
+
+// formatVolume returns the canonical form of the given volume.
+func formatVolume(input string) (string, error) {
+	v, err := ParseVolume(input)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return v.String(), nil
+}
+
+// formatPortMapping returns the canonical form of the given port mapping.
+func formatPortMapping(input string) (string, error) {
+	p, err := PortMapping(input).Parse()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return p.CanonicalString(), nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"testing"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+func TestFormatRules(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Input    string
+		Expected string
+	}{
+		{
+			Name: "group attributes before blocks",
+			Input: `job "shop" {
+	group "web" {
+		task "web" {
+			image = "nginx:1.11"
+		}
+		restart = "all" // If one task is restarted, restart all tasks.
+		constraint {
+			attribute = "node.id"
+			value = "1"
+		}
+		count = 2
+	}
+}
+`,
+			Expected: `job "shop" {
+	group "web" {
+		count = 2
+		restart = "all" // If one task is restarted, restart all tasks.
+		task "web" {
+			image = "nginx:1.11"
+		}
+		constraint {
+			attribute = "node.id"
+			value = "1"
+		}
+	}
+}
+`,
+		},
+		{
+			Name: "template lines",
+			Input: `job "shop" {
+	id="1"
+
+{{if (eq .Cluster.Orchestrator "fleet")}}
+	task "web" {
+		image = "nginx:1.11"
+		global = true
+	}
+{{else if (eq .Cluster.Orchestrator "kubernetes")}}
+	task "web" {
+		image = "nginx:1.12"
+	}
+{{end}}
+}
+`,
+			Expected: `job "shop" {
+	id = "1"
+
+{{if (eq .Cluster.Orchestrator "fleet")}}
+	task "web" {
+		image = "nginx:1.11"
+		global = true
+	}
+{{else if (eq .Cluster.Orchestrator "kubernetes")}}
+	task "web" {
+		image = "nginx:1.12"
+	}
+{{end}}
+}
+`,
+		},
+	}
+	for _, test := range tests {
+		result, err := hclutil.Format([]byte(test.Input), jobs.FormatRules)
+		if err != nil {
+			t.Errorf("%s: failed to format: %v", test.Name, err)
+		} else if string(result) != test.Expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.Name, test.Expected, string(result))
+		}
+	}
+}
@@ -44,6 +44,23 @@ type jobFunctions struct {
 	log     *logging.Logger
 	vault.VaultConfig
 	vault.GithubLoginData
+	offline     bool // If set, no network resources are accessed
+	stubSecrets bool // If set (in offline mode), secrets are replaced by a placeholder
+	lint        *lintContext
+	linkTargets []LinkName  // Targets used in `link_url`, `link_tcp` & `link_tls` template functions
+	output      *outputSpan // Output of the template that is being executed
+}
+
+// lintContext holds information recorded during template execution that is used by Lint.
+type lintContext struct {
+	linkRefs []linkRef
+	failures []Diagnostic // Errors of template functions, which do not stop the template in lint mode
+}
+
+// linkRef is a reference to a link target made in a template function.
+type linkRef struct {
+	Target LinkName
+	File   string
 }
 
 // newJobFunctions creates a new instance of jobFunctions
@@ -74,9 +91,9 @@ func (jf *jobFunctions) Functions() template.FuncMap {
 		"public_ipv4":  func() string { return "${COREOS_PUBLIC_IPV4}" },
 		"hostname":     func() string { return "%H" },
 		"machine_id":   func() string { return "%m" },
-		"link_url":     linkURL,
-		"link_tcp":     linkTCP,
-		"link_tls":     linkTLS,
+		"link_url":     jf.linkURL,
+		"link_tcp":     jf.linkTCP,
+		"link_tls":     jf.linkTLS,
 		"secret":       jf.vaultExtract,
 		"include":      jf.include,
 	}
@@ -88,11 +105,27 @@ func (jf *jobFunctions) Options() parseJobOptions {
 	}
 }
 
+// fail returns the given error of a template function.
+// In lint mode the error is recorded at the current line of the template instead,
+// so the template continues and all failures are reported.
+func (jf *jobFunctions) fail(err error) error {
+	if jf.lint == nil || jf.output == nil {
+		return err
+	}
+	jf.lint.failures = append(jf.lint.failures, Diagnostic{
+		Severity: SeverityError,
+		File:     jf.output.File,
+		Line:     jf.output.currentLine(),
+		Message:  err.Error(),
+	})
+	return nil
+}
+
 // getEnv loads an environment value and returns an error if it is empty.
 func (jf *jobFunctions) getEnv(key string) (string, error) {
 	value := os.Getenv(key)
 	if value == "" {
-		return "", errgo.WithCausef(nil, ValidationError, "Missing environment variables '%s'", key)
+		return "", jf.fail(errgo.WithCausef(nil, ValidationError, "Missing environment variables '%s'", key))
 	}
 	return value, nil
 }
@@ -113,12 +146,12 @@ func (jf *jobFunctions) getOpt(key string) (string, error) {
 			case "instance-count":
 				return strconv.Itoa(jf.cluster.InstanceCount), nil
 			default:
-				return "", errgo.WithCausef(nil, ValidationError, "Missing option '%s'", key)
+				return "", jf.fail(errgo.WithCausef(nil, ValidationError, "Missing option '%s'", key))
 			}
 		}
 	}
 	if result, err := formatOptionValue(value, false); err != nil {
-		return "", maskAny(err)
+		return "", jf.fail(maskAny(err))
 	} else {
 		return result, nil
 	}
@@ -164,9 +197,9 @@ func (jf *jobFunctions) cat(path string) (string, error) {
 	}
 	raw, err := ioutil.ReadFile(absPath)
 	if os.IsNotExist(err) {
-		return "", errgo.WithCausef(nil, ValidationError, "File '%s' not found", absPath)
+		return "", jf.fail(errgo.WithCausef(nil, ValidationError, "File '%s' not found", absPath))
 	} else if err != nil {
-		return "", maskAny(err)
+		return "", jf.fail(maskAny(err))
 	}
 	return string(raw), nil
 }
@@ -176,6 +209,39 @@ func (jf *jobFunctions) jobDir() string {
 	return filepath.Dir(jf.jobPath)
 }
 
+// recordLink records a reference to the given link target.
+func (jf *jobFunctions) recordLink(linkName string) {
+	jf.linkTargets = append(jf.linkTargets, LinkName(linkName))
+	if jf.lint != nil {
+		jf.lint.linkRefs = append(jf.lint.linkRefs, linkRef{Target: LinkName(linkName), File: jf.jobPath})
+	}
+}
+
+// linkResult returns the result of a link template function.
+// In lint mode invalid link targets are reported using the recorded references,
+// so they do not stop the template.
+func (jf *jobFunctions) linkResult(result string, err error) (string, error) {
+	if err != nil && jf.lint != nil {
+		return "", nil
+	}
+	return result, maskAny(err)
+}
+
+func (jf *jobFunctions) linkURL(linkName string) (string, error) {
+	jf.recordLink(linkName)
+	return jf.linkResult(linkURL(linkName))
+}
+
+func (jf *jobFunctions) linkTLS(linkName string) (string, error) {
+	jf.recordLink(linkName)
+	return jf.linkResult(linkTLS(linkName))
+}
+
+func (jf *jobFunctions) linkTCP(linkName string, port int) (string, error) {
+	jf.recordLink(linkName)
+	return jf.linkResult(linkTCP(linkName, port))
+}
+
 // linkURL creates an URL to the domain name (in private namespace) of the given link
 func linkURL(linkName string) (string, error) {
 	ln := LinkName(linkName)
@@ -204,7 +270,14 @@ func linkTCP(linkName string, port int) (string, error) {
 }
 
 // vaultExtract extracts a value out of the current Vault.
+// In offline mode, a placeholder is returned (when secrets are stubbed) or an error.
 func (jf *jobFunctions) vaultExtract(vaultPath string) (string, error) {
+	if jf.offline {
+		if jf.stubSecrets {
+			return fmt.Sprintf("<secret:%s>", vaultPath), nil
+		}
+		return "", maskAny(errgo.WithCausef(nil, ValidationError, "Cannot fetch secret '%s' in offline mode", vaultPath))
+	}
 	vault, err := vault.NewVault(jf.VaultConfig, jf.log)
 	if err != nil {
 		return "", maskAny(err)
@@ -222,27 +295,42 @@ func (jf *jobFunctions) vaultExtract(vaultPath string) (string, error) {
 func (jf *jobFunctions) include(name string) (string, error) {
 	includeData, includePath, err := jf.readInclude(name)
 	if err != nil {
-		return "", maskAny(err)
+		return "", jf.fail(maskAny(err))
 	}
 
 	// Create a template, add the function map, and parse the text.
+	buffer := &bytes.Buffer{}
 	includeJF := *jf
 	includeJF.jobPath = includePath
-	tmpl, err := template.New("include-" + name).Funcs(includeJF.Functions()).Parse(string(includeData))
+	if jf.output != nil {
+		includeJF.output = jf.output.include(includePath, buffer)
+		defer includeJF.output.complete()
+	}
+	tmplName := "include-" + name
+	tmpl, err := template.New(tmplName).Funcs(includeJF.Functions()).Parse(string(includeData))
 	if err != nil {
-		return "", maskAny(err)
+		return "", includeJF.failTemplate(maskAny(err), tmplName)
 	}
 
 	// Run the template to verify the output.
-	buffer := &bytes.Buffer{}
 	err = tmpl.Execute(buffer, includeJF.Options())
 	if err != nil {
-		return "", maskAny(err)
+		return "", includeJF.failTemplate(maskAny(err), tmplName)
 	}
-
 	return buffer.String(), nil
 }
 
+// failTemplate returns the given error of the template with given name.
+// In lint mode the error is recorded at the position it refers to in the template
+// instead, so the including template continues.
+func (jf *jobFunctions) failTemplate(err error, tmplName string) error {
+	if jf.lint == nil {
+		return err
+	}
+	jf.lint.failures = append(jf.lint.failures, templateDiagnostic(err, jf.jobPath, tmplName))
+	return nil
+}
+
 func (jf *jobFunctions) readInclude(name string) ([]byte, string, error) {
 	path := name
 	if !filepath.IsAbs(name) {
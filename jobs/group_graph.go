@@ -0,0 +1,118 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+// GroupDependencies returns the names of all other task groups of the job that the group
+// with given name depends on.
+// A group depends on another group when one of its tasks links to a task of that group,
+// or when it has a `taskgroup` constraint on that group.
+// Tasks can only be `after` tasks of their own group, so `after` keeps tasks of a group together,
+// but never relates groups.
+func (j *Job) GroupDependencies(name TaskGroupName) ([]TaskGroupName, error) {
+	tg, err := j.TaskGroup(name)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var result []TaskGroupName
+	add := func(other TaskGroupName) {
+		if other == name {
+			return
+		}
+		if _, err := j.TaskGroup(other); err != nil {
+			return
+		}
+		for _, x := range result {
+			if x == other {
+				return
+			}
+		}
+		result = append(result, other)
+	}
+	for _, t := range tg.Tasks {
+		for _, l := range t.Links {
+			jn, err := l.Target.Job()
+			if err != nil || jn != j.Name {
+				continue
+			}
+			gn, err := l.Target.TaskGroup()
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			add(gn)
+		}
+		for _, c := range t.MergedConstraints() {
+			if c.Attribute == AttributeTaskGroup {
+				add(TaskGroupName(c.Value))
+			}
+		}
+	}
+	return result, nil
+}
+
+// IndependentGroups splits the given task groups into sets of groups that do not depend on
+// each other (directly or indirectly).
+// Dependencies on groups that are not given are ignored.
+// The sets, and the groups in every set, are ordered as the groups of the job.
+func (j *Job) IndependentGroups(names []TaskGroupName) ([][]TaskGroupName, error) {
+	selected := make(map[TaskGroupName]bool)
+	for _, n := range names {
+		if _, err := j.TaskGroup(n); err != nil {
+			return nil, maskAny(err)
+		}
+		selected[n] = true
+	}
+
+	// Union all groups that are related
+	parent := make(map[TaskGroupName]TaskGroupName)
+	var find func(TaskGroupName) TaskGroupName
+	find = func(n TaskGroupName) TaskGroupName {
+		p, ok := parent[n]
+		if !ok || p == n {
+			return n
+		}
+		root := find(p)
+		parent[n] = root
+		return root
+	}
+	for n := range selected {
+		deps, err := j.GroupDependencies(n)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		for _, dep := range deps {
+			if selected[dep] {
+				parent[find(dep)] = find(n)
+			}
+		}
+	}
+
+	// Collect the sets in job order
+	var result [][]TaskGroupName
+	index := make(map[TaskGroupName]int)
+	for _, tg := range j.Groups {
+		if !selected[tg.Name] {
+			continue
+		}
+		root := find(tg.Name)
+		i, ok := index[root]
+		if !ok {
+			i = len(result)
+			index[root] = i
+			result = append(result, nil)
+		}
+		result[i] = append(result[i], tg.Name)
+	}
+	return result, nil
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pulcy/j2/cluster"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+)
+
+// testRenderer is a renderer that leaves tasks as they are.
+type testRenderer struct {
+	jobs.Renderer
+}
+
+func (testRenderer) NormalizeTask(t *jobs.Task) error {
+	return nil
+}
+
+// parseTestJob parses the given job source.
+func parseTestJob(t *testing.T, src string) *jobs.Job {
+	f, err := ioutil.TempFile("", "j2-test")
+	if err != nil {
+		t.Fatalf("Cannot create job file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(src); err != nil {
+		t.Fatalf("Cannot write job file: %v", err)
+	}
+	f.Close()
+	job, err := jobs.ParseJobFromFileOffline(f.Name(), cluster.Cluster{Stack: "test"}, testRenderer{}, fg.Options{}, nil, false)
+	if err != nil {
+		t.Fatalf("Cannot parse job: %v", err)
+	}
+	return job
+}
+
+const groupGraphTestJob = `
+job "graph" {
+	group "ga" {
+		task "ga" {
+			image = "alpine:3.4"
+			links = ["graph.gb.gb", "graph.ga.ga"]
+		}
+	}
+	group "gb" {
+		task "gb" {
+			image = "alpine:3.4"
+			links = ["graph.ga.ga"]
+		}
+	}
+	group "gc" {
+		task "gc" {
+			image = "alpine:3.4"
+			links = ["other.gx.gx"]
+		}
+	}
+	group "gd" {
+		task "gd" {
+			image = "alpine:3.4"
+			links = ["graph.ge.ge"]
+		}
+	}
+	group "ge" {
+		task "ge" {
+			image = "alpine:3.4"
+			links = ["graph.gf.gf"]
+		}
+	}
+	group "gf" {
+		task "gf" {
+			image = "alpine:3.4"
+		}
+	}
+	group "gg" {
+		task "gg" {
+			image = "alpine:3.4"
+		}
+		constraint {
+			attribute = "taskgroup"
+			value = "gc"
+		}
+	}
+}
+`
+
+func TestGroupDependencies(t *testing.T) {
+	job := parseTestJob(t, groupGraphTestJob)
+	tests := []struct {
+		Group         jobs.TaskGroupName
+		ErrorExpected bool
+		Expected      []jobs.TaskGroupName
+	}{
+		// Links to the group itself are ignored
+		{Group: "ga", Expected: []jobs.TaskGroupName{"gb"}},
+		{Group: "gb", Expected: []jobs.TaskGroupName{"ga"}},
+		// Links to other jobs are ignored
+		{Group: "gc", Expected: nil},
+		{Group: "gd", Expected: []jobs.TaskGroupName{"ge"}},
+		{Group: "gf", Expected: nil},
+		{Group: "gg", Expected: []jobs.TaskGroupName{"gc"}},
+		{Group: "unknown", ErrorExpected: true},
+	}
+	for _, test := range tests {
+		deps, err := job.GroupDependencies(test.Group)
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error for group '%s', got none", test.Group)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Unexpected error for group '%s': %#v", test.Group, err)
+			} else if !reflect.DeepEqual(test.Expected, deps) {
+				t.Errorf("Unexpected dependencies of group '%s'. Expected %v, got %v", test.Group, test.Expected, deps)
+			}
+		}
+	}
+}
+
+func TestIndependentGroups(t *testing.T) {
+	job := parseTestJob(t, groupGraphTestJob)
+	tests := []struct {
+		Groups        []jobs.TaskGroupName
+		ErrorExpected bool
+		Expected      [][]jobs.TaskGroupName
+	}{
+		// Groups that depend on each other (a cycle) end up in the same set
+		{Groups: []jobs.TaskGroupName{"ga", "gb", "gc"}, Expected: [][]jobs.TaskGroupName{{"ga", "gb"}, {"gc"}}},
+		// Indirect dependencies join a set, sets keep the order of the job
+		{Groups: []jobs.TaskGroupName{"gf", "ge", "gd"}, Expected: [][]jobs.TaskGroupName{{"gd", "ge", "gf"}}},
+		// Dependencies on groups that are not given are ignored
+		{Groups: []jobs.TaskGroupName{"gd", "gf"}, Expected: [][]jobs.TaskGroupName{{"gd"}, {"gf"}}},
+		// Constraints on another group are a dependency
+		{Groups: []jobs.TaskGroupName{"ga", "gc", "gg"}, Expected: [][]jobs.TaskGroupName{{"ga"}, {"gc", "gg"}}},
+		{Groups: []jobs.TaskGroupName{"ga", "gb", "gc", "gd", "ge", "gf", "gg"}, Expected: [][]jobs.TaskGroupName{{"ga", "gb"}, {"gc", "gg"}, {"gd", "ge", "gf"}}},
+		{Groups: nil, Expected: nil},
+		{Groups: []jobs.TaskGroupName{"ga", "unknown"}, ErrorExpected: true},
+	}
+	for _, test := range tests {
+		sets, err := job.IndependentGroups(test.Groups)
+		if test.ErrorExpected {
+			if err == nil {
+				t.Errorf("Expected error for groups %v, got none", test.Groups)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Unexpected error for groups %v: %#v", test.Groups, err)
+			} else if !reflect.DeepEqual(test.Expected, sets) {
+				t.Errorf("Unexpected sets for groups %v. Expected %v, got %v", test.Groups, test.Expected, sets)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulcy/j2/cluster"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+)
+
+// nopRenderer is a renderer that leaves tasks as they are.
+type nopRenderer struct {
+	jobs.Renderer
+}
+
+func (nopRenderer) NormalizeTask(t *jobs.Task) error {
+	return nil
+}
+
+// writeFiles writes the given files (name -> content) into a new temporary directory
+// and returns the path of that directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "j2-test")
+	if err != nil {
+		t.Fatalf("Cannot create directory: %v", err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// parseJobSource parses (and validates) the given job source.
+func parseJobSource(src string) (*jobs.Job, error) {
+	f, err := ioutil.TempFile("", "j2-test")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(src); err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.Close()
+	return jobs.ParseJobFromFileOffline(f.Name(), cluster.Cluster{Stack: "test"}, nopRenderer{}, fg.Options{}, nil, false)
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/juju/errgo"
+)
+
+const (
+	HookPreDeploy  = HookType("pre-deploy")  // Runs before the rolling update
+	HookPostDeploy = HookType("post-deploy") // Runs after the rolling update
+
+	// hookTaskName is the name of the task in the group of a hook
+	hookTaskName = TaskName("hook")
+	// defaultHookTimeout is the maximum time to wait for a hook to complete, if not set
+	defaultHookTimeout = 5 * time.Minute
+)
+
+var (
+	// hookBlockKeys are the keys of a hook block that are not part of its task
+	hookBlockKeys = []string{"timeout"}
+)
+
+// HookType specifies when a hook runs.
+type HookType string
+
+// Validate checks if a hook type is a known value.
+func (ht HookType) Validate() error {
+	switch ht {
+	case HookPreDeploy, HookPostDeploy:
+		return nil
+	default:
+		return maskAny(errgo.WithCausef(nil, ValidationError, "invalid hook '%s', expected %s or %s", string(ht), HookPreDeploy, HookPostDeploy))
+	}
+}
+
+// groupName returns the name of the task group that runs a hook of this type
+// for the given group, or for the job when owner is empty.
+func (ht HookType) groupName(owner TaskGroupName) TaskGroupName {
+	prefix := "hook"
+	if owner != "" {
+		prefix = string(owner)
+	}
+	return TaskGroupName(prefix + "_" + strings.Replace(string(ht), "-", "_", -1))
+}
+
+// parseHooks parses the `hook` blocks of a job or group (owner) into task groups
+// that contain a single oneshot task.
+func parseHooks(list *ast.ObjectList, owner TaskGroupName) (TaskGroupList, error) {
+	list = list.Children()
+	var result TaskGroupList
+	seen := make(map[HookType]struct{})
+	for _, item := range list.Items {
+		ht := HookType(item.Keys[0].Token.Value().(string))
+		if err := ht.Validate(); err != nil {
+			return nil, maskAny(err)
+		}
+		if _, ok := seen[ht]; ok {
+			return nil, maskAny(errgo.WithCausef(nil, ValidationError, "hook '%s' defined more than once", ht))
+		}
+		seen[ht] = struct{}{}
+		obj, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			return nil, maskAny(errgo.WithCausef(nil, ValidationError, "hook '%s': should be an object", ht))
+		}
+
+		hookObj, taskObj := splitHookObject(obj)
+		var timeout string
+		if o := hookObj.Filter("timeout"); len(o.Items) > 0 {
+			if len(o.Items) > 1 {
+				return nil, maskAny(errgo.WithCausef(nil, ValidationError, "hook '%s' defines multiple timeouts", ht))
+			}
+			lit, ok := o.Items[0].Val.(*ast.LiteralType)
+			if !ok || lit.Token.Type != token.STRING {
+				return nil, maskAny(errgo.WithCausef(nil, ValidationError, "timeout of hook '%s' is not a string", ht))
+			}
+			timeout = lit.Token.Value().(string)
+		}
+
+		t := &parseTask{}
+		t.Name = hookTaskName
+		if err := t.parse(taskObj, false); err != nil {
+			return nil, maskAny(errgo.Notef(err, "error parsing hook '%s'", ht))
+		}
+		if t.Type == "" {
+			t.Type = "oneshot"
+		} else if !t.Type.IsOneshot() {
+			return nil, maskAny(errgo.WithCausef(nil, ValidationError, "hook '%s' must be of type oneshot, got '%s'", ht, t.Type))
+		}
+
+		tg := &TaskGroup{
+			Name:        ht.groupName(owner),
+			Count:       1,
+			Hook:        ht,
+			HookOf:      owner,
+			HookTimeout: timeout,
+		}
+		if err := tg.addAll(parseTaskList{t}); err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, tg)
+	}
+	return result, nil
+}
+
+// splitHookObject splits the given hook object into the keys of the hook itself
+// and an object with the definition of its task.
+func splitHookObject(obj *ast.ObjectType) (*ast.ObjectList, *ast.ObjectType) {
+	hookList := &ast.ObjectList{}
+	taskList := &ast.ObjectList{}
+	isHookKey := func(item *ast.ObjectItem) bool {
+		if len(item.Keys) == 0 {
+			return false
+		}
+		for _, key := range hookBlockKeys {
+			if item.Keys[0].Token.Text == key || item.Keys[0].Token.Text == `"`+key+`"` {
+				return true
+			}
+		}
+		return false
+	}
+	for _, item := range obj.List.Items {
+		if isHookKey(item) {
+			hookList.Add(item)
+		} else {
+			taskList.Add(item)
+		}
+	}
+	return hookList, &ast.ObjectType{Lbrace: obj.Lbrace, Rbrace: obj.Rbrace, List: taskList}
+}
+
+// addHookProblems checks the configuration of a group that runs a hook
+// and adds all problems found to the given list.
+func (tg *TaskGroup) addHookProblems(p *problems) {
+	p.add(tg.Hook.Validate())
+	if _, err := parseHookTimeout(tg.HookTimeout); err != nil {
+		p.add(errgo.WithCausef(nil, ValidationError, "hook '%s' of %s: %v", tg.Hook, tg.HookOwner(), err), "timeout")
+	}
+	if tg.HookOf != "" {
+		if _, err := tg.job.TaskGroup(tg.HookOf); err != nil {
+			p.add(errgo.WithCausef(nil, ValidationError, "hook '%s' refers to unknown group %s", tg.Hook, tg.HookOf))
+		}
+	}
+	for _, t := range tg.Tasks {
+		if len(t.PublicFrontEnds) > 0 || len(t.PrivateFrontEnds) > 0 {
+			p.add(errgo.WithCausef(nil, ValidationError, "hook '%s' of %s cannot have frontends", tg.Hook, tg.HookOwner()), "frontend", "private-frontend")
+		}
+		if t.Timer != "" {
+			p.add(errgo.WithCausef(nil, ValidationError, "hook '%s' of %s cannot have a timer", tg.Hook, tg.HookOwner()), "timer")
+		}
+	}
+}
+
+// HookTimeoutDuration returns the maximum time to wait for the hook run by this group to complete.
+func (tg *TaskGroup) HookTimeoutDuration() time.Duration {
+	d, _ := parseHookTimeout(tg.HookTimeout)
+	return d
+}
+
+// parseHookTimeout parses the timeout of a hook, returning the default when not set.
+func parseHookTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultHookTimeout, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "invalid timeout '%s': %v", value, err))
+	}
+	if d <= 0 {
+		return 0, maskAny(errgo.WithCausef(nil, ValidationError, "timeout must be positive, got '%s'", value))
+	}
+	return d, nil
+}
+
+// HookOwner returns a description of the job or group a hook belongs to.
+func (tg *TaskGroup) HookOwner() string {
+	if tg.HookOf != "" {
+		return fmt.Sprintf("group %s", tg.HookOf)
+	}
+	return fmt.Sprintf("job %s", tg.job.Name)
+}
+
+// IsHook returns true if this group runs a hook of the job or of another group.
+func (tg *TaskGroup) IsHook() bool {
+	return tg.Hook != ""
+}
+
+// HooksOf returns the groups that run the hooks of given type of the job and of
+// all given groups.
+func (j *Job) HooksOf(ht HookType, includes func(TaskGroupName) bool) TaskGroupList {
+	var result TaskGroupList
+	for _, tg := range j.Hooks {
+		if tg.Hook == ht && (tg.HookOf == "" || includes(tg.HookOf)) {
+			result = append(result, tg)
+		}
+	}
+	return result
+}
+
+// allGroups returns the task groups of the job, followed by the groups that run its hooks.
+func (j *Job) allGroups() TaskGroupList {
+	return append(append(TaskGroupList{}, j.Groups...), j.Hooks...)
+}
+
+// GroupHook returns the type of hook run by the containing group.
+// It is empty when the group does not run a hook.
+func (t *Task) GroupHook() HookType {
+	return t.group.Hook
+}
@@ -42,23 +42,30 @@ func (jn JobName) Validate() error {
 }
 
 type Job struct {
-	ID           string         `json:"id,omitempty"`
-	Name         JobName        `json:"name"`
-	Groups       TaskGroupList  `json:"groups"`
-	Constraints  Constraints    `json:"constraints,omitempty"`
-	Dependencies DependencyList `json:"dependencies,omitempty"`
+	ID           string          `json:"id,omitempty"`
+	Name         JobName         `json:"name"`
+	Groups       TaskGroupList   `json:"groups"`
+	Constraints  Constraints     `json:"constraints,omitempty"`
+	Dependencies DependencyList  `json:"dependencies,omitempty"`
+	Canary       *Canary         `json:"canary,omitempty"`
+	Hooks        TaskGroupList   `json:"hooks,omitempty"`  // Groups that run the hooks of the job & its groups
+	Notify       *cluster.Notify `json:"notify,omitempty"` // Webhooks that override those of the cluster
+
+	PreventDestroy bool `json:"prevent-destroy,omitempty" mapstructure:"prevent-destroy,omitempty"` // Destroying the job requires an explicit override
+
+	linkTargets []LinkName // Targets used in link template functions
 }
 
 // setDefaults fills in all default value.
 func (j *Job) setDefaults(cluster cluster.Cluster) {
-	for _, tg := range j.Groups {
+	for _, tg := range j.allGroups() {
 		tg.setDefaults(cluster)
 	}
 }
 
 // Link objects just after parsing
 func (j *Job) prelink() {
-	for _, tg := range j.Groups {
+	for _, tg := range j.allGroups() {
 		tg.job = j
 		tg.prelink()
 	}
@@ -66,17 +73,18 @@ func (j *Job) prelink() {
 
 // Link objects just after replacing variables
 func (j *Job) link() {
-	for _, tg := range j.Groups {
+	for _, tg := range j.allGroups() {
 		tg.link()
 	}
 	sort.Sort(j.Groups)
+	sort.Sort(j.Hooks)
 	sort.Sort(j.Constraints)
 	sort.Sort(j.Dependencies)
 }
 
 // optimizeFor optimizes the job for the given cluster.
 func (j *Job) optimizeFor(cluster cluster.Cluster) {
-	for _, tg := range j.Groups {
+	for _, tg := range j.allGroups() {
 		tg.optimizeFor(cluster)
 	}
 }
@@ -84,7 +92,7 @@ func (j *Job) optimizeFor(cluster cluster.Cluster) {
 // replaceVariables replaces all known variables in the values of the given job.
 func (j *Job) replaceVariables(renderer Renderer, cluster cluster.Cluster) error {
 	ctx := NewVariableContext(renderer, cluster, j, nil, nil)
-	for _, x := range j.Groups {
+	for _, x := range j.allGroups() {
 		if err := x.replaceVariables(renderer, cluster); err != nil {
 			return maskAny(err)
 		}
@@ -104,24 +112,46 @@ func (j *Job) Validate() error {
 	if len(j.Groups) == 0 {
 		return maskAny(errgo.WithCausef(nil, ValidationError, "job has no groups"))
 	}
-	for i, tg := range j.Groups {
-		err := tg.Validate()
-		if err != nil {
+	for _, tg := range j.allGroups() {
+		if err := tg.Validate(); err != nil {
 			return maskAny(err)
 		}
-		for k := i + 1; k < len(j.Groups); k++ {
-			if j.Groups[k].Name == tg.Name {
-				return maskAny(errgo.WithCausef(nil, ValidationError, "job has duplicate taskgroup %s", tg.Name))
+	}
+	if err := j.ownProblems().first(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// ownProblems checks the values of the given job, excluding its task groups,
+// and returns all problems found.
+func (j *Job) ownProblems() problems {
+	var p problems
+	p.add(j.Name.Validate())
+	if len(j.Groups) == 0 {
+		p.add(errgo.WithCausef(nil, ValidationError, "job has no groups"), "group", "task")
+	}
+	groups := j.allGroups()
+	for i, tg := range groups {
+		for k := i + 1; k < len(groups); k++ {
+			if groups[k].Name == tg.Name {
+				p.add(errgo.WithCausef(nil, ValidationError, "job has duplicate taskgroup %s", tg.Name), "group", "task")
 			}
 		}
 	}
-	if err := j.Constraints.Validate(); err != nil {
-		return maskAny(err)
+	for _, err := range j.Constraints.errors() {
+		p.add(err, "constraint")
 	}
-	if err := j.Dependencies.Validate(); err != nil {
-		return maskAny(err)
+	for _, d := range j.Dependencies {
+		p.add(d.Validate(), "dependency")
 	}
-	return nil
+	if j.Canary != nil {
+		p.add(j.Canary.Validate(), "canary")
+	}
+	if j.Notify != nil {
+		p.add(j.Notify.Validate(), "notify")
+	}
+	return p
 }
 
 func (j *Job) MaxCount() uint {
@@ -153,6 +183,36 @@ func (j *Job) TaskGroup(name TaskGroupName) (*TaskGroup, error) {
 	return nil, maskAny(errgo.WithCausef(nil, TaskGroupNotFoundError, name.String()))
 }
 
+// LinkedJobs returns the names of all other jobs that this job refers to.
+// Jobs are referred to in `dependency` blocks, in links of tasks and in
+// `link_url`, `link_tcp` & `link_tls` template functions.
+func (j *Job) LinkedJobs() []JobName {
+	var targets []LinkName
+	for _, d := range j.Dependencies {
+		targets = append(targets, d.Name)
+	}
+	for _, tg := range j.Groups {
+		for _, t := range tg.Tasks {
+			for _, l := range t.Links {
+				targets = append(targets, l.Target)
+			}
+		}
+	}
+	targets = append(targets, j.linkTargets...)
+
+	var result []JobName
+	seen := make(map[JobName]bool)
+	for _, ln := range targets {
+		jn, err := ln.Job()
+		if err != nil || jn == j.Name || seen[jn] {
+			continue
+		}
+		seen[jn] = true
+		result = append(result, jn)
+	}
+	return result
+}
+
 // Dependency gets a dependency by the given name
 func (j *Job) Dependency(name LinkName) (Dependency, error) {
 	for _, d := range j.Dependencies {
@@ -0,0 +1,108 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"testing"
+
+	"github.com/pulcy/j2/jobs"
+)
+
+func TestParseJobFromJSON(t *testing.T) {
+	job, err := parseJobSource(`job "shop" {
+	canary {
+		count = 1
+	}
+	notify {
+		webhook "deploys" {
+			url = "https://deploys.example.com/events"
+			events = ["start", "finish"]
+		}
+	}
+	hook "pre-deploy" {
+		timeout = "15m"
+		image = "shop-migrations:1.0"
+		args = ["migrate"]
+	}
+	group "web" {
+		count = 2
+		blue-green = true
+		task "web" {
+			image = "nginx:1.11"
+			ports = ["80"]
+			frontend {
+				domain = "shop.example.com"
+			}
+			check {
+				path = "/health"
+			}
+			drain {
+				period = "15s"
+			}
+			links = ["shop.db.db"]
+		}
+		hook "post-deploy" {
+			image = "shop-tools:1.0"
+			args = ["warm-cache"]
+		}
+	}
+	group "db" {
+		task "db" {
+			image = "redis:3.2"
+			volumes = ["/data"]
+			constraint {
+				attribute = "node.meta.db"
+				value = "true"
+			}
+		}
+	}
+}
+`)
+	if err != nil {
+		t.Fatalf("Cannot parse job: %v", err)
+	}
+	expected, err := job.Json()
+	if err != nil {
+		t.Fatalf("Cannot convert job to json: %v", err)
+	}
+
+	parsed, err := jobs.ParseJobFromJSON(expected)
+	if err != nil {
+		t.Fatalf("Cannot parse json: %v", err)
+	}
+	actual, err := parsed.Json()
+	if err != nil {
+		t.Fatalf("Cannot convert parsed job to json: %v", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("Expected json\n%s\ngot\n%s", expected, actual)
+	}
+
+	// Tasks must be linked to their group & job again
+	web, err := parsed.TaskGroup("web")
+	if err != nil {
+		t.Fatalf("Cannot find group web: %v", err)
+	}
+	if name := web.Tasks[0].FullName(); name != "shop/web-blue/web" {
+		t.Errorf("Expected full name 'shop/web-blue/web', got '%s'", name)
+	}
+	if !web.BlueGreen || len(parsed.Hooks) != 2 || parsed.Notify == nil || parsed.Canary == nil {
+		t.Errorf("Expected blue/green, hooks, notify & canary to be restored, got %s", actual)
+	}
+
+	if _, err := jobs.ParseJobFromJSON([]byte("{")); err == nil {
+		t.Errorf("Expected error for invalid json, got none")
+	}
+}
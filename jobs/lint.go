@@ -0,0 +1,633 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/j2/cluster"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/pkg/vault"
+)
+
+// templateErrorPattern matches the template name & location prefix of errors returned by a template.
+var templateErrorPattern = regexp.MustCompile(`^template: ([^:]*):(\d+):(?:(\d+):)? (.*)$`)
+
+// outputPosPattern matches `line:column` positions in HCL parser errors.
+var outputPosPattern = regexp.MustCompile(`: \d+:\d+ `)
+
+// decodeErrorsPattern matches the first line of errors returned when decoding multiple attributes.
+var decodeErrorsPattern = regexp.MustCompile(`^\d+ error\(s\) decoding:$`)
+
+// Severity indicates how serious a problem found by Lint is.
+type Severity string
+
+const (
+	SeverityError   = Severity("error")
+	SeverityWarning = Severity("warning")
+)
+
+// Diagnostic is a single problem found by Lint.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// String returns the diagnostic formatted as `file:line:column: severity: message`.
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos = fmt.Sprintf("%s:%d", pos, d.Line)
+		if d.Column > 0 {
+			pos = fmt.Sprintf("%s:%d", pos, d.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s", pos, d.Severity, d.Message)
+}
+
+// LintResult contains all problems found by Lint.
+type LintResult struct {
+	Errors   []Diagnostic `json:"errors,omitempty"`
+	Warnings []Diagnostic `json:"warnings,omitempty"`
+}
+
+// HasErrors returns true if at least one error was found.
+func (r LintResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// maxSyntaxErrors is the maximum number of HCL syntax errors reported by Lint.
+const maxSyntaxErrors = 10
+
+// Lint parses the job in the file with given path and checks it for problems.
+// Unlike ParseJobFromFile it does not stop at the first problem, but collects all of them,
+// each with the file, line & column it originates from (including files pulled in through `include`).
+// Lint does not access any network resources, secrets are replaced by a placeholder.
+// An error is returned only when the job file cannot be read.
+func Lint(path string, cluster cluster.Cluster, renderer Renderer, options fg.Options, log *logging.Logger) (LintResult, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return LintResult{}, maskAny(err)
+	}
+	jf := newJobFunctions(path, cluster, options, log, vault.VaultConfig{}, vault.GithubLoginData{})
+	jf.offline = true
+	jf.stubSecrets = true
+	jf.lint = &lintContext{}
+	l := &linter{
+		path:    jf.jobPath,
+		sources: make(map[string][]string),
+	}
+
+	output, err := executeJobTemplate(data, jf)
+	for _, d := range jf.lint.failures {
+		l.addDiagnostic(d)
+	}
+	if err != nil {
+		// Template errors refer to lines of the job file itself, so no source map is needed.
+		l.addDiagnostic(templateDiagnostic(err, l.path, "job"))
+		return l.finish(), nil
+	}
+	l.smap = newSourceMap(jf.output)
+
+	list := l.parseHCL(output)
+	if list == nil {
+		return l.finish(), nil
+	}
+	if matches := list.Filter("job"); len(matches.Items) > 0 {
+		l.jobItem = matches.Items[0]
+	}
+
+	// Building the job fails on the first unknown key, so remove them once reported
+	for _, k := range unknownKeys(list) {
+		pos := token.Pos{Line: k.Line, Column: k.Column}
+		l.errorf(pos, "%s", k.Message())
+		removeItems(list, func(item *ast.ObjectItem) bool {
+			if len(item.Keys) == 0 {
+				return false
+			}
+			kp := item.Keys[0].Pos()
+			return kp.Line == pos.Line && kp.Column == pos.Column
+		})
+	}
+
+	job := l.buildJob(list, jf, renderer)
+	if job == nil {
+		return l.finish(), nil
+	}
+
+	l.lintJob(job)
+	l.lintLinkRefs(job, jf.lint.linkRefs)
+
+	return l.finish(), nil
+}
+
+// templateDiagnostic creates a diagnostic for the given error returned by the template with given name,
+// that is read from the given file.
+func templateDiagnostic(err error, file, tmplName string) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, File: file, Message: err.Error()}
+	if m := templateErrorPattern.FindStringSubmatch(d.Message); m != nil && m[1] == tmplName {
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		d.Message = m[4]
+	}
+	return d
+}
+
+// linter collects the diagnostics of a single job file.
+type linter struct {
+	path    string              // Path of the job file
+	smap    sourceMap           // Maps template output lines to source lines
+	jobItem *ast.ObjectItem     // The `job` block
+	sources map[string][]string // Lines of source files, used to search for link references
+	result  LintResult
+}
+
+// parseHCL parses the given template output.
+// After a syntax error, the line containing the error is cleared and parsing is retried,
+// so multiple syntax errors can be reported.
+// Syntax errors on lines where a template function failed are caused by that failure
+// and not reported again.
+// It returns nil when the output cannot be parsed.
+func (l *linter) parseHCL(output string) *ast.ObjectList {
+	lines := strings.Split(output, "\n")
+	for i := 0; i < maxSyntaxErrors; i++ {
+		list, err := parseHCL(strings.Join(lines, "\n"))
+		if err == nil {
+			return list
+		}
+		pe, ok := errgo.Cause(err).(*parser.PosError)
+		if !ok {
+			if len(l.result.Errors) == 0 {
+				// Errors without a position are likely caused by earlier errors, if any
+				l.errorf(token.Pos{}, "%s", err.Error())
+			}
+			return nil
+		}
+		if !l.failedAt(pe.Pos) {
+			// Drop positions in the message, they refer to the template output.
+			l.errorf(pe.Pos, "%s", outputPosPattern.ReplaceAllString(pe.Err.Error(), ": "))
+		}
+		if pe.Pos.Line < 1 || pe.Pos.Line > len(lines) || strings.TrimSpace(lines[pe.Pos.Line-1]) == "" {
+			// Nothing left to clear
+			return nil
+		}
+		lines[pe.Pos.Line-1] = ""
+	}
+	return nil
+}
+
+// failedAt returns true if a template function failed on the source line of the given output position.
+func (l *linter) failedAt(pos token.Pos) bool {
+	file, line := l.smap.resolve(pos.Line)
+	if line == 0 {
+		return false
+	}
+	for _, d := range l.result.Errors {
+		if d.File == file && d.Line == line {
+			return true
+		}
+	}
+	return false
+}
+
+// buildJob builds the job from the given list.
+// Items that cannot be parsed are reported at their own position and removed,
+// so the rest of the job can still be checked.
+// It returns nil when the job cannot be built at all.
+func (l *linter) buildJob(list *ast.ObjectList, jf *jobFunctions, renderer Renderer) *Job {
+	for {
+		job, err := buildJob(list, jf, renderer)
+		if err == nil {
+			return job
+		}
+		item, itemErr := l.failingItem(list, err, jf, renderer)
+		if item == nil {
+			l.errorf(l.pos(l.jobItem), "%s", err.Error())
+			return nil
+		}
+		l.errorf(item.Pos(), "%s", itemErr.Error())
+		removeItems(list, func(x *ast.ObjectItem) bool { return x == item })
+	}
+}
+
+// failingItem returns the deepest item of the job in the given list that causes
+// (part of) the given error when building the job, together with the error caused by that item alone.
+// It returns nil if the error is not caused by a single item of the job.
+func (l *linter) failingItem(list *ast.ObjectList, cause error, jf *jobFunctions, renderer Renderer) (*ast.ObjectItem, error) {
+	var path []*ast.ObjectItem
+	for _, item := range list.Items {
+		if len(item.Keys) > 0 && keyValue(item.Keys[0]) == "job" {
+			path = append(path, item)
+			break
+		}
+	}
+	if len(path) == 0 {
+		return nil, nil
+	}
+	// build returns the error of building the job with only the given items in the last item of the path,
+	// if it is (part of) the cause.
+	build := func(items []*ast.ObjectItem) error {
+		isolated := isolateItems(path, items)
+		_, err := buildJob(&ast.ObjectList{Items: []*ast.ObjectItem{isolated}}, jf, renderer)
+		if err == nil || !isPartOf(err, cause) {
+			return nil
+		}
+		return err
+	}
+	var result *ast.ObjectItem
+	var resultErr error
+	for {
+		obj := objectOf(path[len(path)-1])
+		if obj == nil || obj.List == nil {
+			return result, resultErr
+		}
+		// Drop all items that are not needed to cause the error
+		needed := obj.List.Items
+		for i := 0; i < len(needed); {
+			rest := append(append([]*ast.ObjectItem{}, needed[:i]...), needed[i+1:]...)
+			if build(rest) != nil {
+				needed = rest
+			} else {
+				i++
+			}
+		}
+		if len(needed) != 1 {
+			return result, resultErr
+		}
+		result, resultErr = needed[0], build(needed)
+		path = append(path, result)
+	}
+}
+
+// isPartOf returns true if all lines of the given error message are found in the message of the given cause.
+// Errors that occur while decoding multiple attributes are reported as a single error, with one line per attribute.
+func isPartOf(err, cause error) bool {
+	causeLines := strings.Split(cause.Error(), "\n")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line == "" || decodeErrorsPattern.MatchString(line) {
+			continue
+		}
+		found := false
+		for _, x := range causeLines {
+			if x == line {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isolateItems returns a copy of the first item in the given path, in which the object of
+// each item in the path contains only the next item in the path, and the object of the
+// last item in the path contains only the given items.
+func isolateItems(path []*ast.ObjectItem, items []*ast.ObjectItem) *ast.ObjectItem {
+	for i := len(path) - 1; i >= 0; i-- {
+		parent := *path[i]
+		obj := *objectOf(path[i])
+		obj.List = &ast.ObjectList{Items: items}
+		parent.Val = &obj
+		items = []*ast.ObjectItem{&parent}
+	}
+	return items[0]
+}
+
+// removeItems removes all items for which the given function returns true
+// from the given list and from all objects nested in it.
+func removeItems(list *ast.ObjectList, remove func(*ast.ObjectItem) bool) {
+	if list == nil {
+		return
+	}
+	var items []*ast.ObjectItem
+	for _, item := range list.Items {
+		if remove(item) {
+			continue
+		}
+		if obj := objectOf(item); obj != nil {
+			removeItems(obj.List, remove)
+		}
+		items = append(items, item)
+	}
+	list.Items = items
+}
+
+// errorf adds an error at the given position in the template output.
+func (l *linter) errorf(pos token.Pos, format string, args ...interface{}) {
+	l.add(SeverityError, pos, fmt.Sprintf(format, args...))
+}
+
+// warningf adds a warning at the given position in the template output.
+func (l *linter) warningf(pos token.Pos, format string, args ...interface{}) {
+	l.add(SeverityWarning, pos, fmt.Sprintf(format, args...))
+}
+
+// add adds a diagnostic at the given position in the template output.
+func (l *linter) add(severity Severity, pos token.Pos, msg string) {
+	d := Diagnostic{
+		Severity: severity,
+		File:     l.path,
+		Message:  msg,
+	}
+	if pos.IsValid() {
+		d.File, d.Line = l.smap.resolve(pos.Line)
+		if d.Line > 0 {
+			d.Column = pos.Column
+		}
+	}
+	l.addDiagnostic(d)
+}
+
+func (l *linter) addDiagnostic(d Diagnostic) {
+	if d.Severity == SeverityWarning {
+		l.result.Warnings = append(l.result.Warnings, d)
+	} else {
+		l.result.Errors = append(l.result.Errors, d)
+	}
+}
+
+// finish sorts all diagnostics and returns them.
+func (l *linter) finish() LintResult {
+	sort.Stable(diagnosticsByPosition(l.result.Errors))
+	sort.Stable(diagnosticsByPosition(l.result.Warnings))
+	return l.result
+}
+
+// lintJob checks the given job and all of its task groups (including hooks).
+// The rules of Validate are applied one by one, so all problems are reported,
+// each at the position of the job, group or task (attribute) it belongs to.
+func (l *linter) lintJob(j *Job) {
+	l.addProblems(l.jobItem, j.ownProblems())
+	for _, tg := range j.allGroups() {
+		l.lintTaskGroup(j, tg)
+	}
+}
+
+// lintTaskGroup checks the given task group and all of its tasks.
+func (l *linter) lintTaskGroup(j *Job, tg *TaskGroup) {
+	l.addProblems(l.groupItem(tg), tg.ownProblems())
+	for _, t := range tg.Tasks {
+		l.lintTask(j, tg, t)
+	}
+}
+
+// addProblems adds an error for each of the given problems, found in the given item.
+func (l *linter) addProblems(item *ast.ObjectItem, list problems) {
+	for _, p := range list {
+		l.errorf(l.pos(item, p.Keys...), "%s", p.Err.Error())
+	}
+}
+
+// lintTask checks the given task.
+// Next to the rules of Validate, it checks the image & link targets of the task
+// and warns about risky settings.
+func (l *linter) lintTask(j *Job, tg *TaskGroup, t *Task) {
+	ti := l.taskItem(tg, t)
+	l.addProblems(ti, t.problems())
+	if t.Type != "proxy" {
+		if err := t.Image.Validate(); err != nil {
+			l.errorf(l.pos(ti, "image"), "invalid image in task %s: %s", t.Name, err.Error())
+		} else if v := t.Image.Version; v == "" || v == "latest" {
+			l.warningf(l.pos(ti, "image"), "image '%s' of task %s does not use a fixed version", t.Image, t.Name)
+		}
+	}
+	for _, link := range t.Links {
+		if jn, err := link.Target.Job(); err == nil && jn == j.Name {
+			if _, _, err := link.Target.Resolve(j); err != nil {
+				l.errorf(l.pos(ti, "links", "link"), "link target '%s' of task %s not found", link.Target, t.Name)
+			}
+		}
+	}
+	if t.Resources != nil && t.Resources.Validate() == nil {
+		l.lintResourceRatios(ti, t)
+	}
+	for _, f := range t.PublicFrontEnds {
+		l.lintUsers(l.pos(ti, "frontend"), t, f.Users)
+	}
+	for _, f := range t.PrivateFrontEnds {
+		l.lintUsers(l.pos(ti, "private-frontend"), t, f.Users)
+	}
+}
+
+// lintUsers checks the users of a frontend.
+func (l *linter) lintUsers(pos token.Pos, t *Task, users []User) {
+	for _, u := range users {
+		if u.Password != "" {
+			l.warningf(pos, "frontend user '%s' of task %s has a plaintext password", u.Name, t.Name)
+		}
+	}
+}
+
+// lintResourceRatios warns about limits that are much higher than the requested resources of a task,
+// which allows a single task to starve the others on the same machine.
+func (l *linter) lintResourceRatios(ti *ast.ObjectItem, t *Task) {
+	const maxRatio = 10
+	r := *t.Resources
+	cpu, _ := r.CPUMillis()
+	cpuLimit, _ := r.CPULimitMillis()
+	if cpu > 0 && cpuLimit > maxRatio*cpu {
+		l.warningf(l.pos(ti, "resources"), "cpu-limit (%s) of task %s is more than %d times its cpu (%s)", r.CPULimit, t.Name, maxRatio, r.CPU)
+	}
+	memory, _ := r.MemoryBytes()
+	memoryLimit, _ := r.MemoryLimitBytes()
+	if memory > 0 && memoryLimit > maxRatio*memory {
+		l.warningf(l.pos(ti, "resources"), "memory-limit (%s) of task %s is more than %d times its memory (%s)", r.MemoryLimit, t.Name, maxRatio, r.Memory)
+	}
+}
+
+// lintLinkRefs checks the link targets used in `link_url`, `link_tcp` & `link_tls` template functions.
+func (l *linter) lintLinkRefs(j *Job, refs []linkRef) {
+	for _, ref := range refs {
+		d := l.findInSource(ref.File, strconv.Quote(string(ref.Target)))
+		jn, err := ref.Target.Job()
+		if err != nil {
+			d.Severity = SeverityError
+			d.Message = err.Error()
+		} else if jn == j.Name {
+			if _, _, err := ref.Target.Resolve(j); err != nil {
+				d.Severity = SeverityError
+				d.Message = fmt.Sprintf("link target '%s' not found", ref.Target)
+			}
+		} else if _, err := j.Dependency(ref.Target); err != nil {
+			d.Severity = SeverityWarning
+			d.Message = fmt.Sprintf("link target '%s' refers to job '%s' which is not declared as a dependency", ref.Target, jn)
+		}
+		if d.Message != "" {
+			l.addDiagnostic(d)
+		}
+	}
+}
+
+// findInSource returns a diagnostic located at the first occurrence of the given text in the given file.
+func (l *linter) findInSource(file, text string) Diagnostic {
+	lines, ok := l.sources[file]
+	if !ok {
+		if data, err := ioutil.ReadFile(file); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		l.sources[file] = lines
+	}
+	for i, line := range lines {
+		if col := strings.Index(line, text); col >= 0 {
+			return Diagnostic{File: file, Line: i + 1, Column: col + 1}
+		}
+	}
+	return Diagnostic{File: file}
+}
+
+// pos returns the position of the first of the given keys in the given item.
+// If none of the keys are found, the position of the item itself is returned.
+// If the item is nil, the position of the job is returned.
+func (l *linter) pos(item *ast.ObjectItem, keys ...string) token.Pos {
+	if item == nil {
+		item = l.jobItem
+		if item == nil {
+			return token.Pos{}
+		}
+	}
+	for _, key := range keys {
+		if items := itemsWithKey(objectOf(item), key); len(items) > 0 {
+			return items[0].Pos()
+		}
+	}
+	return item.Pos()
+}
+
+// groupItem returns the item of the given task group.
+// For task groups created from a task directly in the job, the task item is returned.
+// For task groups that run a hook, the hook item is returned.
+func (l *linter) groupItem(tg *TaskGroup) *ast.ObjectItem {
+	jobObj := objectOf(l.jobItem)
+	if tg.IsHook() {
+		owner := jobObj
+		if tg.HookOf != "" {
+			owner = objectOf(namedItem(jobObj, "group", string(tg.HookOf)))
+		}
+		return namedItem(owner, "hook", string(tg.Hook))
+	}
+	if item := namedItem(jobObj, "group", string(tg.Name)); item != nil {
+		return item
+	}
+	return namedItem(jobObj, "task", string(tg.Name))
+}
+
+// taskItem returns the item of the given task.
+func (l *linter) taskItem(tg *TaskGroup, t *Task) *ast.ObjectItem {
+	if tg.IsHook() {
+		// A hook contains a single task, declared by the hook block itself.
+		return l.groupItem(tg)
+	}
+	jobObj := objectOf(l.jobItem)
+	if gi := namedItem(jobObj, "group", string(tg.Name)); gi != nil {
+		return namedItem(objectOf(gi), "task", string(t.Name))
+	}
+	return namedItem(jobObj, "task", string(t.Name))
+}
+
+// constraintItem returns the constraint item in the given scope with the same attribute as the given constraint.
+// If recursive is set, tasks inside the scope are searched as well.
+func constraintItem(scope *ast.ObjectItem, c Constraint, recursive bool) *ast.ObjectItem {
+	obj := objectOf(scope)
+	for _, item := range itemsWithKey(obj, "constraint") {
+		for _, attr := range itemsWithKey(objectOf(item), "attribute") {
+			if lit, ok := attr.Val.(*ast.LiteralType); ok && fmt.Sprintf("%v", lit.Token.Value()) == c.Attribute {
+				return item
+			}
+		}
+	}
+	if recursive {
+		for _, ti := range itemsWithKey(obj, "task") {
+			if item := constraintItem(ti, c, false); item != nil {
+				return item
+			}
+		}
+	}
+	if items := itemsWithKey(obj, "constraint"); len(items) > 0 {
+		return items[0]
+	}
+	return nil
+}
+
+// objectOf returns the object value of the given item, or nil if it has no object value.
+func objectOf(item *ast.ObjectItem) *ast.ObjectType {
+	if item == nil {
+		return nil
+	}
+	obj, _ := item.Val.(*ast.ObjectType)
+	return obj
+}
+
+// itemsWithKey returns all items in the given object with the given (first) key.
+func itemsWithKey(obj *ast.ObjectType, key string) []*ast.ObjectItem {
+	if obj == nil || obj.List == nil {
+		return nil
+	}
+	var result []*ast.ObjectItem
+	for _, item := range obj.List.Items {
+		if len(item.Keys) > 0 && keyValue(item.Keys[0]) == key {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// namedItem returns the item in the given object with the given key, followed by the given name.
+func namedItem(obj *ast.ObjectType, key, name string) *ast.ObjectItem {
+	for _, item := range itemsWithKey(obj, key) {
+		if len(item.Keys) > 1 && keyValue(item.Keys[1]) == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// keyValue returns the (unquoted) value of the given key.
+func keyValue(key *ast.ObjectKey) string {
+	if s, ok := key.Token.Value().(string); ok {
+		return s
+	}
+	return key.Token.Text
+}
+
+type diagnosticsByPosition []Diagnostic
+
+func (l diagnosticsByPosition) Len() int      { return len(l) }
+func (l diagnosticsByPosition) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l diagnosticsByPosition) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/j2/cluster"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Files    map[string]string // The job is read from job.hcl
+		Errors   []string
+		Warnings []string
+	}{
+		{
+			Name: "valid",
+			Files: map[string]string{"job.hcl": `job "lint" {
+	task "web" {
+		image = "alpine:3.4"
+	}
+}
+`},
+		},
+		{
+			Name: "multiple problems",
+			Files: map[string]string{"job.hcl": `job "lint" {
+	group "web" {
+		count = 0
+		task "server" {
+			image = "alpine:3.4"
+			type = "foo"
+			timer = "daily"
+		}
+	}
+	task "db" {
+		image = "mysql"
+		network = "bogus"
+		unknown-key = "x"
+	}
+}
+`},
+			Errors: []string{
+				"job.hcl:3:3: error: group web count <= 0",
+				"job.hcl:6:4: error: type has invalid value 'foo'",
+				"job.hcl:7:4: error: timer only valid in combination with oneshot (in 'server')",
+				"job.hcl:12:3: error: unknown network type 'bogus'",
+				"job.hcl:13:3: error: unknown key 'unknown-key' in task",
+			},
+			Warnings: []string{
+				"job.hcl:11:3: warning: image 'mysql' of task db does not use a fixed version",
+			},
+		},
+		{
+			Name: "template, syntax & build errors",
+			Files: map[string]string{"job.hcl": `job "lint" {
+	task "web" {
+		image = "alpine:3.4"
+		env {
+			FOO = "{{opt "missing"}}"
+		}
+		count = "abc"
+		volumes-from = ["other"]
+		args = [ "a" "b" ]
+	}
+	task "db" {
+		image = "mysql:5.7"
+		global = "xyz"
+		network = "bogus"
+		count = "def"
+	}
+}
+`},
+			Errors: []string{
+				"job.hcl:5: error: Missing option 'missing'",
+				"job.hcl:7:3: error: 1 error(s) decoding:\n\n* cannot parse 'Count' as uint: strconv.ParseUint: parsing \"abc\": invalid syntax",
+				"job.hcl:8:3: error: other",
+				"job.hcl:9:16: error: error parsing list, expected comma or list end, got: STRING",
+				"job.hcl:13:3: error: 1 error(s) decoding:\n\n* cannot parse 'Global' as bool: strconv.ParseBool: parsing \"xyz\": invalid syntax",
+				"job.hcl:14:3: error: unknown network type 'bogus'",
+				"job.hcl:15:3: error: 1 error(s) decoding:\n\n* cannot parse 'Count' as uint: strconv.ParseUint: parsing \"def\": invalid syntax",
+			},
+		},
+		{
+			Name: "include",
+			Files: map[string]string{
+				"job.hcl": `job "lint" {
+	task "web" {
+		image = "alpine:3.4"
+		args = [{{include "args"}}]
+		network = "bogus"
+	}
+	{{include "db"}}
+	task "cache" {
+		image = "redis:3"
+		type = "bogus"
+	}
+}
+`,
+				"args.hcl": `"a",
+"b"
+`,
+				"db.hcl": `task "db" {
+	image = "mysql:5.7"
+	type = "bogus"
+	args = [{{include "args"}}]
+	network = "{{include "missing"}}"
+}
+`,
+			},
+			Errors: []string{
+				"db.hcl:3:2: error: type has invalid value 'bogus'",
+				"db.hcl:5: error: open missing.hcl: no such file or directory",
+				"job.hcl:5:3: error: unknown network type 'bogus'",
+				"job.hcl:10:3: error: type has invalid value 'bogus'",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		dir := writeFiles(t, test.Files)
+		defer os.RemoveAll(dir)
+		result, err := jobs.Lint(filepath.Join(dir, "job.hcl"), cluster.Cluster{Stack: "test"}, nopRenderer{}, fg.Options{}, nil)
+		if err != nil {
+			t.Fatalf("Lint of %s failed: %v", test.Name, err)
+		}
+		format := func(list []jobs.Diagnostic) []string {
+			var result []string
+			for _, d := range list {
+				result = append(result, strings.Replace(d.String(), dir+"/", "", -1))
+			}
+			return result
+		}
+		if errors := format(result.Errors); !reflect.DeepEqual(errors, test.Errors) {
+			t.Errorf("Unexpected errors for %s; expected %q, got %q", test.Name, test.Errors, errors)
+		}
+		if warnings := format(result.Warnings); !reflect.DeepEqual(warnings, test.Warnings) {
+			t.Errorf("Unexpected warnings for %s; expected %q, got %q", test.Name, test.Warnings, warnings)
+		}
+	}
+}
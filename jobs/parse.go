@@ -16,6 +16,7 @@ package jobs
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"text/template"
@@ -45,23 +46,95 @@ type parseTask struct {
 
 type parseTaskList []*parseTask
 
+var (
+	// Keys of blocks that are parsed separately (not by hclutil.Decode)
+	jobBlockKeys   = []string{"group", "task", "constraint", "dependency", "canary", "hook", "notify"}
+	groupBlockKeys = []string{"task", "constraint", "canary", "hook"}
+	taskBlockKeys  = []string{
+		"env",
+		"image",
+		"after",
+		"volumes",
+		"volumes-from",
+		"frontend",
+		"private-frontend",
+		"capabilities",
+		"links",
+		"link",
+		"secret",
+		"constraint",
+		"rewrite",
+		"metrics",
+		"resources",
+		"check",
+		"drain",
+	}
+	checkBlockKeys      = []string{"headers"}
+	frontendBlockKeys   = []string{"user"}
+	dependencyBlockKeys = []string{"private-frontend"}
+)
+
 // ParseJob takes input from a given reader and parses it into a Job.
 func parseJob(input []byte, jf *jobFunctions, renderer Renderer) (*Job, error) {
+	output, err := executeJobTemplate(input, jf)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	// Parse the input
+	list, err := parseHCL(output)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	// Check for unknown keys
+	if jf.cluster.Strict {
+		if keys := unknownKeys(list); len(keys) > 0 {
+			smap := newSourceMap(jf.output)
+			for i, k := range keys {
+				keys[i].File, keys[i].Line = smap.resolve(k.Line)
+			}
+			return nil, maskAny(&hclutil.UnknownKeysError{Keys: keys})
+		}
+	}
+
+	// Build the job
+	job, err := buildJob(list, jf, renderer)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	job.linkTargets = jf.linkTargets
+
+	// Validate the job
+	if err := job.Validate(); err != nil {
+		return nil, maskAny(err)
+	}
+
+	return job, nil
+}
+
+// executeJobTemplate runs the given job input through the template engine.
+func executeJobTemplate(input []byte, jf *jobFunctions) (string, error) {
 	// Create a template, add the function map, and parse the text.
 	tmpl, err := template.New("job").Funcs(jf.Functions()).Parse(string(input))
 	if err != nil {
-		return nil, maskAny(err)
+		return "", maskAny(err)
 	}
 
 	// Run the template to verify the output.
 	buffer := &bytes.Buffer{}
+	jf.output = newOutputSpan(jf.jobPath, buffer)
+	defer jf.output.complete()
 	err = tmpl.Execute(buffer, jf.Options())
 	if err != nil {
-		return nil, maskAny(err)
+		return "", maskAny(err)
 	}
+	return buffer.String(), nil
+}
 
-	// Parse the input
-	root, err := hcl.Parse(buffer.String())
+// parseHCL parses the given HCL text and returns its top-level object list.
+func parseHCL(input string) (*ast.ObjectList, error) {
+	root, err := hcl.Parse(input)
 	if err != nil {
 		return nil, maskAny(err)
 	}
@@ -70,7 +143,12 @@ func parseJob(input []byte, jf *jobFunctions, renderer Renderer) (*Job, error) {
 	if !ok {
 		return nil, errgo.New("error parsing: root should be an object")
 	}
+	return list, nil
+}
 
+// buildJob creates a job from the given top-level object list, prepares it
+// for the given cluster & renderer, but does not validate it.
+func buildJob(list *ast.ObjectList, jf *jobFunctions, renderer Renderer) (*Job, error) {
 	// Parse hcl into Job
 	job := &Job{}
 	matches := list.Filter("job")
@@ -99,7 +177,7 @@ func parseJob(input []byte, jf *jobFunctions, renderer Renderer) (*Job, error) {
 	job.optimizeFor(jf.cluster)
 
 	// Normalize tasks
-	for _, tg := range job.Groups {
+	for _, tg := range job.allGroups() {
 		for _, t := range tg.Tasks {
 			if err := renderer.NormalizeTask(t); err != nil {
 				return nil, maskAny(err)
@@ -107,11 +185,6 @@ func parseJob(input []byte, jf *jobFunctions, renderer Renderer) (*Job, error) {
 		}
 	}
 
-	// Validate the job
-	if err := job.Validate(); err != nil {
-		return nil, maskAny(err)
-	}
-
 	return job, nil
 }
 
@@ -130,6 +203,37 @@ func ParseJobFromFile(path string, cluster cluster.Cluster, renderer Renderer, o
 	return job, nil
 }
 
+// ParseJobFromFileOffline reads a job from file without accessing any network resources.
+// Calls to the `secret` template function result in a placeholder value when stubSecrets is set,
+// otherwise they result in an error.
+func ParseJobFromFileOffline(path string, cluster cluster.Cluster, renderer Renderer, options fg.Options,
+	log *logging.Logger, stubSecrets bool) (*Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	jf := newJobFunctions(path, cluster, options, log, vault.VaultConfig{}, vault.GithubLoginData{})
+	jf.offline = true
+	jf.stubSecrets = stubSecrets
+	job, err := parseJob(data, jf, renderer)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return job, nil
+}
+
+// ParseJobFromJSON reconstructs a job from its json representation (as created by Json),
+// such as the job recorded in the deployment history.
+// The job was resolved when it was converted to json, so no variables are replaced.
+func ParseJobFromJSON(data []byte) (*Job, error) {
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, maskAny(err)
+	}
+	job.prelink()
+	return job, nil
+}
+
 func (j *Job) parse(list *ast.ObjectList) error {
 	list = list.Children()
 	if len(list.Items) != 1 {
@@ -140,7 +244,7 @@ func (j *Job) parse(list *ast.ObjectList) error {
 	obj := list.Items[0]
 
 	// Decode the object
-	if err := hclutil.Decode(obj.Val, []string{"group", "task", "constraint", "dependency"}, nil, j); err != nil {
+	if err := hclutil.Decode(obj.Val, jobBlockKeys, nil, j); err != nil {
 		return maskAny(err)
 	}
 
@@ -211,6 +315,33 @@ func (j *Job) parse(list *ast.ObjectList) error {
 		}
 	}
 
+	// Parse canary
+	if o := listVal.Filter("canary"); len(o.Items) > 0 {
+		c, err := parseCanary(o, "job "+string(j.Name))
+		if err != nil {
+			return maskAny(err)
+		}
+		j.Canary = c
+	}
+
+	// Parse notify
+	if o := listVal.Filter("notify"); len(o.Items) > 0 {
+		n, err := cluster.ParseNotify(o, "job "+string(j.Name))
+		if err != nil {
+			return maskAny(err)
+		}
+		j.Notify = &n
+	}
+
+	// Parse hooks
+	if o := listVal.Filter("hook"); len(o.Items) > 0 {
+		hooks, err := parseHooks(o, "")
+		if err != nil {
+			return maskAny(err)
+		}
+		j.Hooks = append(j.Hooks, hooks...)
+	}
+
 	return nil
 }
 
@@ -243,6 +374,15 @@ func (j *Job) parseGroups(list *ast.ObjectList) error {
 			return maskAny(err)
 		}
 
+		// Parse hooks
+		if o := obj.List.Filter("hook"); len(o.Items) > 0 {
+			hooks, err := parseHooks(o, tg.Name)
+			if err != nil {
+				return maskAny(err)
+			}
+			j.Hooks = append(j.Hooks, hooks...)
+		}
+
 		j.Groups = append(j.Groups, tg)
 	}
 
@@ -255,7 +395,7 @@ func (tg *TaskGroup) parse(obj *ast.ObjectType) error {
 	defaultValues := map[string]interface{}{
 		"count": defaultCount,
 	}
-	if err := hclutil.Decode(obj, []string{"task", "constraint"}, defaultValues, tg); err != nil {
+	if err := hclutil.Decode(obj, groupBlockKeys, defaultValues, tg); err != nil {
 		return maskAny(err)
 	}
 
@@ -285,6 +425,15 @@ func (tg *TaskGroup) parse(obj *ast.ObjectType) error {
 		}
 	}
 
+	// Parse canary
+	if o := obj.List.Filter("canary"); len(o.Items) > 0 {
+		c, err := parseCanary(o, "task-group "+string(tg.Name))
+		if err != nil {
+			return maskAny(err)
+		}
+		tg.Canary = c
+	}
+
 	return nil
 }
 
@@ -336,26 +485,10 @@ func (tasks *parseTaskList) parseTasks(list *ast.ObjectList, anonymousGroup bool
 // parse a task
 func (t *parseTask) parse(obj *ast.ObjectType, anonymousGroup bool) error {
 	// Build the task
-	excludedKeys := []string{
-		"env",
-		"image",
-		"after",
-		"volumes",
-		"volumes-from",
-		"frontend",
-		"private-frontend",
-		"capabilities",
-		"links",
-		"link",
-		"secret",
-		"constraint",
-		"rewrite",
-		"metrics",
-	}
 	defaultValues := map[string]interface{}{
 		"count": defaultCount,
 	}
-	if err := hclutil.Decode(obj, excludedKeys, defaultValues, t); err != nil {
+	if err := hclutil.Decode(obj, taskBlockKeys, defaultValues, t); err != nil {
 		return maskAny(err)
 	}
 	if !anonymousGroup {
@@ -566,16 +699,67 @@ func (t *parseTask) parse(obj *ast.ObjectType, anonymousGroup bool) error {
 		}
 	}
 
+	// Parse resources
+	if o := obj.List.Filter("resources"); len(o.Items) > 0 {
+		if len(o.Items) > 1 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "cannot more than 1 resources object in %s", t.Name))
+		}
+		for _, o := range o.Elem().Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				r := cluster.Resources{}
+				if err := hclutil.Decode(obj, nil, nil, &r); err != nil {
+					return maskAny(err)
+				}
+				t.Resources = &r
+			} else {
+				return maskAny(errgo.WithCausef(nil, ValidationError, "resources of task %s is not an object", t.Name))
+			}
+		}
+	}
+
+	// Parse drain
+	if o := obj.List.Filter("drain"); len(o.Items) > 0 {
+		if len(o.Items) > 1 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "cannot more than 1 drain object in %s", t.Name))
+		}
+		for _, o := range o.Elem().Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				d := Drain{}
+				if err := hclutil.Decode(obj, nil, nil, &d); err != nil {
+					return maskAny(err)
+				}
+				t.Drain = &d
+			} else {
+				return maskAny(errgo.WithCausef(nil, ValidationError, "drain of task %s is not an object", t.Name))
+			}
+		}
+	}
+
+	// Parse check
+	if o := obj.List.Filter("check"); len(o.Items) > 0 {
+		if len(o.Items) > 1 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "cannot more than 1 check object in %s", t.Name))
+		}
+		for _, o := range o.Elem().Items {
+			if obj, ok := o.Val.(*ast.ObjectType); ok {
+				c := Check{}
+				if err := c.parse(obj); err != nil {
+					return maskAny(err)
+				}
+				t.Check = &c
+			} else {
+				return maskAny(errgo.WithCausef(nil, ValidationError, "check of task %s is not an object", t.Name))
+			}
+		}
+	}
+
 	return nil
 }
 
 // parse a public frontend
 func (f *PublicFrontEnd) parse(obj *ast.ObjectType) error {
 	// Build the frontend
-	excludedKeys := []string{
-		"user",
-	}
-	if err := hclutil.Decode(obj, excludedKeys, nil, f); err != nil {
+	if err := hclutil.Decode(obj, frontendBlockKeys, nil, f); err != nil {
 		return maskAny(err)
 	}
 	if o := obj.List.Filter("user"); len(o.Items) > 0 {
@@ -599,13 +783,10 @@ func (f *PublicFrontEnd) parse(obj *ast.ObjectType) error {
 // parse a private frontend
 func (f *PrivateFrontEnd) parse(obj *ast.ObjectType) error {
 	// Build the frontend
-	excludedKeys := []string{
-		"user",
-	}
 	defaultValues := map[string]interface{}{
 		"port": 80,
 	}
-	if err := hclutil.Decode(obj, excludedKeys, defaultValues, f); err != nil {
+	if err := hclutil.Decode(obj, frontendBlockKeys, defaultValues, f); err != nil {
 		return maskAny(err)
 	}
 	if o := obj.List.Filter("user"); len(o.Items) > 0 {
@@ -638,10 +819,7 @@ func (c *Constraint) parse(obj *ast.ObjectType) error {
 // parse a dependency
 func (c *Dependency) parse(obj *ast.ObjectType) error {
 	// Build the dependency
-	excludedKeys := []string{
-		"private-frontend",
-	}
-	if err := hclutil.Decode(obj, excludedKeys, nil, c); err != nil {
+	if err := hclutil.Decode(obj, dependencyBlockKeys, nil, c); err != nil {
 		return maskAny(err)
 	}
 	// Parse private frontends
@@ -701,6 +879,39 @@ func (r *Rewrite) parse(obj *ast.ObjectType) error {
 	return nil
 }
 
+// parseCanary parses the canary objects in the given list.
+// Only 1 canary is allowed.
+func parseCanary(list *ast.ObjectList, owner string) (*Canary, error) {
+	if len(list.Items) > 1 {
+		return nil, maskAny(errgo.WithCausef(nil, ValidationError, "cannot more than 1 canary object in %s", owner))
+	}
+	obj, ok := list.Items[0].Val.(*ast.ObjectType)
+	if !ok {
+		return nil, maskAny(errgo.WithCausef(nil, ValidationError, "canary of %s is not an object", owner))
+	}
+	c := Canary{}
+	if err := hclutil.Decode(obj, nil, nil, &c); err != nil {
+		return nil, maskAny(err)
+	}
+	return &c, nil
+}
+
+// parse a check object
+func (c *Check) parse(obj *ast.ObjectType) error {
+	if err := hclutil.Decode(obj, checkBlockKeys, nil, c); err != nil {
+		return maskAny(err)
+	}
+	// Parse headers
+	if o := obj.List.Filter("headers"); len(o.Items) > 0 {
+		for _, o := range o.Elem().Items {
+			if err := hclutil.Decode(o.Val, nil, nil, &c.Headers); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+	return nil
+}
+
 // parse a metrics object
 func (m *Metrics) parse(obj *ast.ObjectType) error {
 	// Build the rewrite
@@ -88,6 +88,18 @@ func (p ParsedPortMapping) String() string {
 	return p.HostIP + ":" + hostPort + ":" + containerPort
 }
 
+// CanonicalString returns the shortest string representation of the port mapping,
+// that parses into the same port mapping.
+func (p ParsedPortMapping) CanonicalString() string {
+	if p.HasHostIP() || p.HasHostPort() {
+		return p.String()
+	}
+	if p.Protocol == protocolUDP {
+		return strconv.Itoa(p.ContainerPort) + "/" + p.Protocol
+	}
+	return strconv.Itoa(p.ContainerPort)
+}
+
 // Validate checks the port mapping for errors
 func (p ParsedPortMapping) Validate() error {
 	if p.HostPort < 0 || p.HostPort > 65535 {
@@ -0,0 +1,40 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+// problem is a single violation of a validation rule.
+type problem struct {
+	Err  error
+	Keys []string // Keys of the attributes or blocks the problem is found in (empty for the item itself)
+}
+
+// problems collects the violations of all validation rules of a job, group or task.
+// Validate returns the first one, Lint reports all of them.
+type problems []problem
+
+// add adds the given error (if any) found in the attribute or block with one of the given keys.
+func (p *problems) add(err error, keys ...string) {
+	if err != nil {
+		*p = append(*p, problem{Err: err, Keys: keys})
+	}
+}
+
+// first returns the error of the first problem, or nil if there are no problems.
+func (p problems) first() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return maskAny(p[0].Err)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// sourcePos is a position of a line in a source file.
+type sourcePos struct {
+	File string
+	Line int
+}
+
+// outputSpan records the output of a template of the job file or of a file included by it.
+// The output of included files is recorded next to the template output, together with the offset
+// at which it is written, so the output itself is the same as that of a normal parse.
+type outputSpan struct {
+	File     string        // File the template is read from
+	Offset   int           // Offset of the output in the output of the parent
+	Content  string        // Output of the template (once completed)
+	Includes []*outputSpan // Outputs of included files, in the order they are included
+	out      *bytes.Buffer // Output written so far
+}
+
+// newOutputSpan creates a span for the output of the template of the given file,
+// that is written to the given buffer.
+func newOutputSpan(file string, out *bytes.Buffer) *outputSpan {
+	return &outputSpan{File: file, out: out}
+}
+
+// include creates a span for the output of the given included file, which is written
+// into the output of the given span at its current end.
+func (s *outputSpan) include(file string, out *bytes.Buffer) *outputSpan {
+	child := &outputSpan{File: file, Offset: s.out.Len(), out: out}
+	s.Includes = append(s.Includes, child)
+	return child
+}
+
+// complete stores the output written to the span.
+func (s *outputSpan) complete() {
+	s.Content = s.out.String()
+}
+
+// currentLine returns the line of the template that is currently being executed.
+// Lines are counted exactly as long as template actions do not span multiple lines.
+func (s *outputSpan) currentLine() int {
+	line := 1 + strings.Count(s.out.String(), "\n")
+	for _, c := range s.Includes {
+		line -= strings.Count(c.Content, "\n")
+	}
+	return line
+}
+
+// fill sets the source position of all bytes of the output of the span, written at the given offset.
+// Included outputs that are not found at their recorded offset (e.g. because they are
+// passed through another function) are considered part of the output of the span itself.
+func (s *outputSpan) fill(owners []sourcePos, offset int) {
+	line := 1
+	next := 0
+	for i := 0; i < len(s.Content); {
+		for next < len(s.Includes) && s.Includes[next].Offset < i {
+			next++
+		}
+		if next < len(s.Includes) && s.Includes[next].Offset == i {
+			c := s.Includes[next]
+			next++
+			if strings.HasPrefix(s.Content[i:], c.Content) {
+				c.fill(owners, offset+i)
+				i += len(c.Content)
+				continue
+			}
+		}
+		owners[offset+i] = sourcePos{File: s.File, Line: line}
+		if s.Content[i] == '\n' {
+			line++
+		}
+		i++
+	}
+}
+
+// sourceMap maps lines of the output of the job template back to
+// lines of the job file or the files included by it.
+type sourceMap struct {
+	lines []sourcePos // Index is output line - 1
+}
+
+// newSourceMap creates a sourceMap for the output recorded in the given span of the job file.
+// Each output line is mapped to the line of its first non-blank character.
+// Lines are mapped exactly as long as template actions do not span multiple lines.
+func newSourceMap(root *outputSpan) sourceMap {
+	owners := make([]sourcePos, len(root.Content))
+	root.fill(owners, 0)
+	var m sourceMap
+	start := 0
+	for _, line := range strings.SplitAfter(root.Content, "\n") {
+		pos := sourcePos{File: root.File, Line: len(m.lines) + 1}
+		if len(line) > 0 {
+			pos = owners[start]
+			if i := strings.IndexFunc(line, func(r rune) bool { return !unicode.IsSpace(r) }); i >= 0 {
+				pos = owners[start+i]
+			}
+		}
+		m.lines = append(m.lines, pos)
+		start += len(line)
+	}
+	return m
+}
+
+// resolve returns the source file & line of the given output line.
+func (m sourceMap) resolve(line int) (string, int) {
+	if line < 1 || line > len(m.lines) {
+		if len(m.lines) > 0 {
+			return m.lines[0].File, 0
+		}
+		return "", 0
+	}
+	p := m.lines[line-1]
+	return p.File, p.Line
+}
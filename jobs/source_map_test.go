@@ -0,0 +1,75 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Output   *outputSpan
+		Expected []sourcePos
+	}{
+		{
+			Name:     "no includes",
+			Output:   &outputSpan{File: "job", Content: "a\nb\n"},
+			Expected: []sourcePos{{"job", 1}, {"job", 2}, {"job", 3}},
+		},
+		{
+			// job: "a\n{{include "inc"}}\nb"
+			Name: "include on its own line",
+			Output: &outputSpan{File: "job", Content: "a\nx\ny\nb", Includes: []*outputSpan{
+				{File: "inc", Offset: 2, Content: "x\ny"},
+			}},
+			Expected: []sourcePos{{"job", 1}, {"inc", 1}, {"inc", 2}, {"job", 3}},
+		},
+		{
+			// job: "args = [{{include "inc"}}]\nc"
+			Name: "inline include",
+			Output: &outputSpan{File: "job", Content: "args = [\"a\",\n  \"b\"]\nc", Includes: []*outputSpan{
+				{File: "inc", Offset: 8, Content: "\"a\",\n  \"b\""},
+			}},
+			Expected: []sourcePos{{"job", 1}, {"inc", 2}, {"job", 2}},
+		},
+		{
+			// job: "a\n{{include "inc" | trim}}\nb", where the include is modified after it is recorded
+			Name: "modified include",
+			Output: &outputSpan{File: "job", Content: "a\nx\nb", Includes: []*outputSpan{
+				{File: "inc", Offset: 2, Content: "  x\n"},
+			}},
+			Expected: []sourcePos{{"job", 1}, {"job", 2}, {"job", 3}},
+		},
+		{
+			// job: "{{include "outer"}}\nb", outer: "o\n{{include "inner"}}\np"
+			Name: "nested includes",
+			Output: &outputSpan{File: "job", Content: "o\ni\np\nb", Includes: []*outputSpan{
+				{File: "outer", Offset: 0, Content: "o\ni\np", Includes: []*outputSpan{
+					{File: "inner", Offset: 2, Content: "i"},
+				}},
+			}},
+			Expected: []sourcePos{{"outer", 1}, {"inner", 1}, {"outer", 3}, {"job", 2}},
+		},
+	}
+
+	for _, test := range tests {
+		m := newSourceMap(test.Output)
+		if !reflect.DeepEqual(m.lines, test.Expected) {
+			t.Errorf("Unexpected lines for %s; expected %v, got %v", test.Name, test.Expected, m.lines)
+		}
+	}
+}
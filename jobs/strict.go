@@ -0,0 +1,135 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"github.com/hashicorp/hcl/hcl/ast"
+
+	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+// unknownKeys returns all keys in the job found in the given top-level list
+// that are not known, in the order in which they occur.
+func unknownKeys(list *ast.ObjectList) []hclutil.UnknownKey {
+	var result []hclutil.UnknownKey
+	for _, jobItem := range list.Filter("job").Items {
+		jobObj := objectOf(jobItem)
+		result = append(result, hclutil.UnknownKeys(jobObj, "job", jobBlockKeys, Job{})...)
+		for _, obj := range blocks(jobObj, "group") {
+			result = append(result, hclutil.UnknownKeys(obj, "group", groupBlockKeys, TaskGroup{})...)
+			for _, obj := range blocks(obj, "canary") {
+				result = append(result, hclutil.UnknownKeys(obj, "canary", nil, Canary{})...)
+			}
+			for _, obj := range blocks(obj, "task") {
+				result = append(result, unknownTaskKeys(obj)...)
+			}
+			for _, obj := range blocks(obj, "hook") {
+				result = append(result, unknownHookKeys(obj)...)
+			}
+			result = append(result, unknownConstraintKeys(obj)...)
+		}
+		for _, obj := range blocks(jobObj, "task") {
+			result = append(result, unknownTaskKeys(obj)...)
+		}
+		for _, obj := range blocks(jobObj, "hook") {
+			result = append(result, unknownHookKeys(obj)...)
+		}
+		result = append(result, unknownConstraintKeys(jobObj)...)
+		for _, obj := range blocks(jobObj, "canary") {
+			result = append(result, hclutil.UnknownKeys(obj, "canary", nil, Canary{})...)
+		}
+		for _, obj := range blocks(jobObj, "notify") {
+			result = append(result, cluster.UnknownNotifyKeys(obj)...)
+		}
+		for _, obj := range blocks(jobObj, "dependency") {
+			result = append(result, hclutil.UnknownKeys(obj, "dependency", dependencyBlockKeys, Dependency{})...)
+			for _, obj := range blocks(obj, "private-frontend") {
+				result = append(result, unknownFrontendKeys(obj, "private-frontend", PrivateFrontEnd{})...)
+			}
+		}
+	}
+	hclutil.SortUnknownKeys(result)
+	return result
+}
+
+// unknownHookKeys returns all unknown keys in the given hook object.
+func unknownHookKeys(obj *ast.ObjectType) []hclutil.UnknownKey {
+	_, taskObj := splitHookObject(obj)
+	return unknownTaskKeys(taskObj)
+}
+
+// unknownTaskKeys returns all unknown keys in the given task object.
+func unknownTaskKeys(obj *ast.ObjectType) []hclutil.UnknownKey {
+	result := hclutil.UnknownKeys(obj, "task", taskBlockKeys, parseTask{})
+	for _, obj := range blocks(obj, "frontend") {
+		result = append(result, unknownFrontendKeys(obj, "frontend", PublicFrontEnd{})...)
+	}
+	for _, obj := range blocks(obj, "private-frontend") {
+		result = append(result, unknownFrontendKeys(obj, "private-frontend", PrivateFrontEnd{})...)
+	}
+	for _, obj := range blocks(obj, "secret") {
+		result = append(result, hclutil.UnknownKeys(obj, "secret", nil, Secret{})...)
+	}
+	for _, obj := range blocks(obj, "link") {
+		result = append(result, hclutil.UnknownKeys(obj, "link", nil, Link{})...)
+	}
+	for _, obj := range blocks(obj, "rewrite") {
+		result = append(result, hclutil.UnknownKeys(obj, "rewrite", nil, Rewrite{})...)
+	}
+	for _, obj := range blocks(obj, "metrics") {
+		result = append(result, hclutil.UnknownKeys(obj, "metrics", nil, Metrics{})...)
+	}
+	for _, obj := range blocks(obj, "drain") {
+		result = append(result, hclutil.UnknownKeys(obj, "drain", nil, Drain{})...)
+	}
+	for _, obj := range blocks(obj, "check") {
+		result = append(result, hclutil.UnknownKeys(obj, "check", checkBlockKeys, Check{})...)
+	}
+	for _, obj := range blocks(obj, "resources") {
+		result = append(result, hclutil.UnknownKeys(obj, "resources", nil, cluster.Resources{})...)
+	}
+	result = append(result, unknownConstraintKeys(obj)...)
+	return result
+}
+
+// unknownFrontendKeys returns all unknown keys in the given frontend object.
+func unknownFrontendKeys(obj *ast.ObjectType, block string, data interface{}) []hclutil.UnknownKey {
+	result := hclutil.UnknownKeys(obj, block, frontendBlockKeys, data)
+	for _, obj := range blocks(obj, "user") {
+		result = append(result, hclutil.UnknownKeys(obj, "user", nil, User{})...)
+	}
+	return result
+}
+
+// unknownConstraintKeys returns all unknown keys in the constraints of the given object.
+func unknownConstraintKeys(obj *ast.ObjectType) []hclutil.UnknownKey {
+	var result []hclutil.UnknownKey
+	for _, obj := range blocks(obj, "constraint") {
+		result = append(result, hclutil.UnknownKeys(obj, "constraint", nil, Constraint{})...)
+	}
+	return result
+}
+
+// blocks returns the object values of all items in the given object with the given key.
+func blocks(obj *ast.ObjectType, key string) []*ast.ObjectType {
+	var result []*ast.ObjectType
+	for _, item := range itemsWithKey(obj, key) {
+		if obj := objectOf(item); obj != nil {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
@@ -31,32 +31,35 @@ type taskData struct {
 	OriginalIndex int        `json:"-", mapstructure:"-"`
 	group         *TaskGroup `json:"-", mapstructure:"-"`
 
-	Type             TaskType          `json:"type,omitempty" mapstructure:"type,omitempty"`
-	Engine           EngineType        `json:"engine,omitempty" mapstructure:"engine,omitempty"`
-	Timer            string            `json:"timer,omitempty" mapstructure:"timer,omitempty"`
-	Image            DockerImage       `json:"image"`
-	After            []TaskName        `json:"after,omitempty"`
-	VolumesFrom      []TaskName        `json:"volumes-from,omitempty"`
-	Volumes          VolumeList        `json:"volumes,omitempty"`
-	Args             []string          `json:"args,omitempty"`
-	Environment      map[string]string `json:"environment,omitempty"`
-	Ports            []PortMapping     `json:"ports,omitempty"`
-	PublicFrontEnds  []PublicFrontEnd  `json:"frontends,omitempty"`
-	PrivateFrontEnds []PrivateFrontEnd `json:"private-frontends,omitempty"`
-	HttpCheckPath    string            `json:"http-check-path,omitempty" mapstructure:"http-check-path,omitempty"`
-	HttpCheckMethod  string            `json:"http-check-method,omitempty" mapstructure:"http-check-method,omitempty"`
-	Sticky           bool              `json:"sticky,omitempty" mapstructure:"sticky,omitempty"`
-	Backup           bool              `json:"backup,omitempty" mapstructure:"backup,omitempty"`
-	Capabilities     []string          `json:"capabilities,omitempty"`
-	Network          NetworkType       `json:"network,omitempty"`
-	Links            Links             `json:"links,omitempty"`
-	Secrets          SecretList        `json:"secrets,omitempty"`
-	DockerArgs       []string          `json:"docker-args,omitempty" mapstructure:"docker-args,omitempty"`
-	LogDriver        LogDriver         `json:"log-driver,omitempty" mapstructure:"log-driver,omitempty"`
-	Target           LinkName          `json:"target,omitempty" mapstructure:"target,omitempty"`
-	Rewrite          *Rewrite          `json:"rewrite,omitempty" mapstructure:"rewrite,omitempty"`
-	User             string            `json:"user,omitempty" mapstructure:"user,omitempty"`
-	Metrics          *Metrics          `json:"metrics,omitempty"`
+	Type             TaskType           `json:"type,omitempty" mapstructure:"type,omitempty"`
+	Engine           EngineType         `json:"engine,omitempty" mapstructure:"engine,omitempty"`
+	Timer            string             `json:"timer,omitempty" mapstructure:"timer,omitempty"`
+	Image            DockerImage        `json:"image"`
+	After            []TaskName         `json:"after,omitempty"`
+	VolumesFrom      []TaskName         `json:"volumes-from,omitempty"`
+	Volumes          VolumeList         `json:"volumes,omitempty"`
+	Args             []string           `json:"args,omitempty"`
+	Environment      map[string]string  `json:"environment,omitempty"`
+	Ports            []PortMapping      `json:"ports,omitempty"`
+	PublicFrontEnds  []PublicFrontEnd   `json:"frontends,omitempty"`
+	PrivateFrontEnds []PrivateFrontEnd  `json:"private-frontends,omitempty"`
+	HttpCheckPath    string             `json:"http-check-path,omitempty" mapstructure:"http-check-path,omitempty"`
+	HttpCheckMethod  string             `json:"http-check-method,omitempty" mapstructure:"http-check-method,omitempty"`
+	Sticky           bool               `json:"sticky,omitempty" mapstructure:"sticky,omitempty"`
+	Backup           bool               `json:"backup,omitempty" mapstructure:"backup,omitempty"`
+	Capabilities     []string           `json:"capabilities,omitempty"`
+	Network          NetworkType        `json:"network,omitempty"`
+	Links            Links              `json:"links,omitempty"`
+	Secrets          SecretList         `json:"secrets,omitempty"`
+	DockerArgs       []string           `json:"docker-args,omitempty" mapstructure:"docker-args,omitempty"`
+	LogDriver        LogDriver          `json:"log-driver,omitempty" mapstructure:"log-driver,omitempty"`
+	Target           LinkName           `json:"target,omitempty" mapstructure:"target,omitempty"`
+	Rewrite          *Rewrite           `json:"rewrite,omitempty" mapstructure:"rewrite,omitempty"`
+	User             string             `json:"user,omitempty" mapstructure:"user,omitempty"`
+	Metrics          *Metrics           `json:"metrics,omitempty"`
+	Resources        *cluster.Resources `json:"resources,omitempty"`
+	Check            *Check             `json:"check,omitempty"`
+	Drain            *Drain             `json:"drain,omitempty"`
 }
 
 type Task taskData
@@ -76,6 +79,13 @@ func (t *Task) setDefaults(cluster cluster.Cluster) {
 			t.Network = NetworkTypeDefault
 		}
 	}
+	if t.Check != nil {
+		t.Check.setDefaults()
+	}
+	if t.Resources == nil && cluster.DefaultResources != nil {
+		r := *cluster.DefaultResources
+		t.Resources = &r
+	}
 }
 
 // Link objects just after parsing
@@ -139,60 +149,71 @@ func (t *Task) replaceVariables(renderer Renderer, cluster cluster.Cluster) erro
 		m := t.Metrics.replaceVariables(ctx)
 		t.Metrics = &m
 	}
+	if t.Resources != nil {
+		r := *t.Resources
+		r.CPU = ctx.replaceString(r.CPU)
+		r.CPULimit = ctx.replaceString(r.CPULimit)
+		r.Memory = ctx.replaceString(r.Memory)
+		r.MemoryLimit = ctx.replaceString(r.MemoryLimit)
+		t.Resources = &r
+	}
+	if t.Check != nil {
+		c := t.Check.replaceVariables(ctx)
+		t.Check = &c
+	}
+	if t.Drain != nil {
+		d := t.Drain.replaceVariables(ctx)
+		t.Drain = &d
+	}
 	return maskAny(ctx.Err())
 }
 
 // Check for errors
 func (t Task) Validate() error {
-	if err := t.Name.Validate(); err != nil {
-		return maskAny(err)
-	}
-	if err := t.Type.Validate(); err != nil {
+	if err := t.problems().first(); err != nil {
 		return maskAny(err)
 	}
+	return nil
+}
+
+// problems checks the values of the given task and returns all problems found.
+func (t Task) problems() problems {
+	var p problems
+	p.add(t.Name.Validate())
+	p.add(t.Type.Validate(), "type")
 	for _, name := range t.After {
 		_, err := t.group.Task(name)
-		if err != nil {
-			return maskAny(err)
-		}
+		p.add(err, "after")
 	}
 	for _, name := range t.VolumesFrom {
 		_, err := t.group.Task(name)
-		if err != nil {
-			return maskAny(err)
-		}
-	}
-	if err := t.Network.Validate(); err != nil {
-		return maskAny(err)
+		p.add(err, "volumes-from")
 	}
+	p.add(t.Network.Validate(), "network")
 	for _, l := range t.Links {
-		if err := l.Validate(); err != nil {
-			return maskAny(err)
-		}
+		p.add(l.Validate(), "links", "link")
 	}
 	if t.Metrics != nil {
-		if err := t.Metrics.Validate(); err != nil {
-			return maskAny(err)
-		}
+		p.add(t.Metrics.Validate(), "metrics")
 	}
-	for _, p := range t.Ports {
-		if _, err := p.Parse(); err != nil {
-			return maskAny(err)
-		}
+	if t.Resources != nil {
+		p.add(t.Resources.Validate(), "resources")
+	}
+	p.add(t.validateCheck(), "check")
+	p.add(t.validateDrain(), "drain")
+	for _, port := range t.Ports {
+		_, err := port.Parse()
+		p.add(err, "ports")
 	}
 
 	httpFrontends := 0
 	tcpFrontends := 0
 	for _, f := range t.PublicFrontEnds {
-		if err := f.Validate(); err != nil {
-			return maskAny(err)
-		}
+		p.add(f.Validate(), "frontend")
 		httpFrontends++
 	}
 	for _, f := range t.PrivateFrontEnds {
-		if err := f.Validate(); err != nil {
-			return maskAny(err)
-		}
+		p.add(f.Validate(), "private-frontend")
 		if f.IsTcp() {
 			tcpFrontends++
 		} else {
@@ -200,28 +221,26 @@ func (t Task) Validate() error {
 		}
 	}
 	if tcpFrontends > 0 && httpFrontends > 0 {
-		return maskAny(errgo.WithCausef(nil, ValidationError, "cannot mix http and tcp frontends (in '%s')", t.Name))
+		p.add(errgo.WithCausef(nil, ValidationError, "cannot mix http and tcp frontends (in '%s')", t.Name), "private-frontend", "frontend")
 	}
-	if err := t.Secrets.Validate(); err != nil {
-		return maskAny(err)
+	for _, s := range t.Secrets {
+		p.add(s.Validate(), "secret")
 	}
 	if t.Timer != "" {
 		if t.Type != "oneshot" {
-			return maskAny(errgo.WithCausef(nil, ValidationError, "timer only valid in combination with oneshot (in '%s')", t.Name))
+			p.add(errgo.WithCausef(nil, ValidationError, "timer only valid in combination with oneshot (in '%s')", t.Name), "timer")
 		}
 	}
-	if err := t.LogDriver.Validate(); err != nil {
-		return maskAny(err)
-	}
+	p.add(t.LogDriver.Validate(), "log-driver")
 	if t.Target != "" {
 		if t.Type != "proxy" {
-			return maskAny(errgo.WithCausef(nil, ValidationError, "target only valid in combination with proxy (in '%s')", t.Name))
+			p.add(errgo.WithCausef(nil, ValidationError, "target only valid in combination with proxy (in '%s')", t.Name), "target")
 		}
 	}
 	if t.Type == "proxy" && t.Target == "" {
-		return maskAny(errgo.WithCausef(nil, ValidationError, "target must be set with type proxy (in '%s')", t.Name))
+		p.add(errgo.WithCausef(nil, ValidationError, "target must be set with type proxy (in '%s')", t.Name), "type")
 	}
-	return nil
+	return p
 }
 
 // Task gets a task by the given name
@@ -267,6 +286,12 @@ func (t *Task) GroupCount() uint {
 	return t.group.Count
 }
 
+// GroupColor returns the color in which the containing group is rendered.
+// It is empty when the group does not use blue/green deployments.
+func (t *Task) GroupColor() Color {
+	return t.group.Color()
+}
+
 // FullName returns the full name of this task: job/taskgroup/task
 func (t *Task) FullName() string {
 	return fmt.Sprintf("%s/%s", t.group.FullName(), t.Name)
@@ -295,6 +320,12 @@ func (t *Task) ServiceName() string {
 	return strings.Replace(t.FullName(), "/", "-", -1)
 }
 
+// FrontendServiceName returns the name used to register the frontends of this task.
+// Unlike ServiceName, it does not include the color of a blue/green group.
+func (t *Task) FrontendServiceName() string {
+	return fmt.Sprintf("%s-%s-%s", t.group.job.Name, t.group.Name, t.Name)
+}
+
 // ContainerName returns the name of the docker container used for this task.
 func (t *Task) ContainerName(scalingGroup uint) string {
 	return t.containerNameExt(strconv.Itoa(int(scalingGroup)))
@@ -370,3 +401,23 @@ func (t *Task) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(data)
 }
+
+// UnmarshalJSON reads the given task from JSON (as created by MarshalJSON).
+// It restores the default values that MarshalJSON replaced with blanks.
+func (t *Task) UnmarshalJSON(data []byte) error {
+	var td taskData
+	if err := json.Unmarshal(data, &td); err != nil {
+		return maskAny(err)
+	}
+	if td.Type == "" {
+		td.Type = "service"
+	}
+	if td.Engine == "" {
+		td.Engine = "docker"
+	}
+	if td.Network == "" {
+		td.Network = NetworkTypeDefault
+	}
+	*t = Task(td)
+	return nil
+}
@@ -54,11 +54,19 @@ type TaskGroup struct {
 	Name TaskGroupName `json:"name", mapstructure:"-"`
 	job  *Job
 
-	Count         uint          `json:"count"`            // Number of instances of this group
-	Global        bool          `json:"global,omitempty"` // Scheduled on all machines
-	Tasks         TaskList      `json:"tasks"`
-	Constraints   Constraints   `json:"constraints,omitempty"`
-	RestartPolicy RestartPolicy `json:"restart,omitempty" mapstructure:"restart,omitempty"`
+	Count          uint          `json:"count"`            // Number of instances of this group
+	Global         bool          `json:"global,omitempty"` // Scheduled on all machines
+	Tasks          TaskList      `json:"tasks"`
+	Constraints    Constraints   `json:"constraints,omitempty"`
+	RestartPolicy  RestartPolicy `json:"restart,omitempty" mapstructure:"restart,omitempty"`
+	Canary         *Canary       `json:"canary,omitempty"`
+	BlueGreen      bool          `json:"blue-green,omitempty" mapstructure:"blue-green,omitempty"`           // Deploy new versions next to the current version
+	PreventDestroy bool          `json:"prevent-destroy,omitempty" mapstructure:"prevent-destroy,omitempty"` // Destroying the group requires an explicit override
+	Hook           HookType      `json:"hook,omitempty" mapstructure:"-"`                                    // Set when this group runs a hook
+	HookOf         TaskGroupName `json:"hook-of,omitempty" mapstructure:"-"`                                 // Group whose hook this group runs (empty for hooks of the job)
+	HookTimeout    string        `json:"hook-timeout,omitempty" mapstructure:"-"`                            // Maximum time to wait for the hook to complete
+
+	color Color // Color in which a blue/green group is rendered
 }
 
 type TaskGroupList []*TaskGroup
@@ -116,30 +124,49 @@ func (tg *TaskGroup) Validate() error {
 	if err := tg.Name.Validate(); err != nil {
 		return maskAny(err)
 	}
+	for _, t := range tg.Tasks {
+		if err := t.Validate(); err != nil {
+			return maskAny(err)
+		}
+	}
+	if err := tg.ownProblems().first(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// ownProblems checks the values of the given task group, excluding its tasks,
+// and returns all problems found.
+func (tg *TaskGroup) ownProblems() problems {
+	var p problems
+	p.add(tg.Name.Validate())
 	if tg.Count <= 0 {
-		return maskAny(errgo.WithCausef(nil, ValidationError, "group %s count <= 0", tg.Name))
+		p.add(errgo.WithCausef(nil, ValidationError, "group %s count <= 0", tg.Name), "count")
 	}
 	if len(tg.Tasks) == 0 {
-		return maskAny(errgo.WithCausef(nil, ValidationError, "group %s has no tasks", tg.Name))
+		p.add(errgo.WithCausef(nil, ValidationError, "group %s has no tasks", tg.Name))
 	}
 	for i, t := range tg.Tasks {
-		err := t.Validate()
-		if err != nil {
-			return maskAny(err)
-		}
 		for j := i + 1; j < len(tg.Tasks); j++ {
 			if tg.Tasks[j].Name == t.Name {
-				return maskAny(errgo.WithCausef(nil, ValidationError, "group %s has duplicate task %s", tg.Name, t.Name))
+				p.add(errgo.WithCausef(nil, ValidationError, "group %s has duplicate task %s", tg.Name, t.Name), "task")
 			}
 		}
 	}
-	if err := tg.Constraints.Validate(); err != nil {
-		return maskAny(err)
+	for _, err := range tg.Constraints.errors() {
+		p.add(err, "constraint")
 	}
-	if err := tg.RestartPolicy.Validate(); err != nil {
-		return maskAny(err)
+	p.add(tg.RestartPolicy.Validate(), "restart")
+	if tg.Canary != nil {
+		p.add(tg.Canary.Validate(), "canary")
 	}
-	return nil
+	if tg.BlueGreen {
+		tg.addBlueGreenProblems(&p)
+	}
+	if tg.IsHook() {
+		tg.addHookProblems(&p)
+	}
+	return p
 }
 
 // Task gets a task by the given name
@@ -173,10 +200,32 @@ func (tg *TaskGroup) Job() *Job {
 }*/
 
 // FullName returns the full name of this taskgroup: job/taskgroup
+// For blue/green groups, the color is included: job/taskgroup-color
 func (tg *TaskGroup) FullName() string {
+	if color := tg.Color(); color != "" {
+		return fmt.Sprintf("%s/%s-%s", tg.job.Name, tg.Name, color)
+	}
 	return fmt.Sprintf("%s/%s", tg.job.Name, tg.Name)
 }
 
+// Color returns the color in which this group is rendered.
+// It returns an empty color for groups that do not use blue/green deployments.
+func (tg *TaskGroup) Color() Color {
+	if !tg.BlueGreen {
+		return ""
+	}
+	if tg.color == "" {
+		return ColorBlue
+	}
+	return tg.color
+}
+
+// SetColor sets the color in which this group is rendered.
+// It has no effect on groups that do not use blue/green deployments.
+func (tg *TaskGroup) SetColor(color Color) {
+	tg.color = color
+}
+
 func (l TaskGroupList) Len() int {
 	return len(l)
 }
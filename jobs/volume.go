@@ -140,6 +140,20 @@ func (v Volume) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+// UnmarshalJSON parses a volume from its json representation (as created by MarshalJSON).
+func (v *Volume) UnmarshalJSON(data []byte) error {
+	var input string
+	if err := json.Unmarshal(data, &input); err != nil {
+		return maskAny(err)
+	}
+	result, err := ParseVolume(input)
+	if err != nil {
+		return maskAny(err)
+	}
+	*v = result
+	return nil
+}
+
 // ParseVolume parses a string into a Volume
 func ParseVolume(input string) (Volume, error) {
 	parts := strings.Split(input, ":")
@@ -0,0 +1,98 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+)
+
+var (
+	lintCmd = &cobra.Command{
+		Use:   "lint",
+		Short: "Check a job for problems.",
+		Long:  "Check a job for problems, reporting all errors & warnings with their location in the job files.",
+		Run:   lintRun,
+	}
+	lintFlags struct {
+		fg.Flags
+		output string
+	}
+)
+
+func init() {
+	initDeploymentFlags(lintCmd.Flags(), &lintFlags.Flags)
+	lintCmd.Flags().StringVar(&lintFlags.output, "output", defaultOutputFormat, "Output format (text|json)")
+}
+
+func lintRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &lintFlags.Flags, args)
+	if lintFlags.output != "text" && lintFlags.output != "json" {
+		Exitf("--output invalid: must be text or json\n")
+	}
+	if lintFlags.JobPath == "" {
+		Exitf("--job missing\n")
+	}
+
+	cluster, err := loadCluster(&lintFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	path, err := resolvePath(lintFlags.JobPath, "config", ".hcl")
+	if err != nil {
+		Exitf("Cannot resolve job path: %v\n", err)
+	}
+	provider, err := orchestrator.RenderProvider()
+	if err != nil {
+		Exitf("Cannot initialize render provider: %v\n", err)
+	}
+	renderer := provider.CreateRenderer(*cluster)
+
+	result, err := jobs.Lint(path, *cluster, renderer, lintFlags.Options, log)
+	if err != nil {
+		Exitf("Cannot lint job: %v\n", err)
+	}
+
+	if lintFlags.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		assert(encoder.Encode(result))
+	} else {
+		for _, d := range result.Errors {
+			fmt.Println(d.String())
+		}
+		for _, d := range result.Warnings {
+			fmt.Println(d.String())
+		}
+		if !result.HasErrors() && len(result.Warnings) == 0 {
+			fmt.Println("No problems found.")
+		} else {
+			fmt.Printf("%d error(s), %d warning(s)\n", len(result.Errors), len(result.Warnings))
+		}
+	}
+	if result.HasErrors() {
+		os.Exit(1)
+	}
+}
@@ -18,7 +18,9 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/cobra"
@@ -43,6 +45,7 @@ var (
 			setLogLevel(globalFlags.logLevel, defaultLogLevel, projectName)
 			setLogLevel(globalFlags.fleetLogLevel, globalFlags.logLevel, "fleet")
 			docker.SetupImages(dockerImages)
+			setupContext()
 		},
 	}
 	globalFlags struct {
@@ -50,6 +53,7 @@ var (
 		verbose       bool
 		logLevel      string
 		fleetLogLevel string
+		timeout       time.Duration
 	}
 	log          *logging.Logger
 	dockerImages = docker.Images{
@@ -60,12 +64,16 @@ var (
 	}
 )
 
+// exitOutput is the destination of the messages of Exitf.
+var exitOutput io.Writer = os.Stdout
+
 func init() {
 	log = logging.MustGetLogger(projectName)
 	cmdMain.PersistentFlags().BoolVarP(&globalFlags.debug, "debug", "D", false, "Print debug output")
 	cmdMain.PersistentFlags().BoolVarP(&globalFlags.verbose, "verbose", "v", false, "Print verbose output")
 	cmdMain.PersistentFlags().StringVar(&globalFlags.logLevel, "log-level", defaultLogLevel, "Log level (debug|info|warning|error)")
 	cmdMain.PersistentFlags().StringVar(&globalFlags.fleetLogLevel, "fleet-log-level", "", "Log level of the fleet tunnel (debug|info|warning|error)")
+	cmdMain.PersistentFlags().DurationVar(&globalFlags.timeout, "timeout", defaultTimeout, "Maximum duration of the command, after which it is canceled (0 means no timeout)")
 
 	cmdMain.PersistentFlags().StringVar(&dockerImages.VaultMonkey, "image-vault-monkey", dockerImages.VaultMonkey, "Docker image for vault-monkey containers")
 	cmdMain.PersistentFlags().StringVar(&dockerImages.Wormhole, "image-wormhole", dockerImages.Wormhole, "Docker image for wormhole containers")
@@ -76,6 +84,20 @@ func init() {
 func main() {
 	cmdMain.AddCommand(runCmd)
 	cmdMain.AddCommand(destroyCmd)
+	cmdMain.AddCommand(statusCmd)
+	cmdMain.AddCommand(diffCmd)
+	cmdMain.AddCommand(driftCmd)
+	cmdMain.AddCommand(planCmd)
+	cmdMain.AddCommand(applyCmd)
+	cmdMain.AddCommand(promoteCmd)
+	cmdMain.AddCommand(switchCmd)
+	cmdMain.AddCommand(retireCmd)
+	cmdMain.AddCommand(historyCmd)
+	cmdMain.AddCommand(rollbackCmd)
+	cmdMain.AddCommand(stackCmd)
+	cmdMain.AddCommand(renderCmd)
+	cmdMain.AddCommand(lintCmd)
+	cmdMain.AddCommand(fmtCmd)
 
 	cmdMain.Execute()
 }
@@ -85,8 +107,8 @@ func showUsage(cmd *cobra.Command, args []string) {
 }
 
 func Exitf(format string, args ...interface{}) {
-	fmt.Printf(format, args...)
-	fmt.Println()
+	fmt.Fprintf(exitOutput, format, args...)
+	fmt.Fprintln(exitOutput)
 	os.Exit(1)
 }
 
@@ -50,3 +50,13 @@ func (c *Cmdline) Add(env map[string]string, args ...string) *Cmdline {
 func (c *Cmdline) String() string {
 	return strings.Join(c.cmd, " ")
 }
+
+// ShellQuote quotes the given argument for use in a shell command (if needed).
+func ShellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+", r))
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
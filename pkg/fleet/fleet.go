@@ -35,6 +35,7 @@ import (
 	"github.com/coreos/fleet/ssh"
 	aerr "github.com/ewoutp/go-aggregate-error"
 	"github.com/op/go-logging"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -44,6 +45,7 @@ var (
 type FleetTunnel struct {
 	FleetConfig
 	cAPI          client.API
+	kAPI          etcd.KeysAPI
 	machineStates map[string]*machine.MachineState
 }
 
@@ -85,49 +87,81 @@ func (f *FleetTunnel) findUnits(names []string) (sus []schema.Unit, err error) {
 	return filtered, nil
 }
 
+// retry calls the given operation until it succeeds, with an exponential backoff between attempts.
+// It gives up when the backoff expires or when the given context is done.
+func retry(ctx context.Context, op backoff.Operation) error {
+	b := backoff.NewExponentialBackOff()
+	b.Reset()
+	for {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return maskAny(err)
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(ctx.Err())
+		case <-time.After(next):
+		}
+	}
+}
+
+// sleep waits for the given duration, or until the given context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return maskAny(ctx.Err())
+	case <-time.After(d):
+		return nil
+	}
+}
+
 // setUnitTargetStateWithRetry wraps SetUnitTargetState of the fleet API with a retry.
-func (f *FleetTunnel) setUnitTargetStateWithRetry(name, target string) error {
+func (f *FleetTunnel) setUnitTargetStateWithRetry(ctx context.Context, name, target string) error {
 	op := func() error {
 		return maskAny(f.cAPI.SetUnitTargetState(name, target))
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // unitWithRetry wraps Unit of the fleet API with a retry.
-func (f *FleetTunnel) unitWithRetry(unitName string) (*schema.Unit, error) {
+func (f *FleetTunnel) unitWithRetry(ctx context.Context, unitName string) (*schema.Unit, error) {
 	var u *schema.Unit
 	op := func() error {
 		var err error
 		u, err = f.cAPI.Unit(unitName)
 		return maskAny(err)
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return u, maskAny(err)
 	}
 	return u, nil
 }
 
-func (f *FleetTunnel) unitExists(unitName string) (bool, error) {
-	u, err := f.unitWithRetry(unitName)
+func (f *FleetTunnel) unitExists(ctx context.Context, unitName string) (bool, error) {
+	u, err := f.unitWithRetry(ctx, unitName)
 	return u != nil, maskAny(err)
 }
 
 // createUnitWithRetry wraps CreateUnit of the fleet API with a retry.
-func (f *FleetTunnel) createUnitWithRetry(unit *schema.Unit) error {
+func (f *FleetTunnel) createUnitWithRetry(ctx context.Context, unit *schema.Unit) error {
 	op := func() error {
 		return maskAny(f.cAPI.CreateUnit(unit))
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // destroyUnitWithRetry wraps DestroyUnit of the fleet API with a retry.
-func (f *FleetTunnel) destroyUnitWithRetry(unitName string) (notFound bool, err error) {
+func (f *FleetTunnel) destroyUnitWithRetry(ctx context.Context, unitName string) (notFound bool, err error) {
 	op := func() error {
 		if err := f.cAPI.DestroyUnit(unitName); client.IsErrorUnitNotFound(err) {
 			// Ignore 'Unit does not exist' error
@@ -136,14 +170,14 @@ func (f *FleetTunnel) destroyUnitWithRetry(unitName string) (notFound bool, err
 		}
 		return maskAny(err)
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return notFound, maskAny(err)
 	}
 	return notFound, nil
 }
 
 // tryWaitForUnitStates tries to wait for units to reach the desired state.
-// It takes 5 arguments, the units to wait for, the desired state, the
+// It takes 6 arguments, a context, the units to wait for, the desired state, the
 // desired JobState, how many attempts before timing out and a writer
 // interface.
 // tryWaitForUnitStates polls each of the indicated units until they
@@ -152,7 +186,7 @@ func (f *FleetTunnel) destroyUnitWithRetry(unitName string) (notFound bool, err
 // If maxAttempts is zero tryWaitForUnitStates will retry forever, and
 // if it is greater than zero, it will retry up to the indicated value.
 // It returns 0 on success or 1 on errors.
-func (f *FleetTunnel) tryWaitForUnitStates(units []string, state string, js job.JobState, maxAttempts int, events chan Event) error {
+func (f *FleetTunnel) tryWaitForUnitStates(ctx context.Context, units []string, state string, js job.JobState, maxAttempts int, events chan Event) error {
 	// We do not wait just assume we reached the desired state
 	if maxAttempts <= -1 {
 		for _, name := range units {
@@ -161,7 +195,7 @@ func (f *FleetTunnel) tryWaitForUnitStates(units []string, state string, js job.
 		return nil
 	}
 
-	errchan := f.waitForUnitStates(units, js, maxAttempts, events)
+	errchan := f.waitForUnitStates(ctx, units, js, maxAttempts, events)
 	var ae aerr.AggregateError
 	for err := range errchan {
 		ae.Add(maskAny(err))
@@ -176,17 +210,17 @@ func (f *FleetTunnel) tryWaitForUnitStates(units []string, state string, js job.
 
 // waitForUnitStates polls each of the indicated units until each of their
 // states is equal to that which the caller indicates, or until the
-// polling operation times out. waitForUnitStates will retry forever, or
+// polling operation times out or the context is done. waitForUnitStates will retry forever, or
 // up to maxAttempts times before timing out if maxAttempts is greater
 // than zero. Returned is an error channel used to communicate when
 // timeouts occur. The returned error channel will be closed after all
 // polling operation is complete.
-func (f *FleetTunnel) waitForUnitStates(units []string, js job.JobState, maxAttempts int, events chan Event) chan error {
+func (f *FleetTunnel) waitForUnitStates(ctx context.Context, units []string, js job.JobState, maxAttempts int, events chan Event) chan error {
 	errchan := make(chan error)
 	var wg sync.WaitGroup
 	for _, name := range units {
 		wg.Add(1)
-		go f.checkUnitState(name, js, maxAttempts, events, &wg, errchan)
+		go f.checkUnitState(ctx, name, js, maxAttempts, events, &wg, errchan)
 	}
 
 	go func() {
@@ -197,33 +231,37 @@ func (f *FleetTunnel) waitForUnitStates(units []string, js job.JobState, maxAtte
 	return errchan
 }
 
-func (f *FleetTunnel) checkUnitState(name string, js job.JobState, maxAttempts int, events chan Event, wg *sync.WaitGroup, errchan chan error) {
+func (f *FleetTunnel) checkUnitState(ctx context.Context, name string, js job.JobState, maxAttempts int, events chan Event, wg *sync.WaitGroup, errchan chan error) {
 	defer wg.Done()
 
-	sleep := defaultSleepTime
-
 	if maxAttempts < 1 {
 		for {
-			if f.assertUnitState(name, js, events) {
+			if f.assertUnitState(ctx, name, js, events) {
+				return
+			}
+			if err := sleep(ctx, defaultSleepTime); err != nil {
+				errchan <- maskAny(err)
 				return
 			}
-			time.Sleep(sleep)
 		}
 	} else {
 		for attempt := 0; attempt < maxAttempts; attempt++ {
-			if f.assertUnitState(name, js, events) {
+			if f.assertUnitState(ctx, name, js, events) {
+				return
+			}
+			if err := sleep(ctx, defaultSleepTime); err != nil {
+				errchan <- maskAny(err)
 				return
 			}
-			time.Sleep(sleep)
 		}
 		errchan <- fmt.Errorf("timed out waiting for unit %s to report state %s", name, js)
 	}
 }
 
-func (f *FleetTunnel) assertUnitState(name string, js job.JobState, events chan Event) (ret bool) {
+func (f *FleetTunnel) assertUnitState(ctx context.Context, name string, js job.JobState, events chan Event) (ret bool) {
 	var state string
 
-	u, err := f.unitWithRetry(name)
+	u, err := f.unitWithRetry(ctx, name)
 	if err != nil {
 		log.Warningf("Error retrieving Unit(%s) from Registry: %v", name, err)
 		return
@@ -294,7 +332,7 @@ func (f *FleetTunnel) cachedMachineState(machID string) (ms *machine.MachineStat
 // to the given state in the Registry.
 // On success, a slice of the Units for which a state change was made is returned.
 // Any error encountered is immediately returned (i.e. this is not a transaction).
-func (f *FleetTunnel) setTargetStateOfUnits(units []string, state job.JobState) ([]*schema.Unit, error) {
+func (f *FleetTunnel) setTargetStateOfUnits(ctx context.Context, units []string, state job.JobState) ([]*schema.Unit, error) {
 	var triggered []*schema.Unit
 	for _, name := range units {
 		u, err := f.cAPI.Unit(name)
@@ -308,7 +346,7 @@ func (f *FleetTunnel) setTargetStateOfUnits(units []string, state job.JobState)
 		}
 
 		log.Debugf("Setting Unit(%s) target state to %s", u.Name, state)
-		if err := f.setUnitTargetStateWithRetry(u.Name, string(state)); err != nil {
+		if err := f.setUnitTargetStateWithRetry(ctx, u.Name, string(state)); err != nil {
 			return nil, maskAny(err)
 		}
 		triggered = append(triggered, u)
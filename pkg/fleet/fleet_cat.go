@@ -15,13 +15,13 @@
 package fleet
 
 import (
-	"github.com/cenkalti/backoff"
 	"github.com/juju/errgo"
+	"golang.org/x/net/context"
 
 	"github.com/coreos/fleet/schema"
 )
 
-func (f *FleetTunnel) Cat(unitName string) (string, error) {
+func (f *FleetTunnel) Cat(ctx context.Context, unitName string) (string, error) {
 	log.Debugf("cat unit %v", unitName)
 
 	var u *schema.Unit
@@ -30,7 +30,7 @@ func (f *FleetTunnel) Cat(unitName string) (string, error) {
 		u, err = f.cAPI.Unit(unitName)
 		return maskAny(err)
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return "", maskAny(err)
 	}
 	if u == nil {
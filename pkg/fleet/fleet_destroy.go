@@ -16,19 +16,19 @@ package fleet
 
 import (
 	"fmt"
-	"time"
 
 	aerr "github.com/ewoutp/go-aggregate-error"
+	"golang.org/x/net/context"
 )
 
-func (f *FleetTunnel) Destroy(events chan Event, unitNames ...string) error {
+func (f *FleetTunnel) Destroy(ctx context.Context, events chan Event, unitNames ...string) error {
 	log.Debugf("destroying %v", unitNames)
 
 	var ae aerr.AggregateError
 
 	for _, unit := range unitNames {
 		events <- newEvent(unit, "destroying")
-		if notFound, err := f.destroyUnitWithRetry(unit); notFound {
+		if notFound, err := f.destroyUnitWithRetry(ctx, unit); notFound {
 			continue
 		} else if err != nil {
 			ae.Add(maskAny(fmt.Errorf("Error destroying units: %v", err)))
@@ -49,7 +49,7 @@ func (f *FleetTunnel) Destroy(events chan Event, unitNames ...string) error {
 			}
 
 			for retry() {
-				exists, err := f.unitExists(unit)
+				exists, err := f.unitExists(ctx, unit)
 				if err != nil {
 					ae.Add(maskAny(fmt.Errorf("Error destroying units: %v", err)))
 					break
@@ -58,7 +58,10 @@ func (f *FleetTunnel) Destroy(events chan Event, unitNames ...string) error {
 				if !exists {
 					break
 				}
-				time.Sleep(defaultSleepTime)
+				if err := sleep(ctx, defaultSleepTime); err != nil {
+					ae.Add(maskAny(err))
+					break
+				}
 			}
 		}
 
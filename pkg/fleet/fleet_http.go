@@ -0,0 +1,69 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleet
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coreos/fleet/ssh"
+	"golang.org/x/net/context"
+)
+
+const (
+	httpCheckTimeout = 10 * time.Second
+)
+
+// HTTPCheck performs an HTTP request with given method & headers on the given path of the given host & port.
+// When a tunnel is configured, the request is performed through that tunnel.
+// It returns the status code of the response.
+func (f *FleetTunnel) HTTPCheck(ctx context.Context, method, host string, port int, path string, headers map[string]string) (int, error) {
+	log.Debugf("http check %s %s:%d%s", method, host, port, path)
+
+	dial := net.Dial
+	if f.Tunnel != "" {
+		sshClient, err := ssh.NewSSHClient(f.SSHUserName, f.Tunnel, getChecker(f.FleetConfig), false, f.SSHTimeout)
+		if err != nil {
+			return 0, maskAny(fmt.Errorf("failed initializing SSH client: %v", err))
+		}
+		defer sshClient.Close()
+		dial = sshClient.Dial
+	}
+
+	hc := http.Client{
+		Transport: &http.Transport{
+			Dial:              dial,
+			DisableKeepAlives: true,
+		},
+		Timeout: httpCheckTimeout,
+	}
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(host, strconv.Itoa(port)), path)
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleet
+
+import (
+	"fmt"
+
+	"github.com/coreos/fleet/ssh"
+	"golang.org/x/net/context"
+)
+
+// Journal returns the last lines of the journal of the given unit on the machine with given IP.
+// When a tunnel is configured, the machine is reached through that tunnel.
+func (f *FleetTunnel) Journal(ctx context.Context, unitName, machineIP string, lines int) (string, error) {
+	var sshClient *ssh.SSHForwardingClient
+	var err error
+	if f.Tunnel != "" {
+		sshClient, err = ssh.NewTunnelledSSHClient(f.SSHUserName, f.Tunnel, machineIP, getChecker(f.FleetConfig), false, f.SSHTimeout)
+	} else {
+		sshClient, err = ssh.NewSSHClient(f.SSHUserName, machineIP, getChecker(f.FleetConfig), false, f.SSHTimeout)
+	}
+	if err != nil {
+		return "", maskAny(fmt.Errorf("failed initializing SSH client: %v", err))
+	}
+	defer sshClient.Close()
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer session.Close()
+
+	cmd := fmt.Sprintf("journalctl --unit %s --no-pager --output cat -n %d", unitName, lines)
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := session.CombinedOutput(cmd)
+		done <- result{output, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", maskAny(ctx.Err())
+	case r := <-done:
+		if r.err != nil {
+			return "", maskAny(fmt.Errorf("%s failed: %v", cmd, r.err))
+		}
+		return string(r.output), nil
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleet
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/coreos/fleet/pkg"
+	"github.com/coreos/fleet/ssh"
+)
+
+// KeysAPI returns a client for the etcd key space of the cluster.
+// When a tunnel is configured, all requests are performed through that tunnel.
+func (f *FleetTunnel) KeysAPI() (etcd.KeysAPI, error) {
+	if f.kAPI != nil {
+		return f.kAPI, nil
+	}
+
+	dial := net.Dial
+	if f.Tunnel != "" {
+		sshClient, err := ssh.NewSSHClient(f.SSHUserName, f.Tunnel, getChecker(f.FleetConfig), false, f.SSHTimeout)
+		if err != nil {
+			return nil, maskAny(fmt.Errorf("failed initializing SSH client: %v", err))
+		}
+		dial = sshClient.Dial
+	}
+
+	tlsConfig, err := pkg.ReadTLSConfigFiles(f.CAFile, f.CertFile, f.KeyFile)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	eClient, err := etcd.New(etcd.Config{
+		Endpoints: strings.Split(defaultRegistryEndpoint, ","),
+		Transport: &http.Transport{
+			Dial:            dial,
+			TLSClientConfig: tlsConfig,
+		},
+		HeaderTimeoutPerRequest: f.RequestTimeout,
+	})
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	f.kAPI = etcd.NewKeysAPI(eClient)
+	return f.kAPI, nil
+}
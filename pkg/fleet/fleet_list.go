@@ -15,11 +15,11 @@
 package fleet
 
 import (
-	"github.com/cenkalti/backoff"
 	"github.com/coreos/fleet/schema"
+	"golang.org/x/net/context"
 )
 
-func (f *FleetTunnel) List() ([]string, error) {
+func (f *FleetTunnel) List(ctx context.Context) ([]string, error) {
 	log.Debugf("list units")
 
 	var units []*schema.Unit
@@ -28,7 +28,7 @@ func (f *FleetTunnel) List() ([]string, error) {
 		units, err = f.cAPI.Units()
 		return maskAny(err)
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return nil, maskAny(err)
 	}
 
@@ -23,6 +23,7 @@ import (
 	"github.com/coreos/fleet/schema"
 	"github.com/coreos/fleet/unit"
 	aerr "github.com/ewoutp/go-aggregate-error"
+	"golang.org/x/net/context"
 )
 
 type UnitData interface {
@@ -35,14 +36,14 @@ type UnitDataList interface {
 	Get(index int) UnitData
 }
 
-func (f *FleetTunnel) Start(events chan Event, units UnitDataList) error {
+func (f *FleetTunnel) Start(ctx context.Context, events chan Event, units UnitDataList) error {
 	log.Debugf("starting %v", units)
 
-	if err := f.lazyCreateUnits(units, events); err != nil {
+	if err := f.lazyCreateUnits(ctx, units, events); err != nil {
 		return maskAny(fmt.Errorf("Error creating units: %v", err))
 	}
 
-	triggered, err := f.lazyStartUnits(units)
+	triggered, err := f.lazyStartUnits(ctx, units)
 	if err != nil {
 		return maskAny(fmt.Errorf("Error starting units: %v", err))
 	}
@@ -56,7 +57,7 @@ func (f *FleetTunnel) Start(events chan Event, units UnitDataList) error {
 		}
 	}
 
-	if err := f.tryWaitForUnitStates(starting, "start", job.JobStateLaunched, f.BlockAttempts, events); err != nil {
+	if err := f.tryWaitForUnitStates(ctx, starting, "start", job.JobStateLaunched, f.BlockAttempts, events); err != nil {
 		return maskAny(err)
 	}
 	return nil
@@ -72,14 +73,14 @@ func (f *FleetTunnel) Start(events chan Event, units UnitDataList) error {
 // Any error encountered during these steps is returned immediately (i.e.
 // subsequent Jobs are not acted on). An error is also returned if none of the
 // above conditions match a given Job.
-func (f *FleetTunnel) lazyCreateUnits(units UnitDataList, events chan Event) error {
+func (f *FleetTunnel) lazyCreateUnits(ctx context.Context, units UnitDataList, events chan Event) error {
 	errchan := make(chan error)
 	blockAttempts := f.BlockAttempts
 	var wg sync.WaitGroup
 	for i := 0; i < units.Len(); i++ {
 		u := units.Get(i)
 		name := u.Name()
-		create, err := f.checkUnitCreation(name)
+		create, err := f.checkUnitCreation(ctx, name)
 		if err != nil {
 			return err
 		} else if !create {
@@ -95,13 +96,13 @@ func (f *FleetTunnel) lazyCreateUnits(units UnitDataList, events chan Event) err
 		}
 
 		events <- newEvent(name, "creating unit")
-		_, err = f.createUnit(name, uf)
+		_, err = f.createUnit(ctx, name, uf)
 		if err != nil {
 			return err
 		}
 
 		wg.Add(1)
-		go f.checkUnitState(name, job.JobStateInactive, blockAttempts, events, &wg, errchan)
+		go f.checkUnitState(ctx, name, job.JobStateInactive, blockAttempts, events, &wg, errchan)
 	}
 
 	go func() {
@@ -122,24 +123,24 @@ func (f *FleetTunnel) lazyCreateUnits(units UnitDataList, events chan Event) err
 }
 
 // checkUnitCreation checks if the unit with the given name should be created.
-func (f *FleetTunnel) checkUnitCreation(unitName string) (bool, error) {
+func (f *FleetTunnel) checkUnitCreation(ctx context.Context, unitName string) (bool, error) {
 	// First, check if there already exists a Unit by the given name in the Registry
-	exists, err := f.unitExists(unitName)
+	exists, err := f.unitExists(ctx, unitName)
 	if err != nil {
 		return false, maskAny(fmt.Errorf("error retrieving Unit(%s) from Registry: %v", unitName, err))
 	}
 	return !exists, nil
 }
 
-func (f *FleetTunnel) lazyStartUnits(units UnitDataList) ([]*schema.Unit, error) {
+func (f *FleetTunnel) lazyStartUnits(ctx context.Context, units UnitDataList) ([]*schema.Unit, error) {
 	unitNames := make([]string, 0, units.Len())
 	for i := 0; i < units.Len(); i++ {
 		unitNames = append(unitNames, units.Get(i).Name())
 	}
-	return f.setTargetStateOfUnits(unitNames, job.JobStateLaunched)
+	return f.setTargetStateOfUnits(ctx, unitNames, job.JobStateLaunched)
 }
 
-func (f *FleetTunnel) createUnit(name string, uf *unit.UnitFile) (*schema.Unit, error) {
+func (f *FleetTunnel) createUnit(ctx context.Context, name string, uf *unit.UnitFile) (*schema.Unit, error) {
 	if uf == nil {
 		return nil, maskAny(fmt.Errorf("nil unit provided"))
 	}
@@ -161,7 +162,7 @@ func (f *FleetTunnel) createUnit(name string, uf *unit.UnitFile) (*schema.Unit,
 	if err := j.ValidateRequirements(); err != nil {
 		log.Warningf("Unit %s: %v", name, err)
 	}
-	err := f.createUnitWithRetry(&u)
+	err := f.createUnitWithRetry(ctx, &u)
 	if err != nil {
 		return nil, maskAny(fmt.Errorf("failed creating unit %s: %v", name, err))
 	}
@@ -15,11 +15,11 @@
 package fleet
 
 import (
-	"github.com/cenkalti/backoff"
 	"github.com/coreos/fleet/schema"
+	"golang.org/x/net/context"
 )
 
-func (f *FleetTunnel) Status() (StatusMap, error) {
+func (f *FleetTunnel) Status(ctx context.Context) (StatusMap, error) {
 	log.Debugf("list unit status")
 
 	var states []*schema.UnitState
@@ -31,9 +31,18 @@ func (f *FleetTunnel) Status() (StatusMap, error) {
 		}
 		return nil
 	}
-	if err := backoff.Retry(op, backoff.NewExponentialBackOff()); err != nil {
+	if err := retry(ctx, op); err != nil {
 		return StatusMap{}, maskAny(err)
 	}
 
-	return newStatusMapFromUnits(states), nil
+	status := newStatusMapFromUnits(states)
+	for name, machID := range status.machines {
+		if ms := f.cachedMachineState(machID); ms != nil {
+			status.machines[name] = machineFullLegend(*ms, false)
+			if ms.PublicIP != "" {
+				status.ips[name] = ms.PublicIP
+			}
+		}
+	}
+	return status, nil
 }
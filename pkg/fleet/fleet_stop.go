@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/coreos/fleet/job"
+	"golang.org/x/net/context"
 )
 
 type StopStats struct {
@@ -25,7 +26,7 @@ type StopStats struct {
 	StoppedGlobalUnits int
 }
 
-func (f *FleetTunnel) Stop(events chan Event, unitNames ...string) (StopStats, error) {
+func (f *FleetTunnel) Stop(ctx context.Context, events chan Event, unitNames ...string) (StopStats, error) {
 	log.Debugf("stopping %v", unitNames)
 
 	units, err := f.findUnits(unitNames)
@@ -46,7 +47,7 @@ func (f *FleetTunnel) Stop(events chan Event, unitNames ...string) (StopStats, e
 		}
 
 		log.Debugf("Setting target state of Unit(%s) to %s", u.Name, job.JobStateLoaded)
-		if err := f.setUnitTargetStateWithRetry(u.Name, string(job.JobStateLoaded)); err != nil {
+		if err := f.setUnitTargetStateWithRetry(ctx, u.Name, string(job.JobStateLoaded)); err != nil {
 			return StopStats{}, maskAny(err)
 		}
 		if suToGlobal(u) {
@@ -59,7 +60,7 @@ func (f *FleetTunnel) Stop(events chan Event, unitNames ...string) (StopStats, e
 		}
 	}
 
-	if err := f.tryWaitForUnitStates(stopping, "stop", job.JobStateLoaded, f.BlockAttempts, events); err != nil {
+	if err := f.tryWaitForUnitStates(ctx, stopping, "stop", job.JobStateLoaded, f.BlockAttempts, events); err != nil {
 		return StopStats{}, maskAny(err)
 	}
 
@@ -22,7 +22,9 @@ import (
 )
 
 type StatusMap struct {
-	state map[string]string
+	state    map[string]string
+	machines map[string]string
+	ips      map[string]string
 }
 
 func (s StatusMap) Get(unitName string) (string, bool) {
@@ -32,13 +34,34 @@ func (s StatusMap) Get(unitName string) (string, bool) {
 	return "", false
 }
 
+// Machine returns a description of the machine the unit with given name is running on.
+func (s StatusMap) Machine(unitName string) (string, bool) {
+	if machine, ok := s.machines[unitName]; ok {
+		return machine, true
+	}
+	return "", false
+}
+
+// MachineIP returns the public IP address of the machine the unit with given name is running on.
+func (s StatusMap) MachineIP(unitName string) (string, bool) {
+	if ip, ok := s.ips[unitName]; ok {
+		return ip, true
+	}
+	return "", false
+}
+
 func newStatusMapFromUnits(unitStates []*schema.UnitState) StatusMap {
 	//fmt.Printf("Fleet Status:\n%s\n", listUnitsOutput)
 	s := StatusMap{
-		state: make(map[string]string),
+		state:    make(map[string]string),
+		machines: make(map[string]string),
+		ips:      make(map[string]string),
 	}
 	for _, unit := range unitStates {
 		s.state[unit.Name] = unit.SystemdActiveState
+		if unit.MachineID != "" {
+			s.machines[unit.Name] = unit.MachineID
+		}
 	}
 	return s
 }
@@ -0,0 +1,581 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hclutil
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/juju/errgo"
+)
+
+const (
+	templatePlaceholderPrefix = "__j2_template_"
+	maxInlineListLength       = 80
+)
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-]*$`)
+)
+
+// FormatRules specifies the canonical layout of one type of HCL file.
+type FormatRules struct {
+	// Key of the top level block of the files these rules apply to (e.g. "job")
+	Root string
+	// Order of the keys inside blocks, by block key (e.g. "task").
+	// Keys that are not listed follow the listed keys in their original order.
+	KeyOrder map[string][]string
+	// Keys of blocks whose content is sorted by key (e.g. "env")
+	SortedBlocks []string
+	// Functions that normalize string values, by block key & value key (e.g. "task.volumes").
+	// If a function returns an error, the value is left unchanged.
+	Values map[string]func(string) (string, error)
+}
+
+// Format rewrites the given HCL input into a canonical layout, using the first of the given rules
+// whose root matches the top level block of the input.
+// Input with another top level block (e.g. a file included in a job) is formatted using the first rules.
+// Go template sections (`{{ ... }}`) are left untouched. Lines that contain only a template section
+// (e.g. `{{include "..."}}` or `{{if ...}}`) keep their indentation and the blocks that contain them
+// are not reordered.
+func Format(input []byte, rules ...FormatRules) ([]byte, error) {
+	src, sections := protectTemplates(string(input))
+	file, err := parser.Parse([]byte(src))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, maskAny(errgo.New("error parsing: root should be an object"))
+	}
+	if len(list.Items) == 0 {
+		return input, nil
+	}
+	if len(rules) == 0 {
+		return nil, maskAny(errgo.WithCausef(nil, ValidationError, "no format rules specified"))
+	}
+	root := keyString(list.Items[0].Keys[0])
+	selected := rules[0]
+	for _, r := range rules {
+		if r.Root == root {
+			selected = r
+			break
+		}
+	}
+
+	f := &formatter{
+		rules:    selected,
+		comments: standaloneComments(file),
+	}
+	f.printList(list, "", 0, 0, 1<<30)
+	output := strings.TrimSpace(f.buf.String()) + "\n"
+	return []byte(restoreTemplates(output, sections)), nil
+}
+
+// templateKind specifies the context in which a template section is used.
+type templateKind int
+
+const (
+	templateInString templateKind = iota // Inside a string or comment
+	templateValue                        // In place of a value or key
+	templateLine                         // On a line of its own
+)
+
+// templateSection is a Go template section (`{{ ... }}`) that has been replaced by a placeholder.
+type templateSection struct {
+	Text   string
+	Kind   templateKind
+	Indent string // Original indentation of a template section on a line of its own
+}
+
+// placeholder returns the text that replaces the template section with given index.
+func (s templateSection) placeholder(index int) string {
+	name := fmt.Sprintf("%s%d__", templatePlaceholderPrefix, index)
+	switch s.Kind {
+	case templateValue:
+		return `"` + name + `"`
+	case templateLine:
+		return "# " + name
+	default:
+		return name
+	}
+}
+
+// protectTemplates replaces all Go template sections in the given input by placeholders
+// such that the result can be parsed as HCL.
+func protectTemplates(input string) (string, []templateSection) {
+	var buf bytes.Buffer
+	var sections []templateSection
+	inString, inLineComment, inBlockComment := false, false, false
+	lineStart := 0
+	for i := 0; i < len(input); {
+		if strings.HasPrefix(input[i:], "{{") {
+			end := strings.Index(input[i+2:], "}}")
+			if end >= 0 {
+				end += i + 4
+				s := templateSection{Text: input[i:end], Kind: templateValue}
+				if inString || inLineComment || inBlockComment {
+					s.Kind = templateInString
+				} else if indent := buf.String()[lineStart:]; strings.TrimSpace(indent) == "" && isBlankUntilEOL(input[end:]) {
+					s.Kind = templateLine
+					s.Indent = indent
+				}
+				buf.WriteString(s.placeholder(len(sections)))
+				sections = append(sections, s)
+				i = end
+				continue
+			}
+		}
+		c := input[i]
+		next := byte(0)
+		if i+1 < len(input) {
+			next = input[i+1]
+		}
+		switch {
+		case inLineComment:
+			inLineComment = c != '\n'
+		case inBlockComment:
+			if c == '*' && next == '/' {
+				inBlockComment = false
+				buf.WriteString("*/")
+				i += 2
+				continue
+			}
+		case inString:
+			if c == '\\' && next != 0 {
+				buf.WriteByte(c)
+				buf.WriteByte(next)
+				i += 2
+				continue
+			}
+			inString = c != '"' && c != '\n'
+		default:
+			switch {
+			case c == '"':
+				inString = true
+			case c == '#' || (c == '/' && next == '/'):
+				inLineComment = true
+			case c == '/' && next == '*':
+				inBlockComment = true
+			}
+		}
+		buf.WriteByte(c)
+		if c == '\n' {
+			lineStart = buf.Len()
+		}
+		i++
+	}
+	return buf.String(), sections
+}
+
+// restoreTemplates replaces all placeholders in the given output by their original template sections.
+// Template sections on lines of their own are restored with their original indentation.
+func restoreTemplates(output string, sections []templateSection) string {
+	for i, s := range sections {
+		placeholder := s.placeholder(i)
+		idx := strings.Index(output, placeholder)
+		if idx < 0 {
+			continue
+		}
+		start, text := idx, s.Text
+		if s.Kind == templateLine {
+			start = strings.LastIndexByte(output[:idx], '\n') + 1
+			if strings.TrimSpace(output[start:idx]) != "" {
+				// Placeholder has been printed after other content
+				start = idx
+			} else {
+				text = s.Indent + s.Text
+			}
+		}
+		output = output[:start] + text + output[idx+len(placeholder):]
+	}
+	return output
+}
+
+// isBlankUntilEOL returns true if the given input only contains whitespace until the end of the line.
+func isBlankUntilEOL(input string) bool {
+	if idx := strings.IndexByte(input, '\n'); idx >= 0 {
+		input = input[:idx]
+	}
+	return strings.TrimSpace(input) == ""
+}
+
+// isTemplatePlaceholder returns true if the given comment group consists of a template placeholder only.
+func isTemplatePlaceholder(g *ast.CommentGroup) bool {
+	for _, c := range g.List {
+		if strings.HasPrefix(c.Text, "# "+templatePlaceholderPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// standaloneComments returns all comment groups in the given file that are not attached to a node.
+func standaloneComments(file *ast.File) []*ast.CommentGroup {
+	attached := make(map[*ast.Comment]struct{})
+	add := func(g *ast.CommentGroup) {
+		if g != nil {
+			for _, c := range g.List {
+				attached[c] = struct{}{}
+			}
+		}
+	}
+	ast.Walk(file.Node, func(n ast.Node) (ast.Node, bool) {
+		switch t := n.(type) {
+		case *ast.ObjectItem:
+			add(t.LeadComment)
+			add(t.LineComment)
+		case *ast.LiteralType:
+			add(t.LeadComment)
+			add(t.LineComment)
+		}
+		return n, true
+	})
+	var result []*ast.CommentGroup
+	for _, g := range file.Comments {
+		if _, ok := attached[g.List[0]]; !ok {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// formatter prints an HCL syntax tree in canonical layout.
+type formatter struct {
+	rules    FormatRules
+	comments []*ast.CommentGroup // Standalone comments that have not yet been printed
+	buf      bytes.Buffer
+}
+
+// listEntry is an item in an object list, together with the standalone comments that precede it.
+type listEntry struct {
+	item        *ast.ObjectItem // nil for comments at the end of the list
+	comments    []*ast.CommentGroup
+	blankBefore bool // Set if the entry was preceded by an empty line
+	rank        int
+}
+
+// printList prints all items of the given list, which is the content of the block with given key,
+// located between the given lines.
+func (f *formatter) printList(list *ast.ObjectList, block string, indent, startLine, endLine int) {
+	// Collect the standalone comments in this list
+	var comments []*ast.CommentGroup
+	var remaining []*ast.CommentGroup
+	for _, g := range f.comments {
+		line := g.Pos().Line
+		if line > startLine && line < endLine && !insideItem(list, line) {
+			comments = append(comments, g)
+		} else {
+			remaining = append(remaining, g)
+		}
+	}
+	f.comments = remaining
+
+	// Build entries in source order
+	var entries []*listEntry
+	reorder := true
+	prevEnd := startLine
+	for _, item := range list.Items {
+		e := &listEntry{item: item}
+		start := itemStartLine(item)
+		for len(comments) > 0 && comments[0].Pos().Line < start {
+			e.comments = append(e.comments, comments[0])
+			comments = comments[1:]
+		}
+		if len(e.comments) > 0 {
+			start = e.comments[0].Pos().Line
+		}
+		e.blankBefore = start-prevEnd > 1
+		prevEnd = nodeEndLine(item)
+		entries = append(entries, e)
+		if item.LeadComment != nil && isTemplatePlaceholder(item.LeadComment) {
+			reorder = false
+		}
+		for _, g := range e.comments {
+			if isTemplatePlaceholder(g) {
+				reorder = false
+			}
+		}
+	}
+	var trailing *listEntry
+	if len(comments) > 0 {
+		trailing = &listEntry{comments: comments, blankBefore: comments[0].Pos().Line-prevEnd > 1}
+		for _, g := range comments {
+			if isTemplatePlaceholder(g) {
+				reorder = false
+			}
+		}
+	}
+
+	// Put entries in canonical order
+	if reorder {
+		f.sortEntries(entries, block)
+	}
+	if trailing != nil {
+		entries = append(entries, trailing)
+	}
+
+	// Print entries
+	for i, e := range entries {
+		if i > 0 && e.blankBefore {
+			f.buf.WriteString("\n")
+		}
+		for j, g := range e.comments {
+			f.printComment(g, indent)
+			// Preserve empty lines after standalone comments
+			next := 0
+			if j+1 < len(e.comments) {
+				next = e.comments[j+1].Pos().Line
+			} else if e.item != nil {
+				next = itemStartLine(e.item)
+			}
+			if next > 0 && next-commentEndLine(g) > 1 {
+				f.buf.WriteString("\n")
+			}
+		}
+		if e.item != nil {
+			f.printItem(e.item, block, indent)
+		}
+	}
+}
+
+// sortEntries sorts the given entries according to the rules for the block with given key.
+func (f *formatter) sortEntries(entries []*listEntry, block string) {
+	if containsKey(f.rules.SortedBlocks, block) {
+		sort.Stable(entrySorter{entries, func(a, b *listEntry) bool {
+			return keyString(a.item.Keys[0]) < keyString(b.item.Keys[0])
+		}})
+		return
+	}
+	order := f.rules.KeyOrder[block]
+	if len(order) == 0 {
+		return
+	}
+	for _, e := range entries {
+		e.rank = len(order)
+		key := keyString(e.item.Keys[0])
+		for i, k := range order {
+			if k == key {
+				e.rank = i
+				break
+			}
+		}
+	}
+	sort.Stable(entrySorter{entries, func(a, b *listEntry) bool { return a.rank < b.rank }})
+}
+
+// printItem prints a single item, which is part of the block with given key.
+func (f *formatter) printItem(item *ast.ObjectItem, block string, indent int) {
+	if item.LeadComment != nil {
+		f.printComment(item.LeadComment, indent)
+	}
+	f.writeIndent(indent)
+	key := keyString(item.Keys[0])
+	for i, k := range item.Keys {
+		if i > 0 {
+			f.buf.WriteString(" ")
+		}
+		s := keyString(k)
+		if i == 0 && identifierPattern.MatchString(s) && !strings.Contains(s, templatePlaceholderPrefix) {
+			f.buf.WriteString(s)
+		} else {
+			f.buf.WriteString(quoteString(s))
+		}
+	}
+	switch v := item.Val.(type) {
+	case *ast.ObjectType:
+		f.buf.WriteString(" ")
+		f.printObject(v, key, indent)
+	case *ast.ListType:
+		f.buf.WriteString(" = ")
+		f.printListValue(v, block+"."+key, indent)
+	case *ast.LiteralType:
+		f.buf.WriteString(" = ")
+		f.buf.WriteString(f.literal(v, block+"."+key))
+	}
+	if item.LineComment != nil {
+		f.buf.WriteString(" ")
+		f.buf.WriteString(item.LineComment.List[0].Text)
+	}
+	f.buf.WriteString("\n")
+}
+
+// printObject prints an object value, which is the content of the block with given key.
+func (f *formatter) printObject(obj *ast.ObjectType, block string, indent int) {
+	if len(obj.List.Items) == 0 && !f.hasComments(obj.Lbrace.Line, obj.Rbrace.Line) {
+		f.buf.WriteString("{}")
+		return
+	}
+	f.buf.WriteString("{\n")
+	f.printList(obj.List, block, indent+1, obj.Lbrace.Line, obj.Rbrace.Line)
+	f.writeIndent(indent)
+	f.buf.WriteString("}")
+}
+
+// printListValue prints a list value, found under the given value key.
+func (f *formatter) printListValue(list *ast.ListType, valueKey string, indent int) {
+	var elems []string
+	inline := true
+	length := 0
+	for _, n := range list.List {
+		lit, ok := n.(*ast.LiteralType)
+		if !ok || lit.LeadComment != nil || lit.LineComment != nil {
+			inline = false
+			break
+		}
+		s := f.literal(lit, valueKey)
+		elems = append(elems, s)
+		length += len(s) + 2
+	}
+	if inline && length <= maxInlineListLength {
+		f.buf.WriteString("[" + strings.Join(elems, ", ") + "]")
+		return
+	}
+	f.buf.WriteString("[\n")
+	for _, n := range list.List {
+		switch v := n.(type) {
+		case *ast.LiteralType:
+			if v.LeadComment != nil {
+				f.printComment(v.LeadComment, indent+1)
+			}
+			f.writeIndent(indent + 1)
+			f.buf.WriteString(f.literal(v, valueKey) + ",")
+			if v.LineComment != nil {
+				f.buf.WriteString(" " + v.LineComment.List[0].Text)
+			}
+		case *ast.ObjectType:
+			f.writeIndent(indent + 1)
+			f.printObject(v, valueKey, indent+1)
+			f.buf.WriteString(",")
+		}
+		f.buf.WriteString("\n")
+	}
+	f.writeIndent(indent)
+	f.buf.WriteString("]")
+}
+
+// literal returns the canonical text of a literal value, found under the given value key.
+func (f *formatter) literal(lit *ast.LiteralType, valueKey string) string {
+	if lit.Token.Type != token.STRING {
+		return lit.Token.Text
+	}
+	s, ok := lit.Token.Value().(string)
+	if !ok {
+		return lit.Token.Text
+	}
+	if normalize, found := f.rules.Values[valueKey]; found && !strings.Contains(s, templatePlaceholderPrefix) {
+		if normalized, err := normalize(s); err == nil {
+			return quoteString(normalized)
+		}
+	}
+	return lit.Token.Text
+}
+
+// printComment prints all lines of the given comment group.
+func (f *formatter) printComment(g *ast.CommentGroup, indent int) {
+	for _, c := range g.List {
+		f.writeIndent(indent)
+		f.buf.WriteString(c.Text)
+		f.buf.WriteString("\n")
+	}
+}
+
+// hasComments returns true if there are unprinted standalone comments between the given lines.
+func (f *formatter) hasComments(startLine, endLine int) bool {
+	for _, g := range f.comments {
+		if line := g.Pos().Line; line > startLine && line < endLine {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *formatter) writeIndent(indent int) {
+	f.buf.WriteString(strings.Repeat("\t", indent))
+}
+
+// insideItem returns true if the given line is part of one of the items in the given list.
+func insideItem(list *ast.ObjectList, line int) bool {
+	for _, item := range list.Items {
+		if line >= item.Pos().Line && line <= nodeEndLine(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentEndLine returns the last line of the given comment group.
+func commentEndLine(g *ast.CommentGroup) int {
+	last := g.List[len(g.List)-1]
+	return last.Start.Line + strings.Count(last.Text, "\n")
+}
+
+// itemStartLine returns the first line of the given item, including its lead comment.
+func itemStartLine(item *ast.ObjectItem) int {
+	if item.LeadComment != nil {
+		return item.LeadComment.Pos().Line
+	}
+	return item.Pos().Line
+}
+
+// nodeEndLine returns the last line of the given node.
+func nodeEndLine(n ast.Node) int {
+	switch t := n.(type) {
+	case *ast.ObjectItem:
+		end := nodeEndLine(t.Val)
+		if t.LineComment != nil && t.LineComment.Pos().Line > end {
+			end = t.LineComment.Pos().Line
+		}
+		return end
+	case *ast.ObjectType:
+		return t.Rbrace.Line
+	case *ast.ListType:
+		return t.Rbrack.Line
+	case *ast.LiteralType:
+		return t.Token.Pos.Line + strings.Count(t.Token.Text, "\n")
+	}
+	return n.Pos().Line
+}
+
+// keyString returns the (unquoted) value of the given key.
+func keyString(key *ast.ObjectKey) string {
+	if s, ok := key.Token.Value().(string); ok {
+		return s
+	}
+	return key.Token.Text
+}
+
+// quoteString returns the given string as a quoted HCL string.
+func quoteString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
+
+type entrySorter struct {
+	entries []*listEntry
+	less    func(a, b *listEntry) bool
+}
+
+func (s entrySorter) Len() int           { return len(s.entries) }
+func (s entrySorter) Swap(i, j int)      { s.entries[i], s.entries[j] = s.entries[j], s.entries[i] }
+func (s entrySorter) Less(i, j int) bool { return s.less(s.entries[i], s.entries[j]) }
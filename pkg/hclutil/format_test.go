@@ -0,0 +1,75 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hclutil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+func TestFormat(t *testing.T) {
+	rules := hclutil.FormatRules{
+		Root: "job",
+		KeyOrder: map[string][]string{
+			"task": []string{"image", "env", "ports"},
+		},
+		SortedBlocks: []string{"env"},
+		Values: map[string]func(string) (string, error){
+			"task.ports": func(s string) (string, error) { return strings.ToUpper(s), nil },
+		},
+	}
+	tests := []struct {
+		Input    string
+		Expected string
+	}{
+		{
+			Input:    "job \"a\" {\n  task b {\n    ports = [\"53/udp\"]\n    image=\"x\"\n  }\n}\n",
+			Expected: "job \"a\" {\n\ttask \"b\" {\n\t\timage = \"x\"\n\t\tports = [\"53/UDP\"]\n\t}\n}\n",
+		},
+		{
+			// Sorted blocks & quoting of keys
+			Input:    "job \"a\" {\n\ttask \"b\" {\n\t\tenv {\n\t\t\t\"Z\" = \"1\"\n\t\t\tA = \"{{opt \"a\"}}\"\n\t\t}\n\t}\n}\n",
+			Expected: "job \"a\" {\n\ttask \"b\" {\n\t\tenv {\n\t\t\tA = \"{{opt \"a\"}}\"\n\t\t\tZ = \"1\"\n\t\t}\n\t}\n}\n",
+		},
+		{
+			// Templates on lines of their own prevent reordering & keep their indentation, template values are left untouched
+			Input:    "job \"a\" {\n\ttask \"b\" {\n\t\tports = [{{.port}}]\n{{if .x}}\n\t\timage = \"x\"\n  {{else if .y}}\n\t\timage = \"y\"\n{{end}}\n\t}\n}\n",
+			Expected: "job \"a\" {\n\ttask \"b\" {\n\t\tports = [{{.port}}]\n{{if .x}}\n\t\timage = \"x\"\n  {{else if .y}}\n\t\timage = \"y\"\n{{end}}\n\t}\n}\n",
+		},
+		{
+			// Templates on lines of their own at the end of a block
+			Input:    "job \"a\" {\n{{if .x}}\n\ttask \"b\" {}\n\t{{end}}\n}\n{{include \"c\"}}\n",
+			Expected: "job \"a\" {\n{{if .x}}\n\ttask \"b\" {}\n\t{{end}}\n}\n{{include \"c\"}}\n",
+		},
+		{
+			// Comments & empty lines are preserved
+			Input:    "# header\n\njob \"a\" {\n\t# lead\n\ttask \"b\" {} # line\n}\n",
+			Expected: "# header\n\njob \"a\" {\n\t# lead\n\ttask \"b\" {} # line\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		result, err := hclutil.Format([]byte(test.Input), rules)
+		if err != nil {
+			t.Errorf("Failed to format '%s': %#v", test.Input, err)
+		} else if string(result) != test.Expected {
+			t.Errorf("Invalid result for '%s': expected '%s', got '%s'", test.Input, test.Expected, string(result))
+		} else if again, err := hclutil.Format(result, rules); err != nil || string(again) != string(result) {
+			t.Errorf("Formatting '%s' is not idempotent: got '%s'", test.Input, string(again))
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hclutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// UnknownKey is a key in an HCL block that is not known to the structure the block is decoded into.
+type UnknownKey struct {
+	Key        string // The unknown key
+	Block      string // Name of the block containing the key (e.g. task)
+	File       string // File containing the key (if known)
+	Line       int
+	Column     int
+	Suggestion string // Known key that is most similar to Key (if any)
+}
+
+// Message returns a description of the unknown key, without its position.
+func (k UnknownKey) Message() string {
+	msg := fmt.Sprintf("unknown key '%s' in %s", k.Key, k.Block)
+	if k.Suggestion != "" {
+		msg = msg + fmt.Sprintf(" (did you mean '%s'?)", k.Suggestion)
+	}
+	return msg
+}
+
+// String returns a description of the unknown key, prefixed with its position.
+func (k UnknownKey) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", k.File, k.Line, k.Column, k.Message())
+}
+
+// UnknownKeysError is returned when strict decoding finds one or more unknown keys.
+type UnknownKeysError struct {
+	Keys []UnknownKey
+}
+
+func (e *UnknownKeysError) Error() string {
+	lines := []string{fmt.Sprintf("%d unknown key(s) found", len(e.Keys))}
+	for _, k := range e.Keys {
+		lines = append(lines, k.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// UnknownKeys returns all keys in the given object that will not be used by Decode
+// when decoding into the given data structure.
+// Keys in extraKeys are handled outside of Decode and considered known.
+func UnknownKeys(obj *ast.ObjectType, block string, extraKeys []string, data interface{}) []UnknownKey {
+	if obj == nil || obj.List == nil {
+		return nil
+	}
+	known := append(KnownKeys(data), extraKeys...)
+	var result []UnknownKey
+	for _, item := range obj.List.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		key := item.Keys[0]
+		name, ok := key.Token.Value().(string)
+		if !ok {
+			name = key.Token.Text
+		}
+		if containsKey(known, name) {
+			continue
+		}
+		pos := key.Pos()
+		result = append(result, UnknownKey{
+			Key:        name,
+			Block:      block,
+			File:       pos.Filename,
+			Line:       pos.Line,
+			Column:     pos.Column,
+			Suggestion: suggestKey(name, known),
+		})
+	}
+	return result
+}
+
+// SortUnknownKeys sorts the given list of unknown keys by file & position.
+func SortUnknownKeys(keys []UnknownKey) {
+	sort.Stable(unknownKeysByPosition(keys))
+}
+
+// KnownKeys returns the keys that Decode will use when decoding into the given data structure.
+// These are the mapstructure tags of its exported fields, or the lowercase field name for fields without tag.
+func KnownKeys(data interface{}) []string {
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var result []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tagParts := strings.Split(f.Tag.Get("mapstructure"), ",")
+		squash := false
+		for _, tag := range tagParts[1:] {
+			if tag == "squash" {
+				squash = true
+			}
+		}
+		if squash {
+			result = append(result, KnownKeys(reflect.New(f.Type).Interface())...)
+			continue
+		}
+		if f.PkgPath != "" || tagParts[0] == "-" {
+			// Unexported or explicitly ignored
+			continue
+		}
+		if tagParts[0] != "" {
+			result = append(result, tagParts[0])
+		} else {
+			result = append(result, strings.ToLower(f.Name))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// containsKey returns true if the given list contains the given key (case insensitive, like mapstructure).
+func containsKey(list []string, key string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestKey returns the key in the given list of known keys that is most similar to the given key.
+// If none of the known keys is similar enough, an empty string is returned.
+func suggestKey(key string, known []string) string {
+	best := ""
+	bestDistance := len(key)/3 + 1
+	for _, k := range known {
+		if d := levenshtein(strings.ToLower(key), strings.ToLower(k)); d <= bestDistance && (best == "" || d < bestDistance) {
+			best = k
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between the given strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+type unknownKeysByPosition []UnknownKey
+
+func (l unknownKeysByPosition) Len() int      { return len(l) }
+func (l unknownKeysByPosition) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l unknownKeysByPosition) Less(i, j int) bool {
+	if l[i].File != l[j].File {
+		return l[i].File < l[j].File
+	}
+	if l[i].Line != l[j].Line {
+		return l[i].Line < l[j].Line
+	}
+	return l[i].Column < l[j].Column
+}
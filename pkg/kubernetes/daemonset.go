@@ -19,6 +19,7 @@ import (
 	"time"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -90,12 +91,12 @@ func (ds *DaemonSet) Content() string {
 }
 
 // Destroy deletes the daemonset from the cluster.
-func (ds *DaemonSet) Destroy(cs k8s.Client, events chan string) error {
+func (ds *DaemonSet) Destroy(ctx context.Context, cs k8s.Client, events chan string) error {
 	return maskAny(cs.DeleteDaemonSet(ds.Namespace(), ds.Name()))
 }
 
 // Start creates/updates the daemonSet
-func (ds *DaemonSet) Start(cs k8s.Client, events chan string) error {
+func (ds *DaemonSet) Start(ctx context.Context, cs k8s.Client, events chan string) error {
 	current, err := cs.GetDaemonSet(ds.Namespace(), ds.Name())
 	if err == nil {
 		// Update
@@ -111,7 +112,7 @@ func (ds *DaemonSet) Start(cs k8s.Client, events chan string) error {
 			return maskAny(err)
 		}
 		// Delete pods one at a time
-		if err := rotatePods(cs, events, pods.Items, labelSelector); err != nil {
+		if err := rotatePods(ctx, cs, events, pods.Items, labelSelector); err != nil {
 			return maskAny(err)
 		}
 	} else {
@@ -126,7 +127,7 @@ func (ds *DaemonSet) Start(cs k8s.Client, events chan string) error {
 
 // rotatePods deletes all given pods 1 at a time.
 // For each it that is deleted, it waits until the DaemonSet controller has created and started a new pod.
-func rotatePods(cs k8s.Client, events chan string, pods []k8s.Pod, labelSelector map[string]string) error {
+func rotatePods(ctx context.Context, cs k8s.Client, events chan string, pods []k8s.Pod, labelSelector map[string]string) error {
 	for podIndex, pod := range pods {
 		events <- fmt.Sprintf("rotating %s on %s", pod.Name, pod.Status.HostIP)
 		if err := cs.DeletePod(pod.Namespace, pod.Name); err != nil {
@@ -160,10 +161,14 @@ func rotatePods(cs k8s.Client, events chan string, pods []k8s.Pod, labelSelector
 			if time.Since(start) > daemonSetPodStartTimeout {
 				return maskAny(fmt.Errorf("Pod start timeout on %s", pod.Status.HostIP))
 			}
-			time.Sleep(time.Second)
+			if err := sleep(ctx, time.Second); err != nil {
+				return maskAny(err)
+			}
 		}
 		// Wait a bit more
-		time.Sleep(time.Second * 5)
+		if err := sleep(ctx, time.Second*5); err != nil {
+			return maskAny(err)
+		}
 	}
 	return nil
 }
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -111,7 +112,7 @@ func (ds *Deployment) Content() string {
 }
 
 // Destroy deletes the deployment from the cluster.
-func (ds *Deployment) Destroy(cs k8s.Client, events chan string) error {
+func (ds *Deployment) Destroy(ctx context.Context, cs k8s.Client, events chan string) error {
 	// Fetch current deployment
 	current, err := cs.GetDeployment(ds.Namespace(), ds.Name())
 	if err != nil {
@@ -125,7 +126,9 @@ func (ds *Deployment) Destroy(cs k8s.Client, events chan string) error {
 		return maskAny(err)
 	}
 
-	time.Sleep(time.Second)
+	if err := sleep(ctx, time.Second); err != nil {
+		return maskAny(err)
+	}
 
 	// Delete created replicaSets.
 	events <- "deleting replicaSets"
@@ -133,7 +136,9 @@ func (ds *Deployment) Destroy(cs k8s.Client, events chan string) error {
 		return maskAny(err)
 	}
 
-	time.Sleep(time.Second)
+	if err := sleep(ctx, time.Second); err != nil {
+		return maskAny(err)
+	}
 
 	// Delete created pods.
 	events <- "deleting pods"
@@ -144,7 +149,7 @@ func (ds *Deployment) Destroy(cs k8s.Client, events chan string) error {
 }
 
 // Start creates/updates the deployment
-func (ds *Deployment) Start(cs k8s.Client, events chan string) error {
+func (ds *Deployment) Start(ctx context.Context, cs k8s.Client, events chan string) error {
 	var lastGeneration int64
 	current, err := cs.GetDeployment(ds.Namespace(), ds.Name())
 	if err == nil {
@@ -164,13 +169,13 @@ func (ds *Deployment) Start(cs k8s.Client, events chan string) error {
 			return maskAny(err)
 		}
 	}
-	if err := ds.waitUntilStarted(cs, events, lastGeneration, deploymentStartTimeout); err != nil {
+	if err := ds.waitUntilStarted(ctx, cs, events, lastGeneration, deploymentStartTimeout); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
-func (ds *Deployment) waitUntilStarted(cs k8s.Client, events chan string, lastGeneration int64, timeout time.Duration) error {
+func (ds *Deployment) waitUntilStarted(ctx context.Context, cs k8s.Client, events chan string, lastGeneration int64, timeout time.Duration) error {
 	state := 0
 	start := time.Now()
 	events <- "waiting for deployment controller"
@@ -205,6 +210,8 @@ func (ds *Deployment) waitUntilStarted(cs k8s.Client, events chan string, lastGe
 		if time.Since(start) > timeout {
 			return maskAny(fmt.Errorf("Timeout expired"))
 		}
-		time.Sleep(time.Second * 2)
+		if err := sleep(ctx, time.Second*2); err != nil {
+			return maskAny(err)
+		}
 	}
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"golang.org/x/net/context"
 )
 
 // Ingress is a wrapper for a kubernetes v1beta1.Ingress that implements
@@ -81,12 +82,12 @@ func (ds *Ingress) Content() string {
 }
 
 // Destroy deletes the ingress from the cluster.
-func (ds *Ingress) Destroy(cs k8s.Client, events chan string) error {
+func (ds *Ingress) Destroy(ctx context.Context, cs k8s.Client, events chan string) error {
 	return maskAny(cs.DeleteIngress(ds.Namespace(), ds.Name()))
 }
 
 // Start creates/updates the ingress
-func (ds *Ingress) Start(cs k8s.Client, events chan string) error {
+func (ds *Ingress) Start(ctx context.Context, cs k8s.Client, events chan string) error {
 	current, err := cs.GetIngress(ds.Namespace(), ds.Name())
 	if err == nil {
 		// Update
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -111,7 +112,7 @@ func (ds *Job) Content() string {
 }
 
 // Destroy deletes the job from the cluster.
-func (ds *Job) Destroy(cs k8s.Client, events chan string) error {
+func (ds *Job) Destroy(ctx context.Context, cs k8s.Client, events chan string) error {
 	// Fetch current deployment
 	current, err := cs.GetJob(ds.Namespace(), ds.Name())
 	if err != nil {
@@ -134,7 +135,7 @@ func (ds *Job) Destroy(cs k8s.Client, events chan string) error {
 }
 
 // Start creates/updates the job
-func (ds *Job) Start(cs k8s.Client, events chan string) error {
+func (ds *Job) Start(ctx context.Context, cs k8s.Client, events chan string) error {
 	current, err := cs.GetJob(ds.Namespace(), ds.Name())
 	if err == nil {
 		// Update
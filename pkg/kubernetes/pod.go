@@ -51,7 +51,8 @@ func isSamePodSpec(self, other *k8s.PodSpec) ([]string, bool) {
 	diffs, eq := diff(self, other, func(path string) bool {
 		switch path {
 		case ".TerminationGracePeriodSeconds":
-			return true
+			// Only a defaulted grace period can be modified by the cluster.
+			return self.TerminationGracePeriodSeconds == nil
 		}
 		return false
 	})
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"golang.org/x/net/context"
 )
 
 // Secret is a wrapper for a kubernetes v1.Secret that implements
@@ -79,12 +80,12 @@ func (ds *Secret) Content() string {
 }
 
 // Destroy deletes the service from the cluster.
-func (ds *Secret) Destroy(cs k8s.Client, events chan string) error {
+func (ds *Secret) Destroy(ctx context.Context, cs k8s.Client, events chan string) error {
 	return maskAny(cs.DeleteSecret(ds.Namespace(), ds.Name()))
 }
 
 // Start creates/updates the secret
-func (ds *Secret) Start(cs k8s.Client, events chan string) error {
+func (ds *Secret) Start(ctx context.Context, cs k8s.Client, events chan string) error {
 	current, err := cs.GetSecret(ds.Namespace(), ds.Name())
 	if err == nil {
 		// Secrets are never updated, unless their labels are different.
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"golang.org/x/net/context"
 )
 
 // Service is a wrapper for a kubernetes v1.Service that implements
@@ -92,12 +93,12 @@ func (ds *Service) Content() string {
 }
 
 // Destroy deletes the service from the cluster.
-func (ds *Service) Destroy(cs k8s.Client, events chan string) error {
+func (ds *Service) Destroy(ctx context.Context, cs k8s.Client, events chan string) error {
 	return maskAny(cs.DeleteService(ds.Namespace(), ds.Name()))
 }
 
 // Start creates/updates the service
-func (ds *Service) Start(cs k8s.Client, events chan string) error {
+func (ds *Service) Start(ctx context.Context, cs k8s.Client, events chan string) error {
 	current, err := cs.GetService(ds.Namespace(), ds.Name())
 	if err == nil {
 		// Update
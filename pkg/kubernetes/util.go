@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	k8s "github.com/YakLabs/k8s-client"
 	"github.com/YakLabs/k8s-client/intstr"
+	"golang.org/x/net/context"
 	"gopkg.in/d4l3k/messagediff.v1"
 )
 
@@ -19,6 +21,16 @@ func FromString(s string) intstr.IntOrString {
 	return intstr.FromString(s)
 }
 
+// sleep waits for the given duration, or until the given context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return maskAny(ctx.Err())
+	case <-time.After(d):
+		return nil
+	}
+}
+
 func mustRender(resource interface{}) string {
 	raw, err := json.Marshal(resource)
 	if err != nil {
@@ -0,0 +1,119 @@
+// Copyright (c) 2017 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	"golang.org/x/net/context"
+)
+
+const (
+	queryTimeout = 30 * time.Second
+)
+
+// queryResponse is the response of the instant query API.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// sample is a single value of a vector result.
+type sample struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+}
+
+// Query evaluates the given expression with the instant query API of the Prometheus compatible
+// server at the given base URL. It returns the values of all resulting samples.
+// Only scalar and vector results are supported.
+// The query is canceled when the given context is canceled.
+func Query(ctx context.Context, baseURL, expr string) ([]float64, error) {
+	u := strings.TrimSuffix(baseURL, "/") + "/api/v1/query?" + url.Values{"query": []string{expr}}.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	hc := http.Client{Timeout: queryTimeout}
+	resp, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer resp.Body.Close()
+
+	var qr queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&qr); err != nil {
+		return nil, maskAny(errgo.WithCausef(nil, QueryError, "cannot decode response (status %d): %v", resp.StatusCode, err))
+	}
+	if qr.Status != "success" {
+		return nil, maskAny(errgo.WithCausef(nil, QueryError, "%s: %s", qr.ErrorType, qr.Error))
+	}
+
+	switch qr.Data.ResultType {
+	case "scalar":
+		var value []interface{}
+		if err := json.Unmarshal(qr.Data.Result, &value); err != nil {
+			return nil, maskAny(err)
+		}
+		v, err := parseValue(value)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		return []float64{v}, nil
+	case "vector":
+		var samples []sample
+		if err := json.Unmarshal(qr.Data.Result, &samples); err != nil {
+			return nil, maskAny(err)
+		}
+		var result []float64
+		for _, s := range samples {
+			v, err := parseValue(s.Value)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	default:
+		return nil, maskAny(errgo.WithCausef(nil, QueryError, "unsupported result type '%s'", qr.Data.ResultType))
+	}
+}
+
+// parseValue parses a [timestamp, "value"] pair.
+func parseValue(value []interface{}) (float64, error) {
+	if len(value) != 2 {
+		return 0, maskAny(errgo.WithCausef(nil, QueryError, "expected [timestamp, value], got %v", value))
+	}
+	s, ok := value[1].(string)
+	if !ok {
+		return 0, maskAny(errgo.WithCausef(nil, QueryError, "expected string value, got %v", value[1]))
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, maskAny(errgo.WithCausef(nil, QueryError, "invalid value '%s'", s))
+	}
+	return v, nil
+}
@@ -60,11 +60,12 @@ func CreateFrontEndRecords(t *jobs.Task, scalingGroup uint, publicOnly bool, nam
 	if err != nil {
 		return nil, maskAny(err)
 	}
+	checkPath, checkMethod := t.LoadBalancerCheck()
 	httpKey := fmt.Sprintf("/pulcy/frontend/%s-%d", serviceName, scalingGroup)
 	httpRecord := api.FrontendRecord{
 		Service:         targetServiceName,
-		HttpCheckPath:   t.HttpCheckPath,
-		HttpCheckMethod: t.HttpCheckMethod,
+		HttpCheckPath:   checkPath,
+		HttpCheckMethod: checkMethod,
 		Sticky:          t.Sticky,
 		Backup:          t.Backup,
 		Mode:            "", // Defaults to http
@@ -72,8 +73,8 @@ func CreateFrontEndRecords(t *jobs.Task, scalingGroup uint, publicOnly bool, nam
 	tcpKey := fmt.Sprintf("/pulcy/frontend/%s-%d-tcp", serviceName, scalingGroup)
 	tcpRecord := api.FrontendRecord{
 		Service:         targetServiceName,
-		HttpCheckPath:   t.HttpCheckPath,
-		HttpCheckMethod: t.HttpCheckMethod,
+		HttpCheckPath:   checkPath,
+		HttpCheckMethod: checkMethod,
 		Sticky:          t.Sticky,
 		Backup:          t.Backup,
 		Mode:            "tcp",
@@ -81,14 +82,14 @@ func CreateFrontEndRecords(t *jobs.Task, scalingGroup uint, publicOnly bool, nam
 	instanceHttpKey := fmt.Sprintf("/pulcy/frontend/%s-%d-inst", serviceName, scalingGroup)
 	instanceHttpRecord := api.FrontendRecord{
 		Service:       fmt.Sprintf("%s-%d", targetServiceName, scalingGroup),
-		HttpCheckPath: t.HttpCheckPath,
+		HttpCheckPath: checkPath,
 		Sticky:        t.Sticky,
 		Backup:        t.Backup,
 	}
 	instanceTcpKey := fmt.Sprintf("/pulcy/frontend/%s-%d-inst-tcp", serviceName, scalingGroup)
 	instanceTcpRecord := api.FrontendRecord{
 		Service:       fmt.Sprintf("%s-%d", targetServiceName, scalingGroup),
-		HttpCheckPath: t.HttpCheckPath,
+		HttpCheckPath: checkPath,
 		Sticky:        t.Sticky,
 		Backup:        t.Backup,
 		Mode:          "tcp",
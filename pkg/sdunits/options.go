@@ -29,6 +29,7 @@ type execOptions struct {
 	StartLimitInterval      string
 	StartLimitBurst         uint8
 	TimeoutStartSec         uint8
+	TimeoutStopSec          uint16 // 0 means systemd default
 	ContainerTimeoutStopSec uint8
 	EnvironmentFiles        []string
 	Environment             map[string]string
@@ -64,6 +64,9 @@ func (u *Unit) Render(ctx RenderContext) string {
 			lines = append(lines, "StartLimitBurst="+strconv.Itoa(int(u.ExecOptions.StartLimitBurst)))
 		}
 		lines = append(lines, "TimeoutStartSec="+strconv.Itoa(int(u.ExecOptions.TimeoutStartSec)))
+		if u.ExecOptions.TimeoutStopSec > 0 {
+			lines = append(lines, "TimeoutStopSec="+strconv.Itoa(int(u.ExecOptions.TimeoutStopSec)))
+		}
 		for _, x := range u.ExecOptions.EnvironmentFiles {
 			lines = append(lines, "EnvironmentFile="+x)
 		}
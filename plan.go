@@ -0,0 +1,142 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	planCmd = &cobra.Command{
+		Use:   "plan",
+		Short: "Show the actions needed to deploy a job on a stack.",
+		Long:  "Show the actions needed to deploy a job on a stack. Use --out to save the plan, so it can be executed with `apply`.",
+		Run:   planRun,
+	}
+	planFlags struct {
+		fg.Flags
+		out string
+	}
+)
+
+func init() {
+	initDeploymentFlags(planCmd.Flags(), &planFlags.Flags)
+	planCmd.Flags().StringVar(&planFlags.out, "out", "", "Path of the file to save the plan in")
+}
+
+func planRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &planFlags.Flags, args)
+
+	cluster, err := loadCluster(&planFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&planFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&planFlags.Flags),
+		deployment.ScalingGroupSelection(planFlags.ScalingGroup),
+		planFlags.Force,
+		planFlags.AutoContinue,
+		globalFlags.verbose,
+		deployment.DeploymentDelays{},
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{},
+		renderCtx)
+	assert(err)
+
+	plan, err := d.Plan(rootCtx)
+	if err != nil {
+		Exitf("Cannot create plan: %v\n", err)
+	}
+	plan.JobPath = planFlags.JobPath
+	plan.ClusterPath = planFlags.ClusterPath
+
+	printPlan(plan)
+	if planFlags.out != "" {
+		if err := plan.Save(planFlags.out); err != nil {
+			Exitf("Cannot save plan: %v\n", err)
+		}
+		fmt.Printf("Saved plan to %s. Run `j2 apply %s` to execute it.\n", planFlags.out, planFlags.out)
+	}
+}
+
+// printPlan prints all actions of the given plan, followed by a summary.
+func printPlan(plan deployment.Plan) {
+	counts := make(map[deployment.PlanAction]int)
+	printUnit := func(up deployment.UnitPlan) {
+		counts[up.Action]++
+		if up.Action == deployment.PlanActionNone {
+			return
+		}
+		extra := ""
+		if len(up.Diffs) > 0 {
+			extra = " (" + strings.Join(up.Diffs, ",") + ")"
+		} else if up.Message != "" {
+			extra = " (" + up.Message + ")"
+		}
+		fmt.Printf("  %-8s %s%s\n", up.Action, up.Name, extra)
+	}
+	for _, sgp := range plan.ScalingGroups {
+		if !sgp.HasChanges() {
+			continue
+		}
+		fmt.Printf("Scaling group %d:\n", sgp.ScalingGroup)
+		for _, up := range sgp.Units {
+			printUnit(up)
+		}
+	}
+	if plan.HasFrontendChanges() {
+		var colors []string
+		for name, color := range plan.Colors {
+			colors = append(colors, fmt.Sprintf("%s (%s)", name, color))
+		}
+		sort.Strings(colors)
+		fmt.Printf("Switch frontends of %s:\n", strings.Join(colors, ", "))
+		for _, up := range plan.Frontends {
+			printUnit(up)
+		}
+	}
+	if len(plan.Cleanup) > 0 {
+		fmt.Println("Cleanup:")
+		for _, up := range plan.Cleanup {
+			printUnit(up)
+		}
+	}
+
+	if !plan.HasChanges() {
+		fmt.Println("No modifications needed.")
+		return
+	}
+	fmt.Printf("Plan: %d to create, %d to update, %d to restart, %d to remove.\n",
+		counts[deployment.PlanActionCreate],
+		counts[deployment.PlanActionUpdate],
+		counts[deployment.PlanActionRestart],
+		counts[deployment.PlanActionRemove])
+}
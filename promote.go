@@ -0,0 +1,87 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	promoteCmd = &cobra.Command{
+		Use:   "promote",
+		Short: "Promote the canary of a job to all scaling groups.",
+		Long:  "Continue a deployment that stopped after its canary, updating all remaining scaling groups.\nWith --abort, the scaling groups of the canary are restored to their previous version instead.",
+		Run:   promoteRun,
+	}
+	promoteFlags struct {
+		fg.Flags
+		abort bool
+	}
+)
+
+func init() {
+	initDeploymentFlags(promoteCmd.Flags(), &promoteFlags.Flags)
+	initOutputFlags(promoteCmd.Flags(), &promoteFlags.Flags)
+	promoteCmd.Flags().BoolVar(&promoteFlags.abort, "abort", false, "Abort the canary, restoring its scaling groups to their previous version")
+}
+
+func promoteRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &promoteFlags.Flags, args)
+	runValidators(&promoteFlags.Flags)
+
+	cluster, err := loadCluster(&promoteFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&promoteFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	delays := deployment.DeploymentDelays{
+		StopDelay:    promoteFlags.StopDelay,
+		DestroyDelay: promoteFlags.DestroyDelay,
+		SliceDelay:   promoteFlags.SliceDelay,
+	}
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&promoteFlags.Flags),
+		deployment.ScalingGroupSelection(promoteFlags.ScalingGroup),
+		promoteFlags.Force,
+		promoteFlags.AutoContinue,
+		globalFlags.verbose,
+		delays,
+		healthCheckConfig(&promoteFlags.Flags),
+		rolloutOptions(cmd.Flags(), &promoteFlags.Flags, *cluster),
+		renderCtx)
+	assert(err)
+	d.Events = eventSink(&promoteFlags.Flags)
+
+	if promoteFlags.abort {
+		if err := d.AbortCanary(rootCtx); err != nil {
+			Exitf("Cannot abort canary: %v\n", err)
+		}
+		return
+	}
+	if err := d.Promote(rootCtx); err != nil {
+		Exitf("Cannot promote canary: %v\n", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	renderCmd = &cobra.Command{
+		Use:   "render",
+		Short: "Render all units of a job into a directory.",
+		Long:  "Render all units of a job into a directory, without accessing the cluster.",
+		Run:   renderRun,
+	}
+	renderFlags struct {
+		fg.Flags
+		outputDir   string
+		prune       bool
+		stubSecrets bool
+	}
+)
+
+func init() {
+	initDeploymentFlags(renderCmd.Flags(), &renderFlags.Flags)
+	// There is no -o shorthand, since -o is already used by --option
+	renderCmd.Flags().StringVar(&renderFlags.outputDir, "output-dir", "", "Directory to write the rendered units into")
+	renderCmd.Flags().BoolVar(&renderFlags.prune, "prune", false, "Remove files from the output directory that are no longer rendered")
+	renderCmd.Flags().BoolVar(&renderFlags.stubSecrets, "stub-secrets", false, "Replace secrets used in the job by a placeholder instead of failing")
+}
+
+func renderRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &renderFlags.Flags, args)
+	if renderFlags.outputDir == "" {
+		Exitf("--output-dir missing\n")
+	}
+
+	cluster, err := loadCluster(&renderFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJobOffline(&renderFlags.Flags, *cluster, orchestrator, renderFlags.stubSecrets)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&renderFlags.Flags),
+		deployment.ScalingGroupSelection(renderFlags.ScalingGroup),
+		true,
+		true,
+		globalFlags.verbose,
+		deployment.DeploymentDelays{},
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{},
+		renderCtx)
+	assert(err)
+
+	fileNames, staleFileNames, err := d.Render(renderFlags.outputDir, renderFlags.prune)
+	if err != nil {
+		Exitf("Cannot render units: %v\n", err)
+	}
+	for _, name := range fileNames {
+		fmt.Println(name)
+	}
+	// Report stale files on stderr, so the output remains a list of rendered files
+	for _, name := range staleFileNames {
+		if renderFlags.prune {
+			fmt.Fprintf(os.Stderr, "Removed stale file %s\n", name)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: stale file %s is no longer rendered (use --prune to remove it)\n", name)
+		}
+	}
+}
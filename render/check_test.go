@@ -0,0 +1,243 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/j2/render/fleet"
+	"github.com/pulcy/j2/render/kubernetes"
+)
+
+var checkTests = []struct {
+	Name       string
+	Check      string
+	HealthArgs []string // Docker --health-* arguments of the fleet unit
+	WaitCount  int      // Number of seconds ExecStartPost waits for the container to become healthy
+	Record     string   // Robin frontend record
+	Liveness   string   // Kubernetes liveness probe
+	Readiness  string   // Kubernetes readiness probe
+}{
+	{
+		Name: "http",
+		Check: `check {
+			path = "/health"
+			interval = "5s"
+		}`,
+		HealthArgs: []string{
+			`--health-cmd=\"wget -q -O /dev/null http://localhost:80/health || exit 1\"`,
+			`--health-interval=5s`,
+			`--health-timeout=5s`,
+			`--health-retries=3`,
+		},
+		WaitCount: 40,
+		Record:    `{"selectors":[{"domain":"web.example.com"}],"service":"%s","http-check-path":"/health"}`,
+		Liveness:  `{"httpGet":{"path":"/health","port":80},"timeoutSeconds":5,"periodSeconds":5,"successThreshold":1,"failureThreshold":3}`,
+		Readiness: `{"httpGet":{"path":"/health","port":80},"timeoutSeconds":5,"periodSeconds":5,"successThreshold":1,"failureThreshold":3}`,
+	},
+	{
+		Name: "http-headers",
+		Check: `check {
+			path = "health"
+			headers {
+				X-Probe = "j2"
+			}
+			initial-delay = "10s"
+		}`,
+		HealthArgs: []string{
+			`--health-cmd=\"wget -q -O /dev/null --header 'X-Probe: j2' http://localhost:80/health || exit 1\"`,
+			`--health-interval=10s`,
+			`--health-timeout=5s`,
+			`--health-retries=3`,
+			`--health-start-period=10s`,
+		},
+		WaitCount: 70,
+		Record:    `{"selectors":[{"domain":"web.example.com"}],"service":"%s","http-check-path":"health"}`,
+		Liveness:  `{"httpGet":{"path":"health","port":80,"httpHeaders":[{"name":"X-Probe","value":"j2"}]},"initialDelaySeconds":10,"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+		Readiness: `{"httpGet":{"path":"health","port":80,"httpHeaders":[{"name":"X-Probe","value":"j2"}]},"initialDelaySeconds":10,"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+	},
+	{
+		Name: "http-post",
+		Check: `check {
+			path = "/health"
+			method = "POST"
+			status = 204
+		}`,
+		HealthArgs: []string{
+			`--health-cmd=\"curl -s -o /dev/null -D - -X POST http://localhost:80/health | head -n 1 | grep -q '^HTTP/[0-9.]* 204' || exit 1\"`,
+			`--health-interval=10s`,
+			`--health-timeout=5s`,
+			`--health-retries=3`,
+		},
+		WaitCount: 60,
+		Record:    `{"selectors":[{"domain":"web.example.com"}],"service":"%s","http-check-path":"/health","http-check-method":"POST"}`,
+		Liveness:  `{"exec":{"command":["/bin/sh","-c","curl -s -o /dev/null -D - -X POST http://localhost:80/health | head -n 1 | grep -q '^HTTP/[0-9.]* 204'"]},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+		Readiness: `{"exec":{"command":["/bin/sh","-c","curl -s -o /dev/null -D - -X POST http://localhost:80/health | head -n 1 | grep -q '^HTTP/[0-9.]* 204'"]},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+	},
+	{
+		Name: "http-head",
+		Check: `check {
+			path = "/health"
+			method = "HEAD"
+		}`,
+		HealthArgs: []string{
+			`--health-cmd=\"curl -s -o /dev/null -D - -I http://localhost:80/health | head -n 1 | grep -q '^HTTP/[0-9.]* [23]' || exit 1\"`,
+			`--health-interval=10s`,
+			`--health-timeout=5s`,
+			`--health-retries=3`,
+		},
+		WaitCount: 60,
+		Record:    `{"selectors":[{"domain":"web.example.com"}],"service":"%s","http-check-path":"/health","http-check-method":"HEAD"}`,
+		Liveness:  `{"exec":{"command":["/bin/sh","-c","curl -s -o /dev/null -D - -I http://localhost:80/health | head -n 1 | grep -q '^HTTP/[0-9.]* [23]'"]},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+		Readiness: `{"exec":{"command":["/bin/sh","-c","curl -s -o /dev/null -D - -I http://localhost:80/health | head -n 1 | grep -q '^HTTP/[0-9.]* [23]'"]},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+	},
+	{
+		Name: "tcp",
+		Check: `check {
+			port = 8080
+			unhealthy-threshold = 5
+		}`,
+		HealthArgs: []string{
+			`--health-cmd=\"nc -z localhost 8080 || exit 1\"`,
+			`--health-interval=10s`,
+			`--health-timeout=5s`,
+			`--health-retries=5`,
+		},
+		WaitCount: 90,
+		Record:    `{"selectors":[{"domain":"web.example.com"}],"service":"%s"}`,
+		Liveness:  `{"tcpSocket":{"port":8080},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":5}`,
+		Readiness: `{"tcpSocket":{"port":8080},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":5}`,
+	},
+	{
+		Name: "exec",
+		Check: `check {
+			command = ["/bin/check", "--all"]
+			healthy-threshold = 2
+		}`,
+		HealthArgs: []string{
+			`--health-cmd=\"/bin/check --all\"`,
+			`--health-interval=10s`,
+			`--health-timeout=5s`,
+			`--health-retries=3`,
+		},
+		WaitCount: 60,
+		Record:    `{"selectors":[{"domain":"web.example.com"}],"service":"%s"}`,
+		Liveness:  `{"exec":{"command":["/bin/check","--all"]},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3}`,
+		Readiness: `{"exec":{"command":["/bin/check","--all"]},"timeoutSeconds":5,"periodSeconds":10,"successThreshold":2,"failureThreshold":3}`,
+	},
+}
+
+// checkTestJob returns a job with a single task that has a frontend and the given check.
+func checkTestJob(check string) string {
+	return `job "checks" {
+	task "web" {
+		image = "nginx:1.11"
+		ports = ["80", "8080"]
+		frontend {
+			domain = "web.example.com"
+		}
+		` + check + `
+	}
+}
+`
+}
+
+func TestFleetCheck(t *testing.T) {
+	for _, test := range checkTests {
+		units := renderUnits(t, fleet.NewRenderProvider(), checkTestJob(test.Check))
+		content, ok := units["checks-web-web-mn@1.service"]
+		if !ok {
+			t.Fatalf("%s: main unit not found", test.Name)
+		}
+
+		var args []string
+		for _, line := range unitLines(content, `Environment="A`) {
+			if i := strings.Index(line, "=--health-"); i >= 0 {
+				args = append(args, strings.TrimSuffix(line[i+1:], `"`))
+			}
+		}
+		if !reflect.DeepEqual(args, test.HealthArgs) {
+			t.Errorf("%s: expected health arguments %q, got %q", test.Name, test.HealthArgs, args)
+		}
+
+		// The readiness wait must come before the frontend is registered
+		post := unitLines(content, "ExecStartPost=")
+		wait := "ExecStartPost=/bin/sh -c 'for i in $$(seq 1 " + strconv.Itoa(test.WaitCount) + "); do s=$$(/usr/bin/docker inspect -f {{.State.Health.Status}} checks-web-web-1); " +
+			`[ "$$s" = healthy ] && exit 0; [ "$$s" = unhealthy ] && exit 1; sleep 1; done; exit 1'`
+		if len(post) != 2 || post[0] != wait || !strings.Contains(post[1], "/usr/bin/etcdctl set /pulcy/frontend/checks-web-web-1") {
+			t.Errorf("%s: expected readiness wait followed by frontend registration, got %q", test.Name, post)
+		}
+
+		reg := unitLines(content, "FrontEndRegistration=")
+		prefix := `FrontEndRegistration="/pulcy/frontend/checks-web-web-1=`
+		if len(reg) != 1 || !strings.HasPrefix(reg[0], prefix) {
+			t.Fatalf("%s: expected frontend registration, got %q", test.Name, reg)
+		}
+		record := strings.Replace(strings.TrimSuffix(strings.TrimPrefix(reg[0], prefix), `"`), `\"`, `"`, -1)
+		expected := jsonValue(t, fmt.Sprintf(test.Record, "checks-web-web"))
+		if actual := jsonValue(t, record); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected frontend record %s, got %s", test.Name, fmt.Sprintf(test.Record, "checks-web-web"), record)
+		}
+	}
+}
+
+func TestKubernetesCheck(t *testing.T) {
+	for _, test := range checkTests {
+		units := renderUnits(t, kubernetes.NewRenderProvider(), checkTestJob(test.Check))
+		depl, ok := units["web-web-depl"]
+		if !ok {
+			t.Fatalf("%s: deployment not found", test.Name)
+		}
+		spec := deploymentPodSpec(t, depl)
+		if len(spec.Containers) != 1 {
+			t.Fatalf("%s: expected 1 container, got %d", test.Name, len(spec.Containers))
+		}
+		for _, probe := range []struct {
+			Key      string
+			Expected string
+		}{
+			{"livenessProbe", test.Liveness},
+			{"readinessProbe", test.Readiness},
+		} {
+			if actual := spec.Containers[0][probe.Key]; !reflect.DeepEqual(actual, jsonValue(t, probe.Expected)) {
+				raw, _ := json.Marshal(actual)
+				t.Errorf("%s: expected %s %s, got %s", test.Name, probe.Key, probe.Expected, raw)
+			}
+		}
+
+		igr, ok := units["web-web-igr"]
+		if !ok {
+			t.Fatalf("%s: ingress not found", test.Name)
+		}
+		var ingress struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal([]byte(igr), &ingress); err != nil {
+			t.Fatalf("%s: cannot decode ingress: %v", test.Name, err)
+		}
+		records := ingress.Metadata.Annotations["pulcy.com.robin.frontend.records"]
+		expected := "[" + fmt.Sprintf(test.Record, "web-web-srv") + "]"
+		if actual := jsonValue(t, records); !reflect.DeepEqual(actual, jsonValue(t, expected)) {
+			t.Errorf("%s: expected frontend records %s, got %s", test.Name, expected, records)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/j2/render/fleet"
+	"github.com/pulcy/j2/render/kubernetes"
+)
+
+var drainTests = []struct {
+	Name           string
+	Drain          string
+	ExecStop       []string // Stop commands of the fleet unit, in order
+	TimeoutStopSec string   // Empty if not set
+	StopSignal     string   // Docker --stop-signal argument, empty if not set
+	PreStop        string   // Pre-stop script of the kubernetes container, empty if none
+	Grace          int64    // terminationGracePeriodSeconds of the kubernetes pod, 0 if not set
+}{
+	{
+		Name:  "full",
+		Drain: `drain { period = "20s" stop-signal = "SIGQUIT" stop-timeout = "15s" }`,
+		ExecStop: []string{
+			"-/usr/bin/etcdctl rm /pulcy/frontend/drains-web-web-1",
+			"/bin/sleep 20",
+			"-/usr/bin/docker stop -t 15 drains-web-web-1",
+		},
+		TimeoutStopSec: "65",
+		StopSignal:     "--stop-signal=SIGQUIT",
+		PreStop:        "sleep 20; kill -s QUIT 1; i=0; while kill -0 1 2>/dev/null && [ $i -lt 15 ]; do sleep 1; i=$((i+1)); done",
+		Grace:          35,
+	},
+	{
+		Name:  "period",
+		Drain: `drain { period = "15s" }`,
+		ExecStop: []string{
+			"-/usr/bin/etcdctl rm /pulcy/frontend/drains-web-web-1",
+			"/bin/sleep 15",
+			"-/usr/bin/docker stop -t 10 drains-web-web-1",
+		},
+		TimeoutStopSec: "55",
+		PreStop:        "sleep 15",
+		Grace:          25,
+	},
+	{
+		Name:  "signal",
+		Drain: `drain { stop-signal = "SIGUSR1" stop-timeout = "1m" }`,
+		ExecStop: []string{
+			"-/usr/bin/etcdctl rm /pulcy/frontend/drains-web-web-1",
+			"-/usr/bin/docker stop -t 60 drains-web-web-1",
+		},
+		TimeoutStopSec: "90",
+		StopSignal:     "--stop-signal=SIGUSR1",
+		PreStop:        "kill -s USR1 1; i=0; while kill -0 1 2>/dev/null && [ $i -lt 60 ]; do sleep 1; i=$((i+1)); done",
+		Grace:          60,
+	},
+	{
+		Name:  "signal-number",
+		Drain: `drain { stop-signal = "3" stop-timeout = "2s" }`,
+		ExecStop: []string{
+			"-/usr/bin/etcdctl rm /pulcy/frontend/drains-web-web-1",
+			"-/usr/bin/docker stop -t 2 drains-web-web-1",
+		},
+		TimeoutStopSec: "32",
+		StopSignal:     "--stop-signal=3",
+		PreStop:        "kill -3 1; i=0; while kill -0 1 2>/dev/null && [ $i -lt 2 ]; do sleep 1; i=$((i+1)); done",
+		Grace:          2,
+	},
+	{
+		Name: "none",
+		ExecStop: []string{
+			"-/usr/bin/etcdctl rm /pulcy/frontend/drains-web-web-1",
+			"-/usr/bin/docker stop -t 10 drains-web-web-1",
+		},
+	},
+}
+
+// drainTestJob returns a job with a single task that has a frontend and the given drain.
+func drainTestJob(drain string) string {
+	return `job "drains" {
+	task "web" {
+		image = "nginx:1.11"
+		ports = ["80"]
+		frontend {
+			domain = "web.example.com"
+		}
+		` + drain + `
+	}
+}
+`
+}
+
+func TestFleetDrain(t *testing.T) {
+	for _, test := range drainTests {
+		units := renderUnits(t, fleet.NewRenderProvider(), drainTestJob(test.Drain))
+		content, ok := units["drains-web-web-mn@1.service"]
+		if !ok {
+			t.Fatalf("%s: main unit not found", test.Name)
+		}
+
+		var stop []string
+		for _, line := range unitLines(content, "ExecStop=") {
+			stop = append(stop, strings.TrimPrefix(line, "ExecStop="))
+		}
+		if !reflect.DeepEqual(stop, test.ExecStop) {
+			t.Errorf("%s: expected ExecStop %q, got %q", test.Name, test.ExecStop, stop)
+		}
+
+		var timeout string
+		if lines := unitLines(content, "TimeoutStopSec="); len(lines) > 0 {
+			timeout = strings.TrimPrefix(lines[0], "TimeoutStopSec=")
+		}
+		if timeout != test.TimeoutStopSec {
+			t.Errorf("%s: expected TimeoutStopSec '%s', got '%s'", test.Name, test.TimeoutStopSec, timeout)
+		}
+
+		var signal string
+		for _, line := range unitLines(content, `Environment="A`) {
+			if i := strings.Index(line, "=--stop-signal="); i >= 0 {
+				signal = strings.TrimSuffix(line[i+1:], `"`)
+			}
+		}
+		if signal != test.StopSignal {
+			t.Errorf("%s: expected stop signal argument '%s', got '%s'", test.Name, test.StopSignal, signal)
+		}
+	}
+}
+
+func TestKubernetesDrain(t *testing.T) {
+	for _, test := range drainTests {
+		units := renderUnits(t, kubernetes.NewRenderProvider(), drainTestJob(test.Drain))
+		depl, ok := units["web-web-depl"]
+		if !ok {
+			t.Fatalf("%s: deployment not found", test.Name)
+		}
+		spec := deploymentPodSpec(t, depl)
+		if len(spec.Containers) != 1 {
+			t.Fatalf("%s: expected 1 container, got %d", test.Name, len(spec.Containers))
+		}
+
+		var expected interface{}
+		if test.PreStop != "" {
+			expected = map[string]interface{}{
+				"preStop": map[string]interface{}{
+					"exec": map[string]interface{}{
+						"command": []interface{}{"/bin/sh", "-c", test.PreStop},
+					},
+				},
+			}
+		}
+		if lifecycle := spec.Containers[0]["lifecycle"]; !reflect.DeepEqual(lifecycle, expected) {
+			t.Errorf("%s: expected lifecycle %v, got %v", test.Name, expected, lifecycle)
+		}
+
+		var grace int64
+		if spec.TerminationGracePeriodSeconds != nil {
+			grace = *spec.TerminationGracePeriodSeconds
+		}
+		if grace != test.Grace {
+			t.Errorf("%s: expected terminationGracePeriodSeconds %d, got %d", test.Name, test.Grace, grace)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/sdunits"
+)
+
+const (
+	// drainStopMargin is added to the stop timeout of a unit, to allow for the
+	// deregistration & removal of its container.
+	drainStopMargin = 30 * time.Second
+)
+
+// addDrain adds a wait for the drain period of the given task to the stop commands
+// of the given unit, and extends the stop timeout of the unit to cover the drain period
+// and the stop timeout of the task.
+func addDrain(t *jobs.Task, main *sdunits.Unit) {
+	if t.Drain == nil {
+		return
+	}
+	if period := t.Drain.PeriodDuration(); period > 0 {
+		main.ExecOptions.ExecStop = append(
+			[]string{fmt.Sprintf("/bin/sleep %d", int(period/time.Second))},
+			main.ExecOptions.ExecStop...,
+		)
+	}
+	total := t.Drain.PeriodDuration() + t.Drain.StopTimeoutDuration() + drainStopMargin
+	main.ExecOptions.TimeoutStopSec = uint16(total / time.Second)
+}
@@ -28,6 +28,10 @@ import (
 
 // addFrontEndRegistration adds registration code for frontends to the given units
 func addFrontEndRegistration(t *jobs.Task, main *sdunits.Unit, ctx generatorContext) error {
+	if t.GroupColor() != "" {
+		// Frontends of blue/green groups are registered by a separate frontend unit.
+		return nil
+	}
 	publicOnly := false
 	records, err := robin.CreateFrontEndRecords(t, ctx.ScalingGroup, publicOnly, &fleetFrontendNameBuilder{})
 	if err != nil {
@@ -64,8 +68,9 @@ type fleetFrontendNameBuilder struct {
 
 // Create the serviceName of the given task.
 // This name is used in the Key of the returned records.
+// It is the same for both colors of a blue/green group.
 func (nb *fleetFrontendNameBuilder) CreateServiceName(t *jobs.Task) (string, error) {
-	return t.ServiceName(), nil
+	return t.FrontendServiceName(), nil
 }
 
 // Create the name used in the Service field of the returned records.
@@ -60,6 +60,19 @@ func (g *fleetRenderer) GenerateUnits(job jobs.Job, ctx render.RenderContext, co
 				InstanceCount: instanceCount,
 				Cluster:       config.Cluster,
 			}
+			if config.FrontendsOnly {
+				if !tg.BlueGreen {
+					continue
+				}
+				frontendUnits, err := createFrontendUnits(tg, genCtx)
+				if err != nil {
+					return nil, maskAny(err)
+				}
+				for _, unit := range frontendUnits {
+					units = append(units, newUnitData(unit.FullName, unit.Render(ctx)))
+				}
+				continue
+			}
 			unitChains, err := createTaskGroupUnits(tg, genCtx)
 			if err != nil {
 				return nil, maskAny(err)
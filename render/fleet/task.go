@@ -31,6 +31,7 @@ const (
 	unitKindVolume = "-vl"
 	unitKindProxy  = "-pr"
 	unitKindTimer  = "-ti"
+	unitKindFront  = "-fe"
 )
 
 var (
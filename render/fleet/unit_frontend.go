@@ -0,0 +1,83 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/robin"
+	"github.com/pulcy/j2/pkg/sdunits"
+)
+
+// createFrontendUnits creates the frontend units for all tasks of the given blue/green group.
+func createFrontendUnits(tg *jobs.TaskGroup, ctx generatorContext) ([]*sdunits.Unit, error) {
+	if ctx.ScalingGroup > tg.Count {
+		return nil, nil
+	}
+	var units []*sdunits.Unit
+	for _, t := range tg.Tasks {
+		unit, err := createFrontendUnit(t, ctx)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		if unit != nil {
+			units = append(units, unit)
+		}
+	}
+	return units, nil
+}
+
+// createFrontendUnit creates a oneshot unit that registers the frontends of a task
+// in a blue/green group.
+// The keys of the records are the same for both colors, their service is that of the
+// color of the group, so starting the unit of the other color switches all frontends
+// at once. When stopped, records are only removed when they still point to this color.
+func createFrontendUnit(t *jobs.Task, ctx generatorContext) (*sdunits.Unit, error) {
+	publicOnly := false
+	records, err := robin.CreateFrontEndRecords(t, ctx.ScalingGroup, publicOnly, &fleetFrontendNameBuilder{})
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	unit := createBaseUnit(t,
+		unitName(t, unitKindFront, ctx.ScalingGroup),
+		unitDescription(t, "Frontend", ctx.ScalingGroup),
+		"service", ctx)
+	unit.ExecOptions.Oneshot()
+	unit.ExecOptions.RemainAfterExit()
+	unit.ExecOptions.ExecStart = "/usr/bin/true"
+	for _, r := range records {
+		json, err := json.Marshal(&r.Record)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		encoded := base64.StdEncoding.EncodeToString(json)
+		unit.ProjectSetting(r.ProjectSetting, r.Key+"="+string(json))
+		unit.ExecOptions.ExecStartPost = append(unit.ExecOptions.ExecStartPost,
+			fmt.Sprintf("/bin/sh -c 'echo %s | base64 -d | /usr/bin/etcdctl set %s'", encoded, r.Key),
+		)
+		unit.ExecOptions.ExecStop = append(unit.ExecOptions.ExecStop,
+			fmt.Sprintf("-/bin/sh -c '/usr/bin/etcdctl rm --with-value \"$$(echo %s | base64 -d)\" %s'", encoded, r.Key),
+		)
+	}
+
+	return unit, nil
+}
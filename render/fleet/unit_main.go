@@ -37,7 +37,13 @@ func createMainUnit(t *jobs.Task, sidekickUnitNames []string, engine engine.Engi
 	switch t.Type {
 	case "oneshot":
 		unit.ExecOptions.IsOneshot = true
-		unit.ExecOptions.Restart = "on-failure"
+		if t.GroupHook() != "" {
+			// Hooks run once, their outcome is reported by the state of the unit.
+			unit.ExecOptions.Restart = "no"
+			unit.ExecOptions.RemainAfterExit()
+		} else {
+			unit.ExecOptions.Restart = "on-failure"
+		}
 	case "proxy":
 		unit.ExecOptions.Restart = "always"
 	default:
@@ -57,6 +63,10 @@ func createMainUnit(t *jobs.Task, sidekickUnitNames []string, engine engine.Engi
 		unit.ExecOptions.After(otherName)
 	}
 
+	// Add drain period. This must be done before the registrations are added,
+	// since those put their deregistration commands in front of ExecStop.
+	addDrain(t, unit)
+
 	// Add metrics registration commands
 	if err := addMetricsRegistration(t, unit, ctx); err != nil {
 		return nil, maskAny(err)
@@ -76,6 +76,9 @@ func setupUnitFromCmds(unit *sdunits.Unit, cmds engine.Cmds) {
 		}
 		unit.ExecOptions.ExecStart = formatCmd(cmds.Start[len(cmds.Start)-1])
 	}
+	for _, cmd := range cmds.StartPost {
+		unit.ExecOptions.ExecStartPost = append(unit.ExecOptions.ExecStartPost, formatCmd(cmd))
+	}
 	if len(cmds.Stop) > 0 {
 		unit.ExecOptions.ExecStop = append(unit.ExecOptions.ExecStop, formatCmd(cmds.Stop[0]))
 		for i := 1; i < len(cmds.Stop); i++ {
@@ -0,0 +1,113 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/j2/cluster"
+	_ "github.com/pulcy/j2/engine/docker"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/render"
+)
+
+// testContext is a render context with fixed project & image names.
+type testContext struct{}
+
+func (testContext) ProjectName() string      { return "j2" }
+func (testContext) ProjectVersion() string   { return "test" }
+func (testContext) ProjectBuild() string     { return "test" }
+func (testContext) ImageVaultMonkey() string { return "pulcy/vault-monkey:latest" }
+func (testContext) ImageWormhole() string    { return "pulcy/wormhole:latest" }
+func (testContext) ImageAlpine() string      { return "alpine:3.4" }
+func (testContext) ImageCephVolume() string  { return "pulcy/ceph-volume:latest" }
+
+// renderUnits parses the given job source and renders the units of its first scaling group
+// using the given provider. It returns the content of all units by name.
+func renderUnits(t *testing.T, p render.RenderProvider, src string) map[string]string {
+	f, err := ioutil.TempFile("", "j2-test")
+	if err != nil {
+		t.Fatalf("Cannot create job file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(src); err != nil {
+		t.Fatalf("Cannot write job file: %v", err)
+	}
+	f.Close()
+	cl := cluster.New("example.com", "test", 1)
+	r := p.CreateRenderer(cl)
+	job, err := jobs.ParseJobFromFileOffline(f.Name(), cl, r, fg.Options{}, nil, false)
+	if err != nil {
+		t.Fatalf("Cannot parse job: %v", err)
+	}
+	units, err := r.GenerateUnits(*job, testContext{}, render.RenderConfig{Cluster: cl, CurrentScalingGroup: 1}, cl.InstanceCount)
+	if err != nil {
+		t.Fatalf("Cannot generate units: %v", err)
+	}
+	result := make(map[string]string)
+	for _, u := range units {
+		result[u.Name()] = u.Content()
+	}
+	return result
+}
+
+// unitLines returns all lines of the given unit file that start with one of the given prefixes.
+func unitLines(content string, prefixes ...string) []string {
+	var result []string
+	for _, line := range strings.Split(content, "\n") {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(line, prefix) {
+				result = append(result, line)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// k8sPodSpec holds the parts of the pod spec of a kubernetes deployment that are tested.
+type k8sPodSpec struct {
+	Containers                    []map[string]interface{} `json:"containers"`
+	TerminationGracePeriodSeconds *int64                   `json:"terminationGracePeriodSeconds"`
+}
+
+// deploymentPodSpec returns the pod spec of the given kubernetes deployment.
+func deploymentPodSpec(t *testing.T, content string) k8sPodSpec {
+	var depl struct {
+		Spec struct {
+			Template struct {
+				Spec k8sPodSpec `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(content), &depl); err != nil {
+		t.Fatalf("Cannot decode deployment: %v", err)
+	}
+	return depl.Spec.Template.Spec
+}
+
+// jsonValue decodes the given JSON text, failing the test on errors.
+func jsonValue(t *testing.T, text string) interface{} {
+	var result interface{}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("Cannot decode %s: %v", text, err)
+	}
+	return result
+}
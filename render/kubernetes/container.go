@@ -3,9 +3,15 @@ package kubernetes
 import (
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	k8s "github.com/YakLabs/k8s-client"
+	"github.com/YakLabs/k8s-client/intstr"
+	"github.com/pulcy/j2/cluster"
 	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/pkg/cmdline"
 	pkg "github.com/pulcy/j2/pkg/kubernetes"
 )
 
@@ -28,6 +34,23 @@ func createTaskContainers(t *jobs.Task, pod pod, ctx generatorContext, hostNetwo
 	if len(t.Args) > 0 {
 		c.Args = t.Args
 	}
+	if t.Resources != nil {
+		c.Resources = createResourceRequirements(*t.Resources)
+	}
+	if t.Check != nil {
+		readiness, err := createProbe(t)
+		if err != nil {
+			return nil, nil, nil, maskAny(err)
+		}
+		// Liveness probes must use a success threshold of 1
+		liveness := *readiness
+		liveness.SuccessThreshold = 1
+		c.LivenessProbe = &liveness
+		c.ReadinessProbe = readiness
+	}
+	if t.Drain != nil {
+		c.Lifecycle = createDrainLifecycle(*t.Drain)
+	}
 
 	// Exposed ports
 	for _, p := range t.Ports {
@@ -146,6 +169,115 @@ func newContainer(name, image string) *k8s.Container {
 	}
 }
 
+// createResourceRequirements returns the requests & limits of the given resources.
+func createResourceRequirements(r cluster.Resources) *k8s.ResourceRequirements {
+	rr := &k8s.ResourceRequirements{}
+	add := func(list *k8s.ResourceList, name k8s.ResourceName, value string) {
+		if value == "" {
+			return
+		}
+		if *list == nil {
+			*list = make(k8s.ResourceList)
+		}
+		(*list)[name] = value
+	}
+	add(&rr.Requests, k8s.ResourceCPU, r.CPU)
+	add(&rr.Requests, k8s.ResourceMemory, r.Memory)
+	add(&rr.Limits, k8s.ResourceCPU, r.CPULimit)
+	add(&rr.Limits, k8s.ResourceMemory, r.MemoryLimit)
+	return rr
+}
+
+// createProbe creates a probe for the check of the given task.
+func createProbe(t *jobs.Task) (*k8s.Probe, error) {
+	check := *t.Check
+	probe := &k8s.Probe{
+		InitialDelaySeconds: durationSeconds(check.InitialDelayDuration()),
+		TimeoutSeconds:      durationSeconds(check.TimeoutDuration()),
+		PeriodSeconds:       durationSeconds(check.IntervalDuration()),
+		SuccessThreshold:    check.HealthyThresholdCount(),
+		FailureThreshold:    check.UnhealthyThresholdCount(),
+	}
+	switch check.Type {
+	case jobs.CheckTypeHTTP:
+		port, err := t.CheckPort()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		if !check.IsPlainHTTPGet() {
+			// HTTP probes only perform GET requests and accept any 2xx or 3xx status, use curl instead
+			var quoted []string
+			for _, arg := range check.CurlArgs(port) {
+				quoted = append(quoted, cmdline.ShellQuote(arg))
+			}
+			script := fmt.Sprintf("%s | head -n 1 | grep -q %s", strings.Join(quoted, " "), cmdline.ShellQuote(check.StatusLinePattern()))
+			probe.Exec = &k8s.ExecAction{Command: []string{"/bin/sh", "-c", script}}
+			break
+		}
+		action := &k8s.HTTPGetAction{
+			Path: check.Path,
+			Port: intstr.FromInt(port),
+		}
+		for name, value := range check.Headers {
+			action.HTTPHeaders = append(action.HTTPHeaders, k8s.HTTPHeader{Name: name, Value: value})
+		}
+		sort.Sort(httpHeaderByName(action.HTTPHeaders))
+		probe.HTTPGet = action
+	case jobs.CheckTypeTCP:
+		port, err := t.CheckPort()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		probe.TCPSocket = &k8s.TCPSocketAction{Port: intstr.FromInt(port)}
+	case jobs.CheckTypeExec:
+		probe.Exec = &k8s.ExecAction{Command: check.Command}
+	default:
+		return nil, maskAny(fmt.Errorf("unknown check type '%s'", check.Type))
+	}
+	return probe, nil
+}
+
+// createDrainLifecycle creates a lifecycle with a pre-stop hook that waits for the drain period
+// and sends the stop signal of the given drain (if that is not the default SIGTERM).
+// Since kubernetes sends SIGTERM as soon as the pre-stop hook returns, the hook waits for the
+// main process to exit (at most the stop timeout) after sending the stop signal.
+// It returns nil if no pre-stop hook is needed.
+func createDrainLifecycle(d jobs.Drain) *k8s.Lifecycle {
+	var script []string
+	if period := durationSeconds(d.PeriodDuration()); period > 0 {
+		script = append(script, fmt.Sprintf("sleep %d", period))
+	}
+	if !d.HasDefaultStopSignal() {
+		signal := d.StopSignalName()
+		if _, err := strconv.Atoi(signal); err == nil {
+			// `kill -s` only accepts signal names in POSIX shells
+			script = append(script, fmt.Sprintf("kill -%s 1", signal))
+		} else {
+			script = append(script, fmt.Sprintf("kill -s %s 1", strings.TrimPrefix(signal, "SIG")))
+		}
+		script = append(script, fmt.Sprintf("i=0; while kill -0 1 2>/dev/null && [ $i -lt %d ]; do sleep 1; i=$((i+1)); done", durationSeconds(d.StopTimeoutDuration())))
+	}
+	if len(script) == 0 {
+		return nil
+	}
+	return &k8s.Lifecycle{
+		PreStop: &k8s.Handler{
+			Exec: &k8s.ExecAction{Command: []string{"/bin/sh", "-c", strings.Join(script, "; ")}},
+		},
+	}
+}
+
+// durationSeconds returns the given duration in whole seconds, rounded up.
+func durationSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+type httpHeaderByName []k8s.HTTPHeader
+
+func (l httpHeaderByName) Len() int           { return len(l) }
+func (l httpHeaderByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l httpHeaderByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
 type envVarByName []k8s.EnvVar
 
 func (l envVarByName) Len() int           { return len(l) }
@@ -69,6 +69,22 @@ func (g *k8sRenderer) GenerateUnits(job jobs.Job, ctx render.RenderContext, conf
 		if err != nil {
 			return nil, maskAny(err)
 		}
+		if config.FrontendsOnly {
+			// Only the ingresses of blue/green groups
+			if !tg.BlueGreen {
+				continue
+			}
+			for _, p := range pods {
+				if ingresses, err := createIngresses(tg, p, genCtx); err != nil {
+					return nil, maskAny(err)
+				} else {
+					for _, res := range ingresses {
+						units = append(units, &k8s.Ingress{Ingress: res})
+					}
+				}
+			}
+			continue
+		}
 		for _, p := range pods {
 			if deployments, err := createDeployments(tg, p, genCtx); err != nil {
 				return nil, maskAny(err)
@@ -105,6 +121,10 @@ func (g *k8sRenderer) GenerateUnits(job jobs.Job, ctx render.RenderContext, conf
 					units = append(units, &k8s.Service{Service: res})
 				}
 			}
+			if tg.BlueGreen {
+				// Ingresses of blue/green groups are generated as frontend units
+				continue
+			}
 			if ingresses, err := createIngresses(tg, p, genCtx); err != nil {
 				return nil, maskAny(err)
 			} else {
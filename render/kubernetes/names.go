@@ -23,14 +23,23 @@ func resourceName(fullName string, kind string) string {
 	return k8s.ResourceName(fullName + kind)
 }
 
+// groupName returns the name of the group with given name, followed by the given color (if any).
+func groupName(name jobs.TaskGroupName, color jobs.Color) string {
+	if color != "" {
+		return fmt.Sprintf("%s-%s", name, color)
+	}
+	return name.String()
+}
+
 // taskIngressName creates the name of the ingress created for the given task.
+// It is the same for both colors of a blue/green group.
 func taskIngressName(t *jobs.Task) string {
 	return resourceName(fmt.Sprintf("%s-%s", t.GroupName(), t.Name), kindIngress)
 }
 
 // taskServiceName creates the name of the service created for the given task.
 func taskServiceName(t *jobs.Task) string {
-	return resourceName(fmt.Sprintf("%s-%s", t.GroupName(), t.Name), kindService)
+	return resourceName(fmt.Sprintf("%s-%s", groupName(t.GroupName(), t.GroupColor()), t.Name), kindService)
 }
 
 // taskServiceDNSName creates the DNS name of the service created for the given task.
@@ -57,5 +66,5 @@ func dependencyServiceDNSName(d jobs.Dependency, clusterDomain string) string {
 
 // taskSecretName creates the name of the secret created for the given task.
 func taskSecretName(t *jobs.Task) string {
-	return resourceName(fmt.Sprintf("%s-%s", t.GroupName(), t.Name), kindSecret)
+	return resourceName(fmt.Sprintf("%s-%s", groupName(t.GroupName(), t.GroupColor()), t.Name), kindSecret)
 }
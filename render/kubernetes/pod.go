@@ -21,7 +21,7 @@ func groupTaskIntoPods(tg *jobs.TaskGroup) ([]pod, error) {
 	if tg.RestartPolicy.IsAll() {
 		// Put everything into 1 pod
 		p := pod{
-			name:  resourceName(tg.Name.String(), ""),
+			name:  resourceName(groupName(tg.Name, tg.Color()), ""),
 			tasks: tg.Tasks,
 		}
 		if err := p.validate(); err != nil {
@@ -122,9 +122,9 @@ func createPodName(p *pod, tg *jobs.TaskGroup) string {
 		}
 	}
 	if lowestOriginalIndex < 0 {
-		return resourceName(tg.Name.String(), "")
+		return resourceName(groupName(tg.Name, tg.Color()), "")
 	}
-	return resourceName(tg.Name.String(), "-"+taskName)
+	return resourceName(groupName(tg.Name, tg.Color()), "-"+taskName)
 }
 
 type podByIndex []pod
@@ -206,6 +206,16 @@ func (p *pod) hasOneShotTasks() bool {
 	return false
 }
 
+// isHook returns true if the tasks in this pod run a hook.
+func (p *pod) isHook() bool {
+	for _, t := range p.tasks {
+		if t.GroupHook() != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // hasRWHostVolumes returns true if there is at least 1 task that has a volume mapped to a host folder and is read/write.
 func (p *pod) hasRWHostVolumes() bool {
 	for _, t := range p.tasks {
@@ -46,6 +46,13 @@ func createPodSpec(tg *jobs.TaskGroup, pod pod, ctx generatorContext, requireRes
 		allInitContainers = append(allInitContainers, initContainers...)
 		spec.Volumes = appendVolumes(spec.Volumes, extraVols...)
 		spec.Containers = append(spec.Containers, containers...)
+		if t.Drain != nil {
+			// The grace period includes the pre-stop hook, so it covers both the drain period & stop timeout.
+			grace := int64(durationSeconds(t.Drain.PeriodDuration() + t.Drain.StopTimeoutDuration()))
+			if spec.TerminationGracePeriodSeconds == nil || *spec.TerminationGracePeriodSeconds < grace {
+				spec.TerminationGracePeriodSeconds = &grace
+			}
+		}
 	}
 
 	// Image pull secrets
@@ -83,6 +90,10 @@ func getRestartPolicy(pod pod, requireAlways bool) k8s.RestartPolicy {
 	if requireAlways {
 		return RestartPolicyAlways
 	}
+	if pod.isHook() {
+		// Hooks run once, their outcome is reported by the status of the job.
+		return RestartPolicyNever
+	}
 	oneShotTasks := 0
 	serviceTasks := 0
 	for _, t := range pod.tasks {
@@ -23,6 +23,9 @@ type RenderConfig struct {
 	Groups              []jobs.TaskGroupName
 	CurrentScalingGroup uint
 	Cluster             cluster.Cluster
+	// If set, only the frontend units of blue/green groups are generated.
+	// Otherwise all units are generated, except for the frontend units of blue/green groups.
+	FrontendsOnly bool
 }
 
 type RenderProvider interface {
@@ -0,0 +1,87 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+)
+
+var (
+	retireCmd = &cobra.Command{
+		Use:   "retire",
+		Short: "Remove the inactive color of blue/green groups.",
+		Long:  "Remove all units of blue/green groups of a job in the color that does not serve their frontends.",
+		Run:   retireRun,
+	}
+	retireFlags struct {
+		fg.Flags
+		color string
+	}
+)
+
+func init() {
+	initDeploymentFlags(retireCmd.Flags(), &retireFlags.Flags)
+	initOutputFlags(retireCmd.Flags(), &retireFlags.Flags)
+	retireCmd.Flags().StringVar(&retireFlags.color, "color", "", "Color to retire (blue|green), needed only when the active color cannot be determined")
+}
+
+func retireRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &retireFlags.Flags, args)
+	color := jobs.Color(retireFlags.color)
+	if color != "" {
+		if err := color.Validate(); err != nil {
+			Exitf("--color invalid: %v\n", err)
+		}
+	}
+
+	cluster, err := loadCluster(&retireFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&retireFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	delays := deployment.DeploymentDelays{
+		StopDelay:    retireFlags.StopDelay,
+		DestroyDelay: retireFlags.DestroyDelay,
+		SliceDelay:   retireFlags.SliceDelay,
+	}
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&retireFlags.Flags),
+		deployment.ScalingGroupSelection(retireFlags.ScalingGroup),
+		retireFlags.Force,
+		retireFlags.AutoContinue,
+		globalFlags.verbose,
+		delays,
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{StealLock: retireFlags.StealLock},
+		renderCtx)
+	assert(err)
+	d.Events = eventSink(&retireFlags.Flags)
+
+	if err := d.Retire(rootCtx, color); err != nil {
+		Exitf("Cannot retire: %v\n", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	rollbackCmd = &cobra.Command{
+		Use:   "rollback",
+		Short: "Redeploy an earlier revision of a job.",
+		Long:  "Redeploy the units recorded in an earlier revision of the deployment history of a job, using a rolling update.",
+		Run:   rollbackRun,
+	}
+	rollbackFlags struct {
+		fg.Flags
+		to int
+	}
+)
+
+func init() {
+	initDeploymentFlags(rollbackCmd.Flags(), &rollbackFlags.Flags)
+	initOutputFlags(rollbackCmd.Flags(), &rollbackFlags.Flags)
+	rollbackCmd.Flags().IntVar(&rollbackFlags.to, "to", 0, "Revision to redeploy (see `history`)")
+}
+
+func rollbackRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &rollbackFlags.Flags, args)
+	runValidators(&rollbackFlags.Flags)
+	if rollbackFlags.to <= 0 {
+		Exitf("--to missing\n")
+	}
+
+	cluster, err := loadCluster(&rollbackFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&rollbackFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	delays := deployment.DeploymentDelays{
+		StopDelay:    rollbackFlags.StopDelay,
+		DestroyDelay: rollbackFlags.DestroyDelay,
+		SliceDelay:   rollbackFlags.SliceDelay,
+	}
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&rollbackFlags.Flags),
+		deployment.ScalingGroupSelection(rollbackFlags.ScalingGroup),
+		rollbackFlags.Force,
+		rollbackFlags.AutoContinue,
+		globalFlags.verbose,
+		delays,
+		healthCheckConfig(&rollbackFlags.Flags),
+		rolloutOptions(cmd.Flags(), &rollbackFlags.Flags, *cluster),
+		renderCtx)
+	assert(err)
+	d.Events = eventSink(&rollbackFlags.Flags)
+
+	if err := d.Rollback(rootCtx, rollbackFlags.to); err != nil {
+		Exitf("Cannot rollback to revision %d: %v\n", rollbackFlags.to, err)
+	}
+}
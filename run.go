@@ -40,6 +40,8 @@ var (
 
 func init() {
 	initDeploymentFlags(runCmd.Flags(), &runFlags.Flags)
+	initOutputFlags(runCmd.Flags(), &runFlags.Flags)
+	runCmd.Flags().BoolVar(&runFlags.Resume, "resume", defaultResume, "Skip the scaling groups completed by an interrupted deployment of the same job")
 }
 
 func runRun(cmd *cobra.Command, args []string) {
@@ -71,17 +73,27 @@ func runRun(cmd *cobra.Command, args []string) {
 		runFlags.AutoContinue,
 		globalFlags.verbose,
 		delays,
+		healthCheckConfig(&runFlags.Flags),
+		rolloutOptions(cmd.Flags(), &runFlags.Flags, *cluster),
 		renderCtx)
 	assert(err)
+	d.Events = eventSink(&runFlags.Flags)
 
 	if runFlags.DryRun {
 		assert(d.DryRun())
 	} else {
-		assert(d.Run())
+		assert(d.Run(rootCtx))
 	}
 }
 
 func runValidators(f *fg.Flags) {
+	mode := deployment.HealthCheckMode(f.HealthCheck)
+	if err := mode.Validate(); err != nil {
+		Exitf("--health-check invalid: %v\n", err)
+	}
+	if mode.IsEnabled() && f.HealthTimeout <= 0 {
+		Exitf("--health-timeout must be positive, got %s\n", f.HealthTimeout)
+	}
 }
 
 type renderContext struct {
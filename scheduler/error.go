@@ -20,9 +20,19 @@ import (
 
 var (
 	NotFoundError = errgo.New("not found")
+	LockedError   = errgo.New("locked")
+	LockLostError = errgo.New("lock lost")
 	maskAny       = errgo.MaskFunc(errgo.Any)
 )
 
 func IsNotFound(err error) bool {
 	return errgo.Cause(err) == NotFoundError
 }
+
+func IsLocked(err error) bool {
+	return errgo.Cause(err) == LockedError
+}
+
+func IsLockLost(err error) bool {
+	return errgo.Cause(err) == LockLostError
+}
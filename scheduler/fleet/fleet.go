@@ -20,7 +20,9 @@ import (
 	"sync"
 	"time"
 
+	fleetunit "github.com/coreos/fleet/unit"
 	"github.com/juju/errgo"
+	"golang.org/x/net/context"
 
 	"github.com/pulcy/j2/jobs"
 	"github.com/pulcy/j2/pkg/fleet"
@@ -44,10 +46,16 @@ func NewScheduler(job jobs.Job, tunnel string) (scheduler.Scheduler, error) {
 	}, nil
 }
 
+const (
+	// statusCacheTimeout is the maximum age of the cached unit status.
+	statusCacheTimeout = 5 * time.Second
+)
+
 type fleetScheduler struct {
 	tunnel      fleet.FleetTunnel
 	statusMutex sync.Mutex
 	status      *fleet.StatusMap
+	statusTime  time.Time
 	job         jobs.Job
 }
 
@@ -57,19 +65,32 @@ func (u fleetUnit) Name() string {
 	return string(u)
 }
 
+type fleetUnitData struct {
+	name    string
+	content string
+}
+
+func (u fleetUnitData) Name() string {
+	return u.name
+}
+
+func (u fleetUnitData) Content() string {
+	return u.content
+}
+
 // ValidateCluster checks if the cluster is suitable to run the configured job.
-func (s *fleetScheduler) ValidateCluster() error {
+func (s *fleetScheduler) ValidateCluster(ctx context.Context) error {
 	return nil
 }
 
 // ConfigureCluster configures the cluster for use by J2.
-func (s *fleetScheduler) ConfigureCluster(config scheduler.ClusterConfig) error {
+func (s *fleetScheduler) ConfigureCluster(ctx context.Context, config scheduler.ClusterConfig) error {
 	return maskAny(fmt.Errorf("Fleet cluster cannot be configured like this. Use Quark & Gluon."))
 }
 
 // List returns the names of all units on the cluster
-func (s *fleetScheduler) List() ([]scheduler.Unit, error) {
-	names, err := s.tunnel.List()
+func (s *fleetScheduler) List(ctx context.Context) ([]scheduler.Unit, error) {
+	names, err := s.tunnel.List(ctx)
 	if err != nil {
 		return nil, maskAny(err)
 	}
@@ -80,8 +101,8 @@ func (s *fleetScheduler) List() ([]scheduler.Unit, error) {
 	return units, nil
 }
 
-func (s *fleetScheduler) GetState(unit scheduler.Unit) (scheduler.UnitState, error) {
-	status, err := s.getStatus()
+func (s *fleetScheduler) GetState(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
+	status, err := s.getStatus(ctx)
 	if err != nil {
 		return scheduler.UnitState{}, maskAny(err)
 	}
@@ -89,19 +110,48 @@ func (s *fleetScheduler) GetState(unit scheduler.Unit) (scheduler.UnitState, err
 	if !found {
 		return scheduler.UnitState{}, maskAny(scheduler.NotFoundError)
 	}
+	machine, _ := status.Machine(unit.Name())
 	state := scheduler.UnitState{
-		Failed: unitState == "failed",
+		Failed:  unitState == "failed",
+		Healthy: unitState == "active" || (unitState == "inactive" && s.isOneshotUnit(unit)),
+		Message: unitState,
+		Machine: machine,
 	}
 	return state, nil
 }
 
-func (s *fleetScheduler) Cat(unit scheduler.Unit) (string, error) {
-	return s.tunnel.Cat(unit.Name())
+// Cat returns the content of the given unit as it exists on the cluster.
+func (s *fleetScheduler) Cat(ctx context.Context, unit scheduler.Unit) (string, error) {
+	content, err := s.tunnel.Cat(ctx, unit.Name())
+	if fleet.IsNotFound(err) {
+		return "", maskAny(errgo.WithCausef(nil, scheduler.NotFoundError, unit.Name()))
+	} else if err != nil {
+		return "", maskAny(err)
+	}
+	return content, nil
+}
+
+// GetCurrent returns the given unit as it exists on the cluster.
+func (s *fleetScheduler) GetCurrent(ctx context.Context, unit scheduler.Unit) (scheduler.UnitData, error) {
+	content, err := s.Cat(ctx, unit)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return fleetUnitData{name: unit.Name(), content: content}, nil
+}
+
+// NormalizeContent returns the content of the given unit in the format used by Cat.
+func (s *fleetScheduler) NormalizeContent(unit scheduler.UnitData) (string, error) {
+	uf, err := fleetunit.NewUnitFile(unit.Content())
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return uf.String(), nil
 }
 
 // HasChanged returns true when the given unit is different on the system
-func (s *fleetScheduler) HasChanged(unit scheduler.UnitData) ([]string, bool, error) {
-	current, err := s.tunnel.Cat(unit.Name())
+func (s *fleetScheduler) HasChanged(ctx context.Context, unit scheduler.UnitData) ([]string, bool, error) {
+	current, err := s.tunnel.Cat(ctx, unit.Name())
 	if err != nil {
 		return nil, false, maskAny(err)
 	}
@@ -137,9 +187,9 @@ func normalizeUnitContent(content string) []string {
 	return result
 }
 
-func (s *fleetScheduler) Stop(events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) (scheduler.StopStats, error) {
+func (s *fleetScheduler) Stop(ctx context.Context, events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) (scheduler.StopStats, error) {
 	s.clearStatus()
-	stats, err := s.tunnel.Stop(eventWrapper(events), getUnitNames(units)...)
+	stats, err := s.tunnel.Stop(ctx, eventWrapper(events), getUnitNames(units)...)
 	if err != nil {
 		return scheduler.StopStats{}, maskAny(err)
 	}
@@ -149,9 +199,9 @@ func (s *fleetScheduler) Stop(events chan scheduler.Event, reason scheduler.Reas
 	}, nil
 }
 
-func (s *fleetScheduler) Destroy(events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) error {
+func (s *fleetScheduler) Destroy(ctx context.Context, events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) error {
 	s.clearStatus()
-	if err := s.tunnel.Destroy(eventWrapper(events), getUnitNames(units)...); err != nil {
+	if err := s.tunnel.Destroy(ctx, eventWrapper(events), getUnitNames(units)...); err != nil {
 		return maskAny(err)
 	}
 	return nil
@@ -177,9 +227,9 @@ func (l *unitDataWrapper) Get(index int) fleet.UnitData {
 	return l.units.Get(index)
 }
 
-func (s *fleetScheduler) Start(events chan scheduler.Event, units scheduler.UnitDataList) error {
+func (s *fleetScheduler) Start(ctx context.Context, events chan scheduler.Event, units scheduler.UnitDataList) error {
 	s.clearStatus()
-	if err := s.tunnel.Start(eventWrapper(events), &unitDataWrapper{units: units}); err != nil {
+	if err := s.tunnel.Start(ctx, eventWrapper(events), &unitDataWrapper{units: units}); err != nil {
 		return maskAny(err)
 	}
 	return nil
@@ -205,16 +255,17 @@ func (s *fleetScheduler) UpdateDestroyDelay(d time.Duration) time.Duration {
 	return d // Do not modify
 }
 
-func (s *fleetScheduler) getStatus() (*fleet.StatusMap, error) {
+func (s *fleetScheduler) getStatus(ctx context.Context) (*fleet.StatusMap, error) {
 	s.statusMutex.Lock()
 	defer s.statusMutex.Unlock()
 
-	if s.status == nil {
-		statusMap, err := s.tunnel.Status()
+	if s.status == nil || time.Since(s.statusTime) > statusCacheTimeout {
+		statusMap, err := s.tunnel.Status(ctx)
 		if err != nil {
 			return nil, maskAny(err)
 		}
 		s.status = &statusMap
+		s.statusTime = time.Now()
 	}
 	return s.status, nil
 }
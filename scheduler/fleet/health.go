@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetscheduler
+
+import (
+	"fmt"
+
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// CheckHTTPHealth returns the state of the given unit, where Healthy is based on
+// the response of the http check of its task.
+// The http-check-path of the task takes precedence over an http check.
+// Units without an http check (or without a host port to check) are checked using GetState.
+func (s *fleetScheduler) CheckHTTPHealth(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
+	state, err := s.GetState(ctx, unit)
+	if err != nil {
+		return state, maskAny(err)
+	}
+	if !state.Healthy {
+		return state, nil
+	}
+	t := s.taskForUnit(unit)
+	if t == nil {
+		return state, nil
+	}
+	check, found := httpCheckForTask(t)
+	if !found {
+		return state, nil
+	}
+	port, found := httpCheckPort(t, check.Port)
+	if !found {
+		return state, nil
+	}
+	status, err := s.getStatus(ctx)
+	if err != nil {
+		return state, maskAny(err)
+	}
+	ip, found := status.MachineIP(unit.Name())
+	if !found {
+		state.Healthy = false
+		state.Message = "machine IP unknown"
+		return state, nil
+	}
+	method := check.HTTPMethod()
+	statusCode, err := s.tunnel.HTTPCheck(ctx, method, ip, port, check.Path, check.Headers)
+	if err != nil {
+		state.Healthy = false
+		state.Message = fmt.Sprintf("%s %s failed: %v", method, check.Path, err)
+		return state, nil
+	}
+	state.Healthy = check.IsExpectedStatus(statusCode)
+	state.Message = fmt.Sprintf("%s %s returned %d", method, check.Path, statusCode)
+	return state, nil
+}
+
+// httpCheckForTask returns the http check used to check the health of the given task.
+// The port of the returned check is the container port to check, or 0 for the first host port.
+func httpCheckForTask(t *jobs.Task) (jobs.Check, bool) {
+	if t.HttpCheckPath != "" {
+		return jobs.Check{
+			Type:   jobs.CheckTypeHTTP,
+			Path:   t.HttpCheckPath,
+			Method: t.HttpCheckMethod,
+		}, true
+	}
+	if t.Check == nil || t.Check.Type != jobs.CheckTypeHTTP {
+		return jobs.Check{}, false
+	}
+	check := *t.Check
+	if port, err := t.CheckPort(); err == nil {
+		check.Port = port
+	}
+	return check, true
+}
+
+// isOneshotUnit returns true if the given unit is the main unit of a oneshot task.
+func (s *fleetScheduler) isOneshotUnit(unit scheduler.Unit) bool {
+	t := s.taskForUnit(unit)
+	return t != nil && t.Type.IsOneshot()
+}
+
+// taskForUnit returns the task for which the given unit is the main unit, or nil if not found.
+// The tasks of hooks are included.
+func (s *fleetScheduler) taskForUnit(unit scheduler.Unit) *jobs.Task {
+	for _, list := range []jobs.TaskGroupList{s.job.Groups, s.job.Hooks} {
+		for _, g := range list {
+			for _, t := range g.Tasks {
+				if IsUnitForTask(unit.Name(), s.job.Name, g.Name, t.Name) {
+					return t
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// httpCheckPort returns the host port mapped to the given container port of the given task.
+// If containerPort is 0 (or not mapped), the first TCP host port is returned.
+func httpCheckPort(t *jobs.Task, containerPort int) (int, bool) {
+	var mappings []jobs.ParsedPortMapping
+	for _, p := range t.Ports {
+		pm, err := p.Parse()
+		if err != nil {
+			continue
+		}
+		if pm.IsTCP() && pm.HasHostPort() {
+			mappings = append(mappings, pm)
+		}
+	}
+	for _, pm := range mappings {
+		if pm.ContainerPort == containerPort {
+			return pm.HostPort, true
+		}
+	}
+	if len(mappings) > 0 {
+		return mappings[0].HostPort, true
+	}
+	return 0, false
+}
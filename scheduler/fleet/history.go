@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetscheduler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/scheduler"
+)
+
+const (
+	// historyKeyPrefix is the etcd directory containing the deployment history of all jobs.
+	historyKeyPrefix = "/pulcy/j2/history/"
+)
+
+// AddHistory stores the given entry in the deployment history of the job.
+// Every entry is stored in its own etcd key, named after its revision.
+func (s *fleetScheduler) AddHistory(ctx context.Context, entry scheduler.HistoryEntry) (scheduler.HistoryEntry, error) {
+	kAPI, err := s.tunnel.KeysAPI()
+	if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+	entries, err := s.History(ctx)
+	if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+	entry.Revision = 1
+	if len(entries) > 0 {
+		entry.Revision = entries[len(entries)-1].Revision + 1
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+	if _, err := kAPI.Set(ctx, s.historyKey(entry.Revision), string(raw), &etcd.SetOptions{PrevExist: etcd.PrevNoExist}); isEtcdError(err, etcd.ErrorCodeNodeExist) {
+		// Someone else added an entry in the meantime, try again
+		return s.AddHistory(ctx, entry)
+	} else if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+
+	// Remove old entries
+	for i := 0; i < len(entries)+1-scheduler.MaxHistoryEntries; i++ {
+		if _, err := kAPI.Delete(ctx, s.historyKey(entries[i].Revision), nil); err != nil && !etcd.IsKeyNotFound(err) {
+			return scheduler.HistoryEntry{}, maskAny(err)
+		}
+	}
+	return entry, nil
+}
+
+// History returns the deployment history of the job, oldest entry first.
+func (s *fleetScheduler) History(ctx context.Context) ([]scheduler.HistoryEntry, error) {
+	kAPI, err := s.tunnel.KeysAPI()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	resp, err := kAPI.Get(ctx, historyKeyPrefix+s.job.Name.String(), &etcd.GetOptions{Sort: true})
+	if etcd.IsKeyNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	// Keys are named after the (zero padded) revision, so sorted nodes are in revision order
+	var entries []scheduler.HistoryEntry
+	for _, node := range resp.Node.Nodes {
+		var entry scheduler.HistoryEntry
+		if err := json.Unmarshal([]byte(node.Value), &entry); err != nil {
+			return nil, maskAny(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ParseUnit reconstructs a unit from the name & content of a unit in the deployment history.
+func (s *fleetScheduler) ParseUnit(name, content string) (scheduler.UnitData, error) {
+	return fleetUnitData{name: name, content: content}, nil
+}
+
+// historyKey returns the etcd key of the history entry with given revision.
+func (s *fleetScheduler) historyKey(revision int) string {
+	return fmt.Sprintf("%s%s/%08d", historyKeyPrefix, s.job.Name, revision)
+}
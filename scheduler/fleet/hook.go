@@ -0,0 +1,55 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetscheduler
+
+import (
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// GetCompletion returns the state of a unit that runs to completion.
+// Such units remain active after they exited successfully.
+func (s *fleetScheduler) GetCompletion(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
+	s.clearStatus()
+	state, err := s.GetState(ctx, unit)
+	if err != nil {
+		return state, maskAny(err)
+	}
+	state.Healthy = state.Message == "active"
+	return state, nil
+}
+
+// Output returns the last lines of the journal of the given unit.
+// Units other than the main unit of a task result in an empty string.
+func (s *fleetScheduler) Output(ctx context.Context, unit scheduler.Unit, lines int) (string, error) {
+	if s.taskForUnit(unit) == nil {
+		return "", nil
+	}
+	status, err := s.getStatus(ctx)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	ip, found := status.MachineIP(unit.Name())
+	if !found {
+		return "", maskAny(errgo.WithCausef(nil, scheduler.NotFoundError, "machine of %s", unit.Name()))
+	}
+	output, err := s.tunnel.Journal(ctx, unit.Name(), ip, lines)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return output, nil
+}
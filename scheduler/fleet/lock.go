@@ -0,0 +1,147 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetscheduler
+
+import (
+	"encoding/json"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/juju/errgo"
+	"golang.org/x/net/context"
+
+	"github.com/pulcy/j2/scheduler"
+)
+
+const (
+	// lockKeyPrefix is the etcd directory containing the deployment locks of all jobs.
+	lockKeyPrefix = "/pulcy/j2/locks/"
+)
+
+// Lock acquires the deployment lock of the job for the given holder.
+// The lock is stored in an etcd key with a TTL, so it expires when the holder
+// stops renewing it.
+func (s *fleetScheduler) Lock(ctx context.Context, holder string, ttl time.Duration, steal bool) (scheduler.Lease, error) {
+	kAPI, err := s.tunnel.KeysAPI()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	for attempt := 0; attempt < scheduler.LockAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, maskAny(err)
+		}
+		l, retry, err := s.tryLock(ctx, kAPI, holder, ttl, steal)
+		if !retry {
+			return l, maskAny(err)
+		}
+	}
+	return nil, maskAny(errgo.WithCausef(nil, scheduler.LockedError, "lock of job '%s' keeps changing, giving up after %d attempts", s.job.Name, scheduler.LockAttempts))
+}
+
+// tryLock makes a single attempt to acquire the deployment lock of the job.
+// It returns true when the lock changed during the attempt, so it must be tried again.
+func (s *fleetScheduler) tryLock(ctx context.Context, kAPI etcd.KeysAPI, holder string, ttl time.Duration, steal bool) (scheduler.Lease, bool, error) {
+	l := &fleetLease{
+		kAPI: kAPI,
+		key:  lockKeyPrefix + s.job.Name.String(),
+		ttl:  ttl,
+		info: scheduler.NewLockInfo(holder, ttl),
+	}
+	raw, err := json.Marshal(l.info)
+	if err != nil {
+		return nil, false, maskAny(err)
+	}
+	l.value = string(raw)
+
+	if _, err := kAPI.Set(ctx, l.key, l.value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: ttl}); err == nil {
+		return l, false, nil
+	} else if !isEtcdError(err, etcd.ErrorCodeNodeExist) {
+		return nil, false, maskAny(err)
+	}
+
+	// The lock is held by someone else
+	resp, err := kAPI.Get(ctx, l.key, nil)
+	if etcd.IsKeyNotFound(err) {
+		// Expired in the meantime, try again
+		return nil, true, nil
+	} else if err != nil {
+		return nil, false, maskAny(err)
+	}
+	var current scheduler.LockInfo
+	if err := json.Unmarshal([]byte(resp.Node.Value), &current); err != nil {
+		return nil, false, maskAny(err)
+	}
+	if !steal {
+		return nil, false, maskAny(errgo.WithCausef(nil, scheduler.LockedError, "job '%s' is locked by %s", s.job.Name, current))
+	}
+	if _, err := kAPI.Set(ctx, l.key, l.value, &etcd.SetOptions{PrevValue: resp.Node.Value, TTL: ttl}); err != nil {
+		if isEtcdError(err, etcd.ErrorCodeTestFailed) || etcd.IsKeyNotFound(err) {
+			// The lock changed in the meantime, try again
+			return nil, true, nil
+		}
+		return nil, false, maskAny(err)
+	}
+	l.stolen = &current
+	return l, false, nil
+}
+
+type fleetLease struct {
+	kAPI   etcd.KeysAPI
+	key    string
+	value  string
+	ttl    time.Duration
+	info   scheduler.LockInfo
+	stolen *scheduler.LockInfo
+}
+
+// Info returns the description of this lease.
+func (l *fleetLease) Info() scheduler.LockInfo {
+	return l.info
+}
+
+// Stolen returns the lock that was taken over when this lease was acquired (if any).
+func (l *fleetLease) Stolen() *scheduler.LockInfo {
+	return l.stolen
+}
+
+// Renew extends the lease with its TTL.
+func (l *fleetLease) Renew(ctx context.Context) error {
+	_, err := l.kAPI.Set(ctx, l.key, l.value, &etcd.SetOptions{PrevValue: l.value, TTL: l.ttl})
+	if isEtcdError(err, etcd.ErrorCodeTestFailed) || etcd.IsKeyNotFound(err) {
+		return maskAny(errgo.WithCausef(nil, scheduler.LockLostError, "lock of '%s' is no longer held by %s", l.key, l.info.Holder))
+	} else if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Release gives up the lock, if it is still held by this lease.
+func (l *fleetLease) Release(ctx context.Context) error {
+	_, err := l.kAPI.Delete(ctx, l.key, &etcd.DeleteOptions{PrevValue: l.value})
+	if isEtcdError(err, etcd.ErrorCodeTestFailed) || etcd.IsKeyNotFound(err) {
+		return nil
+	} else if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// isEtcdError returns true if the given error is an etcd error with given code.
+func isEtcdError(err error, code int) bool {
+	if eErr, ok := err.(etcd.Error); ok {
+		return eErr.Code == code
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// MaxHistoryEntries is the maximum number of entries kept in the deployment history of a job.
+	// Older entries are removed when a new entry is added.
+	MaxHistoryEntries = 25
+)
+
+// HistoryEntry describes a single successful deployment of a job.
+type HistoryEntry struct {
+	Revision     int               `json:"revision"`              // Sequence number of the entry (set by the scheduler)
+	Created      time.Time         `json:"created"`               // When the deployment finished
+	Operator     string            `json:"operator"`              // Who performed the deployment (user@host)
+	Version      string            `json:"version"`               // Version of j2 used to render the units
+	RollbackOf   int               `json:"rollback-of,omitempty"` // Revision that was redeployed by a rollback (if any)
+	Groups       []string          `json:"groups,omitempty"`      // Selected task groups (empty means all)
+	ScalingGroup uint              `json:"scaling-group"`         // Selected scaling group (0 means all)
+	Colors       map[string]string `json:"colors,omitempty"`      // Deployed color of blue/green groups
+	Job          json.RawMessage   `json:"job"`                   // Resolved job
+	Units        []HistoryUnit     `json:"units"`                 // Rendered units
+}
+
+// HistoryUnit is a rendered unit of a deployment, as stored in the deployment history.
+type HistoryUnit struct {
+	Name         string `json:"name"`
+	ScalingGroup uint   `json:"scaling-group"` // 0 for frontend units of blue/green groups
+	Hash         string `json:"hash"`          // Hex encoded SHA256 hash of the content
+	Content      string `json:"content"`
+}
+
+// HistoryEntryList is a list of history entries that can be sorted by revision.
+type HistoryEntryList []HistoryEntry
+
+func (l HistoryEntryList) Len() int           { return len(l) }
+func (l HistoryEntryList) Less(i, j int) bool { return l[i].Revision < l[j].Revision }
+func (l HistoryEntryList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
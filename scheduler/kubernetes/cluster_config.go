@@ -24,16 +24,17 @@ import (
 	k8s "github.com/YakLabs/k8s-client"
 	pkg "github.com/pulcy/j2/pkg/kubernetes"
 	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
 )
 
 // ValidateCluster checks if the cluster is suitable to run the configured job.
-func (s *k8sScheduler) ValidateCluster() error {
+func (s *k8sScheduler) ValidateCluster(ctx context.Context) error {
 	if err := s.validateCluster(); err == nil {
 		// All good
 		return nil
 	}
 	// Try to configure based on environment variables
-	s.ConfigureCluster(&envClusterConfig{})
+	s.ConfigureCluster(ctx, &envClusterConfig{})
 
 	// And now re-validate
 	if err := s.validateCluster(); err != nil {
@@ -83,7 +84,7 @@ func (s *k8sScheduler) validateCluster() error {
 }
 
 // ConfigureCluster configures the cluster for use by J2.
-func (s *k8sScheduler) ConfigureCluster(config scheduler.ClusterConfig) error {
+func (s *k8sScheduler) ConfigureCluster(ctx context.Context, config scheduler.ClusterConfig) error {
 	// Fetch info (if needed)
 	clusterID := config.ClusterID()
 	registrySecretConfigs := make(map[string][]byte)
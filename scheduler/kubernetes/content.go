@@ -0,0 +1,65 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+
+	pkg "github.com/pulcy/j2/pkg/kubernetes"
+	"github.com/pulcy/j2/scheduler"
+)
+
+var (
+	// serverManagedMetadata contains the metadata fields that are set by the server.
+	serverManagedMetadata = []string{
+		"creationTimestamp",
+		"generation",
+		"resourceVersion",
+		"selfLink",
+		"uid",
+	}
+)
+
+// normalizeContent converts the JSON content of the given resource into YAML,
+// leaving out status and server managed metadata fields.
+// Values of secrets are replaced by a hash of their content.
+func normalizeContent(unit scheduler.UnitData) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(unit.Content()), &obj); err != nil {
+		return "", maskAny(err)
+	}
+	delete(obj, "status")
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, key := range serverManagedMetadata {
+			delete(meta, key)
+		}
+	}
+	if _, ok := unit.(*pkg.Secret); ok {
+		if data, ok := obj["data"].(map[string]interface{}); ok {
+			for k, v := range data {
+				data[k] = fmt.Sprintf("<hidden sha256:%x>", sha256.Sum256([]byte(fmt.Sprintf("%v", v))))
+			}
+		}
+	}
+	raw, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return string(raw), nil
+}
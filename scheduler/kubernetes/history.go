@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	k8s "github.com/YakLabs/k8s-client"
+
+	pkg "github.com/pulcy/j2/pkg/kubernetes"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+const (
+	// historyLabel is the label that marks config maps containing a deployment history entry.
+	historyLabel = "j2.pulcy.com/history"
+	// historyDataKey is the key in the data of a history config map that holds the JSON encoded entry.
+	historyDataKey = "entry"
+)
+
+// AddHistory stores the given entry in the deployment history of the job.
+// Every entry is stored in its own config map in the namespace of the job.
+func (s *k8sScheduler) AddHistory(ctx context.Context, entry scheduler.HistoryEntry) (scheduler.HistoryEntry, error) {
+	if err := s.ensureNamespace(s.defaultNamespace); err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+	entries, err := s.History(ctx)
+	if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+	entry.Revision = 1
+	if len(entries) > 0 {
+		entry.Revision = entries[len(entries)-1].Revision + 1
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+	cm := k8s.NewConfigMap(s.defaultNamespace, historyConfigMapName(entry.Revision))
+	cm.Labels = map[string]string{historyLabel: strconv.Itoa(entry.Revision)}
+	cm.Data[historyDataKey] = raw
+	if _, err := s.client.CreateConfigMap(s.defaultNamespace, cm); isConflictError(err) {
+		// Someone else added an entry in the meantime, try again
+		return s.AddHistory(ctx, entry)
+	} else if err != nil {
+		return scheduler.HistoryEntry{}, maskAny(err)
+	}
+
+	// Remove old entries
+	for i := 0; i < len(entries)+1-scheduler.MaxHistoryEntries; i++ {
+		if err := s.client.DeleteConfigMap(s.defaultNamespace, historyConfigMapName(entries[i].Revision)); err != nil && !k8s.IsNotFoundError(err) {
+			return scheduler.HistoryEntry{}, maskAny(err)
+		}
+	}
+	return entry, nil
+}
+
+// History returns the deployment history of the job, oldest entry first.
+func (s *k8sScheduler) History(ctx context.Context) ([]scheduler.HistoryEntry, error) {
+	list, err := s.client.ListConfigMaps(s.defaultNamespace, nil)
+	if k8s.IsNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	var entries scheduler.HistoryEntryList
+	for _, cm := range list.Items {
+		if _, found := cm.Labels[historyLabel]; !found {
+			continue
+		}
+		var entry scheduler.HistoryEntry
+		if err := json.Unmarshal(cm.Data[historyDataKey], &entry); err != nil {
+			return nil, maskAny(err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Sort(entries)
+	return entries, nil
+}
+
+// ParseUnit reconstructs a unit from the name & content of a unit in the deployment history.
+// The type of the unit is derived from the kind of the resource in the content.
+func (s *k8sScheduler) ParseUnit(name, content string) (scheduler.UnitData, error) {
+	var meta k8s.TypeMeta
+	if err := json.Unmarshal([]byte(content), &meta); err != nil {
+		return nil, maskAny(err)
+	}
+	var unit scheduler.UnitData
+	switch meta.Kind {
+	case "DaemonSet":
+		unit = &pkg.DaemonSet{}
+	case "Deployment":
+		unit = &pkg.Deployment{}
+	case "Ingress":
+		unit = &pkg.Ingress{}
+	case "Job":
+		unit = &pkg.Job{}
+	case "Secret":
+		unit = &pkg.Secret{}
+	case "Service":
+		unit = &pkg.Service{}
+	default:
+		return nil, maskAny(fmt.Errorf("Unit '%s' has unknown kind '%s'", name, meta.Kind))
+	}
+	if err := json.Unmarshal([]byte(content), unit); err != nil {
+		return nil, maskAny(err)
+	}
+	return unit, nil
+}
+
+// historyConfigMapName returns the name of the config map holding the history entry with given revision.
+func historyConfigMapName(revision int) string {
+	return fmt.Sprintf("j2-history-%d", revision)
+}
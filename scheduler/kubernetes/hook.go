@@ -0,0 +1,92 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	k8s "github.com/YakLabs/k8s-client"
+	"github.com/juju/errgo"
+
+	pkg "github.com/pulcy/j2/pkg/kubernetes"
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+// GetCompletion returns the state of a unit that runs to completion.
+// Jobs are completed once one of their pods succeeded or failed, other kinds of units
+// are completed as soon as they are in a valid state.
+func (s *k8sScheduler) GetCompletion(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
+	j, ok := unit.(*pkg.Job)
+	if !ok {
+		return s.GetState(ctx, unit)
+	}
+	current, err := s.client.GetJob(j.Namespace(), j.Name())
+	if k8s.IsNotFoundError(errgo.Cause(err)) {
+		return scheduler.UnitState{}, maskAny(errgo.WithCausef(nil, scheduler.NotFoundError, "%s", unit.Name()))
+	} else if err != nil {
+		return scheduler.UnitState{}, maskAny(err)
+	}
+	// Node names are informational only, do not fail on them
+	nodes, _ := s.getNodeNames(j)
+	state := scheduler.UnitState{Machine: nodes}
+	if status := current.Status; status != nil {
+		state.Healthy = status.Succeeded > 0
+		state.Failed = status.Failed > 0 && !state.Healthy
+		state.Message = fmt.Sprintf("%d pods active, %d succeeded, %d failed", status.Active, status.Succeeded, status.Failed)
+	}
+	return state, nil
+}
+
+// Output returns the termination state of the containers of the pods created for the given unit.
+// The kubernetes client does not provide access to the logs of a container, so the
+// termination message (written to /dev/termination-log) is the only output available.
+// Units other than jobs result in an empty string.
+func (s *k8sScheduler) Output(ctx context.Context, unit scheduler.Unit, lines int) (string, error) {
+	j, ok := unit.(*pkg.Job)
+	if !ok {
+		return "", nil
+	}
+	labelSelector := j.ObjectMeta().GetLabels()
+	list, err := s.client.ListPods(j.Namespace(), &k8s.ListOptions{LabelSelector: k8s.LabelSelector{MatchLabels: labelSelector}})
+	if err != nil {
+		return "", maskAny(err)
+	}
+	var result []string
+	for _, p := range list.Items {
+		if p.Status == nil {
+			continue
+		}
+		for _, cs := range p.Status.ContainerStatuses {
+			if cs.State == nil || cs.State.Terminated == nil {
+				continue
+			}
+			t := cs.State.Terminated
+			line := fmt.Sprintf("%s/%s: exit code %d", p.Name, cs.Name, t.ExitCode)
+			if t.Reason != "" {
+				line = fmt.Sprintf("%s (%s)", line, t.Reason)
+			}
+			result = append(result, line)
+			if msg := strings.TrimSpace(t.Message); msg != "" {
+				result = append(result, strings.Split(msg, "\n")...)
+			}
+		}
+	}
+	if len(result) > lines {
+		result = result[len(result)-lines:]
+	}
+	return strings.Join(result, "\n"), nil
+}
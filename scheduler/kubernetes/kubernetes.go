@@ -25,6 +25,7 @@ import (
 	"github.com/pulcy/j2/jobs"
 	pkg "github.com/pulcy/j2/pkg/kubernetes"
 	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -40,8 +41,8 @@ type Unit interface {
 	GetCurrent(cs k8s.Client) (interface{}, error)
 	IsEqual(interface{}) ([]string, bool, error)
 	IsValidState(cs k8s.Client) (bool, string, error)
-	Start(cs k8s.Client, events chan string) error
-	Destroy(cs k8s.Client, events chan string) error
+	Start(ctx context.Context, cs k8s.Client, events chan string) error
+	Destroy(ctx context.Context, cs k8s.Client, events chan string) error
 }
 
 // NewScheduler creates a new kubernetes implementation of scheduler.Scheduler.
@@ -67,7 +68,7 @@ type k8sScheduler struct {
 }
 
 // List returns the names of all units on the cluster
-func (s *k8sScheduler) List() ([]scheduler.Unit, error) {
+func (s *k8sScheduler) List(ctx context.Context) ([]scheduler.Unit, error) {
 	var units []scheduler.Unit
 	if list, err := s.listDeployments(); err != nil {
 		return nil, maskAny(err)
@@ -103,7 +104,7 @@ func (s *k8sScheduler) List() ([]scheduler.Unit, error) {
 	return units, nil
 }
 
-func (s *k8sScheduler) GetState(unit scheduler.Unit) (scheduler.UnitState, error) {
+func (s *k8sScheduler) GetState(ctx context.Context, unit scheduler.Unit) (scheduler.UnitState, error) {
 	ku, ok := unit.(Unit)
 	if !ok {
 		return scheduler.UnitState{}, maskAny(fmt.Errorf("Expected unit '%s' to implement Kubernetes.Unit", unit.Name()))
@@ -112,23 +113,68 @@ func (s *k8sScheduler) GetState(unit scheduler.Unit) (scheduler.UnitState, error
 	if err != nil {
 		return scheduler.UnitState{}, maskAny(err)
 	}
+	// Node names are informational only, do not fail on them
+	nodes, _ := s.getNodeNames(ku)
 	state := scheduler.UnitState{
 		Failed:  !ok,
+		Healthy: ok,
 		Message: msg,
+		Machine: nodes,
 	}
 	return state, nil
 }
 
-func (s *k8sScheduler) Cat(unit scheduler.Unit) (string, error) {
+// GetCurrent returns the given unit as it exists on the cluster.
+// Server generated metadata is removed, so the result can be used to re-create the unit.
+func (s *k8sScheduler) GetCurrent(ctx context.Context, unit scheduler.Unit) (scheduler.UnitData, error) {
+	ku, ok := unit.(Unit)
+	if !ok {
+		return nil, maskAny(fmt.Errorf("Expected unit '%s' to implement Kubernetes.Unit", unit.Name()))
+	}
+	current, err := ku.GetCurrent(s.client)
+	if k8s.IsNotFoundError(errgo.Cause(err)) {
+		return nil, maskAny(errgo.WithCausef(nil, scheduler.NotFoundError, "%s", unit.Name()))
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	cu, ok := current.(Unit)
+	if !ok {
+		return nil, maskAny(fmt.Errorf("Expected current unit '%s' to implement Kubernetes.Unit", unit.Name()))
+	}
+	meta := cu.ObjectMeta()
+	meta.UID = ""
+	meta.ResourceVersion = ""
+	meta.SelfLink = ""
+	meta.CreationTimestamp = nil
+	meta.Generation = 0
+	return cu, nil
+}
+
+// Cat returns the content of the given unit as it exists on the cluster.
+// The content is returned as normalized YAML.
+func (s *k8sScheduler) Cat(ctx context.Context, unit scheduler.Unit) (string, error) {
 	ku, ok := unit.(Unit)
 	if !ok {
 		return "", maskAny(fmt.Errorf("Expected unit '%s' to implement Kubernetes.Unit", unit.Name()))
 	}
-	return ku.Content(), nil
+	current, err := ku.GetCurrent(s.client)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	cu, ok := current.(scheduler.UnitData)
+	if !ok {
+		return "", maskAny(fmt.Errorf("Expected current unit '%s' to implement scheduler.UnitData", unit.Name()))
+	}
+	return normalizeContent(cu)
+}
+
+// NormalizeContent returns the content of the given unit as normalized YAML.
+func (s *k8sScheduler) NormalizeContent(unit scheduler.UnitData) (string, error) {
+	return normalizeContent(unit)
 }
 
 // HasChanged returns true when the given unit is different on the system
-func (s *k8sScheduler) HasChanged(unit scheduler.UnitData) ([]string, bool, error) {
+func (s *k8sScheduler) HasChanged(ctx context.Context, unit scheduler.UnitData) ([]string, bool, error) {
 	//fmt.Fprintf(os.Stderr, "HasChanged(%s)\n", unit.Name())
 	ku, ok := unit.(Unit)
 	if !ok {
@@ -145,14 +191,14 @@ func (s *k8sScheduler) HasChanged(unit scheduler.UnitData) ([]string, bool, erro
 	return diffs, !eq, nil
 }
 
-func (s *k8sScheduler) Stop(events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) (scheduler.StopStats, error) {
+func (s *k8sScheduler) Stop(ctx context.Context, events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) (scheduler.StopStats, error) {
 	return scheduler.StopStats{
 		StoppedUnits:       len(units),
 		StoppedGlobalUnits: 0,
 	}, nil
 }
 
-func (s *k8sScheduler) Destroy(events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) error {
+func (s *k8sScheduler) Destroy(ctx context.Context, events chan scheduler.Event, reason scheduler.Reason, units ...scheduler.Unit) error {
 	if reason != scheduler.ReasonObsolete {
 		return nil
 	}
@@ -173,7 +219,7 @@ func (s *k8sScheduler) Destroy(events chan scheduler.Event, reason scheduler.Rea
 					}
 				}
 			}()
-			if err := ku.Destroy(s.client, destroyEvents); err != nil {
+			if err := ku.Destroy(ctx, s.client, destroyEvents); err != nil {
 				return maskAny(err)
 			}
 			close(destroyEvents)
@@ -203,7 +249,7 @@ func (s *k8sScheduler) ensureNamespace(namespace string) error {
 	return nil
 }
 
-func (s *k8sScheduler) Start(events chan scheduler.Event, units scheduler.UnitDataList) error {
+func (s *k8sScheduler) Start(ctx context.Context, events chan scheduler.Event, units scheduler.UnitDataList) error {
 	g := errgroup.Group{}
 	for i := 0; i < units.Len(); i++ {
 		unit := units.Get(i)
@@ -229,7 +275,7 @@ func (s *k8sScheduler) Start(events chan scheduler.Event, units scheduler.UnitDa
 					}
 				}
 			}()
-			if err := ku.Start(s.client, startEvents); err != nil {
+			if err := ku.Start(ctx, s.client, startEvents); err != nil {
 				return maskAny(err)
 			}
 			close(startEvents)
@@ -281,6 +327,35 @@ func (s *k8sScheduler) IsUnitForTaskGroup(unit scheduler.Unit, g jobs.TaskGroupN
 	}
 }
 
+// UpdatesInPlace returns true, since starting an existing resource updates it.
+func (s *k8sScheduler) UpdatesInPlace() bool {
+	return true
+}
+
+// DescribeUnit returns the kind, namespace & name of the resource of the given unit.
+func (s *k8sScheduler) DescribeUnit(unit scheduler.Unit) string {
+	ku, ok := unit.(Unit)
+	if !ok {
+		return unit.Name()
+	}
+	kind := "resource"
+	switch unit.(type) {
+	case *pkg.DaemonSet:
+		kind = "daemonset"
+	case *pkg.Deployment:
+		kind = "deployment"
+	case *pkg.Ingress:
+		kind = "ingress"
+	case *pkg.Job:
+		kind = "job"
+	case *pkg.Secret:
+		kind = "secret"
+	case *pkg.Service:
+		kind = "service"
+	}
+	return fmt.Sprintf("%s %s/%s", kind, ku.Namespace(), unit.Name())
+}
+
 func (s *k8sScheduler) UpdateStopDelay(d time.Duration) time.Duration {
 	// Stopping is done by Kubernetes, do not wait for it
 	return time.Duration(0)
@@ -0,0 +1,221 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"time"
+
+	k8s "github.com/YakLabs/k8s-client"
+	"github.com/juju/errgo"
+	"github.com/pkg/errors"
+
+	"github.com/pulcy/j2/scheduler"
+	"golang.org/x/net/context"
+)
+
+const (
+	// lockConfigMapName is the name of the config map (in the namespace of the job) that holds the deployment lock.
+	lockConfigMapName = "j2-lock"
+	// lockAnnotation is the annotation of the lock config map containing the JSON encoded lock info.
+	lockAnnotation = "j2.pulcy.com/lock"
+)
+
+// Lock acquires the deployment lock of the job for the given holder.
+// The lock is stored as an annotation of a config map in the namespace of the job.
+// Since kubernetes does not expire such a lock itself, a lock that is past its
+// expiration time is considered free.
+func (s *k8sScheduler) Lock(ctx context.Context, holder string, ttl time.Duration, steal bool) (scheduler.Lease, error) {
+	if err := s.ensureNamespace(s.defaultNamespace); err != nil {
+		return nil, maskAny(err)
+	}
+	for attempt := 0; attempt < scheduler.LockAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, maskAny(err)
+		}
+		l, retry, err := s.tryLock(holder, ttl, steal)
+		if !retry {
+			return l, maskAny(err)
+		}
+	}
+	return nil, maskAny(errgo.WithCausef(nil, scheduler.LockedError, "lock of job '%s' keeps changing, giving up after %d attempts", s.job.Name, scheduler.LockAttempts))
+}
+
+// tryLock makes a single attempt to acquire the deployment lock of the job.
+// It returns true when the lock changed during the attempt, so it must be tried again.
+func (s *k8sScheduler) tryLock(holder string, ttl time.Duration, steal bool) (scheduler.Lease, bool, error) {
+	l := &k8sLease{
+		client:    s.client,
+		namespace: s.defaultNamespace,
+		ttl:       ttl,
+		info:      scheduler.NewLockInfo(holder, ttl),
+	}
+
+	cm, err := s.client.GetConfigMap(l.namespace, lockConfigMapName)
+	if k8s.IsNotFoundError(err) {
+		cm = k8s.NewConfigMap(l.namespace, lockConfigMapName)
+		if err := l.store(cm); err != nil {
+			return nil, false, maskAny(err)
+		}
+		created, err := s.client.CreateConfigMap(l.namespace, cm)
+		if isConflictError(err) {
+			// Someone else was faster, try again
+			return nil, true, nil
+		} else if err != nil {
+			return nil, false, maskAny(err)
+		}
+		l.resourceVersion = created.ResourceVersion
+		return l, false, nil
+	} else if err != nil {
+		return nil, false, maskAny(err)
+	}
+
+	current, found, err := parseLockInfo(cm)
+	if err != nil {
+		return nil, false, maskAny(err)
+	}
+	if found && !current.IsExpired() {
+		if !steal {
+			return nil, false, maskAny(errgo.WithCausef(nil, scheduler.LockedError, "job '%s' is locked by %s", s.job.Name, current))
+		}
+		l.stolen = &current
+	}
+	if err := l.store(cm); err != nil {
+		return nil, false, maskAny(err)
+	}
+	// The update fails with a conflict when someone else changed the lock in the meantime
+	updated, err := s.client.UpdateConfigMap(l.namespace, cm)
+	if isConflictError(err) {
+		return nil, true, nil
+	} else if err != nil {
+		return nil, false, maskAny(err)
+	}
+	l.resourceVersion = updated.ResourceVersion
+	return l, false, nil
+}
+
+type k8sLease struct {
+	client          k8s.Client
+	namespace       string
+	ttl             time.Duration
+	info            scheduler.LockInfo
+	stolen          *scheduler.LockInfo
+	resourceVersion string
+}
+
+// Info returns the description of this lease.
+func (l *k8sLease) Info() scheduler.LockInfo {
+	return l.info
+}
+
+// Stolen returns the lock that was taken over when this lease was acquired (if any).
+func (l *k8sLease) Stolen() *scheduler.LockInfo {
+	return l.stolen
+}
+
+// Renew extends the lease with its TTL.
+func (l *k8sLease) Renew(ctx context.Context) error {
+	cm, err := l.getOwnConfigMap()
+	if err != nil {
+		return maskAny(err)
+	}
+	l.info.Expires = time.Now().Add(l.ttl)
+	if err := l.store(cm); err != nil {
+		return maskAny(err)
+	}
+	updated, err := l.client.UpdateConfigMap(l.namespace, cm)
+	if isConflictError(err) {
+		return maskAny(l.lostError())
+	} else if err != nil {
+		return maskAny(err)
+	}
+	l.resourceVersion = updated.ResourceVersion
+	return nil
+}
+
+// Release gives up the lock, if it is still held by this lease.
+// The lock annotation is removed (instead of deleting the config map), so the update fails
+// with a conflict when the lock has been taken over since it was fetched.
+func (l *k8sLease) Release(ctx context.Context) error {
+	cm, err := l.getOwnConfigMap()
+	if scheduler.IsLockLost(err) {
+		return nil
+	} else if err != nil {
+		return maskAny(err)
+	}
+	delete(cm.Annotations, lockAnnotation)
+	if _, err := l.client.UpdateConfigMap(l.namespace, cm); isConflictError(err) || k8s.IsNotFoundError(err) {
+		return nil
+	} else if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// getOwnConfigMap fetches the lock config map and checks that it is still
+// held by this lease.
+func (l *k8sLease) getOwnConfigMap() (*k8s.ConfigMap, error) {
+	cm, err := l.client.GetConfigMap(l.namespace, lockConfigMapName)
+	if k8s.IsNotFoundError(err) {
+		return nil, maskAny(l.lostError())
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	current, found, err := parseLockInfo(cm)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if !found || current.Token != l.info.Token {
+		return nil, maskAny(l.lostError())
+	}
+	return cm, nil
+}
+
+// store puts the info of this lease in the annotations of the given config map.
+func (l *k8sLease) store(cm *k8s.ConfigMap) error {
+	raw, err := json.Marshal(l.info)
+	if err != nil {
+		return maskAny(err)
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	cm.Annotations[lockAnnotation] = string(raw)
+	return nil
+}
+
+func (l *k8sLease) lostError() error {
+	return errgo.WithCausef(nil, scheduler.LockLostError, "lock in namespace '%s' is no longer held by %s", l.namespace, l.info.Holder)
+}
+
+// parseLockInfo extracts the lock info from the annotations of the given config map.
+func parseLockInfo(cm *k8s.ConfigMap) (scheduler.LockInfo, bool, error) {
+	raw, found := cm.Annotations[lockAnnotation]
+	if !found || raw == "" {
+		return scheduler.LockInfo{}, false, nil
+	}
+	var info scheduler.LockInfo
+	if err := json.Unmarshal([]byte(raw), &info); err != nil {
+		return scheduler.LockInfo{}, false, maskAny(err)
+	}
+	return info, true, nil
+}
+
+// isConflictError returns true if the given error is caused by a
+// concurrent modification of a resource.
+func isConflictError(err error) bool {
+	s, ok := errors.Cause(err).(*k8s.Status)
+	return ok && s.Code == 409
+}
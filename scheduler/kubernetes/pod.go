@@ -0,0 +1,54 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"sort"
+	"strings"
+
+	k8s "github.com/YakLabs/k8s-client"
+
+	pkg "github.com/pulcy/j2/pkg/kubernetes"
+)
+
+// getNodeNames returns a comma separated list of the names of the nodes
+// on which the pods of the given unit are running.
+// Units that do not create pods result in an empty string.
+func (s *k8sScheduler) getNodeNames(ku Unit) (string, error) {
+	switch ku.(type) {
+	case *pkg.Deployment, *pkg.DaemonSet, *pkg.Job:
+		// These create pods
+	default:
+		return "", nil
+	}
+	labelSelector := ku.ObjectMeta().GetLabels()
+	list, err := s.client.ListPods(ku.Namespace(), &k8s.ListOptions{LabelSelector: k8s.LabelSelector{MatchLabels: labelSelector}})
+	if err != nil {
+		return "", maskAny(err)
+	}
+	found := make(map[string]struct{})
+	var names []string
+	for _, p := range list.Items {
+		if p.Spec == nil || p.Spec.NodeName == "" {
+			continue
+		}
+		if _, ok := found[p.Spec.NodeName]; !ok {
+			found[p.Spec.NodeName] = struct{}{}
+			names = append(names, p.Spec.NodeName)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ","), nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// LockAttempts is the maximum number of attempts to acquire a deployment lock that is
+// changed by someone else while it is being acquired.
+const LockAttempts = 5
+
+// LockInfo describes the holder of the deployment lock of a job.
+type LockInfo struct {
+	Holder  string    `json:"holder"`  // Who holds the lock (user@host)
+	Since   time.Time `json:"since"`   // When the lock was acquired
+	Expires time.Time `json:"expires"` // When the lock expires, unless it is renewed
+	Token   string    `json:"token"`   // Unique identifier of the lease
+}
+
+// String returns a human readable description of the lock holder.
+func (i LockInfo) String() string {
+	return fmt.Sprintf("%s since %s", i.Holder, i.Since.Local().Format(time.RFC1123))
+}
+
+// IsExpired returns true if the lock has not been renewed in time.
+func (i LockInfo) IsExpired() bool {
+	return !i.Expires.IsZero() && time.Now().After(i.Expires)
+}
+
+// Lease is an acquired deployment lock of a job.
+type Lease interface {
+	// Info returns the description of this lease.
+	Info() LockInfo
+	// Stolen returns the lock that was taken over when this lease was acquired (if any).
+	Stolen() *LockInfo
+	// Renew extends the lease with its TTL.
+	// If the lock has been taken over by someone else, a LockLostError is returned.
+	Renew(ctx context.Context) error
+	// Release gives up the lock, if it is still held by this lease.
+	Release(ctx context.Context) error
+}
+
+// NewLockInfo creates the description of a new lease for the given holder.
+func NewLockInfo(holder string, ttl time.Duration) LockInfo {
+	now := time.Now()
+	return LockInfo{
+		Holder:  holder,
+		Since:   now,
+		Expires: now.Add(ttl),
+		Token:   fmt.Sprintf("%s-%d", holder, now.UnixNano()),
+	}
+}
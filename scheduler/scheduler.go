@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/pulcy/j2/jobs"
+	"golang.org/x/net/context"
 )
 
 type Reason int
@@ -30,24 +31,35 @@ const (
 
 type Scheduler interface {
 	// ValidateCluster checks if the cluster is suitable to run the configured job.
-	ValidateCluster() error
+	ValidateCluster(ctx context.Context) error
 
 	// ConfigureCluster configures the cluster for use by J2.
-	ConfigureCluster(config ClusterConfig) error
+	ConfigureCluster(ctx context.Context, config ClusterConfig) error
 
 	// List returns the names of all units on the cluster
-	List() ([]Unit, error)
+	List(ctx context.Context) ([]Unit, error)
 
-	GetState(Unit) (UnitState, error)
-	//	Cat(Unit) (string, error)
+	GetState(ctx context.Context, unit Unit) (UnitState, error)
+
+	// Cat returns the content of the given unit as it exists on the cluster.
+	// The content is normalized in the same way as NormalizeContent does.
+	Cat(ctx context.Context, unit Unit) (string, error)
+
+	// GetCurrent returns the given unit as it exists on the cluster, in a form that
+	// can be passed to Start to restore it.
+	GetCurrent(ctx context.Context, unit Unit) (UnitData, error)
+
+	// NormalizeContent returns the content of the given (rendered) unit in a form
+	// that can be compared line by line with the result of Cat.
+	NormalizeContent(UnitData) (string, error)
 
 	// HasChanged returns true when the given unit is different on the system (or does not exist on the system)
-	HasChanged(UnitData) ([]string, bool, error)
+	HasChanged(ctx context.Context, unit UnitData) ([]string, bool, error)
 
-	Stop(events chan Event, reason Reason, units ...Unit) (StopStats, error)
-	Destroy(events chan Event, reason Reason, units ...Unit) error
+	Stop(ctx context.Context, events chan Event, reason Reason, units ...Unit) (StopStats, error)
+	Destroy(ctx context.Context, events chan Event, reason Reason, units ...Unit) error
 
-	Start(events chan Event, units UnitDataList) error
+	Start(ctx context.Context, events chan Event, units UnitDataList) error
 
 	IsUnitForScalingGroup(unit Unit, scalingGroup uint) bool
 	IsUnitForJob(unit Unit) bool
@@ -55,6 +67,31 @@ type Scheduler interface {
 
 	UpdateStopDelay(time.Duration) time.Duration
 	UpdateDestroyDelay(time.Duration) time.Duration
+
+	// Lock acquires the deployment lock of the job for the given holder.
+	// The lock expires after the given TTL, unless the lease is renewed.
+	// If the lock is held by someone else, a LockedError is returned, unless steal is set.
+	Lock(ctx context.Context, holder string, ttl time.Duration, steal bool) (Lease, error)
+
+	// AddHistory stores the given entry in the deployment history of the job.
+	// It returns the entry with its revision set.
+	AddHistory(ctx context.Context, entry HistoryEntry) (HistoryEntry, error)
+
+	// History returns the deployment history of the job, oldest entry first.
+	History(ctx context.Context) ([]HistoryEntry, error)
+
+	// ParseUnit reconstructs a unit from the name & content of a unit in the deployment history,
+	// in a form that can be passed to Start.
+	ParseUnit(name, content string) (UnitData, error)
+
+	// GetCompletion returns the state of a unit that runs to completion, such as a hook.
+	// Healthy is set once the unit has completed successfully, Failed once it has failed.
+	// Neither is set while the unit has not yet completed.
+	GetCompletion(ctx context.Context, unit Unit) (UnitState, error)
+
+	// Output returns the last lines of output of the given unit, as far as available.
+	// Units that do not run a task of their own result in an empty string.
+	Output(ctx context.Context, unit Unit, lines int) (string, error)
 }
 
 type ClusterConfig interface {
@@ -70,7 +107,32 @@ type VaultConfig interface {
 
 type UnitState struct {
 	Failed  bool
+	Healthy bool // Unit is up & running as intended
 	Message string
+	Machine string // Machine (or node) the unit is running on (if known)
+}
+
+// HTTPHealthChecker is implemented by schedulers that can check the health of a unit
+// by performing an HTTP request on the `http-check-path` of its task.
+type HTTPHealthChecker interface {
+	// CheckHTTPHealth returns the state of the given unit, where Healthy is based on
+	// the response of the http check of its task.
+	// Units without an http check are checked using GetState.
+	CheckHTTPHealth(ctx context.Context, unit Unit) (UnitState, error)
+}
+
+// InPlaceUpdater is implemented by schedulers that update existing units when they are started,
+// so these units do not have to be destroyed first.
+type InPlaceUpdater interface {
+	// UpdatesInPlace returns true if Start replaces existing units without interruption.
+	UpdatesInPlace() bool
+}
+
+// UnitDescriber is implemented by schedulers that can describe the resource behind a unit
+// in more detail than its name.
+type UnitDescriber interface {
+	// DescribeUnit returns a description of the given unit, such as its kind & namespace.
+	DescribeUnit(unit Unit) string
 }
 
 type StopStats struct {
@@ -0,0 +1,280 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/j2/cluster"
+	"github.com/pulcy/j2/deployment"
+	"github.com/pulcy/j2/extpoints"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+	"github.com/pulcy/j2/stack"
+)
+
+var (
+	stackCmd = &cobra.Command{
+		Use:   "stack",
+		Short: "Commands operating on all jobs of a stack file.",
+		Run:   showUsage,
+	}
+	stackRunCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Create or update all jobs of a stack file.",
+		Long:  "Create or update all jobs of a stack file, in dependency order. Stops at the first job that fails.",
+		Run:   stackRunRun,
+	}
+	stackPlanCmd = &cobra.Command{
+		Use:   "plan",
+		Short: "Show the actions needed to deploy all jobs of a stack file.",
+		Long:  "Show the actions needed to deploy all jobs of a stack file, in dependency order.",
+		Run:   stackPlanRun,
+	}
+	stackDestroyCmd = &cobra.Command{
+		Use:   "destroy",
+		Short: "Destroy all jobs of a stack file.",
+		Long:  "Destroy all jobs of a stack file, in reverse dependency order. Stops at the first job that fails.",
+		Run:   stackDestroyRun,
+	}
+	stackStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of all jobs of a stack file.",
+		Long:  "Show the state of all units of all jobs of a stack file and whether they differ from their rendered version.",
+		Run:   stackStatusRun,
+	}
+	stackFlags struct {
+		fg.Flags
+		stackPath string
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{stackRunCmd, stackPlanCmd, stackDestroyCmd, stackStatusCmd} {
+		initDeploymentFlags(cmd.Flags(), &stackFlags.Flags)
+		cmd.Flags().StringVarP(&stackFlags.stackPath, "stack", "s", "", "filename of the stack description")
+		stackCmd.AddCommand(cmd)
+	}
+}
+
+// stackJob is a job of a stack, parsed for deployment.
+type stackJob struct {
+	stack.JobRef
+	job *jobs.Job
+}
+
+// stackResult holds the outcome of a command for a single job of a stack.
+type stackResult struct {
+	name   string
+	result string
+	failed bool
+}
+
+func stackRunRun(cmd *cobra.Command, args []string) {
+	runValidators(&stackFlags.Flags)
+	s, cluster, orchestrator, list := loadStack(cmd.Flags(), args)
+	results := forEachStackJob(list, func(sj stackJob) (string, error) {
+		d := newStackDeployment(cmd.Flags(), sj, *cluster, orchestrator)
+		if stackFlags.DryRun {
+			return "reviewed", maskAny(d.DryRun())
+		}
+		return "deployed", maskAny(d.Run(rootCtx))
+	})
+	printStackSummary(s, cluster.Stack, results)
+}
+
+func stackPlanRun(cmd *cobra.Command, args []string) {
+	s, cluster, orchestrator, list := loadStack(cmd.Flags(), args)
+	results := forEachStackJob(list, func(sj stackJob) (string, error) {
+		d := newStackDeployment(cmd.Flags(), sj, *cluster, orchestrator)
+		plan, err := d.Plan(rootCtx)
+		if err != nil {
+			return "", maskAny(err)
+		}
+		fmt.Printf("Job '%s':\n", sj.Name)
+		printPlan(plan)
+		fmt.Println()
+		if plan.HasChanges() {
+			return "has changes", nil
+		}
+		return "up to date", nil
+	})
+	printStackSummary(s, cluster.Stack, results)
+}
+
+func stackDestroyRun(cmd *cobra.Command, args []string) {
+	s, cluster, orchestrator, list := loadStack(cmd.Flags(), args)
+	// Destroy jobs before the jobs they depend on
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	results := forEachStackJob(list, func(sj stackJob) (string, error) {
+		d := newStackDeployment(cmd.Flags(), sj, *cluster, orchestrator)
+		if stackFlags.DryRun {
+			return "reviewed", maskAny(d.Destroy(rootCtx, deployment.DestroyOptions{DryRun: true}))
+		}
+		return "destroyed", maskAny(d.Destroy(rootCtx, deployment.DestroyOptions{}))
+	})
+	printStackSummary(s, cluster.Stack, results)
+}
+
+func stackStatusRun(cmd *cobra.Command, args []string) {
+	s, cluster, orchestrator, list := loadStack(cmd.Flags(), args)
+	results := forEachStackJob(list, func(sj stackJob) (string, error) {
+		d := newStackDeployment(cmd.Flags(), sj, *cluster, orchestrator)
+		units, err := d.Status(rootCtx)
+		if err != nil {
+			return "", maskAny(err)
+		}
+		fmt.Printf("Job '%s':\n", sj.Name)
+		printStatus(sj.job.Name, cluster.Stack, units)
+		changed := 0
+		for _, u := range units {
+			if u.Changed || u.Obsolete {
+				changed++
+			}
+		}
+		if changed > 0 {
+			return fmt.Sprintf("%d of %d unit(s) differ", changed, len(units)), nil
+		}
+		return fmt.Sprintf("%d unit(s) up to date", len(units)), nil
+	})
+	printStackSummary(s, cluster.Stack, results)
+}
+
+// loadStack loads the stack file, the cluster and all jobs of the stack.
+// The jobs are returned in dependency order.
+func loadStack(fs *pflag.FlagSet, args []string) (*stack.Stack, *cluster.Cluster, extpoints.Orchestrator, []stackJob) {
+	deploymentDefaults(fs, &stackFlags.Flags, nil)
+	if stackFlags.stackPath == "" && len(args) >= 1 {
+		stackFlags.stackPath = args[0]
+	}
+	if stackFlags.ClusterPath == "" && len(args) >= 2 {
+		stackFlags.ClusterPath = args[1]
+	}
+	if stackFlags.stackPath == "" {
+		Exitf("--stack missing\n")
+	}
+
+	s, err := stack.ParseStackFromFile(stackFlags.stackPath)
+	if err != nil {
+		Exitf("Cannot load stack: %v\n", err)
+	}
+	cluster, err := loadCluster(&stackFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+
+	// Load all jobs
+	parsed := make(map[string]stackJob)
+	jobNames := make(map[jobs.JobName]string)
+	for _, ref := range s.Jobs {
+		f := stackFlags.Flags
+		f.JobPath = ref.Path
+		f.Options = stackFlags.Options.With(ref.Options)
+		job, err := loadJob(&f, *cluster, orchestrator)
+		if err != nil {
+			Exitf("Cannot load job '%s': %v\n", ref.Name, err)
+		}
+		parsed[ref.Name] = stackJob{JobRef: ref, job: job}
+		jobNames[job.Name] = ref.Name
+	}
+
+	// Infer dependencies from links to other jobs
+	inferred := make(map[string][]string)
+	for name, sj := range parsed {
+		for _, jn := range sj.job.LinkedJobs() {
+			if dep, ok := jobNames[jn]; ok {
+				inferred[name] = append(inferred[name], dep)
+			}
+		}
+	}
+	ordered, err := s.Order(inferred)
+	if err != nil {
+		Exitf("Cannot order jobs of stack: %v\n", err)
+	}
+	var list []stackJob
+	for _, ref := range ordered {
+		list = append(list, parsed[ref.Name])
+	}
+	return s, cluster, orchestrator, list
+}
+
+// newStackDeployment creates a deployment for the given job of a stack.
+func newStackDeployment(fs *pflag.FlagSet, sj stackJob, cluster cluster.Cluster, orchestrator extpoints.Orchestrator) *deployment.Deployment {
+	delays := deployment.DeploymentDelays{
+		StopDelay:    stackFlags.StopDelay,
+		DestroyDelay: stackFlags.DestroyDelay,
+		SliceDelay:   stackFlags.SliceDelay,
+	}
+	d, err := deployment.NewDeployment(orchestrator, *sj.job, cluster,
+		groups(&stackFlags.Flags),
+		deployment.ScalingGroupSelection(stackFlags.ScalingGroup),
+		stackFlags.Force,
+		stackFlags.AutoContinue,
+		globalFlags.verbose,
+		delays,
+		healthCheckConfig(&stackFlags.Flags),
+		rolloutOptions(fs, &stackFlags.Flags, cluster),
+		renderCtx)
+	assert(err)
+	return d
+}
+
+// forEachStackJob calls the given action for all given jobs, until an action fails.
+// Jobs after the failing job are reported as skipped.
+func forEachStackJob(list []stackJob, action func(stackJob) (string, error)) []stackResult {
+	var results []stackResult
+	failed := false
+	for _, sj := range list {
+		if failed {
+			results = append(results, stackResult{name: sj.Name, result: "skipped"})
+			continue
+		}
+		result, err := action(sj)
+		if err != nil {
+			failed = true
+			results = append(results, stackResult{name: sj.Name, result: fmt.Sprintf("failed: %v", err), failed: true})
+			continue
+		}
+		results = append(results, stackResult{name: sj.Name, result: result})
+	}
+	return results
+}
+
+// printStackSummary prints the outcome for every job of the stack.
+// It exits with a non-zero status code when a job failed.
+func printStackSummary(s *stack.Stack, clusterStack string, results []stackResult) {
+	lines := []string{"Job | Result"}
+	failed := false
+	for _, r := range results {
+		lines = append(lines, fmt.Sprintf("%s | %s", r.name, r.result))
+		failed = failed || r.failed
+	}
+	fmt.Printf("Summary of stack '%s' on '%s':\n", s.Name, clusterStack)
+	fmt.Println(columnize.SimpleFormat(lines))
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/pkg/hclutil"
+)
+
+var (
+	// Keys that are parsed separately (not by hclutil.Decode)
+	jobBlockKeys = []string{"depends-on", "options"}
+)
+
+// ParseStackFromFile reads a stack from file.
+// Paths of job files are made relative to the directory of the stack file.
+func ParseStackFromFile(path string) (*Stack, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	// Parse the input
+	root, err := hcl.Parse(string(data))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	// Top-level item should be a list
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errgo.New("error parsing: root should be an object")
+	}
+	matches := list.Filter("stack")
+	if len(matches.Items) == 0 {
+		return nil, errgo.New("'stack' stanza not found")
+	}
+
+	// Parse hcl into Stack
+	s := &Stack{}
+	if err := s.parse(matches); err != nil {
+		return nil, maskAny(err)
+	}
+
+	// Resolve job paths
+	dir := filepath.Dir(path)
+	for i, j := range s.Jobs {
+		if j.Path == "" {
+			j.Path = j.Name + ".hcl"
+		}
+		if !filepath.IsAbs(j.Path) {
+			j.Path = filepath.Join(dir, j.Path)
+		}
+		s.Jobs[i] = j
+	}
+
+	// Validate the stack
+	if err := s.validate(); err != nil {
+		return nil, maskAny(err)
+	}
+
+	return s, nil
+}
+
+// Parse a Stack
+func (s *Stack) parse(list *ast.ObjectList) error {
+	list = list.Children()
+	if len(list.Items) != 1 {
+		return fmt.Errorf("only one 'stack' block allowed")
+	}
+
+	// Get our stack object
+	obj := list.Items[0]
+	s.Name = obj.Keys[0].Token.Value().(string)
+
+	// Value should be an object
+	var listVal *ast.ObjectList
+	if ot, ok := obj.Val.(*ast.ObjectType); ok {
+		listVal = ot.List
+	} else {
+		return errgo.Newf("stack '%s' value: should be an object", s.Name)
+	}
+	for _, item := range listVal.Items {
+		if len(item.Keys) == 0 || item.Keys[0].Token.Value() != "job" {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "stack '%s' can only contain 'job' blocks", s.Name))
+		}
+	}
+
+	// Parse jobs
+	for _, o := range listVal.Filter("job").Items {
+		if len(o.Keys) != 1 {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "job in stack '%s' must have exactly one name", s.Name))
+		}
+		obj, ok := o.Val.(*ast.ObjectType)
+		if !ok {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "job of stack '%s' is not an object", s.Name))
+		}
+		j := JobRef{Name: o.Keys[0].Token.Value().(string)}
+		if err := j.parse(obj); err != nil {
+			return maskAny(err)
+		}
+		s.Jobs = append(s.Jobs, j)
+	}
+
+	return nil
+}
+
+// parse a JobRef
+func (j *JobRef) parse(obj *ast.ObjectType) error {
+	// Parse the object
+	if err := hclutil.Decode(obj, jobBlockKeys, nil, j); err != nil {
+		return maskAny(err)
+	}
+	// Parse depends-on
+	if o := obj.List.Filter("depends-on"); len(o.Items) > 0 {
+		list, err := hclutil.ParseStringList(o, fmt.Sprintf("depends-on of job '%s'", j.Name))
+		if err != nil {
+			return maskAny(err)
+		}
+		j.DependsOn = append(j.DependsOn, list...)
+	}
+	// Parse options
+	if o := obj.List.Filter("options"); len(o.Items) > 0 {
+		for _, o := range o.Elem().Items {
+			var m map[string]interface{}
+			if err := hcl.DecodeObject(&m, o.Val); err != nil {
+				return maskAny(err)
+			}
+			if j.Options == nil {
+				j.Options = make(map[string]interface{})
+			}
+			for k, v := range m {
+				j.Options[k] = v
+			}
+		}
+	}
+	return nil
+}
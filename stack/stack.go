@@ -0,0 +1,120 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"strings"
+
+	"github.com/juju/errgo"
+)
+
+// Stack is a set of jobs that are deployed together onto a cluster.
+type Stack struct {
+	Name string
+	Jobs []JobRef
+}
+
+// JobRef refers to a job file that is part of a stack.
+type JobRef struct {
+	Name      string                 `mapstructure:"-"`
+	Path      string                 `mapstructure:"path,omitempty"` // Path of the job file, relative to the stack file
+	DependsOn []string               `mapstructure:"-"`              // Names of jobs in the stack that must be deployed first
+	Options   map[string]interface{} `mapstructure:"-"`              // Options used when parsing the job file
+}
+
+// Job returns the job with given name.
+func (s Stack) Job(name string) (JobRef, bool) {
+	for _, j := range s.Jobs {
+		if j.Name == name {
+			return j, true
+		}
+	}
+	return JobRef{}, false
+}
+
+// validate checks the stack for errors.
+func (s Stack) validate() error {
+	names := make(map[string]struct{})
+	for _, j := range s.Jobs {
+		if j.Name == "" {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "job in stack '%s' has no name", s.Name))
+		}
+		if _, found := names[j.Name]; found {
+			return maskAny(errgo.WithCausef(nil, ValidationError, "stack '%s' has duplicate job '%s'", s.Name, j.Name))
+		}
+		names[j.Name] = struct{}{}
+	}
+	for _, j := range s.Jobs {
+		for _, dep := range j.DependsOn {
+			if dep == j.Name {
+				return maskAny(errgo.WithCausef(nil, ValidationError, "job '%s' depends on itself", j.Name))
+			}
+			if _, found := names[dep]; !found {
+				return maskAny(errgo.WithCausef(nil, ValidationError, "job '%s' depends on unknown job '%s'", j.Name, dep))
+			}
+		}
+	}
+	return nil
+}
+
+// Order returns all jobs of the stack, ordered such that every job comes after the jobs it depends on.
+// Besides `depends-on`, the given dependencies (job name to names of jobs it depends on) are used.
+// Dependencies on jobs that are not part of the stack are ignored.
+// Jobs that do not depend on each other keep the order of the stack file.
+func (s Stack) Order(inferred map[string][]string) ([]JobRef, error) {
+	deps := make(map[string]map[string]struct{})
+	for _, j := range s.Jobs {
+		deps[j.Name] = make(map[string]struct{})
+		for _, dep := range append(append([]string{}, j.DependsOn...), inferred[j.Name]...) {
+			if _, found := s.Job(dep); found && dep != j.Name {
+				deps[j.Name][dep] = struct{}{}
+			}
+		}
+	}
+
+	var result []JobRef
+	done := make(map[string]bool)
+	for len(result) < len(s.Jobs) {
+		progress := false
+		for _, j := range s.Jobs {
+			if done[j.Name] {
+				continue
+			}
+			ready := true
+			for dep := range deps[j.Name] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				result = append(result, j)
+				done[j.Name] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			var remaining []string
+			for _, j := range s.Jobs {
+				if !done[j.Name] {
+					remaining = append(remaining, j.Name)
+				}
+			}
+			return nil, maskAny(errgo.WithCausef(nil, CycleError, "cannot order jobs %s, their dependencies form a cycle", strings.Join(remaining, ", ")))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/j2/stack"
+)
+
+func TestStackOrder(t *testing.T) {
+	tests := []struct {
+		Jobs          []stack.JobRef
+		Inferred      map[string][]string
+		CycleExpected bool
+		Expected      []string
+	}{
+		{
+			// No dependencies keeps the order of the stack file
+			Jobs:     []stack.JobRef{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			Expected: []string{"a", "b", "c"},
+		},
+		{
+			Jobs:     []stack.JobRef{{Name: "a", DependsOn: []string{"c"}}, {Name: "b"}, {Name: "c", DependsOn: []string{"b"}}},
+			Expected: []string{"b", "c", "a"},
+		},
+		{
+			// Inferred dependencies are used as well
+			Jobs:     []stack.JobRef{{Name: "a"}, {Name: "b"}},
+			Inferred: map[string][]string{"a": {"b"}},
+			Expected: []string{"b", "a"},
+		},
+		{
+			// Dependencies on unknown jobs and on the job itself are ignored
+			Jobs:     []stack.JobRef{{Name: "a", DependsOn: []string{"x"}}, {Name: "b"}},
+			Inferred: map[string][]string{"b": {"b", "y"}},
+			Expected: []string{"a", "b"},
+		},
+		{
+			Jobs:          []stack.JobRef{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+			CycleExpected: true,
+		},
+		{
+			// A cycle through an inferred dependency
+			Jobs:          []stack.JobRef{{Name: "a", DependsOn: []string{"c"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c"}},
+			Inferred:      map[string][]string{"c": {"b"}},
+			CycleExpected: true,
+		},
+		{
+			// Jobs outside a cycle do not hide it
+			Jobs:          []stack.JobRef{{Name: "a"}, {Name: "b", DependsOn: []string{"c"}}, {Name: "c", DependsOn: []string{"b"}}},
+			CycleExpected: true,
+		},
+	}
+	for i, test := range tests {
+		s := stack.Stack{Name: "test", Jobs: test.Jobs}
+		ordered, err := s.Order(test.Inferred)
+		if test.CycleExpected {
+			if errgo.Cause(err) != stack.CycleError {
+				t.Errorf("Expected cycle error in test %d, got %v", i, err)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Unexpected error in test %d: %#v", i, err)
+			} else {
+				var names []string
+				for _, j := range ordered {
+					names = append(names, j.Name)
+				}
+				if !reflect.DeepEqual(test.Expected, names) {
+					t.Errorf("Unexpected result in test %d. Expected %v, got %v", i, test.Expected, names)
+				}
+			}
+		}
+	}
+}
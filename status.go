@@ -0,0 +1,131 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+	"github.com/pulcy/j2/jobs"
+)
+
+var (
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of a job on a stack.",
+		Long:  "Show the state of all units of a job on a stack and whether they differ from their rendered version.",
+		Run:   statusRun,
+	}
+	statusFlags struct {
+		fg.Flags
+		output string
+	}
+)
+
+func init() {
+	initDeploymentFlags(statusCmd.Flags(), &statusFlags.Flags)
+	statusCmd.Flags().StringVar(&statusFlags.output, "output", defaultOutputFormat, "Output format (text|json)")
+}
+
+func statusRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &statusFlags.Flags, args)
+	if statusFlags.output != "text" && statusFlags.output != "json" {
+		Exitf("--output invalid: must be text or json\n")
+	}
+
+	cluster, err := loadCluster(&statusFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&statusFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&statusFlags.Flags),
+		deployment.ScalingGroupSelection(statusFlags.ScalingGroup),
+		statusFlags.Force,
+		statusFlags.AutoContinue,
+		globalFlags.verbose,
+		deployment.DeploymentDelays{},
+		deployment.HealthCheckConfig{},
+		deployment.RolloutOptions{},
+		renderCtx)
+	assert(err)
+
+	units, err := d.Status(rootCtx)
+	if err != nil {
+		Exitf("Cannot get status: %v\n", err)
+	}
+
+	if statusFlags.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		assert(encoder.Encode(units))
+		return
+	}
+
+	printStatus(job.Name, cluster.Stack, units)
+}
+
+// printStatus prints the state of the given units of a job, grouped by task group & scaling group.
+func printStatus(jobName jobs.JobName, stack string, units []deployment.UnitStatus) {
+	if len(units) == 0 {
+		fmt.Printf("No units of job '%s' found on '%s'\n", jobName, stack)
+		return
+	}
+	var lines []string
+	flush := func() {
+		if len(lines) > 1 {
+			fmt.Println(columnize.SimpleFormat(lines))
+			fmt.Println()
+		}
+	}
+	header := ""
+	for _, u := range units {
+		h := fmt.Sprintf("Task group '%s', scaling group %d", u.TaskGroup, u.ScalingGroup)
+		if h != header {
+			flush()
+			header = h
+			fmt.Println(header)
+			lines = []string{"Unit | State | Machine | Changed | Message"}
+		}
+		changed := "no"
+		if u.Obsolete {
+			changed = "obsolete"
+		} else if u.Inactive {
+			changed = "inactive"
+		} else if u.Changed {
+			changed = "yes"
+			if len(u.Diffs) > 0 {
+				changed = fmt.Sprintf("yes (%s)", strings.Join(u.Diffs, ","))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s | %s | %s | %s | %s", u.Name, u.State, u.Machine, changed, u.Message))
+	}
+	flush()
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/j2/deployment"
+	fg "github.com/pulcy/j2/flags"
+)
+
+var (
+	switchCmd = &cobra.Command{
+		Use:   "switch",
+		Short: "Switch the frontends of blue/green groups back to their other color.",
+		Long:  "Point the frontends of all blue/green groups of a job back to the color that does not serve them now, as long as that color has not been retired.",
+		Run:   switchRun,
+	}
+	switchFlags struct {
+		fg.Flags
+	}
+)
+
+func init() {
+	initDeploymentFlags(switchCmd.Flags(), &switchFlags.Flags)
+	initOutputFlags(switchCmd.Flags(), &switchFlags.Flags)
+}
+
+func switchRun(cmd *cobra.Command, args []string) {
+	deploymentDefaults(cmd.Flags(), &switchFlags.Flags, args)
+	runValidators(&switchFlags.Flags)
+
+	cluster, err := loadCluster(&switchFlags.Flags)
+	if err != nil {
+		Exitf("Cannot load cluster: %v\n", err)
+	}
+	orchestrator, err := getOrchestrator(cluster)
+	if err != nil {
+		Exitf("Cannot initialize orchestrator: %v\n", err)
+	}
+	job, err := loadJob(&switchFlags.Flags, *cluster, orchestrator)
+	if err != nil {
+		Exitf("Cannot load job: %v\n", err)
+	}
+
+	delays := deployment.DeploymentDelays{
+		StopDelay:    switchFlags.StopDelay,
+		DestroyDelay: switchFlags.DestroyDelay,
+		SliceDelay:   switchFlags.SliceDelay,
+	}
+	d, err := deployment.NewDeployment(orchestrator, *job, *cluster,
+		groups(&switchFlags.Flags),
+		deployment.ScalingGroupSelection(switchFlags.ScalingGroup),
+		switchFlags.Force,
+		switchFlags.AutoContinue,
+		globalFlags.verbose,
+		delays,
+		healthCheckConfig(&switchFlags.Flags),
+		deployment.RolloutOptions{StealLock: switchFlags.StealLock},
+		renderCtx)
+	assert(err)
+	d.Events = eventSink(&switchFlags.Flags)
+
+	if err := d.Switch(rootCtx); err != nil {
+		Exitf("Cannot switch frontends: %v\n", err)
+	}
+}